@@ -0,0 +1,99 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package audit provides structured audit logging of security relevant events.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventTypeLogon            = "logon"
+	EventTypeTokenIssued      = "token_issued"
+	EventTypeSessionDestroyed = "session_destroyed"
+)
+
+// Outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is an audit record. The JSON field names are part of the audit log
+// format and must remain stable. Events must never carry secrets like
+// passwords or tokens.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"event"`
+	Outcome    string    `json:"outcome"`
+	Subject    string    `json:"sub,omitempty"`
+	Username   string    `json:"username,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	GrantType  string    `json:"grant_type,omitempty"`
+	Scopes     []string  `json:"scopes,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// Logger defines the interface for audit loggers.
+type Logger interface {
+	Log(event *Event)
+}
+
+// JSONLogger implements a Logger which writes each event as a single line of
+// JSON to an io.Writer.
+type JSONLogger struct {
+	mutex sync.Mutex
+
+	w       io.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONLogger creates a new JSONLogger writing to the provided io.Writer.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Log implements the Logger interface.
+func (l *JSONLogger) Log(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Errors are ignored, auditing must never break request handling.
+	_ = l.encoder.Encode(event)
+}
+
+type nopLogger struct{}
+
+func (l *nopLogger) Log(event *Event) {
+}
+
+// NopLogger is a Logger which discards all events.
+var NopLogger Logger = &nopLogger{}
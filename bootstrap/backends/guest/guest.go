@@ -44,6 +44,8 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	logger := config.Config.Logger
 
 	identityManagerConfig := &identity.Config{
+		ClientScopedGuestSubjects: config.Config.ClientScopedGuestSubjects,
+
 		Logger: logger,
 	}
 
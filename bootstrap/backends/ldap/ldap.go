@@ -21,8 +21,13 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
 
 	"github.com/libregraph/lico/bootstrap"
 	"github.com/libregraph/lico/identifier"
@@ -83,6 +88,32 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	if numericUIDAttribute := os.Getenv("LDAP_UIDNUMBER_ATTRIBUTE"); numericUIDAttribute != "" {
 		attributeMapping[ldap.AttributeNumericUID] = numericUIDAttribute
 	}
+	if preferredUsernameAttribute := os.Getenv("LDAP_PREFERRED_USERNAME_ATTRIBUTE"); preferredUsernameAttribute != "" {
+		attributeMapping[ldap.AttributePreferredUsername] = preferredUsernameAttribute
+	}
+	if phoneNumberAttribute := os.Getenv("LDAP_PHONE_ATTRIBUTE"); phoneNumberAttribute != "" {
+		attributeMapping[ldap.AttributePhoneNumber] = phoneNumberAttribute
+	}
+	if localeAttribute := os.Getenv("LDAP_LOCALE_ATTRIBUTE"); localeAttribute != "" {
+		attributeMapping[ldap.AttributeLocale] = localeAttribute
+	}
+	if zoneinfoAttribute := os.Getenv("LDAP_ZONEINFO_ATTRIBUTE"); zoneinfoAttribute != "" {
+		attributeMapping[ldap.AttributeZoneinfo] = zoneinfoAttribute
+	}
+	if groupsAttribute := os.Getenv("LDAP_GROUPS_ATTRIBUTE"); groupsAttribute != "" {
+		attributeMapping[ldap.AttributeGroups] = groupsAttribute
+	}
+	for n, envName := range map[string]string{
+		ldap.AttributeStreetAddress: "LDAP_STREET_ADDRESS_ATTRIBUTE",
+		ldap.AttributeLocality:      "LDAP_LOCALITY_ATTRIBUTE",
+		ldap.AttributeRegion:        "LDAP_REGION_ATTRIBUTE",
+		ldap.AttributePostalCode:    "LDAP_POSTAL_CODE_ATTRIBUTE",
+		ldap.AttributeCountry:       "LDAP_COUNTRY_ATTRIBUTE",
+	} {
+		if addressAttribute := os.Getenv(envName); addressAttribute != "" {
+			attributeMapping[n] = addressAttribute
+		}
+	}
 	// Sub from LDAP attribute mappings.
 	var subMapping []string
 	if subMappingString := os.Getenv("LDAP_SUB_ATTRIBUTES"); subMappingString != "" {
@@ -104,9 +135,40 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 		}
 	}
 
+	startTLS, _ := strconv.ParseBool(os.Getenv("LDAP_STARTTLS"))
+
+	var roleMappings []*ldap.RoleMapping
+	if rolesFile := os.Getenv("LDAP_ROLES_FILE"); rolesFile != "" {
+		rolesData, err := ioutil.ReadFile(rolesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read roles file '%s': %w", rolesFile, err)
+		}
+		if err = yaml.Unmarshal(rolesData, &roleMappings); err != nil {
+			return nil, fmt.Errorf("failed to parse roles file '%s': %w", rolesFile, err)
+		}
+	}
+
+	poolSize := 10
+	if poolSizeString := os.Getenv("LDAP_POOL_SIZE"); poolSizeString != "" {
+		if v, err := strconv.Atoi(poolSizeString); err == nil && v >= 0 {
+			poolSize = v
+		} else {
+			return nil, fmt.Errorf("invalid LDAP_POOL_SIZE value: %v", poolSizeString)
+		}
+	}
+	poolIdleTimeout := 60 * time.Second
+	if poolIdleTimeoutString := os.Getenv("LDAP_POOL_IDLE_TIMEOUT"); poolIdleTimeoutString != "" {
+		if v, err := strconv.ParseUint(poolIdleTimeoutString, 10, 64); err == nil {
+			poolIdleTimeout = time.Duration(v) * time.Second
+		} else {
+			return nil, fmt.Errorf("invalid LDAP_POOL_IDLE_TIMEOUT value: %v", poolIdleTimeoutString)
+		}
+	}
+
 	identifierBackend, identifierErr := ldap.NewLDAPIdentifierBackend(
 		config.Config,
 		tlsConfig,
+		startTLS,
 		os.Getenv("LDAP_URI"),
 		os.Getenv("LDAP_BINDDN"),
 		os.Getenv("LDAP_BINDPW"),
@@ -115,6 +177,10 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 		os.Getenv("LDAP_FILTER"),
 		subMapping,
 		attributeMapping,
+		poolSize,
+		poolIdleTimeout,
+		os.Getenv("LDAP_ROLES_CLAIM"),
+		roleMappings,
 	)
 	if identifierErr != nil {
 		return nil, fmt.Errorf("failed to create identifier backend: %v", identifierErr)
@@ -123,6 +189,10 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	fullAuthorizationEndpointURL := bootstrap.WithSchemeAndHost(config.AuthorizationEndpointURI, config.IssuerIdentifierURI)
 	fullSignInFormURL := bootstrap.WithSchemeAndHost(config.SignInFormURI, config.IssuerIdentifierURI)
 	fullSignedOutEndpointURL := bootstrap.WithSchemeAndHost(config.SignedOutURI, config.IssuerIdentifierURI)
+	var fullSignUpFormURL *url.URL
+	if config.SignUpFormURI != nil {
+		fullSignUpFormURL = bootstrap.WithSchemeAndHost(config.SignUpFormURI, config.IssuerIdentifierURI)
+	}
 
 	activeIdentifier, err := identifier.NewIdentifier(&identifier.Config{
 		Config: config.Config,
@@ -142,6 +212,10 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 		DefaultUsernameHintText: config.IdentifierDefaultUsernameHintText,
 		UILocales:               config.IdentifierUILocales,
 
+		PersistentSessionDuration: time.Duration(config.PersistentSessionDurationSeconds) * time.Second,
+
+		AdminSecret: config.AdminSecret,
+
 		Backend: identifierBackend,
 	})
 	if err != nil {
@@ -151,14 +225,26 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid --encryption-secret parameter value for identifier: %v", err)
 	}
+	for kid, key := range config.EncryptionKeys {
+		if err = activeIdentifier.AddKeyWithID(kid, key); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for identifier: %v", err)
+		}
+	}
+	if config.EncryptionKeyID != "" {
+		if err = activeIdentifier.SetKeyID(config.EncryptionKeyID); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for identifier: %v", err)
+		}
+	}
 
 	identityManagerConfig := &identity.Config{
 		SignInFormURI: fullSignInFormURL,
+		SignUpFormURI: fullSignUpFormURL,
 		SignedOutURI:  fullSignedOutEndpointURL,
 
 		Logger: logger,
 
 		ScopesSupported: config.Config.AllowedScopes,
+		RememberConsent: config.Config.RememberConsent,
 	}
 
 	identifierIdentityManager := managers.NewIdentifierIdentityManager(identityManagerConfig, activeIdentifier)
@@ -19,8 +19,10 @@ package bslibregraph
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/cevaris/ordered_map"
 
@@ -106,6 +108,10 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	fullAuthorizationEndpointURL := bootstrap.WithSchemeAndHost(config.AuthorizationEndpointURI, config.IssuerIdentifierURI)
 	fullSignInFormURL := bootstrap.WithSchemeAndHost(config.SignInFormURI, config.IssuerIdentifierURI)
 	fullSignedOutEndpointURL := bootstrap.WithSchemeAndHost(config.SignedOutURI, config.IssuerIdentifierURI)
+	var fullSignUpFormURL *url.URL
+	if config.SignUpFormURI != nil {
+		fullSignUpFormURL = bootstrap.WithSchemeAndHost(config.SignUpFormURI, config.IssuerIdentifierURI)
+	}
 
 	activeIdentifier, err := identifier.NewIdentifier(&identifier.Config{
 		Config: config.Config,
@@ -125,6 +131,10 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 		DefaultUsernameHintText: config.IdentifierDefaultUsernameHintText,
 		UILocales:               config.IdentifierUILocales,
 
+		PersistentSessionDuration: time.Duration(config.PersistentSessionDurationSeconds) * time.Second,
+
+		AdminSecret: config.AdminSecret,
+
 		Backend: identifierBackend,
 	})
 	if err != nil {
@@ -134,14 +144,26 @@ func NewIdentityManager(bs bootstrap.Bootstrap) (identity.Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid --encryption-secret parameter value for identifier: %v", err)
 	}
+	for kid, key := range config.EncryptionKeys {
+		if err = activeIdentifier.AddKeyWithID(kid, key); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for identifier: %v", err)
+		}
+	}
+	if config.EncryptionKeyID != "" {
+		if err = activeIdentifier.SetKeyID(config.EncryptionKeyID); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for identifier: %v", err)
+		}
+	}
 
 	identityManagerConfig := &identity.Config{
 		SignInFormURI: fullSignInFormURL,
+		SignUpFormURI: fullSignUpFormURL,
 		SignedOutURI:  fullSignedOutEndpointURL,
 
 		Logger: logger,
 
 		ScopesSupported: config.Config.AllowedScopes,
+		RememberConsent: config.Config.RememberConsent,
 	}
 
 	identifierIdentityManager := managers.NewIdentifierIdentityManager(identityManagerConfig, activeIdentifier)
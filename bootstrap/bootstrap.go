@@ -18,26 +18,32 @@
 package bootstrap
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
 	"github.com/longsleep/rndm"
 	"github.com/sirupsen/logrus"
 
 	"github.com/libregraph/lico/config"
 	"github.com/libregraph/lico/encryption"
+	"github.com/libregraph/lico/identifier"
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/managers"
 	oidcProvider "github.com/libregraph/lico/oidc/provider"
@@ -57,6 +63,10 @@ const (
 	DefaultSigningKeyID   = "default"
 	DefaultSigningKeyBits = 2048
 
+	// SigningMethodAuto selects the signing method from the type of the
+	// signing key.
+	SigningMethodAuto = "auto"
+
 	DefaultGuestIdentityManagerName = "guest"
 )
 
@@ -88,13 +98,10 @@ func (bs *bootstrap) Managers() *managers.Managers {
 	return bs.managers
 }
 
-// Boot is the main entry point to bootstrap the service after validating the
-// given configuration. The resulting Bootstrap struct can be used to retrieve
-// configured identity-managers and their respective http-handlers and config.
-//
-// This function should be used by consumers which want to embed this project
-// as a library.
-func Boot(ctx context.Context, settings *Settings, cfg *config.Config) (Bootstrap, error) {
+// ConfigureSigningMethods sets up the registered JWT signing methods the way
+// tokens are signed and validated by the provider. It is called by Boot and
+// must be called by consumers which sign tokens without booting.
+func ConfigureSigningMethods() {
 	// NOTE(longsleep): Ensure to use same salt length as the hash size.
 	// See https://www.ietf.org/mail-archive/web/jose/current/msg02901.html for
 	// reference and https://github.com/golang-jwt/jwt/v4/issues/285 for
@@ -105,6 +112,16 @@ func Boot(ctx context.Context, settings *Settings, cfg *config.Config) (Bootstra
 			signingMethodRSAPSS.Options.SaltLength = rsa.PSSSaltLengthEqualsHash
 		}
 	}
+}
+
+// Boot is the main entry point to bootstrap the service after validating the
+// given configuration. The resulting Bootstrap struct can be used to retrieve
+// configured identity-managers and their respective http-handlers and config.
+//
+// This function should be used by consumers which want to embed this project
+// as a library.
+func Boot(ctx context.Context, settings *Settings, cfg *config.Config) (Bootstrap, error) {
+	ConfigureSigningMethods()
 
 	bs := &bootstrap{
 		config: &Config{
@@ -141,10 +158,10 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		return fmt.Errorf("invalid iss value, iss is not a valid URL), %v", err)
 	} else if settings.Iss == "" {
 		return fmt.Errorf("missing iss value, did you provide the --iss parameter?")
+	} else if err = validateIssuerIdentifier(bs.config.IssuerIdentifierURI, settings.Insecure); err != nil {
+		return fmt.Errorf("invalid iss value, %v", err)
 	} else if bs.config.IssuerIdentifierURI.Scheme != "https" {
-		return fmt.Errorf("invalid iss value, URL must start with https://")
-	} else if bs.config.IssuerIdentifierURI.Host == "" {
-		return fmt.Errorf("invalid iss value, URL must have a host")
+		logger.Warnln("insecure mode, iss is not using https, this is not suitable for production")
 	}
 
 	bs.uriBasePath = settings.URIBasePath
@@ -154,6 +171,13 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		return fmt.Errorf("invalid sign-in URI, %v", err)
 	}
 
+	if settings.SignUpURI != "" {
+		bs.config.SignUpFormURI, err = url.Parse(settings.SignUpURI)
+		if err != nil {
+			return fmt.Errorf("invalid sign-up URI, %v", err)
+		}
+	}
+
 	bs.config.SignedOutURI, err = url.Parse(settings.SignedOutURI)
 	if err != nil {
 		return fmt.Errorf("invalid signed-out URI, %v", err)
@@ -176,6 +200,36 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 	} else {
 		bs.config.TLSClientConfig = utils.DefaultTLSConfig()
 	}
+	bs.config.TLSClientConfig.MinVersion, err = utils.TLSVersionFromString(settings.TLSClientMinVersion)
+	if err != nil {
+		return fmt.Errorf("invalid tls-client-min-version, %v", err)
+	}
+	bs.config.TLSClientConfig.CipherSuites, err = utils.TLSCipherSuitesFromStrings(settings.TLSClientCipherSuites)
+	if err != nil {
+		return fmt.Errorf("invalid tls-client-cipher-suite, %v", err)
+	}
+	if settings.TLSClientCAFile != "" {
+		bs.config.TLSClientConfig.RootCAs, err = utils.CertPoolWithPEMFile(settings.TLSClientCAFile)
+		if err != nil {
+			return fmt.Errorf("invalid tls-client-ca-file, %v", err)
+		}
+		logger.Infof("using CA bundle %v for TLS client connections", settings.TLSClientCAFile)
+	}
+	if settings.HTTPProxy != "" {
+		bs.config.HTTPProxyURL, err = url.Parse(settings.HTTPProxy)
+		if err != nil {
+			return fmt.Errorf("invalid http-proxy, %v", err)
+		}
+		switch bs.config.HTTPProxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid http-proxy, unsupported scheme: %v", bs.config.HTTPProxyURL.Scheme)
+		}
+		if bs.config.HTTPProxyURL.Host == "" {
+			return fmt.Errorf("invalid http-proxy, host is missing")
+		}
+		logger.Infof("using proxy %v for outbound HTTP requests", bs.config.HTTPProxyURL.Redacted())
+	}
 
 	for _, trustedProxy := range settings.TrustedProxy {
 		if ip := net.ParseIP(trustedProxy); ip != nil {
@@ -197,18 +251,211 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 	if len(settings.AllowScope) > 0 {
 		bs.config.Config.AllowedScopes = settings.AllowScope
 		logger.Infoln("using custom allowed OAuth 2 scopes", bs.config.Config.AllowedScopes)
+		offlineAccess := false
+		for _, scope := range bs.config.Config.AllowedScopes {
+			if scope == oidc.ScopeOfflineAccess {
+				offlineAccess = true
+				break
+			}
+		}
+		if !offlineAccess {
+			logger.Infoln("offline_access scope is not allowed, refresh tokens will not be issued")
+		}
+	}
+
+	if len(settings.DefaultScope) > 0 {
+		if len(bs.config.Config.AllowedScopes) > 0 {
+			allowedScopes := make(map[string]bool)
+			for _, scope := range bs.config.Config.AllowedScopes {
+				allowedScopes[scope] = true
+			}
+			for _, scope := range settings.DefaultScope {
+				if !allowedScopes[scope] {
+					return fmt.Errorf("default scope %s is not an allowed scope", scope)
+				}
+			}
+		}
+		bs.config.Config.DefaultScopes = settings.DefaultScope
+		logger.Infoln("using default OAuth 2 scopes", bs.config.Config.DefaultScopes)
+	}
+
+	for _, origin := range settings.AllowedOrigins {
+		if origin != "*" {
+			originURL, errParse := url.Parse(strings.Replace(origin, "*.", "", 1))
+			if errParse != nil || originURL.Scheme == "" || originURL.Host == "" || (originURL.Path != "" && originURL.Path != "/") {
+				return fmt.Errorf("invalid allowed origin: %s", origin)
+			}
+		}
+		bs.config.Config.AllowedOrigins = append(bs.config.Config.AllowedOrigins, strings.TrimSuffix(origin, "/"))
+	}
+	if len(bs.config.Config.AllowedOrigins) > 0 {
+		logger.Infoln("using custom allowed CORS origins", bs.config.Config.AllowedOrigins)
+	}
+	bs.config.Config.AllowClientOrigins = settings.AllowClientOrigins
+	if bs.config.Config.AllowClientOrigins {
+		logger.Infoln("token and userinfo CORS origins are derived from registered clients")
+	}
+
+	for _, alg := range settings.AllowedClientSigningAlgs {
+		if jwt.GetSigningMethod(alg) == nil {
+			return fmt.Errorf("unknown allowed client signing alg: %s", alg)
+		}
+		bs.config.Config.AllowedClientSigningAlgs = append(bs.config.Config.AllowedClientSigningAlgs, alg)
+	}
+	if len(bs.config.Config.AllowedClientSigningAlgs) > 0 {
+		logger.Infoln("using custom allowed client signing algs", bs.config.Config.AllowedClientSigningAlgs)
+	}
+
+	if settings.LogonLockoutAttempts < 0 {
+		return fmt.Errorf("invalid logon-lockout-attempts value: %d", settings.LogonLockoutAttempts)
+	}
+	for _, pattern := range settings.LogonLockoutExempt {
+		if _, errMatch := path.Match(pattern, ""); errMatch != nil {
+			return fmt.Errorf("invalid logon-lockout-exempt pattern %s: %v", pattern, errMatch)
+		}
+	}
+	bs.config.Config.LogonLockoutAttempts = settings.LogonLockoutAttempts
+	bs.config.Config.LogonLockoutDuration = time.Duration(settings.LogonLockoutDurationSeconds) * time.Second
+	bs.config.Config.LogonLockoutExempt = settings.LogonLockoutExempt
+	if bs.config.Config.LogonLockoutAttempts > 0 {
+		logger.WithFields(logrus.Fields{
+			"attempts": bs.config.Config.LogonLockoutAttempts,
+			"duration": bs.config.Config.LogonLockoutDuration,
+			"exempt":   bs.config.Config.LogonLockoutExempt,
+		}).Infoln("logon lockout is enabled")
+	}
+
+	if strings.ContainsAny(settings.SubjectPrefix, " \t\r\n") {
+		return fmt.Errorf("invalid subject-prefix value: %q", settings.SubjectPrefix)
+	}
+	bs.config.Config.SubjectPrefix = settings.SubjectPrefix
+	if bs.config.Config.SubjectPrefix != "" {
+		logger.WithField("prefix", bs.config.Config.SubjectPrefix).Infoln("using subject prefix for identifier users")
+	}
+
+	if settings.MaxConcurrentSessions < 0 {
+		return fmt.Errorf("invalid max-concurrent-sessions value: %d", settings.MaxConcurrentSessions)
+	}
+	switch settings.SessionLimitPolicy {
+	case "":
+		settings.SessionLimitPolicy = identifier.SessionLimitPolicyReject
+	case identifier.SessionLimitPolicyReject, identifier.SessionLimitPolicyEvictOldest:
+	default:
+		return fmt.Errorf("invalid session-limit-policy value: %s", settings.SessionLimitPolicy)
+	}
+	if settings.MaxConcurrentSessions > 0 && settings.SessionIdleTimeoutSeconds == 0 {
+		return fmt.Errorf("max-concurrent-sessions requires session-idle-timeout")
+	}
+	bs.config.Config.MaxConcurrentSessions = settings.MaxConcurrentSessions
+	bs.config.Config.SessionLimitPolicy = settings.SessionLimitPolicy
+	if bs.config.Config.MaxConcurrentSessions > 0 {
+		logger.WithFields(logrus.Fields{
+			"max":    bs.config.Config.MaxConcurrentSessions,
+			"policy": bs.config.Config.SessionLimitPolicy,
+		}).Infoln("concurrent session limit is enabled")
+	}
+	bs.config.Config.SessionIdleTimeout = time.Duration(settings.SessionIdleTimeoutSeconds) * time.Second
+	if bs.config.Config.SessionIdleTimeout > 0 {
+		logger.WithField("timeout", bs.config.Config.SessionIdleTimeout).Infoln("session idle timeout is enabled")
+	}
+
+	switch settings.ClaimLimitPolicy {
+	case "":
+		settings.ClaimLimitPolicy = oidcProvider.ClaimLimitPolicyTruncate
+	case oidcProvider.ClaimLimitPolicyTruncate, oidcProvider.ClaimLimitPolicyReject:
+	default:
+		return fmt.Errorf("invalid claim-limit-policy value: %s", settings.ClaimLimitPolicy)
+	}
+	if settings.MaxClaimValues < 0 {
+		return fmt.Errorf("invalid max-claim-values value: %d", settings.MaxClaimValues)
+	}
+	bs.config.Config.MaxClaimValues = settings.MaxClaimValues
+	bs.config.Config.ClaimLimitPolicy = settings.ClaimLimitPolicy
+	if bs.config.Config.MaxClaimValues > 0 {
+		logger.WithFields(logrus.Fields{
+			"max":    bs.config.Config.MaxClaimValues,
+			"policy": bs.config.Config.ClaimLimitPolicy,
+		}).Infoln("claim value limit is enabled")
 	}
 
 	bs.config.Config.AllowClientGuests = settings.AllowClientGuests
 	if bs.config.Config.AllowClientGuests {
 		logger.Infoln("client controlled guests are enabled")
 	}
+	bs.config.Config.ClientScopedGuestSubjects = settings.ClientScopedGuestSubjects
+	if bs.config.Config.AllowClientGuests && bs.config.Config.ClientScopedGuestSubjects {
+		logger.Infoln("guest subjects are scoped to the requesting client")
+	}
 
 	bs.config.Config.AllowDynamicClientRegistration = settings.AllowDynamicClientRegistration
 	if bs.config.Config.AllowDynamicClientRegistration {
 		logger.Infoln("dynamic client registration is enabled")
 	}
 
+	if settings.MaxPostLogoutRedirectURIs < 0 {
+		return fmt.Errorf("invalid max-post-logout-redirect-uris value: %d", settings.MaxPostLogoutRedirectURIs)
+	}
+	bs.config.Config.MaxPostLogoutRedirectURIs = settings.MaxPostLogoutRedirectURIs
+	bs.config.Config.AllowNativeImplicit = settings.AllowNativeImplicit
+	if bs.config.Config.AllowNativeImplicit {
+		logger.Warnln("native clients are allowed to register token response types")
+	}
+
+	bs.config.Config.MinimalIDTokenClaims = settings.MinimalIDTokenClaims
+	if bs.config.Config.MinimalIDTokenClaims {
+		logger.Infoln("minimal id token claims are enabled")
+	}
+
+	bs.config.Config.AllowMultipleAudiences = settings.AllowMultipleAudiences
+	if bs.config.Config.AllowMultipleAudiences {
+		logger.Infoln("access tokens with multiple audiences are enabled")
+	}
+	bs.config.Config.AdditionalAudiences = settings.AdditionalAudiences
+	if len(bs.config.Config.AdditionalAudiences) > 0 {
+		logger.WithField("audiences", bs.config.Config.AdditionalAudiences).Infoln("additional access token audiences are enabled")
+	}
+	bs.config.Config.RequirePKCEForPublicClients = settings.RequirePKCEForPublicClients
+	if bs.config.Config.RequirePKCEForPublicClients {
+		logger.Infoln("pkce is required for public clients")
+	}
+	bs.config.Config.NativeClientHardening = settings.NativeClientHardening
+	if bs.config.Config.NativeClientHardening {
+		logger.Infoln("native client hardening is enabled")
+	}
+	if len(settings.ACRMaxAges) > 0 {
+		bs.config.Config.ACRMaxAges = make(map[string]time.Duration)
+		for _, acrMaxAge := range settings.ACRMaxAges {
+			idx := strings.LastIndex(acrMaxAge, "=")
+			if idx < 1 {
+				return fmt.Errorf("invalid acr-max-age value: %s", acrMaxAge)
+			}
+			seconds, err := strconv.ParseUint(acrMaxAge[idx+1:], 10, 64)
+			if err != nil || seconds == 0 {
+				return fmt.Errorf("invalid acr-max-age value: %s", acrMaxAge)
+			}
+			bs.config.Config.ACRMaxAges[acrMaxAge[:idx]] = time.Duration(seconds) * time.Second
+		}
+		logger.WithField("acr_max_ages", bs.config.Config.ACRMaxAges).Infoln("acr step-up authentication is enabled")
+	}
+
+	bs.config.Config.RememberConsent = settings.RememberConsent
+	if bs.config.Config.RememberConsent {
+		logger.Infoln("remembered consent is enabled")
+	}
+
+	bs.config.Config.CookieSameSite, err = parseCookieSameSite(settings.CookieSameSite)
+	if err != nil {
+		return err
+	}
+	bs.config.Config.CookieDomain = settings.CookieDomain
+	if settings.RequestBodySizeLimit < 0 {
+		return fmt.Errorf("invalid request-body-size-limit value: %d", settings.RequestBodySizeLimit)
+	}
+	bs.config.Config.RequestBodySizeLimit = settings.RequestBodySizeLimit
+	if bs.config.Config.CookieDomain != "" {
+		logger.Infoln("using cookie domain", bs.config.Config.CookieDomain)
+	}
+
 	encryptionSecretFn := settings.EncryptionSecretFile
 
 	if encryptionSecretFn != "" {
@@ -225,7 +472,53 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		bs.config.EncryptionSecret = rndm.GenerateRandomBytes(encryption.KeySize)
 	}
 
+	bs.config.EncryptionKeys = make(map[string][]byte)
+	for _, fn := range settings.EncryptionKeyringFiles {
+		_, name := filepath.Split(fn)
+		kid := getKeyIDFromFilename(name)
+		if _, ok := bs.config.EncryptionKeys[kid]; ok {
+			return fmt.Errorf("duplicate encryption keyring key id: %s", kid)
+		}
+		logger.WithFields(logrus.Fields{
+			"file": fn,
+			"kid":  kid,
+		}).Infoln("loading encryption keyring key from file")
+		key, errRead := ioutil.ReadFile(fn)
+		if errRead != nil {
+			return fmt.Errorf("failed to load encryption keyring key from file: %v", errRead)
+		}
+		if len(key) != encryption.KeySize {
+			return fmt.Errorf("invalid encryption keyring key size - must be %d bytes", encryption.KeySize)
+		}
+		bs.config.EncryptionKeys[kid] = key
+		if bs.config.EncryptionKeyID == "" {
+			// First key is the primary.
+			bs.config.EncryptionKeyID = kid
+		}
+	}
+	if bs.config.EncryptionKeyID != "" {
+		logger.WithField("kid", bs.config.EncryptionKeyID).Infoln("using encryption keyring key as primary")
+	}
+
+	if settings.AdminSecretFile != "" {
+		logger.WithField("file", settings.AdminSecretFile).Infoln("loading admin secret from file, admin API is enabled")
+		adminSecret, errRead := ioutil.ReadFile(settings.AdminSecretFile)
+		if errRead != nil {
+			return fmt.Errorf("failed to load admin secret from file: %v", errRead)
+		}
+		bs.config.AdminSecret = bytes.TrimSpace(adminSecret)
+		if len(bs.config.AdminSecret) < 32 {
+			return fmt.Errorf("invalid admin secret size - must be at least 32 bytes")
+		}
+	}
+
 	bs.config.Config.ListenAddr = settings.Listen
+	bs.config.Config.EnableH2C = settings.EnableH2C
+
+	bs.config.Config.HSTSMaxAge = time.Duration(settings.HSTSMaxAgeSeconds) * time.Second
+	bs.config.Config.FrameOptions = settings.FrameOptions
+	bs.config.Config.ContentSecurityPolicy = settings.ContentSecurityPolicy
+	bs.config.Config.ReferrerPolicy = settings.ReferrerPolicy
 
 	bs.config.IdentifierClientDisabled = settings.IdentifierClientDisabled
 	bs.config.IdentifierClientPath = settings.IdentifierClientPath
@@ -238,6 +531,8 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		}
 		bs.config.IdentifierAuthoritiesConf = bs.config.IdentifierRegistrationConf
 	}
+	bs.config.AuthoritiesFetchMaxSize = settings.AuthoritiesFetchMaxSize
+	bs.config.AuthoritiesFetchTimeout = time.Duration(settings.AuthoritiesFetchTimeoutSeconds) * time.Second
 
 	bs.config.IdentifierScopesConf = settings.IdentifierScopesConf
 	if bs.config.IdentifierScopesConf != "" {
@@ -263,30 +558,69 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 	}
 	bs.config.IdentifierUILocales = settings.IdentifierUILocales
 
+	if settings.ErrorPageTemplate != "" {
+		logger.WithField("file", settings.ErrorPageTemplate).Infoln("loading error page template")
+		bs.config.ErrorPageTemplate, err = template.ParseFiles(settings.ErrorPageTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to load error page template: %v", err)
+		}
+	}
+	if settings.StaticClaimsFile != "" {
+		logger.WithField("file", settings.StaticClaimsFile).Infoln("loading static token claims")
+		bs.config.StaticClaims, err = oidcProvider.LoadStaticClaimsFromFile(settings.StaticClaimsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load static claims: %v", err)
+		}
+	}
+	if settings.ClaimTransformsFile != "" {
+		logger.WithField("file", settings.ClaimTransformsFile).Infoln("loading claim transforms")
+		bs.config.ClaimTransforms, err = oidcProvider.LoadClaimTransformsFromFile(settings.ClaimTransformsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load claim transforms: %v", err)
+		}
+	}
+	if settings.ErrorDocumentationURI != "" {
+		bs.config.ErrorDocumentationURI, err = url.Parse(settings.ErrorDocumentationURI)
+		if err != nil {
+			return fmt.Errorf("invalid error-documentation-uri, %v", err)
+		}
+		if !bs.config.ErrorDocumentationURI.IsAbs() {
+			return fmt.Errorf("invalid error-documentation-uri, URL must be absolute")
+		}
+	}
+
 	bs.config.SigningKeyID = settings.SigningKid
 	bs.config.Signers = make(map[string]crypto.Signer)
 	bs.config.Validators = make(map[string]crypto.PublicKey)
 	bs.config.Certificates = make(map[string][]*x509.Certificate)
 
 	signingMethodString := settings.SigningMethod
-	bs.config.SigningMethod = jwt.GetSigningMethod(signingMethodString)
-	if bs.config.SigningMethod == nil {
-		return fmt.Errorf("unknown signing method: %s", signingMethodString)
+	if signingMethodString != SigningMethodAuto {
+		bs.config.SigningMethod = jwt.GetSigningMethod(signingMethodString)
+		if bs.config.SigningMethod == nil {
+			return fmt.Errorf("unknown signing method: %s", signingMethodString)
+		}
 	}
 
+	// The kid of the signer which is used by default, this is the provided id
+	// or the kid of the first loaded signing key.
+	signingKeyID := bs.config.SigningKeyID
 	signingKeyFns := settings.SigningPrivateKeyFiles
 	if len(signingKeyFns) > 0 {
 		first := true
 		for _, signingKeyFn := range signingKeyFns {
 			logger.WithField("path", signingKeyFn).Infoln("loading signing key")
-			err = addSignerWithIDFromFile(signingKeyFn, "", bs)
+			kid, err := addSignerWithIDFromFile(signingKeyFn, "", bs)
 			if err != nil {
 				return err
 			}
 			if first {
 				// Also add key under the provided id.
 				first = false
-				err = addSignerWithIDFromFile(signingKeyFn, bs.config.SigningKeyID, bs)
+				if signingKeyID == "" {
+					signingKeyID = kid
+				}
+				_, err = addSignerWithIDFromFile(signingKeyFn, bs.config.SigningKeyID, bs)
 				if err != nil {
 					return err
 				}
@@ -300,6 +634,13 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		signer, _ := rsa.GenerateKey(rand.Reader, DefaultSigningKeyBits)
 		bs.config.Signers[bs.config.SigningKeyID] = signer
 	}
+	if bs.config.SigningMethod == nil {
+		bs.config.SigningMethod, err = signingMethodForSigner(bs.config.Signers[signingKeyID])
+		if err != nil {
+			return err
+		}
+		logger.WithField("alg", bs.config.SigningMethod.Alg()).Infoln("using signing method matching the signing key")
+	}
 
 	// Ensure we have a signer for the things we need.
 	err = validateSigners(bs)
@@ -316,12 +657,18 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 		}
 	}
 
-	bs.config.Config.HTTPTransport = utils.HTTPTransportWithTLSClientConfig(bs.config.TLSClientConfig)
+	httpTransport := utils.HTTPTransportWithTLSClientConfig(bs.config.TLSClientConfig)
+	httpTransport.Proxy = utils.HTTPProxyFunc(bs.config.HTTPProxyURL)
+	bs.config.Config.HTTPTransport = httpTransport
 
 	bs.config.AccessTokenDurationSeconds = settings.AccessTokenDurationSeconds
 	if bs.config.AccessTokenDurationSeconds == 0 {
 		bs.config.AccessTokenDurationSeconds = 60 * 10 // 10 Minutes
 	}
+	bs.config.AuthorizationCodeDurationSeconds = settings.AuthorizationCodeDurationSeconds
+	if bs.config.AuthorizationCodeDurationSeconds == 0 {
+		bs.config.AuthorizationCodeDurationSeconds = 60 * 2 // 2 Minutes
+	}
 	bs.config.IDTokenDurationSeconds = settings.IDTokenDurationSeconds
 	if bs.config.IDTokenDurationSeconds == 0 {
 		bs.config.IDTokenDurationSeconds = 60 * 60 // 1 Hour
@@ -330,7 +677,35 @@ func (bs *bootstrap) initialize(settings *Settings) error {
 	if bs.config.RefreshTokenDurationSeconds == 0 {
 		bs.config.RefreshTokenDurationSeconds = 60 * 60 * 24 * 365 * 3 // 3 Years
 	}
+	for _, ceiling := range []struct {
+		name     string
+		duration uint64
+		max      uint64
+		target   *time.Duration
+	}{
+		{"access-token-expiration", bs.config.AccessTokenDurationSeconds, settings.MaxAccessTokenDurationSeconds, &bs.config.Config.MaxAccessTokenDuration},
+		{"id-token-expiration", bs.config.IDTokenDurationSeconds, settings.MaxIDTokenDurationSeconds, &bs.config.Config.MaxIDTokenDuration},
+		{"refresh-token-expiration", bs.config.RefreshTokenDurationSeconds, settings.MaxRefreshTokenDurationSeconds, &bs.config.Config.MaxRefreshTokenDuration},
+	} {
+		if ceiling.max == 0 {
+			continue
+		}
+		*ceiling.target = time.Duration(ceiling.max) * time.Second
+		if ceiling.duration > ceiling.max {
+			logger.WithFields(logrus.Fields{
+				"expiration": ceiling.duration,
+				"max":        ceiling.max,
+			}).Warnf("%s exceeds its maximum and is clamped", ceiling.name)
+		}
+	}
 	bs.config.DyamicClientSecretDurationSeconds = settings.DyamicClientSecretDurationSeconds
+	bs.config.PersistentSessionDurationSeconds = settings.PersistentSessionDurationSeconds
+	if bs.config.PersistentSessionDurationSeconds > 0 {
+		logger.Infoln("persistent remember me sessions are enabled")
+	}
+	bs.config.JwksMaxAgeSeconds = settings.JwksMaxAgeSeconds
+	utils.SetJWTLeeway(time.Duration(settings.JWTLeewaySeconds) * time.Second)
+	logger.WithField("leeway", utils.JWTLeeway()).Debugln("jwt validation leeway set")
 
 	return nil
 }
@@ -356,6 +731,11 @@ func (bs *bootstrap) setup(ctx context.Context, settings *Settings) error {
 	}
 	managers.Set("guest", guestManager)
 
+	err = bs.validateEndpointURIs()
+	if err != nil {
+		return err
+	}
+
 	oidcProvider, err := bs.setupOIDCProvider(ctx)
 	if err != nil {
 		return err
@@ -377,6 +757,29 @@ func (bs *bootstrap) setup(ctx context.Context, settings *Settings) error {
 	return nil
 }
 
+// validateEndpointURIs checks that the endpoint URIs, as finalized by the
+// identity manager, are consistent with the issuer identifier.
+func (bs *bootstrap) validateEndpointURIs() error {
+	endpoints := []struct {
+		name string
+		uri  *url.URL
+	}{
+		{"authorization-endpoint-uri", bs.config.AuthorizationEndpointURI},
+		{"endsession-endpoint-uri", bs.config.EndSessionEndpointURI},
+		{"uri-base-path", &url.URL{Path: bs.MakeURIPath(APITypeKonnect, "")}},
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.uri == nil || endpoint.uri.String() == "" {
+			continue
+		}
+		if err := validateEndpointURI(bs.config.IssuerIdentifierURI, endpoint.uri); err != nil {
+			return fmt.Errorf("invalid %s value, %v", endpoint.name, err)
+		}
+	}
+
+	return nil
+}
+
 func (bs *bootstrap) MakeURIPath(api APIType, subpath string) string {
 	subpath = strings.TrimPrefix(subpath, "/")
 	uriPath := ""
@@ -418,9 +821,10 @@ func (bs *bootstrap) setupIdentity(ctx context.Context, settings *Settings) (ide
 		return nil, err
 	}
 	logger.WithFields(logrus.Fields{
-		"name":   identityManagerName,
-		"scopes": identityManager.ScopesSupported(nil),
-		"claims": identityManager.ClaimsSupported(nil),
+		"name":    identityManagerName,
+		"backend": identityManager.Name(),
+		"scopes":  identityManager.ScopesSupported(nil),
+		"claims":  identityManager.ClaimsSupported(nil),
 	}).Infoln("identity manager set up")
 
 	return identityManager, nil
@@ -464,6 +868,7 @@ func (bs *bootstrap) setupOIDCProvider(ctx context.Context) (*oidcProvider.Provi
 
 		IssuerIdentifier:       bs.config.IssuerIdentifierURI.String(),
 		WellKnownPath:          "/.well-known/openid-configuration",
+		OAuthMetadataPath:      "/.well-known/oauth-authorization-server",
 		JwksPath:               bs.MakeURIPath(APITypeKonnect, "/jwks.json"),
 		AuthorizationPath:      bs.config.AuthorizationEndpointURI.EscapedPath(),
 		TokenPath:              bs.MakeURIPath(APITypeKonnect, "/token"),
@@ -471,6 +876,7 @@ func (bs *bootstrap) setupOIDCProvider(ctx context.Context) (*oidcProvider.Provi
 		EndSessionPath:         bs.config.EndSessionEndpointURI.EscapedPath(),
 		CheckSessionIframePath: bs.MakeURIPath(APITypeKonnect, "/session/check-session.html"),
 		RegistrationPath:       registrationPath,
+		IntrospectionPath:      bs.MakeURIPath(APITypeKonnect, "/introspect"),
 
 		BrowserStateCookiePath: bs.MakeURIPath(APITypeKonnect, "/session/"),
 		BrowserStateCookieName: "__Secure-KKBS", // Kopano-Konnect-Browser-State
@@ -481,6 +887,14 @@ func (bs *bootstrap) setupOIDCProvider(ctx context.Context) (*oidcProvider.Provi
 		AccessTokenDuration:  time.Duration(bs.config.AccessTokenDurationSeconds) * time.Second,
 		IDTokenDuration:      time.Duration(bs.config.IDTokenDurationSeconds) * time.Second,
 		RefreshTokenDuration: time.Duration(bs.config.RefreshTokenDurationSeconds) * time.Second,
+
+		JwksMaxAge: time.Duration(bs.config.JwksMaxAgeSeconds) * time.Second,
+
+		ErrorPageTemplate:     bs.config.ErrorPageTemplate,
+		ErrorDocumentationURI: bs.config.ErrorDocumentationURI,
+
+		StaticClaims:    bs.config.StaticClaims,
+		ClaimTransforms: bs.config.ClaimTransforms,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create provider: %v", err)
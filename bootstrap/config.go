@@ -21,11 +21,14 @@ import (
 	"crypto"
 	"crypto/tls"
 	"crypto/x509"
+	"html/template"
 	"net/url"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/libregraph/lico/config"
+	oidcProvider "github.com/libregraph/lico/oidc/provider"
 )
 
 // Config is a typed application config which represents the active
@@ -35,11 +38,13 @@ type Config struct {
 	Settings *Settings
 
 	SignInFormURI            *url.URL
+	SignUpFormURI            *url.URL
 	SignedOutURI             *url.URL
 	AuthorizationEndpointURI *url.URL
 	EndSessionEndpointURI    *url.URL
 
 	TLSClientConfig *tls.Config
+	HTTPProxyURL    *url.URL
 
 	IssuerIdentifierURI *url.URL
 
@@ -47,13 +52,24 @@ type Config struct {
 	IdentifierClientPath              string
 	IdentifierRegistrationConf        string
 	IdentifierAuthoritiesConf         string
+	AuthoritiesFetchMaxSize           int64
+	AuthoritiesFetchTimeout           time.Duration
 	IdentifierScopesConf              string
 	IdentifierDefaultBannerLogo       []byte
 	IdentifierDefaultSignInPageText   *string
 	IdentifierDefaultUsernameHintText *string
 	IdentifierUILocales               []string
 
+	ErrorPageTemplate     *template.Template
+	ErrorDocumentationURI *url.URL
+
+	StaticClaims    map[string]interface{}
+	ClaimTransforms oidcProvider.ClaimTransforms
+
 	EncryptionSecret []byte
+	EncryptionKeyID  string
+	EncryptionKeys   map[string][]byte
+	AdminSecret      []byte
 	SigningMethod    jwt.SigningMethod
 	SigningKeyID     string
 	Signers          map[string]crypto.Signer
@@ -61,7 +77,10 @@ type Config struct {
 	Certificates     map[string][]*x509.Certificate
 
 	AccessTokenDurationSeconds        uint64
+	AuthorizationCodeDurationSeconds  uint64
 	IDTokenDurationSeconds            uint64
 	RefreshTokenDurationSeconds       uint64
 	DyamicClientSecretDurationSeconds uint64
+	PersistentSessionDurationSeconds  uint64
+	JwksMaxAgeSeconds                 uint64
 }
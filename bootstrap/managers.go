@@ -63,11 +63,21 @@ func newManagers(ctx context.Context, bs *bootstrap) (*managers.Managers, error)
 	if err != nil {
 		return nil, fmt.Errorf("invalid --encryption-secret parameter value for encryption: %v", err)
 	}
+	for kid, key := range bs.config.EncryptionKeys {
+		if err = encryption.AddKeyWithID(kid, key); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for encryption: %v", err)
+		}
+	}
+	if bs.config.EncryptionKeyID != "" {
+		if err = encryption.SetKeyID(bs.config.EncryptionKeyID); err != nil {
+			return nil, fmt.Errorf("invalid --encryption-keyring parameter value for encryption: %v", err)
+		}
+	}
 	mgrs.Set("encryption", encryption)
 	logger.Infof("encryption set up with %d key size", encryption.GetKeySize())
 
 	// OIDC code manage.
-	code := codeManagers.NewMemoryMapManager(ctx)
+	code := codeManagers.NewMemoryMapManager(ctx, time.Duration(bs.config.AuthorizationCodeDurationSeconds)*time.Second)
 	mgrs.Set("code", code)
 
 	// Identifier client registry manager.
@@ -78,7 +88,7 @@ func newManagers(ctx context.Context, bs *bootstrap) (*managers.Managers, error)
 	mgrs.Set("clients", clients)
 
 	// Identifier authorities registry manager.
-	authorities, err := identityAuthorities.NewRegistry(ctx, bs.MakeURI(APITypeSignin, ""), bs.config.IdentifierAuthoritiesConf, logger)
+	authorities, err := identityAuthorities.NewRegistry(ctx, bs.MakeURI(APITypeSignin, ""), bs.config.IdentifierAuthoritiesConf, bs.config.AuthoritiesFetchMaxSize, bs.config.AuthoritiesFetchTimeout, bs.config.TLSClientConfig, bs.config.HTTPProxyURL, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create authorities registry: %v", err)
 	}
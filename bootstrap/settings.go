@@ -24,24 +24,68 @@ type Settings struct {
 	IdentityManager                   string
 	URIBasePath                       string
 	SignInURI                         string
+	SignUpURI                         string
 	SignedOutURI                      string
 	AuthorizationEndpointURI          string
 	EndsessionEndpointURI             string
 	Insecure                          bool
+	TLSClientMinVersion               string
+	TLSClientCipherSuites             []string
+	TLSClientCAFile                   string
+	HTTPProxy                         string
 	TrustedProxy                      []string
 	AllowScope                        []string
+	DefaultScope                      []string
+	AllowedOrigins                    []string
+	AllowClientOrigins                bool
+	AllowedClientSigningAlgs          []string
+	LogonLockoutAttempts              int
+	SubjectPrefix                     string
+	LogonLockoutDurationSeconds       uint64
+	LogonLockoutExempt                []string
+	MaxConcurrentSessions             int
+	SessionLimitPolicy                string
+	SessionIdleTimeoutSeconds         uint64
+	MaxClaimValues                    int
+	ClaimLimitPolicy                  string
 	AllowClientGuests                 bool
+	ClientScopedGuestSubjects         bool
 	AllowDynamicClientRegistration    bool
+	RememberConsent                   bool
+	MaxPostLogoutRedirectURIs         int
+	AllowNativeImplicit               bool
+	MinimalIDTokenClaims              bool
+	AllowMultipleAudiences            bool
+	AdditionalAudiences               []string
+	RequirePKCEForPublicClients       bool
+	NativeClientHardening             bool
+	ACRMaxAges                        []string
+	CookieSameSite                    string
+	CookieDomain                      string
+	RequestBodySizeLimit              int64
 	EncryptionSecretFile              string
+	EncryptionKeyringFiles            []string
+	AdminSecretFile                   string
 	Listen                            string
+	EnableH2C                         bool
+	HSTSMaxAgeSeconds                 uint64
+	FrameOptions                      string
+	ContentSecurityPolicy             string
+	ReferrerPolicy                    string
 	IdentifierClientDisabled          bool
 	IdentifierClientPath              string
 	IdentifierRegistrationConf        string
+	AuthoritiesFetchMaxSize           int64
+	AuthoritiesFetchTimeoutSeconds    uint64
 	IdentifierScopesConf              string
 	IdentifierDefaultBannerLogo       string
 	IdentifierDefaultSignInPageText   string
 	IdentifierDefaultUsernameHintText string
 	IdentifierUILocales               []string
+	ErrorPageTemplate                 string
+	StaticClaimsFile                  string
+	ClaimTransformsFile               string
+	ErrorDocumentationURI             string
 	SigningKid                        string
 	SigningMethod                     string
 	SigningPrivateKeyFiles            []string
@@ -49,7 +93,15 @@ type Settings struct {
 	CookieBackendURI                  string
 	CookieNames                       []string
 	AccessTokenDurationSeconds        uint64
+	AuthorizationCodeDurationSeconds  uint64
 	IDTokenDurationSeconds            uint64
 	RefreshTokenDurationSeconds       uint64
+	MaxAccessTokenDurationSeconds     uint64
+	MaxIDTokenDurationSeconds         uint64
+	MaxRefreshTokenDurationSeconds    uint64
 	DyamicClientSecretDurationSeconds uint64
+	PersistentSessionDurationSeconds  uint64
+	JwksMaxAgeSeconds                 uint64
+	JWTLeewaySeconds                  uint64
+	TenantsConf                       string
 }
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package bootstrap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TenantSettings define the per tenant settings which override the base
+// Settings when running multiple issuers selected by request host.
+type TenantSettings struct {
+	Iss                        string   `yaml:"iss"`
+	SigningKid                 string   `yaml:"signing_kid"`
+	SigningPrivateKeyFiles     []string `yaml:"signing_private_keys"`
+	ValidationKeysPath         string   `yaml:"validation_keys_path"`
+	EncryptionSecretFile       string   `yaml:"encryption_secret"`
+	EncryptionKeyringFiles     []string `yaml:"encryption_keyring"`
+	IdentifierRegistrationConf string   `yaml:"identifier_registration_conf"`
+}
+
+type tenantsData struct {
+	Tenants []*TenantSettings `yaml:"tenants"`
+}
+
+// LoadTenantSettingsFromFile loads the tenants configuration from the
+// provided YAML file.
+func LoadTenantSettingsFromFile(fn string) ([]*TenantSettings, error) {
+	tenantsFile, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants conf: %w", err)
+	}
+
+	data := &tenantsData{}
+	err = yaml.Unmarshal(tenantsFile, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tenants conf: %w", err)
+	}
+
+	hosts := make(map[string]bool)
+	for _, tenant := range data.Tenants {
+		host, err := tenant.Host()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := hosts[host]; exists {
+			return nil, fmt.Errorf("duplicate tenant host: %s", host)
+		}
+		hosts[host] = true
+	}
+
+	return data.Tenants, nil
+}
+
+// Host returns the host of the accociated tenant's issuer identifier which is
+// used to select the tenant for incoming requests.
+func (ts *TenantSettings) Host() (string, error) {
+	issURL, err := url.Parse(ts.Iss)
+	if err != nil {
+		return "", fmt.Errorf("invalid tenant iss value: %w", err)
+	}
+	if issURL.Host == "" {
+		return "", fmt.Errorf("invalid tenant iss value, URL must have a host")
+	}
+
+	return issURL.Host, nil
+}
+
+// Settings returns a copy of the provided base Settings with the accociated
+// tenant's values applied.
+func (ts *TenantSettings) Settings(base *Settings) *Settings {
+	settings := *base
+
+	settings.Iss = ts.Iss
+	if ts.SigningKid != "" {
+		settings.SigningKid = ts.SigningKid
+	}
+	if len(ts.SigningPrivateKeyFiles) > 0 {
+		settings.SigningPrivateKeyFiles = ts.SigningPrivateKeyFiles
+	}
+	if ts.ValidationKeysPath != "" {
+		settings.ValidationKeysPath = ts.ValidationKeysPath
+	}
+	if ts.EncryptionSecretFile != "" {
+		settings.EncryptionSecretFile = ts.EncryptionSecretFile
+	}
+	if len(ts.EncryptionKeyringFiles) > 0 {
+		settings.EncryptionKeyringFiles = ts.EncryptionKeyringFiles
+	}
+	if ts.IdentifierRegistrationConf != "" {
+		settings.IdentifierRegistrationConf = ts.IdentifierRegistrationConf
+	}
+
+	return &settings
+}
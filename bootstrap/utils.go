@@ -6,10 +6,12 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -106,7 +108,7 @@ func parsePEMSigner(pemBytes []byte) (crypto.Signer, error) {
 
 // LoadValidatorFromFile loads a public-key used for validation.
 //
-// Supported formats are JSON-JWK and PEM
+// Supported formats are JSON-JWK (.json or .jwk) and PEM
 func LoadValidatorFromFile(fn string) (string, crypto.PublicKey, error) {
 	kid, _, key, err := loadValidatorFromFile(fn)
 	return kid, key, err
@@ -115,7 +117,7 @@ func LoadValidatorFromFile(fn string) (string, crypto.PublicKey, error) {
 // LoadCertificatesAndValidatorFromFile loads chain of certificates and a
 // public-key used for validation.
 //
-// Supported formats are JSON-JWK and PEM
+// Supported formats are JSON-JWK (.json or .jwk) and PEM
 func LoadCertificatesAndValidatorFromFile(fn string) (string, []*x509.Certificate, crypto.PublicKey, error) {
 	return loadValidatorFromFile(fn)
 }
@@ -128,7 +130,7 @@ func loadValidatorFromFile(fn string) (string, []*x509.Certificate, crypto.Publi
 
 	ext := filepath.Ext(fn)
 	switch ext {
-	case ".json":
+	case ".json", ".jwk":
 		k, err := parseJSONWebKey(readBytes)
 		if err != nil {
 			return "", nil, nil, fmt.Errorf("failed to parse key file as JWK: %v", err)
@@ -151,6 +153,38 @@ func loadValidatorFromFile(fn string) (string, []*x509.Certificate, crypto.Publi
 	}
 }
 
+// loadValidatorsFromJWKSFile loads all public keys of the JWK set in the
+// provided file. Private keys are reduced to their public part.
+func loadValidatorsFromJWKSFile(fn string) ([]*jose.JSONWebKey, error) {
+	readBytes, errRead := ioutil.ReadFile(fn)
+	if errRead != nil {
+		return nil, fmt.Errorf("failed to parse key file: %v", errRead)
+	}
+
+	jwks := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(readBytes, jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse key file as JWKS: %v", err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, fmt.Errorf("jwks file contains no keys")
+	}
+
+	keys := make([]*jose.JSONWebKey, 0, len(jwks.Keys))
+	for idx := range jwks.Keys {
+		k := &jwks.Keys[idx]
+		if !k.Valid() {
+			return nil, fmt.Errorf("jwks file contains an invalid JWK at index %d", idx)
+		}
+		if !k.IsPublic() {
+			public := k.Public()
+			k = &public
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
 func parsePEMValidator(pemBytes []byte) ([]*x509.Certificate, crypto.PublicKey, error) {
 	block, _ := pem.Decode(pemBytes)
 	if block == nil {
@@ -207,22 +241,24 @@ func parsePEMValidator(pemBytes []byte) ([]*x509.Certificate, crypto.PublicKey,
 	return certificates, validator, nil
 }
 
-func addSignerWithIDFromFile(fn string, kid string, bs *bootstrap) error {
+// addSignerWithIDFromFile loads the signer from the file at fn and adds it
+// with kid, returning the kid the signer is known by.
+func addSignerWithIDFromFile(fn string, kid string, bs *bootstrap) (string, error) {
 	fi, err := os.Lstat(fn)
 	if err != nil {
-		return fmt.Errorf("failed load load signer key: %v", err)
+		return "", fmt.Errorf("failed load load signer key: %v", err)
 	}
 
 	mode := fi.Mode()
 	switch {
 	case mode.IsDir():
-		return fmt.Errorf("signer key must be a file")
+		return "", fmt.Errorf("signer key must be a file")
 	}
 
 	// Load file.
 	signerKid, signer, err := LoadSignerFromFile(fn)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if kid == "" {
 		kid = signerKid
@@ -233,7 +269,7 @@ func addSignerWithIDFromFile(fn string, kid string, bs *bootstrap) error {
 		if mode&os.ModeSymlink != 0 {
 			real, err = os.Readlink(fn)
 			if err != nil {
-				return err
+				return "", err
 			}
 			_, real = filepath.Split(real)
 		} else {
@@ -248,7 +284,7 @@ func addSignerWithIDFromFile(fn string, kid string, bs *bootstrap) error {
 			"path": fn,
 			"kid":  kid,
 		}).Warnln("skipped as signer with same kid already loaded")
-		return nil
+		return kid, nil
 	} else {
 		bs.config.Config.Logger.WithFields(logrus.Fields{
 			"path": fn,
@@ -257,7 +293,33 @@ func addSignerWithIDFromFile(fn string, kid string, bs *bootstrap) error {
 	}
 
 	bs.config.Signers[kid] = signer
-	return nil
+	return kid, nil
+}
+
+// signingMethodForSigner returns the default signing method for the type of
+// the provided signer.
+func signingMethodForSigner(signer crypto.Signer) (jwt.SigningMethod, error) {
+	switch s := signer.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodPS256, nil
+	case *ecdsa.PrivateKey:
+		switch s.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA signing key curve: %s", s.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		return signing.SigningMethodEdDSA, nil
+	case nil:
+		return nil, fmt.Errorf("no signing key to select signing method")
+	default:
+		return nil, fmt.Errorf("unsupported signer type: %v", s)
+	}
 }
 
 func validateSigners(bs *bootstrap) error {
@@ -332,45 +394,72 @@ func addValidatorsFromPath(pn string, bs *bootstrap) error {
 	} else {
 		files = append(files, pemFiles...)
 	}
-	if jsonFiles, err := filepath.Glob(filepath.Join(pn, "*.json")); err != nil {
-		return fmt.Errorf("validator path err: %v", err)
-	} else {
-		files = append(files, jsonFiles...)
+	for _, pattern := range []string{"*.json", "*.jwk", "*.jwks"} {
+		if jsonFiles, err := filepath.Glob(filepath.Join(pn, pattern)); err != nil {
+			return fmt.Errorf("validator path err: %v", err)
+		} else {
+			files = append(files, jsonFiles...)
+		}
 	}
 
 	for _, file := range files {
+		// Get ID from file, without following symbolic links.
+		_, fn := filepath.Split(file)
+		fallbackKid := getKeyIDFromFilename(fn)
+
+		if filepath.Ext(file) == ".jwks" {
+			keys, err := loadValidatorsFromJWKSFile(file)
+			if err != nil {
+				bs.config.Config.Logger.WithError(err).WithField("path", file).Warnln("failed to load validator keys")
+				continue
+			}
+			for _, k := range keys {
+				kid := k.KeyID
+				if kid == "" {
+					if len(keys) > 1 {
+						bs.config.Config.Logger.WithField("path", file).Warnln("skipped validator key without kid in jwks file with multiple keys")
+						continue
+					}
+					kid = fallbackKid
+				}
+				addValidator(bs, file, kid, k.Certificates, k.Key)
+			}
+			continue
+		}
+
 		kid, certificates, validator, err := loadValidatorFromFile(file)
 		if err != nil {
 			bs.config.Config.Logger.WithError(err).WithField("path", file).Warnln("failed to load validator key")
 			continue
 		}
-
-		// Get ID from file, without following symbolic links.
 		if kid == "" {
-			_, fn := filepath.Split(file)
-			kid = getKeyIDFromFilename(fn)
-		}
-		if _, ok := bs.config.Validators[kid]; ok {
-			bs.config.Config.Logger.WithFields(logrus.Fields{
-				"path": file,
-				"kid":  kid,
-			}).Warnln("skipped as validator with same kid already loaded")
-			continue
-		} else {
-			bs.config.Config.Logger.WithFields(logrus.Fields{
-				"path": file,
-				"kid":  kid,
-			}).Debugln("loaded validator key")
-		}
-		bs.config.Validators[kid] = validator
-		if certificates != nil {
-			bs.config.Certificates[kid] = certificates
+			kid = fallbackKid
 		}
+		addValidator(bs, file, kid, certificates, validator)
 	}
 
 	return nil
 }
 
+func addValidator(bs *bootstrap, file string, kid string, certificates []*x509.Certificate, validator crypto.PublicKey) {
+	if _, ok := bs.config.Validators[kid]; ok {
+		bs.config.Config.Logger.WithFields(logrus.Fields{
+			"path": file,
+			"kid":  kid,
+		}).Warnln("skipped as validator with same kid already loaded")
+		return
+	}
+
+	bs.config.Config.Logger.WithFields(logrus.Fields{
+		"path": file,
+		"kid":  kid,
+	}).Debugln("loaded validator key")
+	bs.config.Validators[kid] = validator
+	if len(certificates) > 0 {
+		bs.config.Certificates[kid] = certificates
+	}
+}
+
 func WithSchemeAndHost(u, base *url.URL) *url.URL {
 	if u.Host != "" && u.Scheme != "" {
 		return u
@@ -408,3 +497,68 @@ func getCommonURLPathPrefix(p1, p2 string) (string, error) {
 
 	return strings.Join(common, "/"), nil
 }
+
+// validateIssuerIdentifier checks that the provided issuer identifier is an
+// absolute URL with a host and without query or fragment. The https scheme is
+// required, unless insecure is true in which case http is accepted as well.
+func validateIssuerIdentifier(iss *url.URL, insecure bool) error {
+	if !iss.IsAbs() {
+		return errors.New("URL must be absolute")
+	}
+	switch iss.Scheme {
+	case "https":
+	case "http":
+		if !insecure {
+			return errors.New("URL must start with https://")
+		}
+	default:
+		return errors.New("URL must start with https://")
+	}
+	if iss.Host == "" {
+		return errors.New("URL must have a host")
+	}
+	if iss.RawQuery != "" || iss.Fragment != "" {
+		return errors.New("URL must not have a query or fragment")
+	}
+
+	return nil
+}
+
+// validateEndpointURI checks that the provided endpoint URI is consistent with
+// the provided issuer identifier. Absolute endpoint URIs must use the same
+// scheme and host as the issuer and all endpoint paths must be below the path
+// of the issuer.
+func validateEndpointURI(iss *url.URL, u *url.URL) error {
+	if u.Scheme != "" && u.Scheme != iss.Scheme {
+		return fmt.Errorf("scheme %s does not match iss scheme %s", u.Scheme, iss.Scheme)
+	}
+	if u.Host != "" && u.Host != iss.Host {
+		return fmt.Errorf("host %s does not match iss host %s", u.Host, iss.Host)
+	}
+
+	issPath := strings.TrimSuffix(iss.EscapedPath(), "/")
+	if issPath == "" {
+		return nil
+	}
+	prefix, err := getCommonURLPathPrefix(issPath, u.EscapedPath())
+	if err != nil || prefix != issPath {
+		return fmt.Errorf("path %s is not below iss path %s", u.EscapedPath(), issPath)
+	}
+
+	return nil
+}
+
+func parseCookieSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "none":
+		// SameSite=None requires Secure, which is always set for our cookies
+		// as their names use the __Secure- prefix.
+		return http.SameSiteNoneMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	default:
+		return 0, fmt.Errorf("invalid cookie-samesite value: %s", value)
+	}
+}
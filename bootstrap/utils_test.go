@@ -0,0 +1,252 @@
+package bootstrap
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/signing"
+)
+
+func TestValidateIssuerIdentifier(t *testing.T) {
+	tests := []struct {
+		iss      string
+		insecure bool
+		valid    bool
+	}{
+		{"https://example.com", false, true},
+		{"https://example.com/lico", false, true},
+		{"http://example.com", false, false},
+		{"http://example.com", true, true},
+		{"ftp://example.com", true, false},
+		{"https://", false, false},
+		{"/lico", false, false},
+		{"https://example.com/?tenant=1", false, false},
+	}
+
+	for _, test := range tests {
+		iss, _ := url.Parse(test.iss)
+		err := validateIssuerIdentifier(iss, test.insecure)
+		if test.valid && err != nil {
+			t.Errorf("unexpected error for %s (insecure %v): %v", test.iss, test.insecure, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected error for %s (insecure %v)", test.iss, test.insecure)
+		}
+	}
+}
+
+func TestValidateEndpointURI(t *testing.T) {
+	tests := []struct {
+		iss   string
+		uri   string
+		valid bool
+	}{
+		{"https://example.com", "/signin/v1/identifier/_/authorize", true},
+		{"https://example.com", "https://example.com/signin/v1/identifier/_/authorize", true},
+		{"https://example.com", "http://example.com/signin/v1/identifier/_/authorize", false},
+		{"https://example.com", "https://other.example.com/authorize", false},
+		{"https://example.com/lico", "/lico/signin/v1/identifier/_/authorize", true},
+		{"https://example.com/lico/", "/lico/konnect/v1", true},
+		{"https://example.com/lico", "/signin/v1/identifier/_/authorize", false},
+		{"https://example.com/lico", "/licorice/konnect/v1", false},
+	}
+
+	for _, test := range tests {
+		iss, _ := url.Parse(test.iss)
+		uri, _ := url.Parse(test.uri)
+		err := validateEndpointURI(iss, uri)
+		if test.valid && err != nil {
+			t.Errorf("unexpected error for %s with iss %s: %v", test.uri, test.iss, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected error for %s with iss %s", test.uri, test.iss)
+		}
+	}
+}
+
+func TestAddValidatorsFromPathJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	jwks, err := json.Marshal(&jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{Key: rsaKey.Public(), KeyID: "partner-rsa", Use: "sig"},
+			{Key: ecKey.Public(), KeyID: "partner-ec", Use: "sig"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "partner.jwks"), jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := json.Marshal(&jose.JSONWebKey{Key: ecKey.Public(), Use: "sig"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "single.jwk"), jwk, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(pemKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "local.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	bs := &bootstrap{
+		config: &Config{
+			Config: &config.Config{
+				Logger: logrus.New(),
+			},
+			Validators:   make(map[string]crypto.PublicKey),
+			Certificates: make(map[string][]*x509.Certificate),
+		},
+	}
+	if err = addValidatorsFromPath(dir, bs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kid := range []string{"partner-rsa", "partner-ec", "single", "local"} {
+		if _, ok := bs.config.Validators[kid]; !ok {
+			t.Errorf("validator %s was not loaded", kid)
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Subject: "user1",
+	})
+	token.Header["kid"] = "partner-rsa"
+	signed, err := token.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwt.ParseWithClaims(signed, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return bs.config.Validators[kid], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Valid {
+		t.Errorf("token signed by a jwks key did not validate")
+	}
+}
+
+func TestSigningMethodForSigner(t *testing.T) {
+	ecP256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecP384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		signer   crypto.Signer
+		expected jwt.SigningMethod
+	}{
+		{"P-256", ecP256Key, jwt.SigningMethodES256},
+		{"P-384", ecP384Key, jwt.SigningMethodES384},
+		{"Ed25519", ed25519Key, signing.SigningMethodEdDSA},
+		{"RSA", rsaKey, jwt.SigningMethodPS256},
+	}
+
+	for _, test := range tests {
+		sm, err := signingMethodForSigner(test.signer)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if sm != test.expected {
+			t.Errorf("%s: expected signing method %s, got %s", test.name, test.expected.Alg(), sm.Alg())
+		}
+	}
+
+	if _, err := signingMethodForSigner(nil); err == nil {
+		t.Errorf("expected error without signer")
+	}
+}
+
+func TestInitializeSigningMethodAutoWithoutKid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(t.TempDir(), "ec.pem")
+	if err = ioutil.WriteFile(fn, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &Settings{
+		Iss:                    "https://lico.example.com",
+		IdentityManager:        "dummy",
+		SigningMethod:          SigningMethodAuto,
+		SigningPrivateKeyFiles: []string{fn},
+		JWTLeewaySeconds:       60,
+	}
+	bs := &bootstrap{
+		config: &Config{
+			Config: &config.Config{
+				Logger: logrus.New(),
+			},
+			Settings: settings,
+		},
+	}
+	if err = bs.initialize(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if bs.config.SigningKeyID != "" {
+		t.Errorf("expected no signing kid, got %s", bs.config.SigningKeyID)
+	}
+	if bs.config.SigningMethod != jwt.SigningMethodES256 {
+		t.Errorf("expected signing method matching the key, got %v", bs.config.SigningMethod)
+	}
+	if _, ok := bs.config.Signers["ec"]; !ok {
+		t.Errorf("signer was not loaded with the kid from its file name")
+	}
+}
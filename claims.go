@@ -18,12 +18,15 @@
 package lico
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/libregraph/lico/oidc"
 	"github.com/libregraph/lico/oidc/payload"
+	"github.com/libregraph/lico/utils"
 )
 
 // Access token claims used.
@@ -50,6 +53,52 @@ const (
 	InternalExtraAccessTokenClaimsClaim = "$lico.at.extra"
 )
 
+// reservedClaims are the protocol claims which are set by the provider when
+// issuing tokens and can never be set from configuration or upstream data.
+var reservedClaims = map[string]bool{
+	"iss":       true,
+	"sub":       true,
+	"aud":       true,
+	"exp":       true,
+	"iat":       true,
+	"auth_time": true,
+	"sid":       true,
+	"nbf":       true,
+	"jti":       true,
+	"azp":       true,
+	"nonce":     true,
+	"acr":       true,
+	"amr":       true,
+	"at_hash":   true,
+	"c_hash":    true,
+	"client_id": true,
+	"scope":     true,
+	"cnf":       true,
+	ScopesClaim: true,
+}
+
+// reservedClaimPrefixes are the prefixes of the claims used internally in
+// tokens, which can never be set from configuration or upstream data.
+var reservedClaimPrefixes = []string{
+	"lg.",
+	"$",
+}
+
+// IsReservedClaim returns true if the provided claim is a protocol or internal
+// claim.
+func IsReservedClaim(claim string) bool {
+	if reservedClaims[claim] {
+		return true
+	}
+	for _, prefix := range reservedClaimPrefixes {
+		if strings.HasPrefix(claim, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // TokenType defines the token type value.
 type TokenTypeValue string
 
@@ -78,16 +127,44 @@ type AccessTokenClaims struct {
 	IdentityClaims   jwt.MapClaims `json:"lg.i"`
 	IdentityProvider string        `json:"lg.p,omitempty"`
 
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ClientID        string   `json:"client_id,omitempty"`
+	Audiences       []string `json:"-"`
+
 	*oidc.SessionClaims
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface. Access tokens with
+// multiple audiences are supported. The first audience is set as Audience and
+// all audiences are set as Audiences.
+func (c *AccessTokenClaims) UnmarshalJSON(b []byte) error {
+	type accessTokenClaims AccessTokenClaims
+	aux := struct {
+		*accessTokenClaims
+		Audience jwt.ClaimStrings `json:"aud,omitempty"`
+	}{
+		accessTokenClaims: (*accessTokenClaims)(c),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	c.Audiences = aux.Audience
+	c.Audience = ""
+	if len(aux.Audience) > 0 {
+		c.Audience = aux.Audience[0]
+	}
+
+	return nil
+}
+
 // Valid implements the jwt.Claims interface.
 func (c AccessTokenClaims) Valid() error {
-	if err := c.StandardClaims.Valid(); err != nil {
+	if err := utils.ValidateTimeClaims(&c.StandardClaims); err != nil {
 		return err
 	}
 	if c.IdentityClaims != nil {
-		if err := c.IdentityClaims.Valid(); err != nil {
+		if err := utils.ValidateTimeClaims(c.IdentityClaims); err != nil {
 			return err
 		}
 	}
@@ -125,11 +202,11 @@ type RefreshTokenClaims struct {
 
 // Valid implements the jwt.Claims interface.
 func (c RefreshTokenClaims) Valid() error {
-	if err := c.StandardClaims.Valid(); err != nil {
+	if err := utils.ValidateTimeClaims(&c.StandardClaims); err != nil {
 		return err
 	}
 	if c.IdentityClaims != nil {
-		if err := c.IdentityClaims.Valid(); err != nil {
+		if err := utils.ValidateTimeClaims(c.IdentityClaims); err != nil {
 			return err
 		}
 	}
@@ -21,6 +21,8 @@ import (
 	"os"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/audit"
 )
 
 func newLogger(disableTimestamp bool, logLevelString string) (logrus.FieldLogger, error) {
@@ -37,3 +39,21 @@ func newLogger(disableTimestamp bool, logLevelString string) (logrus.FieldLogger
 		Level: logLevel,
 	}, nil
 }
+
+func newAuditLogger(sink string) (audit.Logger, error) {
+	switch sink {
+	case "":
+		return nil, nil
+	case "stdout":
+		return audit.NewJSONLogger(os.Stdout), nil
+	case "stderr":
+		return audit.NewJSONLogger(os.Stderr), nil
+	}
+
+	f, err := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.NewJSONLogger(f), nil
+}
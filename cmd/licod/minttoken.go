@@ -0,0 +1,207 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+	"github.com/longsleep/rndm"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/bootstrap"
+	"github.com/libregraph/lico/config"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+	"github.com/libregraph/lico/oidc/provider"
+)
+
+const (
+	mintTokenTypeAccess = "access"
+	mintTokenTypeID     = "id"
+)
+
+type mintTokenOptions struct {
+	kid           string
+	signingMethod string
+	tokenType     string
+
+	iss      string
+	sub      string
+	aud      []string
+	scope    string
+	lifetime time.Duration
+}
+
+func commandMintToken() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mint-token [key.pem]",
+		Short: "Create a signed token for debugging of token validation",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := mintTokenCmd(cmd, args); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().String("kid", "", "Key ID kid (defaults to the kid of the key file or its file name)")
+	cmd.Flags().String("signing-method", "PS256", "JWT signing method")
+	cmd.Flags().String("type", mintTokenTypeAccess, fmt.Sprintf("Token type (one of %s, %s)", mintTokenTypeAccess, mintTokenTypeID))
+	cmd.Flags().String("iss", "", "Issuer iss claim value")
+	cmd.Flags().String("sub", "", "Subject sub claim value (required)")
+	cmd.Flags().StringArray("aud", nil, "Audience aud claim value (required, can be used multiple times)")
+	cmd.Flags().String("scope", "openid", "Space separated scopes of access tokens")
+	cmd.Flags().Uint64("lifetime", 3600, "Token lifetime in seconds") // 1 hour.
+
+	return cmd
+}
+
+func mintTokenCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Help()
+		os.Exit(2)
+	}
+
+	opts := &mintTokenOptions{}
+	opts.kid, _ = cmd.Flags().GetString("kid")
+	opts.signingMethod, _ = cmd.Flags().GetString("signing-method")
+	opts.tokenType, _ = cmd.Flags().GetString("type")
+	opts.iss, _ = cmd.Flags().GetString("iss")
+	opts.sub, _ = cmd.Flags().GetString("sub")
+	opts.aud, _ = cmd.Flags().GetStringArray("aud")
+	opts.scope, _ = cmd.Flags().GetString("scope")
+	lifetime, _ := cmd.Flags().GetUint64("lifetime")
+	opts.lifetime = time.Duration(lifetime) * time.Second
+
+	token, err := mintToken(args[0], opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, token)
+
+	return nil
+}
+
+// mintToken creates a token with the provided options, signed with the key
+// from the provided file. The signing key is set up with the same provider
+// logic used by the server, so the resulting alg and kid match what the
+// server would produce for the same key and signing method.
+func mintToken(fn string, opts *mintTokenOptions) (string, error) {
+	if opts.sub == "" {
+		return "", fmt.Errorf("sub is required")
+	}
+	if len(opts.aud) == 0 {
+		return "", fmt.Errorf("aud is required")
+	}
+	if opts.lifetime <= 0 {
+		return "", fmt.Errorf("lifetime must be larger than 0")
+	}
+
+	bootstrap.ConfigureSigningMethods()
+	signingMethod := jwt.GetSigningMethod(opts.signingMethod)
+	if signingMethod == nil {
+		return "", fmt.Errorf("unknown signing method: %s", opts.signingMethod)
+	}
+
+	signerKid, signer, err := bootstrap.LoadSignerFromFile(fn)
+	if err != nil {
+		return "", fmt.Errorf("failed to load signing key: %v", err)
+	}
+	kid := opts.kid
+	if kid == "" {
+		kid = signerKid
+	}
+	if kid == "" {
+		// Use file name as kid if no kid was given.
+		_, fn := filepath.Split(fn)
+		kid = strings.TrimSuffix(fn, filepath.Ext(fn))
+	}
+
+	p, err := provider.NewProvider(&provider.Config{
+		Config: &config.Config{
+			Logger: &logrus.Logger{
+				Out:   ioutil.Discard,
+				Level: logrus.PanicLevel,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create provider: %v", err)
+	}
+	if err = p.SetSigningMethod(signingMethod); err != nil {
+		return "", err
+	}
+	if err = p.SetSigningKey(kid, signer); err != nil {
+		return "", fmt.Errorf("failed to set signing key: %v", err)
+	}
+	sk, ok := p.GetSigningKey(signingMethod)
+	if !ok {
+		return "", fmt.Errorf("signing key can not be used with signing method %s", signingMethod.Alg())
+	}
+
+	now := time.Now()
+	standardClaims := jwt.StandardClaims{
+		Issuer:    opts.iss,
+		Subject:   opts.sub,
+		Audience:  opts.aud[0],
+		ExpiresAt: now.Add(opts.lifetime).Unix(),
+		IssuedAt:  now.Unix(),
+		Id:        rndm.GenerateRandomString(24),
+	}
+
+	var claims jwt.Claims
+	switch opts.tokenType {
+	case mintTokenTypeAccess:
+		claims = konnect.AccessTokenClaims{
+			TokenType:            konnect.TokenTypeAccessToken,
+			AuthorizedScopesList: payload.ScopesValue(strings.Fields(opts.scope)),
+			ClientID:             opts.aud[0],
+			StandardClaims:       standardClaims,
+		}
+	case mintTokenTypeID:
+		claims = konnectoidc.IDTokenClaims{
+			StandardClaims: standardClaims,
+		}
+	default:
+		return "", fmt.Errorf("unknown token type: %s", opts.tokenType)
+	}
+
+	if len(opts.aud) > 1 {
+		claimsMap, err := payload.ToMap(claims)
+		if err != nil {
+			return "", err
+		}
+		claimsMap[oidc.AudienceClaim] = opts.aud
+		claims = jwt.MapClaims(claimsMap)
+	}
+
+	token := jwt.NewWithClaims(sk.SigningMethod, claims)
+	token.Header[oidc.JWTHeaderKeyID] = sk.ID
+
+	return token.SignedString(sk.PrivateKey)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	konnect "github.com/libregraph/lico"
+)
+
+func TestMintToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := writeTestRSAKey(t, key)
+
+	signed, err := mintToken(fn, &mintTokenOptions{
+		signingMethod: "PS256",
+		tokenType:     mintTokenTypeAccess,
+
+		iss:      "https://lico.example.com",
+		sub:      "user1",
+		aud:      []string{"https://api.example.com", "https://files.example.com"},
+		scope:    "openid profile",
+		lifetime: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := &konnect.AccessTokenClaims{}
+	token, err := jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != "PS256" {
+			t.Errorf("unexpected alg: %s", token.Method.Alg())
+		}
+		if kid := token.Header["kid"]; kid != "signing" {
+			t.Errorf("expected kid from file name, got %v", kid)
+		}
+		return &key.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.Valid {
+		t.Fatalf("minted token did not validate")
+	}
+
+	if claims.Issuer != "https://lico.example.com" || claims.Subject != "user1" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if len(claims.Audiences) != 2 || claims.Audiences[1] != "https://files.example.com" {
+		t.Errorf("unexpected audiences: %v", claims.Audiences)
+	}
+	if claims.TokenType != konnect.TokenTypeAccessToken {
+		t.Errorf("unexpected token type: %v", claims.TokenType)
+	}
+	if len(claims.AuthorizedScopesList) != 2 {
+		t.Errorf("unexpected scopes: %v", claims.AuthorizedScopesList)
+	}
+	if exp := time.Unix(claims.ExpiresAt, 0); exp.After(time.Now().Add(time.Minute)) {
+		t.Errorf("unexpected expiration: %v", exp)
+	}
+
+	if _, err = mintToken(fn, &mintTokenOptions{
+		signingMethod: "ES256",
+		tokenType:     mintTokenTypeID,
+		sub:           "user1",
+		aud:           []string{"client"},
+		lifetime:      time.Minute,
+	}); err == nil {
+		t.Errorf("expected error for signing method not matching the key")
+	}
+}
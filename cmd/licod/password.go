@@ -0,0 +1,75 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/libregraph/lico/identifier/backends/file"
+)
+
+func commandHashPassword() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hash-password",
+		Short: "Create password hash for use in a file backend users file",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := hashPassword(cmd, args); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().String("scheme", file.PasswordSchemeArgon2id, fmt.Sprintf("Password hash scheme (one of %s, %s)", file.PasswordSchemeArgon2id, file.PasswordSchemeBcrypt))
+
+	return cmd
+}
+
+func hashPassword(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Help()
+		os.Exit(2)
+	}
+
+	scheme, _ := cmd.Flags().GetString("scheme")
+
+	// Read password from stdin to keep it out of the process list and shell
+	// history.
+	reader := bufio.NewReader(os.Stdin)
+	password, err := reader.ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	hash, err := file.HashPassword(scheme, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stdout, hash)
+
+	return nil
+}
@@ -28,16 +28,20 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"stash.kopano.io/kgol/ksurveyclient-go"
 	"stash.kopano.io/kgol/ksurveyclient-go/autosurvey"
 
+	"github.com/libregraph/lico/audit"
 	"github.com/libregraph/lico/bootstrap"
 	"github.com/libregraph/lico/config"
 	"github.com/libregraph/lico/encryption"
+	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/server"
 	"github.com/libregraph/lico/version"
 
+	fileBackendSupport "github.com/libregraph/lico/bootstrap/backends/file"
 	guestBackendSupport "github.com/libregraph/lico/bootstrap/backends/guest"
 	ldapBackendSupport "github.com/libregraph/lico/bootstrap/backends/ldap"
 	libreGraphBackendSupport "github.com/libregraph/lico/bootstrap/backends/libregraph"
@@ -76,37 +80,91 @@ func commandServe() *cobra.Command {
 
 	cfg := bootstrapConfig
 
-	serveCmd.Flags().StringVar(&cfg.Listen, "listen", envOrDefault("LICOD_LISTEN", defaultListenAddr), fmt.Sprintf("TCP listen address (default \"%s\")", defaultListenAddr))
+	serveCmd.Flags().StringVar(&cfg.Listen, "listen", envOrDefault("LICOD_LISTEN", defaultListenAddr), fmt.Sprintf("TCP listen address, not used with systemd socket activation (default \"%s\")", defaultListenAddr))
+	serveCmd.Flags().BoolVar(&cfg.EnableH2C, "enable-h2c", false, "Enable HTTP/2 over cleartext (h2c) for the listener, for use behind a TLS terminating proxy")
+	serveCmd.Flags().Uint64Var(&cfg.HSTSMaxAgeSeconds, "hsts-max-age", 31536000, "Max age of the Strict-Transport-Security response header, 0 disables the header") // 1 year.
+	serveCmd.Flags().StringVar(&cfg.FrameOptions, "frame-options", "DENY", "Value of the X-Frame-Options response header, empty disables the header")
+	serveCmd.Flags().StringVar(&cfg.ContentSecurityPolicy, "content-security-policy", "frame-ancestors 'none'", "Default value of the Content-Security-Policy response header for responses which do not set their own, empty disables the header")
+	serveCmd.Flags().StringVar(&cfg.ReferrerPolicy, "referrer-policy", "origin", "Default value of the Referrer-Policy response header, empty disables the header")
 	serveCmd.Flags().StringVar(&cfg.Iss, "iss", "", "OIDC issuer URL")
 	serveCmd.Flags().StringArrayVar(&cfg.SigningPrivateKeyFiles, "signing-private-key", listEnvArg("LICOD_SIGNING_PRIVATE_KEY"), "Full path to PEM encoded private key file (must match the --signing-method algorithm)")
 	serveCmd.Flags().StringVar(&cfg.SigningKid, "signing-kid", os.Getenv("LICOD_SIGNING_KID"), "Value of kid field to use in created tokens (uniquely identifying the signing-private-key)")
 	serveCmd.Flags().StringVar(&cfg.ValidationKeysPath, "validation-keys-path", os.Getenv("LICOD_VALIDATION_KEYS_PATH"), "Full path to a folder containing PEM encoded private or public key files used for token validaton (file name without extension is used as kid)")
 	serveCmd.Flags().StringVar(&cfg.EncryptionSecretFile, "encryption-secret", os.Getenv("LICOD_ENCRYPTION_SECRET"), fmt.Sprintf("Full path to a file containing a %d bytes secret key", encryption.KeySize))
-	serveCmd.Flags().StringVar(&cfg.SigningMethod, "signing-method", "PS256", "JWT default signing method")
+	serveCmd.Flags().StringArrayVar(&cfg.EncryptionKeyringFiles, "encryption-keyring", listEnvArg("LICOD_ENCRYPTION_KEYRING"), fmt.Sprintf("Full path to a file containing a %d bytes secret key to seal cookies with key id (can be used multiple times, first is primary, key id is the file name without extension)", encryption.KeySize))
+	serveCmd.Flags().StringVar(&cfg.AdminSecretFile, "admin-secret", os.Getenv("LICOD_ADMIN_SECRET"), "Full path to a file containing a bearer secret of at least 32 bytes which enables the session admin API")
+	serveCmd.Flags().StringVar(&cfg.SigningMethod, "signing-method", "PS256", "JWT default signing method (auto selects the method matching the type of the signing key)")
 	serveCmd.Flags().StringVar(&cfg.URIBasePath, "uri-base-path", "", "Custom base path for URI endpoints")
 	serveCmd.Flags().StringVar(&cfg.SignInURI, "sign-in-uri", "", "Custom redirection URI to sign-in form")
+	serveCmd.Flags().StringVar(&cfg.SignUpURI, "sign-up-uri", "", "Redirection URI to account creation form, used for prompt=create requests")
 	serveCmd.Flags().StringVar(&cfg.SignedOutURI, "signed-out-uri", "", "Custom redirection URI to signed-out goodbye page")
 	serveCmd.Flags().StringVar(&cfg.AuthorizationEndpointURI, "authorization-endpoint-uri", "", "Custom authorization endpoint URI")
 	serveCmd.Flags().StringVar(&cfg.EndsessionEndpointURI, "endsession-endpoint-uri", "", "Custom endsession endpoint URI")
 	serveCmd.Flags().BoolVar(&cfg.IdentifierClientDisabled, "disable-identifier-client", false, "Disable loading the identifier web client")
 	serveCmd.Flags().StringVar(&cfg.IdentifierClientPath, "identifier-client-path", envOrDefault("LICOD_IDENTIFIER_CLIENT_PATH", defaultIdentifierClientPath), fmt.Sprintf("Path to the identifier web client base folder (default \"%s\")", defaultIdentifierClientPath))
 	serveCmd.Flags().StringVar(&cfg.IdentifierRegistrationConf, "identifier-registration-conf", "", "Path to a identifier-registration.yaml configuration file")
+	serveCmd.Flags().Int64Var(&cfg.AuthoritiesFetchMaxSize, "authorities-fetch-max-size", 5*1024*1024, "Maximum size in bytes of discovery documents, key sets and meta data fetched from external authorities") // 5 MiB.
+	serveCmd.Flags().Uint64Var(&cfg.AuthoritiesFetchTimeoutSeconds, "authorities-fetch-timeout", 30, "Timeout in seconds for fetching discovery documents, key sets and meta data from external authorities")
 	serveCmd.Flags().StringVar(&cfg.IdentifierScopesConf, "identifier-scopes-conf", "", "Path to a scopes.yaml configuration file")
 	serveCmd.Flags().StringVar(&cfg.IdentifierDefaultBannerLogo, "identifier-default-banner-logo", "", "Path to a default banner logo that appears on sign-in page.")
 	serveCmd.Flags().StringVar(&cfg.IdentifierDefaultSignInPageText, "identifier-default-sign-in-page-text", "", "Default text that appears at the bottom of the sign-in box.")
 	serveCmd.Flags().StringVar(&cfg.IdentifierDefaultUsernameHintText, "identifier-default-username-hint-text", "", "Default string that shows as the hint in the username textbox on the sign-in screen.")
 	serveCmd.Flags().StringArrayVar(&cfg.IdentifierUILocales, "identifier-ui-locale", nil, "Enabled user interface locales (can be used multiple times, if not set all supported locales are enabled)")
-	serveCmd.Flags().BoolVar(&cfg.Insecure, "insecure", false, "Disable TLS certificate and hostname validation")
+	serveCmd.Flags().StringVar(&cfg.ErrorPageTemplate, "error-page-template", "", "Path to a HTML template file used to render errors which cannot be returned to the client")
+	serveCmd.Flags().StringVar(&cfg.StaticClaimsFile, "static-claims-file", "", "Path to a YAML or JSON file with constant claims added to all issued ID and access tokens, protocol claims cannot be set")
+	serveCmd.Flags().StringVar(&cfg.ClaimTransformsFile, "claim-transforms-file", "", "Path to a YAML or JSON file with per claim transforms (lowercase, uppercase, trim or regex-replace) applied to ID token, access token and userinfo claims, protocol claims cannot be transformed")
+	serveCmd.Flags().StringVar(&cfg.ErrorDocumentationURI, "error-documentation-uri", "", "Base URL of error troubleshooting documentation, used to set error_uri in OAuth2 error responses")
+	serveCmd.Flags().StringVar(&cfg.TenantsConf, "tenants-conf", "", "Path to a tenants.yaml configuration file to serve multiple issuers selected by request host")
+	serveCmd.Flags().BoolVar(&cfg.Insecure, "insecure", false, "Disable TLS certificate and hostname validation and allow http iss")
+	serveCmd.Flags().StringVar(&cfg.TLSClientMinVersion, "tls-client-min-version", "", "Minimum TLS version (1.0, 1.1, 1.2 or 1.3) for outbound TLS client connections, if not set the Go default is used")
+	serveCmd.Flags().StringArrayVar(&cfg.TLSClientCipherSuites, "tls-client-cipher-suite", nil, "Allowed cipher suite name like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 for outbound TLS 1.2 and lower client connections (can be used multiple times, if not set the Go defaults are used)")
+	serveCmd.Flags().StringVar(&cfg.TLSClientCAFile, "tls-client-ca-file", "", "Path to a PEM file with additional CA certificates trusted for outbound TLS client connections")
+	serveCmd.Flags().StringVar(&cfg.HTTPProxy, "http-proxy", "", "Proxy URL for outbound HTTP requests like authority discovery, key set and request_uri fetches, overrides HTTP_PROXY and HTTPS_PROXY environment variables (NO_PROXY is still honored)")
 	serveCmd.Flags().StringArrayVar(&cfg.TrustedProxy, "trusted-proxy", nil, "Trusted proxy IP or IP network (can be used multiple times)")
-	serveCmd.Flags().StringArrayVar(&cfg.AllowScope, "allow-scope", nil, "Allow OAuth 2 scope (can be used multiple times, if not set default scopes are allowed)")
+	serveCmd.Flags().StringArrayVar(&cfg.AllowScope, "allow-scope", nil, "Allow OAuth 2 scope (can be used multiple times, if not set default scopes are allowed, include offline_access to allow refresh tokens)")
+	serveCmd.Flags().StringArrayVar(&cfg.DefaultScope, "default-scope", nil, "Default OAuth 2 scope applied to authorization requests without scope, must be allowed (can be used multiple times, openid is always added)")
+	serveCmd.Flags().StringArrayVar(&cfg.AllowedOrigins, "allowed-origin", nil, "Allowed CORS origin for browser-facing endpoints, supports wildcard subdomains like https://*.example.com (can be used multiple times, if not set all origins are allowed)")
+	serveCmd.Flags().BoolVar(&cfg.AllowClientOrigins, "allow-client-origins", false, "Restrict CORS of the token and userinfo endpoints to the origins of redirect_uris of registered web clients and the --allowed-origin values")
 	serveCmd.Flags().BoolVar(&cfg.AllowClientGuests, "allow-client-guests", false, "Allow sign in of client controlled guest users")
+	serveCmd.Flags().BoolVar(&cfg.ClientScopedGuestSubjects, "client-scoped-guest-subjects", false, "Scope the subjects of guest users to the requesting client, this changes the subjects of existing guests")
 	serveCmd.Flags().BoolVar(&cfg.AllowDynamicClientRegistration, "allow-dynamic-client-registration", false, "Allow dynamic OAuth2 client registration")
+	serveCmd.Flags().IntVar(&cfg.MaxPostLogoutRedirectURIs, "max-post-logout-redirect-uris", 10, "Maximum number of post_logout_redirect_uris accepted for dynamically registered clients (0 means no limit)")
+	serveCmd.Flags().BoolVar(&cfg.AllowNativeImplicit, "allow-native-implicit", false, "Allow dynamically registered native clients to use response types which return tokens from the authorization endpoint")
+	serveCmd.Flags().StringArrayVar(&cfg.AllowedClientSigningAlgs, "allowed-client-signing-alg", nil, "Allowed signing alg for request objects and client assertions (can be used multiple times, if not set all supported algs except none are allowed)")
+	serveCmd.Flags().StringArrayVar(&cfg.AdditionalAudiences, "access-token-audience", nil, "Audience which is added to all access tokens in addition to the client or resource server audience (can be used multiple times)")
+	serveCmd.Flags().BoolVar(&cfg.AllowMultipleAudiences, "allow-multiple-audiences", false, "Issue access tokens with multiple audiences when requested scopes span multiple resource servers of a client instead of rejecting the request")
+	serveCmd.Flags().BoolVar(&cfg.RequirePKCEForPublicClients, "require-pkce-public-clients", true, "Require PKCE with S256 for registered clients without client secret or keys, unless configured otherwise for the client")
+	serveCmd.Flags().StringArrayVar(&cfg.ACRMaxAges, "acr-max-age", nil, "Authentication context class reference value with maximum authentication age in seconds like urn:example:acr:high=300, forcing re-authentication when requested with acr_values (can be used multiple times)")
+	serveCmd.Flags().BoolVar(&cfg.NativeClientHardening, "native-client-hardening", false, "Require native clients to use code flow with PKCE S256, custom scheme or loopback redirect URIs and no client secret")
+	serveCmd.Flags().BoolVar(&cfg.MinimalIDTokenClaims, "minimal-id-token-claims", false, "Only include sub and protocol claims in ID tokens unless other claims are requested with the claims parameter")
+	serveCmd.Flags().BoolVar(&cfg.RememberConsent, "remember-consent", false, "Remember given consent per user and client and skip consent for already approved scopes")
+	serveCmd.Flags().StringVar(&cfg.CookieSameSite, "cookie-samesite", "none", "SameSite attribute of cookies set by the server (one of none, lax or strict, cookies are always Secure)")
+	serveCmd.Flags().StringVar(&cfg.CookieDomain, "cookie-domain", "", "Domain attribute of cookies set by the server (if not set, cookies are host-only)")
+	serveCmd.Flags().Int64Var(&cfg.RequestBodySizeLimit, "request-body-size-limit", 0, "Maximum size in bytes of request bodies accepted by the registration and token endpoints (if not set, per endpoint defaults are used)")
 	serveCmd.Flags().Uint64Var(&cfg.AccessTokenDurationSeconds, "access-token-expiration", 60*10, "Expiration time of access tokens in seconds since generated")                                             // 10 Minutes.
+	serveCmd.Flags().Uint64Var(&cfg.AuthorizationCodeDurationSeconds, "authorization-code-expiration", 60*2, "Expiration time of authorization codes in seconds since generated")                            // 2 Minutes.
 	serveCmd.Flags().Uint64Var(&cfg.IDTokenDurationSeconds, "id-token-expiration", 60*60, "Expiration time of id tokens in seconds since generated")                                                         // 1 Hour.
 	serveCmd.Flags().Uint64Var(&cfg.RefreshTokenDurationSeconds, "refresh-token-expiration", 60*60*24*365*3, "Expiration time of refresh tokens in seconds since generated")                                 // 3 Years.
+	serveCmd.Flags().Uint64Var(&cfg.MaxAccessTokenDurationSeconds, "max-access-token-expiration", 0, "Maximum expiration time of access tokens in seconds, also for client lifetimes")                       // 0 by default -> no maximum.
+	serveCmd.Flags().Uint64Var(&cfg.MaxIDTokenDurationSeconds, "max-id-token-expiration", 0, "Maximum expiration time of id tokens in seconds, also for client lifetimes")                                   // 0 by default -> no maximum.
+	serveCmd.Flags().Uint64Var(&cfg.MaxRefreshTokenDurationSeconds, "max-refresh-token-expiration", 0, "Maximum expiration time of refresh tokens in seconds, also for client lifetimes")                    // 0 by default -> no maximum.
 	serveCmd.Flags().Uint64Var(&cfg.DyamicClientSecretDurationSeconds, "dynamic-client-secret-expiration", 0, "Expiration time of generated dynamic OAuth2 client client_secret in seconds since generated") // 0 by default -> does not expire.
+	serveCmd.Flags().Uint64Var(&cfg.PersistentSessionDurationSeconds, "persistent-session-expiration", 0, "Maximum lifetime in seconds of remember me sign-in sessions, kept in memory only")                // 0 by default -> remember me is disabled.
+	serveCmd.Flags().Uint64Var(&cfg.JwksMaxAgeSeconds, "jwks-max-age", 60*5, "Time in seconds clients are allowed to cache the JWKS endpoint response")                                                      // 5 Minutes, 0 disables caching.
+	serveCmd.Flags().Uint64Var(&cfg.JWTLeewaySeconds, "jwt-leeway", 60, "Leeway in seconds applied to exp, nbf and iat checks when validating JWTs to tolerate clock skew")                                  // 1 Minute, 0 disables leeway.
+	serveCmd.Flags().StringVar(&cfg.SubjectPrefix, "subject-prefix", "", "Prefix added to the subjects of identifier backend users to keep them unique across backends (example: ldap:), changes the sub of all users")
+	serveCmd.Flags().IntVar(&cfg.LogonLockoutAttempts, "logon-lockout-attempts", 0, "Number of failed logons after which a username is locked out (0 disables the lockout)")
+	serveCmd.Flags().Uint64Var(&cfg.LogonLockoutDurationSeconds, "logon-lockout-expiration", 60*15, "Time in seconds failed logons are counted and a username stays locked out") // 15 Minutes.
+	serveCmd.Flags().StringArrayVar(&cfg.LogonLockoutExempt, "logon-lockout-exempt", nil, "Username or glob pattern of usernames which are never locked out, for example service accounts (can be used multiple times)")
+	serveCmd.Flags().IntVar(&cfg.MaxConcurrentSessions, "max-concurrent-sessions", 0, "Maximum number of concurrent active sign-in sessions per user, requires session-idle-timeout (0 disables the limit)")
+	serveCmd.Flags().StringVar(&cfg.SessionLimitPolicy, "session-limit-policy", "reject", "What to do when a user reaches max-concurrent-sessions (one of reject or evict-oldest)")
+	serveCmd.Flags().Uint64Var(&cfg.SessionIdleTimeoutSeconds, "session-idle-timeout", 0, "Time in seconds after which sign-in sessions expire when not used, independent of their maximum lifetime") // 0 by default -> sessions do not expire when idle.
+	serveCmd.Flags().IntVar(&cfg.MaxClaimValues, "max-claim-values", 0, "Maximum number of values of list claims like groups or roles in ID tokens, access tokens and userinfo (0 disables the limit)")
+	serveCmd.Flags().StringVar(&cfg.ClaimLimitPolicy, "claim-limit-policy", "truncate", "What to do when a list claim exceeds max-claim-values (one of truncate, which adds a <claim>_truncated claim, or reject)")
+	serveCmd.Flags().Bool("check", false, "Validate configuration, keys and configuration files and exit without serving")
 	serveCmd.Flags().Bool("log-timestamp", true, "Prefix each log line with timestamp")
 	serveCmd.Flags().String("log-level", "info", "Log level (one of panic, fatal, error, warn, info or debug)")
+	serveCmd.Flags().String("audit-log", "", "Write JSON audit log of logon, token and session events to file (or stdout, stderr)")
 	serveCmd.Flags().Bool("with-pprof", false, "With pprof enabled")
 	serveCmd.Flags().String("pprof-listen", "127.0.0.1:6060", "TCP listen address for pprof")
 	serveCmd.Flags().Bool("with-metrics", false, "Enable metrics")
@@ -126,10 +184,21 @@ func serve(cmd *cobra.Command, args []string) error {
 	}
 	logger.Infoln("serve start")
 
+	check, _ := cmd.Flags().GetBool("check")
+
+	auditLogSink, _ := cmd.Flags().GetString("audit-log")
+	auditLogger, err := newAuditLogger(auditLogSink)
+	if err != nil {
+		return fmt.Errorf("failed to create audit logger: %v", err)
+	}
+	if auditLogger != nil {
+		logger.WithField("sink", auditLogSink).Infoln("audit log enabled")
+	}
+
 	// Metrics support.
 	withMetrics, _ := cmd.Flags().GetBool("with-metrics")
 	metricsListenAddr, _ := cmd.Flags().GetString("metrics-listen")
-	if withMetrics && metricsListenAddr != "" {
+	if withMetrics && metricsListenAddr != "" && !check {
 		go func() {
 			metricsListen := metricsListenAddr
 			handler := http.NewServeMux()
@@ -143,29 +212,48 @@ func serve(cmd *cobra.Command, args []string) error {
 	}
 
 	// Register imported plugable backends.
+	fileBackendSupport.MustRegister()
 	guestBackendSupport.MustRegister()
 	ldapBackendSupport.MustRegister()
 	libreGraphBackendSupport.MustRegister()
 
-	// Boot our setup.
-	bs, err := bootstrap.Boot(ctx, bootstrapConfig, &config.Config{
-		WithMetrics: withMetrics,
-		Logger:      logger,
-	})
-	if err != nil {
-		return err
-	}
+	var serverConfig *server.Config
+	if bootstrapConfig.TenantsConf != "" {
+		// Boot a setup for each tenant.
+		serverConfig, err = bootTenants(ctx, bootstrapConfig, withMetrics, logger, auditLogger)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Boot our setup.
+		bs, err := bootstrap.Boot(ctx, bootstrapConfig, &config.Config{
+			WithMetrics: withMetrics,
+			Logger:      logger,
+			AuditLogger: auditLogger,
+		})
+		if err != nil {
+			return err
+		}
+
+		serverConfig = &server.Config{
+			Config: bs.Config().Config,
 
-	srv, err := server.NewServer(&server.Config{
-		Config: bs.Config().Config,
+			Handler: bs.Managers().Must("handler").(http.Handler),
+			Routes:  []server.WithRoutes{bs.Managers().Must("identity").(server.WithRoutes)},
+			Backend: bs.Managers().Must("identity").(identity.Manager).Name(),
+		}
+	}
 
-		Handler: bs.Managers().Must("handler").(http.Handler),
-		Routes:  []server.WithRoutes{bs.Managers().Must("identity").(server.WithRoutes)},
-	})
+	srv, err := server.NewServer(serverConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %v", err)
 	}
 
+	if check {
+		logger.Infoln("serve check successful")
+		return nil
+	}
+
 	// Profiling support.
 	withPprof, _ := cmd.Flags().GetBool("with-pprof")
 	pprofListenAddr, _ := cmd.Flags().GetString("pprof-listen")
@@ -207,3 +295,46 @@ func serve(cmd *cobra.Command, args []string) error {
 	logger.Infoln("serve started")
 	return srv.Serve(ctx)
 }
+
+func bootTenants(ctx context.Context, settings *bootstrap.Settings, withMetrics bool, logger logrus.FieldLogger, auditLogger audit.Logger) (*server.Config, error) {
+	tenants, err := bootstrap.LoadTenantSettingsFromFile(settings.TenantsConf)
+	if err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants found in tenants-conf")
+	}
+
+	serverConfig := &server.Config{}
+	for _, tenant := range tenants {
+		host, _ := tenant.Host()
+		tenantLogger := logger.WithField("tenant", host)
+		bs, err := bootstrap.Boot(ctx, tenant.Settings(settings), &config.Config{
+			WithMetrics: withMetrics,
+			Logger:      tenantLogger,
+			AuditLogger: auditLogger,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to boot tenant %s: %w", host, err)
+		}
+		if serverConfig.Config == nil {
+			// All tenants share the same listener and server settings.
+			serverConfigConfig := *bs.Config().Config
+			serverConfigConfig.Logger = logger
+			serverConfig.Config = &serverConfigConfig
+		}
+
+		backend := bs.Managers().Must("identity").(identity.Manager).Name()
+		serverConfig.Tenants = append(serverConfig.Tenants, &server.Tenant{
+			Host:    host,
+			Iss:     tenant.Iss,
+			Backend: backend,
+
+			Handler: bs.Managers().Must("handler").(http.Handler),
+			Routes:  []server.WithRoutes{bs.Managers().Must("identity").(server.WithRoutes)},
+		})
+		tenantLogger.WithField("backend", backend).Infoln("tenant ready")
+	}
+
+	return serverConfig, nil
+}
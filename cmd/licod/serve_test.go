@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestRSAKey(t *testing.T, key *rsa.PrivateKey) string {
+	fn := filepath.Join(t.TempDir(), "signing.pem")
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if err := os.WriteFile(fn, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+// generateWeakExponentRSAKey creates a RSA key with public exponent 3, which
+// is rejected by the signer validation.
+func generateWeakExponentRSAKey(t *testing.T) *rsa.PrivateKey {
+	e := big.NewInt(3)
+	one := big.NewInt(1)
+	prime := func() *big.Int {
+		for {
+			p, err := rand.Prime(rand.Reader, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if new(big.Int).Mod(new(big.Int).Sub(p, one), e).Sign() != 0 {
+				return p
+			}
+		}
+	}
+	p, q := prime(), prime()
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: new(big.Int).Mul(p, q),
+			E: 3,
+		},
+		D:      new(big.Int).ModInverse(e, phi),
+		Primes: []*big.Int{p, q},
+	}
+	key.Precompute()
+	return key
+}
+
+func runServeCheck(t *testing.T, signingKeyFile string) error {
+	cmd := commandServe()
+	if err := cmd.ParseFlags([]string{
+		"--check",
+		"--iss=https://localhost",
+		"--signing-private-key=" + signingKeyFile,
+		"--log-level=error",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Args(cmd, []string{"guest"}); err != nil {
+		t.Fatal(err)
+	}
+	return serve(cmd, nil)
+}
+
+func TestServeCheck(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runServeCheck(t, writeTestRSAKey(t, key)); err != nil {
+		t.Fatalf("unexpected error for valid signing key: %v", err)
+	}
+}
+
+func TestServeCheckBadSigningKey(t *testing.T) {
+	key := generateWeakExponentRSAKey(t)
+
+	err := runServeCheck(t, writeTestRSAKey(t, key))
+	if err == nil {
+		t.Fatal("expected error for signing key with weak exponent")
+	}
+	if !strings.Contains(err.Error(), "exponent") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
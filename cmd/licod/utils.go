@@ -34,6 +34,8 @@ func commandUtils() *cobra.Command {
 	}
 
 	jwkCmd.AddCommand(commandJwkFromPem())
+	jwkCmd.AddCommand(commandHashPassword())
+	jwkCmd.AddCommand(commandMintToken())
 
 	return jwkCmd
 }
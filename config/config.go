@@ -20,23 +20,69 @@ package config
 import (
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/audit"
 )
 
 // Config defines a Server's configuration settings.
 type Config struct {
 	ListenAddr string
+	EnableH2C  bool
+
+	HSTSMaxAge            time.Duration
+	FrameOptions          string
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
 
 	WithMetrics bool
 
 	Logger        logrus.FieldLogger
+	AuditLogger   audit.Logger
 	HTTPTransport http.RoundTripper
 
 	TrustedProxyIPs  []*net.IP
 	TrustedProxyNets []*net.IPNet
 
 	AllowedScopes                  []string
+	DefaultScopes                  []string
 	AllowClientGuests              bool
+	ClientScopedGuestSubjects      bool
 	AllowDynamicClientRegistration bool
+	RememberConsent                bool
+	MaxPostLogoutRedirectURIs      int
+	AllowNativeImplicit            bool
+	MinimalIDTokenClaims           bool
+	AllowMultipleAudiences         bool
+	AdditionalAudiences            []string
+	RequirePKCEForPublicClients    bool
+	NativeClientHardening          bool
+	ACRMaxAges                     map[string]time.Duration
+	MaxAccessTokenDuration         time.Duration
+	MaxIDTokenDuration             time.Duration
+	MaxRefreshTokenDuration        time.Duration
+	MaxClaimValues                 int
+	ClaimLimitPolicy               string
+
+	CookieSameSite http.SameSite
+	CookieDomain   string
+
+	RequestBodySizeLimit int64
+
+	AllowedOrigins     []string
+	AllowClientOrigins bool
+
+	AllowedClientSigningAlgs []string
+
+	LogonLockoutAttempts int
+	LogonLockoutDuration time.Duration
+	LogonLockoutExempt   []string
+
+	SubjectPrefix string
+
+	MaxConcurrentSessions int
+	SessionLimitPolicy    string
+	SessionIdleTimeout    time.Duration
 }
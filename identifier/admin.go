@@ -0,0 +1,199 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/authorities"
+	"github.com/libregraph/lico/utils"
+)
+
+// AdminSession is the admin API representation of an active session.
+type AdminSession struct {
+	ID        string `json:"id"`
+	Subject   string `json:"sub"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// AdminSessionsResponse is the admin API response listing active sessions.
+type AdminSessionsResponse struct {
+	Sessions []*AdminSession `json:"sessions"`
+}
+
+// AdminAuthorityKeysResponse is the admin API response listing the key ids
+// of an authority after its keys have been refreshed.
+type AdminAuthorityKeysResponse struct {
+	ID   string   `json:"id"`
+	Kids []string `json:"kids"`
+}
+
+// adminHandler wraps the provided handler to require the configured admin
+// secret as bearer token.
+func (i *Identifier) adminHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		addNoCacheResponseHeaders(rw.Header())
+
+		auth := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+		if len(auth) != 2 || !strings.EqualFold(auth[0], "Bearer") || subtle.ConstantTimeCompare([]byte(auth[1]), i.adminSecret) != 1 {
+			i.logger.Debugln("identifier admin request without valid credentials")
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// handleAdminSessions lists the active sessions of the user identified by
+// the sub query parameter.
+func (i *Identifier) handleAdminSessions(rw http.ResponseWriter, req *http.Request) {
+	sub := req.URL.Query().Get("sub")
+	if sub == "" {
+		http.Error(rw, "missing sub", http.StatusBadRequest)
+		return
+	}
+
+	response := &AdminSessionsResponse{
+		Sessions: make([]*AdminSession, 0),
+	}
+	if i.persistentSessions != nil {
+		for id, session := range i.persistentSessions.list(sub) {
+			response.Sessions = append(response.Sessions, &AdminSession{
+				ID:        id,
+				Subject:   session.sub,
+				CreatedAt: session.createdAt.Unix(),
+				ExpiresAt: session.expiresAt.Unix(),
+			})
+		}
+		sort.Slice(response.Sessions, func(a, b int) bool {
+			return response.Sessions[a].CreatedAt < response.Sessions[b].CreatedAt
+		})
+	}
+
+	err := utils.WriteJSON(rw, http.StatusOK, response, "")
+	if err != nil {
+		i.logger.WithError(err).Errorln("admin sessions request failed writing response")
+	}
+}
+
+// handleAdminSessionRevoke revokes the session identified by the id route
+// variable of the user identified by the sub query parameter and destroys
+// the associated backend session. Logon cookies bound to the revoked session
+// are no longer accepted.
+func (i *Identifier) handleAdminSessionRevoke(rw http.ResponseWriter, req *http.Request) {
+	sub := req.URL.Query().Get("sub")
+	if sub == "" {
+		http.Error(rw, "missing sub", http.StatusBadRequest)
+		return
+	}
+
+	var session *persistentSession
+	found := false
+	if i.persistentSessions != nil {
+		session, found = i.persistentSessions.revokeForSub(mux.Vars(req)["id"], sub)
+	}
+	if !found {
+		http.Error(rw, "session not found", http.StatusNotFound)
+		return
+	}
+
+	if session.sessionRef != nil {
+		ctx := identity.NewSessionDestroyReasonContext(req.Context(), identity.SessionDestroyReasonAdmin)
+		if err := i.backend.DestroySession(ctx, session.sessionRef); err != nil {
+			i.logger.WithError(err).Warnln("identifier admin failed to destroy backend session")
+		}
+	}
+	i.auditLog(req, &audit.Event{
+		Type:    audit.EventTypeSessionDestroyed,
+		Outcome: audit.OutcomeSuccess,
+		Subject: sub,
+		Reason:  string(identity.SessionDestroyReasonAdmin),
+	})
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminConsentRevoke revokes the remembered consent of the user
+// identified by the sub query parameter for the client identified by the
+// client_id route variable, so that the user is asked for consent again.
+func (i *Identifier) handleAdminConsentRevoke(rw http.ResponseWriter, req *http.Request) {
+	sub := req.URL.Query().Get("sub")
+	if sub == "" {
+		http.Error(rw, "missing sub", http.StatusBadRequest)
+		return
+	}
+	if len(i.onRevokeConsentCallbacks) == 0 {
+		http.Error(rw, "consent not found", http.StatusNotFound)
+		return
+	}
+
+	clientID := mux.Vars(req)["client_id"]
+	for _, f := range i.onRevokeConsentCallbacks {
+		if err := f(req.Context(), sub, clientID); err != nil {
+			i.logger.WithError(err).WithField("client_id", clientID).Warnln("identifier admin failed to revoke consent")
+			http.Error(rw, "failed to revoke consent", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminAuthorityJWKSRefresh forces the authority identified by the id
+// route variable to fetch its JWKS immediately and returns the new key ids.
+func (i *Identifier) handleAdminAuthorityJWKSRefresh(rw http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	if i.authorities == nil {
+		http.Error(rw, "authority not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := i.authorities.Get(req.Context(), id); !ok {
+		http.Error(rw, "authority not found", http.StatusNotFound)
+		return
+	}
+
+	kids, err := i.authorities.RefreshValidationKeys(req.Context(), id)
+	if err != nil {
+		i.logger.WithError(err).WithField("id", id).Warnln("identifier admin failed to refresh authority keys")
+		if errors.Is(err, authorities.ErrValidationKeysRefreshNotSupported) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(rw, "failed to refresh authority keys", http.StatusBadGateway)
+		return
+	}
+
+	err = utils.WriteJSON(rw, http.StatusOK, &AdminAuthorityKeysResponse{
+		ID:   id,
+		Kids: kids,
+	}, "")
+	if err != nil {
+		i.logger.WithError(err).Errorln("admin authority keys request failed writing response")
+	}
+}
@@ -0,0 +1,240 @@
+package identifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+)
+
+const testAdminSecret = "0123456789abcdef0123456789abcdef"
+
+type destroyRecordingBackend struct {
+	testBackend
+
+	destroyed []string
+	reasons   []identity.SessionDestroyReason
+}
+
+func (b *destroyRecordingBackend) DestroySession(ctx context.Context, sessionRef *string) error {
+	b.destroyed = append(b.destroyed, *sessionRef)
+	reason, _ := identity.SessionDestroyReasonFromContext(ctx)
+	b.reasons = append(b.reasons, reason)
+	return nil
+}
+
+func TestAdminSessions(t *testing.T) {
+	i := newTestIdentifier(t, time.Hour)
+	backend := &destroyRecordingBackend{}
+	i.backend = backend
+	i.adminSecret = []byte(testAdminSecret)
+
+	router := mux.NewRouter()
+	i.AddRoutes(context.Background(), router)
+
+	sessionRef := "ref1"
+	expiresAt := time.Now().Add(time.Hour)
+	id1, _ := i.persistentSessions.create("user1", &sessionRef, expiresAt)
+	i.persistentSessions.create("user1", nil, expiresAt)
+	i.persistentSessions.create("user2", nil, expiresAt)
+
+	do := func(method string, target string, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	list := func(sub string) []*AdminSession {
+		rr := do(http.MethodGet, "/identifier/_/admin/sessions?sub="+sub, testAdminSecret)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("admin sessions returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var response AdminSessionsResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Sessions
+	}
+
+	if rr := do(http.MethodGet, "/identifier/_/admin/sessions?sub=user1", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("admin sessions without credentials returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := do(http.MethodGet, "/identifier/_/admin/sessions?sub=user1", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("admin sessions with wrong credentials returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	sessions := list("user1")
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions for user1, got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Subject != "user1" || session.ExpiresAt != expiresAt.Unix() {
+			t.Errorf("unexpected session: %+v", session)
+		}
+	}
+
+	// Sessions can only be revoked for the user they belong to.
+	if rr := do(http.MethodDelete, "/identifier/_/admin/sessions/"+id1+"?sub=user2", testAdminSecret); rr.Code != http.StatusNotFound {
+		t.Errorf("admin revoke for other user returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	if rr := do(http.MethodDelete, "/identifier/_/admin/sessions/"+id1+"?sub=user1", testAdminSecret); rr.Code != http.StatusNoContent {
+		t.Errorf("admin revoke returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if len(backend.destroyed) != 1 || backend.destroyed[0] != sessionRef {
+		t.Errorf("expected backend session to be destroyed, got %v", backend.destroyed)
+	}
+
+	sessions = list("user1")
+	if len(sessions) != 1 || sessions[0].ID == id1 {
+		t.Errorf("expected revoked session to be gone, got %v", sessions)
+	}
+	if sessions = list("user2"); len(sessions) != 1 {
+		t.Errorf("expected sessions of other user to be untouched, got %v", sessions)
+	}
+}
+
+func TestSessionDestroyReasons(t *testing.T) {
+	i := newTestIdentifier(t, time.Hour)
+	backend := &destroyRecordingBackend{}
+	i.backend = backend
+	i.adminSecret = []byte(testAdminSecret)
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+
+	router := mux.NewRouter()
+	i.AddRoutes(context.Background(), router)
+
+	readEvent := func() *audit.Event {
+		var event audit.Event
+		if err := json.NewDecoder(&buf).Decode(&event); err != nil {
+			t.Fatalf("failed to decode audit event: %v", err)
+		}
+		return &event
+	}
+
+	// User initiated logoff.
+	logonRef := "ref-logoff"
+	rr := httptest.NewRecorder()
+	if err := i.SetUserToLogonCookie(context.Background(), rr, &IdentifiedUser{
+		sub:        "user1",
+		username:   "user1",
+		backend:    i.backend,
+		logonAt:    time.Now(),
+		sessionRef: &logonRef,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/identifier/_/logoff", bytes.NewBufferString(`{"state":"state"}`))
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rr = httptest.NewRecorder()
+	i.handleLogoff(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("logoff returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if event := readEvent(); event.Type != audit.EventTypeSessionDestroyed || event.Reason != string(identity.SessionDestroyReasonLogoff) {
+		t.Errorf("unexpected audit event for logoff: %+v", event)
+	}
+
+	// Admin revoke.
+	adminRef := "ref-admin"
+	id, _ := i.persistentSessions.create("user2", &adminRef, time.Now().Add(time.Hour))
+	req = httptest.NewRequest(http.MethodDelete, "/identifier/_/admin/sessions/"+id+"?sub=user2", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminSecret)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("admin revoke returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if event := readEvent(); event.Type != audit.EventTypeSessionDestroyed || event.Reason != string(identity.SessionDestroyReasonAdmin) || event.Subject != "user2" {
+		t.Errorf("unexpected audit event for admin revoke: %+v", event)
+	}
+
+	expected := []identity.SessionDestroyReason{identity.SessionDestroyReasonLogoff, identity.SessionDestroyReasonAdmin}
+	if len(backend.reasons) != len(expected) || backend.reasons[0] != expected[0] || backend.reasons[1] != expected[1] {
+		t.Errorf("expected backend to receive reasons %v, got %v", expected, backend.reasons)
+	}
+}
+
+func TestAdminSessionRevokeEndsLogon(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIdentifier(t, time.Hour)
+	i.adminSecret = []byte(testAdminSecret)
+
+	router := mux.NewRouter()
+	i.AddRoutes(ctx, router)
+
+	// Logon with remember me, like the logon handler.
+	rr := httptest.NewRecorder()
+	user := &IdentifiedUser{
+		sub:      "user1",
+		username: "user1",
+		backend:  i.backend,
+		logonAt:  time.Now(),
+	}
+	if err := i.SetUserToPersistentCookie(ctx, rr, httptest.NewRequest(http.MethodPost, "/identifier/_/logon", nil), user); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.SetUserToLogonCookie(ctx, rr, user); err != nil {
+		t.Fatal(err)
+	}
+	logonCookie := findCookie(rr.Result().Cookies(), i.logonCookieName)
+	persistentCookie := findCookie(rr.Result().Cookies(), persistentCookieName)
+
+	// Resume with the persistent cookie only, which sets a new logon cookie.
+	rr = httptest.NewRecorder()
+	if resumed, err := i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{persistentCookie}), 0); err != nil || resumed == nil {
+		t.Fatalf("expected persistent session to resume: %v", err)
+	}
+	resumedLogonCookie := findCookie(rr.Result().Cookies(), i.logonCookieName)
+	if resumedLogonCookie == nil {
+		t.Fatal("logon cookie not set on resume")
+	}
+	persistentCookie = findCookie(rr.Result().Cookies(), persistentCookieName)
+
+	for _, cookie := range []*http.Cookie{logonCookie, resumedLogonCookie} {
+		if u, err := i.GetUserFromLogonCookie(ctx, httptest.NewRecorder(), newRequestWithCookies([]*http.Cookie{cookie}), 0, true); err != nil || u == nil {
+			t.Fatalf("expected logon cookie to be valid before revoke: %v", err)
+		}
+	}
+
+	sessions := i.persistentSessions.list("user1")
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions))
+	}
+	for id := range sessions {
+		req := httptest.NewRequest(http.MethodDelete, "/identifier/_/admin/sessions/"+id+"?sub=user1", nil)
+		req.Header.Set("Authorization", "Bearer "+testAdminSecret)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("admin revoke returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+		}
+	}
+
+	// Authorize looks up the logon cookie first and then tries to resume the
+	// persistent session, both must require a new logon now.
+	req := newRequestWithCookies([]*http.Cookie{logonCookie, persistentCookie})
+	if u, err := i.GetUserFromLogonCookie(ctx, httptest.NewRecorder(), req, 0, true); err != nil || u != nil {
+		t.Errorf("expected logon cookie of revoked session to be ignored: %v %v", u, err)
+	}
+	if u, err := i.GetUserFromLogonCookie(ctx, httptest.NewRecorder(), newRequestWithCookies([]*http.Cookie{resumedLogonCookie}), 0, true); err != nil || u != nil {
+		t.Errorf("expected resumed logon cookie of revoked session to be ignored: %v %v", u, err)
+	}
+	if u, err := i.GetUserFromPersistentCookie(ctx, httptest.NewRecorder(), req, 0); err != nil || u != nil {
+		t.Errorf("expected revoked persistent session not to resume: %v %v", u, err)
+	}
+}
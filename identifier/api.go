@@ -52,6 +52,7 @@ func (i Identifier) writeHelloResponse(rw http.ResponseWriter, req *http.Request
 			SignInPageText:   i.Config.DefaultSignInPageText,
 			Locales:          i.Config.UILocales,
 		},
+		RememberMe: i.persistentSessions != nil,
 	}
 
 handleHelloLoop:
@@ -77,11 +78,18 @@ handleHelloLoop:
 
 		if identifiedUser == nil {
 			// Check if logged in via cookie.
-			identifiedUser, err = i.GetUserFromLogonCookie(req.Context(), req, r.MaxAge, true)
+			identifiedUser, err = i.GetUserFromLogonCookie(req.Context(), rw, req, r.MaxAge, true)
 			if err != nil {
 				i.logger.WithError(err).Debugln("identifier failed to decode logon cookie in hello")
 			}
 		}
+		if identifiedUser == nil {
+			// Check if remembered via persistent session cookie.
+			identifiedUser, err = i.GetUserFromPersistentCookie(req.Context(), rw, req, r.MaxAge)
+			if err != nil {
+				i.logger.WithError(err).Debugln("identifier failed to decode persistent cookie in hello")
+			}
+		}
 
 		if identifiedUser != nil {
 			response.Username = identifiedUser.Username()
@@ -0,0 +1,79 @@
+package identifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libregraph/lico/audit"
+)
+
+func logonWithAudit(t *testing.T, username string, password string) (*httptest.ResponseRecorder, map[string]interface{}, string) {
+	i := newTestIdentifier(t, time.Duration(0))
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+
+	body, _ := json.Marshal(&LogonRequest{
+		State:  "state",
+		Params: []string{username, password, ModeLogonUsernamePassword},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/identifier/_/logon", bytes.NewReader(body))
+	req.RemoteAddr = "192.0.2.10:4711"
+	rr := httptest.NewRecorder()
+	i.handleLogon(rr, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected exactly one audit record, got %d: %s", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("audit record is not valid JSON: %v", err)
+	}
+
+	return rr, record, buf.String()
+}
+
+func TestLogonAuditSuccess(t *testing.T) {
+	rr, record, raw := logonWithAudit(t, "user1", testPassword)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected logon status: %d", rr.Code)
+	}
+	if record["event"] != audit.EventTypeLogon || record["outcome"] != audit.OutcomeSuccess {
+		t.Errorf("unexpected audit record: %v", record)
+	}
+	if record["username"] != "user1" || record["sub"] != "user1" {
+		t.Errorf("audit record has wrong user: %v", record)
+	}
+	if record["remote_addr"] != "192.0.2.10" {
+		t.Errorf("audit record has wrong remote_addr: %v", record["remote_addr"])
+	}
+	if _, ok := record["time"]; !ok {
+		t.Errorf("audit record has no time: %v", record)
+	}
+	if strings.Contains(raw, testPassword) {
+		t.Errorf("audit record contains password: %s", raw)
+	}
+}
+
+func TestLogonAuditFailure(t *testing.T) {
+	rr, record, raw := logonWithAudit(t, "user1", "wrong-password")
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("unexpected logon status: %d", rr.Code)
+	}
+	if record["event"] != audit.EventTypeLogon || record["outcome"] != audit.OutcomeFailure {
+		t.Errorf("unexpected audit record: %v", record)
+	}
+	if record["username"] != "user1" {
+		t.Errorf("audit record has wrong username: %v", record)
+	}
+	if strings.Contains(raw, "wrong-password") {
+		t.Errorf("audit record contains password: %s", raw)
+	}
+}
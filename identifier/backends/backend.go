@@ -44,6 +44,13 @@ type Backend interface {
 	Name() string
 }
 
+// BackendWithClaimScopes is a Backend which provides claims with configurable
+// names which belong to a scope.
+type BackendWithClaimScopes interface {
+	Backend
+	ClaimScopes() map[string]string
+}
+
 // UserFromBackend are users as provided by backends which can have additional
 // claims together with a user name.
 type UserFromBackend interface {
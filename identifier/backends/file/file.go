@@ -0,0 +1,257 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/libregraph/oidc-go"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identifier/backends"
+	"github.com/libregraph/lico/identifier/meta/scopes"
+)
+
+const fileIdentifierBackendName = "identifier-file"
+
+var fileSupportedScopes = []string{
+	oidc.ScopeProfile,
+	oidc.ScopeEmail,
+}
+
+// UserRecord is a user entry of a users file.
+type UserRecord struct {
+	ID            string `yaml:"id"`
+	Password      string `yaml:"password"`
+	Name          string `yaml:"name"`
+	GivenName     string `yaml:"given_name"`
+	FamilyName    string `yaml:"family_name"`
+	Email         string `yaml:"email"`
+	EmailVerified bool   `yaml:"email_verified"`
+}
+
+type usersData struct {
+	Users map[string]*UserRecord `yaml:"users"`
+}
+
+type fileUser struct {
+	username string
+	record   *UserRecord
+}
+
+func (u *fileUser) Subject() string {
+	return u.record.ID
+}
+
+func (u *fileUser) Email() string {
+	return u.record.Email
+}
+
+func (u *fileUser) EmailVerified() bool {
+	return u.record.EmailVerified
+}
+
+func (u *fileUser) Name() string {
+	return u.record.Name
+}
+
+func (u *fileUser) FamilyName() string {
+	return u.record.FamilyName
+}
+
+func (u *fileUser) GivenName() string {
+	return u.record.GivenName
+}
+
+func (u *fileUser) Username() string {
+	return u.username
+}
+
+func (u *fileUser) BackendClaims() map[string]interface{} {
+	claims := make(map[string]interface{})
+	claims[konnect.IdentifiedUserIDClaim] = u.record.ID
+
+	return claims
+}
+
+func (u *fileUser) BackendScopes() []string {
+	return nil
+}
+
+func (u *fileUser) RequiredScopes() []string {
+	return nil
+}
+
+// FileIdentifierBackend is a backend for the Identifier which authenticates
+// users from a YAML users file containing password hashes.
+type FileIdentifierBackend struct {
+	users map[string]*fileUser
+	byID  map[string]*fileUser
+
+	// dummyPasswordHash is verified for unknown users, so that logons take
+	// about the same time whether the user exists or not.
+	dummyPasswordHash string
+
+	logger logrus.FieldLogger
+}
+
+// NewFileIdentifierBackend creates a new FileIdentifierBackend with the users
+// loaded from the provided file. The file maps user names to their password
+// hash and meta data. Users without id use their user name as id.
+func NewFileIdentifierBackend(c *config.Config, fn string) (*FileIdentifierBackend, error) {
+	if fn == "" {
+		return nil, fmt.Errorf("file identifier backend users file must not be empty")
+	}
+
+	usersFile, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("file identifier backend failed to read users file: %w", err)
+	}
+
+	data := &usersData{}
+	err = yaml.Unmarshal(usersFile, data)
+	if err != nil {
+		return nil, fmt.Errorf("file identifier backend failed to parse users file: %w", err)
+	}
+
+	b := &FileIdentifierBackend{
+		users: make(map[string]*fileUser),
+		byID:  make(map[string]*fileUser),
+
+		logger: c.Logger,
+	}
+	for username, record := range data.Users {
+		if username == "" || record == nil || record.Password == "" {
+			return nil, fmt.Errorf("file identifier backend invalid users file entry: %v", username)
+		}
+		if record.ID == "" {
+			record.ID = username
+		}
+		if _, exists := b.byID[record.ID]; exists {
+			return nil, fmt.Errorf("file identifier backend duplicate user id: %v", record.ID)
+		}
+		user := &fileUser{
+			username: username,
+			record:   record,
+		}
+		b.users[username] = user
+		b.byID[record.ID] = user
+	}
+
+	b.dummyPasswordHash, err = HashPassword(PasswordSchemeArgon2id, "dummy")
+	if err != nil {
+		return nil, fmt.Errorf("file identifier backend failed to create dummy password hash: %w", err)
+	}
+
+	b.logger.WithField("users", len(b.users)).Infoln("file identifier backend set up")
+
+	return b, nil
+}
+
+// RunWithContext implements the Backend interface.
+func (b *FileIdentifierBackend) RunWithContext(ctx context.Context) error {
+	return nil
+}
+
+// Logon implements the Backend interface, enabling Logon with user name and
+// password as provided.
+func (b *FileIdentifierBackend) Logon(ctx context.Context, audience, username, password string) (bool, *string, *string, backends.UserFromBackend, error) {
+	user, ok := b.users[username]
+	if !ok {
+		// Verify anyway to not reveal that the user does not exist.
+		VerifyPassword(b.dummyPasswordHash, password)
+		return false, nil, nil, nil, nil
+	}
+
+	valid, err := VerifyPassword(user.record.Password, password)
+	if err != nil {
+		return false, nil, nil, nil, fmt.Errorf("file identifier backend logon error: %v", err)
+	}
+	if !valid {
+		return false, nil, nil, nil, nil
+	}
+
+	// Use the users subject as user id.
+	userID := user.Subject()
+
+	b.logger.WithFields(logrus.Fields{
+		"username": user.Username(),
+		"id":       userID,
+	}).Debugln("file identifier backend logon")
+
+	return true, &userID, nil, user, nil
+}
+
+// ResolveUserByUsername implements the Beckend interface, providing lookup for
+// user by providing the username.
+func (b *FileIdentifierBackend) ResolveUserByUsername(ctx context.Context, username string) (backends.UserFromBackend, error) {
+	user, ok := b.users[username]
+	if !ok {
+		return nil, nil
+	}
+
+	return user, nil
+}
+
+// GetUser implements the Backend interface, providing user meta data retrieval
+// for the user specified by the userID.
+func (b *FileIdentifierBackend) GetUser(ctx context.Context, userID string, sessionRef *string, requestedScopes map[string]bool) (backends.UserFromBackend, error) {
+	user, ok := b.byID[userID]
+	if !ok {
+		return nil, fmt.Errorf("file identifier backend get user error: no such user")
+	}
+
+	return user, nil
+}
+
+// RefreshSession implements the Backend interface.
+func (b *FileIdentifierBackend) RefreshSession(ctx context.Context, userID string, sessionRef *string, claims map[string]interface{}) error {
+	return nil
+}
+
+// DestroySession implements the Backend interface.
+func (b *FileIdentifierBackend) DestroySession(ctx context.Context, sessionRef *string) error {
+	return nil
+}
+
+// UserClaims implements the Backend interface, providing user specific claims
+// for the user specified by the userID.
+func (b *FileIdentifierBackend) UserClaims(userID string, authorizedScopes map[string]bool) map[string]interface{} {
+	return nil
+}
+
+// ScopesSupported implements the Backend interface, providing supported scopes
+// when running this backend.
+func (b *FileIdentifierBackend) ScopesSupported() []string {
+	return fileSupportedScopes
+}
+
+// ScopesMeta implements the Backend interface, providing meta data for
+// supported scopes.
+func (b *FileIdentifierBackend) ScopesMeta() *scopes.Scopes {
+	return nil
+}
+
+// Name implements the Backend interface.
+func (b *FileIdentifierBackend) Name() string {
+	return fileIdentifierBackendName
+}
@@ -0,0 +1,99 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestFileIdentifierBackendLogon(t *testing.T) {
+	hash, err := HashPassword(PasswordSchemeBcrypt, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn := filepath.Join(t.TempDir(), "users.yaml")
+	if err = os.WriteFile(fn, []byte(`users:
+  alice:
+    id: "1000"
+    password: "`+hash+`"
+    name: Alice Example
+    email: alice@example.com
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewFileIdentifierBackend(&config.Config{Logger: logrus.New()}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	success, userID, _, user, err := b.Logon(ctx, "", "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success || *userID != "1000" || user.Username() != "alice" {
+		t.Errorf("unexpected logon result: %v %v %v", success, userID, user)
+	}
+
+	success, _, _, _, err = b.Logon(ctx, "", "alice", "wrong")
+	if err != nil || success {
+		t.Errorf("logon with wrong password must fail without error: %v %v", success, err)
+	}
+
+	success, _, _, _, err = b.Logon(ctx, "", "bob", "secret")
+	if err != nil || success {
+		t.Errorf("logon with unknown user must fail without error: %v %v", success, err)
+	}
+
+	user, err = b.GetUser(ctx, "1000", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email := user.(*fileUser).Email(); email != "alice@example.com" {
+		t.Errorf("unexpected user email: %v", email)
+	}
+
+	user, err = b.ResolveUserByUsername(ctx, "alice")
+	if err != nil || user == nil || user.Subject() != "1000" {
+		t.Errorf("unexpected resolve result: %v %v", user, err)
+	}
+}
+
+func TestFileIdentifierBackendLogonUnknownUserTiming(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(fn, []byte("users: {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewFileIdentifierBackend(&config.Config{Logger: logrus.New()}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	if _, err = VerifyPassword(b.dummyPasswordHash, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	verifyDuration := time.Since(started)
+
+	// Logons of unknown users verify the dummy hash, so they take about as
+	// long as logons of existing users.
+	started = time.Now()
+	success, _, _, _, err := b.Logon(context.Background(), "", "bob", "secret")
+	logonDuration := time.Since(started)
+	if err != nil || success {
+		t.Fatalf("logon with unknown user must fail without error: %v %v", success, err)
+	}
+	if logonDuration < verifyDuration/4 {
+		t.Errorf("logon of unknown user took %v, expected about %v", logonDuration, verifyDuration)
+	}
+}
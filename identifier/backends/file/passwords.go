@@ -0,0 +1,145 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package file
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hashing schemes.
+const (
+	PasswordSchemeBcrypt   = "bcrypt"
+	PasswordSchemeArgon2id = "argon2id"
+)
+
+// Argon2id parameters used when creating new hashes. Verification uses the
+// parameters encoded in the hash.
+const (
+	argon2idTime    = 3
+	argon2idMemory  = 64 * 1024
+	argon2idThreads = 2
+	argon2idKeyLen  = 32
+	argon2idSaltLen = 16
+)
+
+// argon2idMaxMemory is the largest memory parameter in KiB accepted when
+// verifying argon2id hashes, to not exhaust memory with a crafted hash.
+const argon2idMaxMemory = 1024 * 1024
+
+var argon2idEncoding = base64.RawStdEncoding
+
+// HashPassword creates a new hash of the provided password using the provided
+// scheme. The result is encoded in the usual modular crypt format, so it
+// can be stored in a users file as is.
+func HashPassword(scheme string, password string) (string, error) {
+	switch scheme {
+	case PasswordSchemeBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+
+	case PasswordSchemeArgon2id:
+		salt := make([]byte, argon2idSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			argon2idMemory, argon2idTime, argon2idThreads,
+			argon2idEncoding.EncodeToString(salt),
+			argon2idEncoding.EncodeToString(key),
+		), nil
+
+	default:
+		return "", fmt.Errorf("unsupported password scheme: %v", scheme)
+	}
+}
+
+// VerifyPassword checks if the provided password matches the provided hash.
+// It returns false without error if the password does not match and an error
+// if the hash cannot be used.
+func VerifyPassword(hash string, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2id(hash, password)
+
+	default:
+		return false, fmt.Errorf("unsupported password hash")
+	}
+}
+
+func verifyArgon2id(hash string, password string) (bool, error) {
+	// Format is $argon2id$v=19$m=65536,t=3,p=2$salt$key.
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, fmt.Errorf("invalid argon2id hash version: %w", err)
+	}
+	if version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2id hash version: %d", version)
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2id hash parameters: %w", err)
+	}
+	if time < 1 || threads < 1 || memory < 8*uint32(threads) || memory > argon2idMaxMemory {
+		return false, fmt.Errorf("invalid argon2id hash parameters: m=%d,t=%d,p=%d", memory, time, threads)
+	}
+
+	salt, err := argon2idEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id hash salt: %w", err)
+	}
+	key, err := argon2idEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid argon2id hash key: %w", err)
+	}
+	if len(key) == 0 {
+		return false, fmt.Errorf("invalid argon2id hash key length")
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
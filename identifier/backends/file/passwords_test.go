@@ -0,0 +1,49 @@
+package file
+
+import (
+	"testing"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	for _, scheme := range []string{PasswordSchemeBcrypt, PasswordSchemeArgon2id} {
+		t.Run(scheme, func(t *testing.T) {
+			hash, err := HashPassword(scheme, "correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			valid, err := VerifyPassword(hash, "correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valid {
+				t.Errorf("correct password not accepted for %v hash", scheme)
+			}
+
+			valid, err = VerifyPassword(hash, "battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid {
+				t.Errorf("incorrect password accepted for %v hash", scheme)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordInvalidHash(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2id$v=19$m=65536,t=3,p=2$broken",
+		"$argon2id$v=18$m=65536,t=3,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=65536,t=0,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=65536,t=3,p=0$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8,t=3,p=2$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4194304,t=3,p=2$c2FsdA$a2V5",
+	} {
+		if _, err := VerifyPassword(hash, "password"); err == nil {
+			t.Errorf("expected error for invalid hash %q", hash)
+		}
+	}
+}
@@ -30,7 +30,21 @@ const (
 
 // Additional mappable virtual attributes.
 const (
-	AttributeNumericUID = "konnectNumericID"
+	AttributeNumericUID  = "konnectNumericID"
+	AttributePhoneNumber = "konnectPhoneNumber"
+
+	AttributePreferredUsername = "konnectPreferredUsername"
+
+	AttributeLocale   = "konnectLocale"
+	AttributeZoneinfo = "konnectZoneinfo"
+
+	AttributeGroups = "konnectGroups"
+
+	AttributeStreetAddress = "konnectStreetAddress"
+	AttributeLocality      = "konnectLocality"
+	AttributeRegion        = "konnectRegion"
+	AttributePostalCode    = "konnectPostalCode"
+	AttributeCountry       = "konnectCountry"
 )
 
 // Define our known LDAP attribute value types.
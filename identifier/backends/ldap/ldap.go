@@ -38,6 +38,7 @@ import (
 	"github.com/libregraph/lico/config"
 	"github.com/libregraph/lico/identifier/backends"
 	"github.com/libregraph/lico/identifier/meta/scopes"
+	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
 const ldapIdentifierBackendName = "identifier-ldap"
@@ -53,6 +54,7 @@ var ldapSupportedScopes = []string{
 type LDAPIdentifierBackend struct {
 	addr         string
 	isTLS        bool
+	startTLS     bool
 	bindDN       string
 	bindPassword string
 
@@ -69,8 +71,12 @@ type LDAPIdentifierBackend struct {
 	dialer    *net.Dialer
 	tlsConfig *tls.Config
 
+	rolesClaim   string
+	roleMappings []*RoleMapping
+
 	timeout int
 	limiter *rate.Limiter
+	pool    *ldapConnPool
 }
 
 type ldapAttributeMapping map[string]string
@@ -101,12 +107,17 @@ type ldapUser struct {
 	entryID string
 	id      int64
 	data    ldapAttributeMapping
+	groups  []string
+
+	rolesClaim string
+	roles      []string
 }
 
 func newLdapUser(entryID string, mapping ldapAttributeMapping, entry *ldap.Entry) (*ldapUser, error) {
 	// Go through all returned attributes, add them to the local data set if
 	// we know them in the mapping.
 	var id int64
+	var groups []string
 	data := make(ldapAttributeMapping)
 	for _, attribute := range entry.Attributes {
 		if len(attribute.Values) == 0 {
@@ -116,6 +127,12 @@ func newLdapUser(entryID string, mapping ldapAttributeMapping, entry *ldap.Entry
 			// LDAP attribute descriptors / short names are case insensitive. See
 			// https://tools.ietf.org/html/rfc4512#page-4.
 			if strings.ToLower(attribute.Name) == strings.ToLower(mapped) {
+				if n == AttributeGroups {
+					// Groups are multi valued, keep all values.
+					groups = append(groups, attribute.Values...)
+					continue
+				}
+
 				// Check if we need conversion.
 				switch mapping[fmt.Sprintf("%s_type", n)] {
 				case AttributeValueTypeBinary:
@@ -146,6 +163,7 @@ func newLdapUser(entryID string, mapping ldapAttributeMapping, entry *ldap.Entry
 		entryID: entryID,
 		id:      id,
 		data:    data,
+		groups:  groups,
 	}, nil
 }
 
@@ -169,6 +187,29 @@ func (u *ldapUser) EmailVerified() bool {
 	return false
 }
 
+func (u *ldapUser) PhoneNumber() string {
+	return u.getAttributeValue(AttributePhoneNumber)
+}
+
+func (u *ldapUser) PhoneNumberVerified() bool {
+	return false
+}
+
+func (u *ldapUser) Address() *konnectoidc.Address {
+	address := &konnectoidc.Address{
+		StreetAddress: u.getAttributeValue(AttributeStreetAddress),
+		Locality:      u.getAttributeValue(AttributeLocality),
+		Region:        u.getAttributeValue(AttributeRegion),
+		PostalCode:    u.getAttributeValue(AttributePostalCode),
+		Country:       u.getAttributeValue(AttributeCountry),
+	}
+	if address.IsEmpty() {
+		return nil
+	}
+
+	return address
+}
+
 func (u *ldapUser) Name() string {
 	return u.getAttributeValue(AttributeName)
 }
@@ -181,10 +222,22 @@ func (u *ldapUser) GivenName() string {
 	return u.getAttributeValue(AttributeGivenName)
 }
 
+func (u *ldapUser) Locale() string {
+	return u.getAttributeValue(AttributeLocale)
+}
+
+func (u *ldapUser) Zoneinfo() string {
+	return u.getAttributeValue(AttributeZoneinfo)
+}
+
 func (u *ldapUser) Username() string {
 	return u.getAttributeValue(AttributeLogin)
 }
 
+func (u *ldapUser) PreferredUsername() string {
+	return u.getAttributeValue(AttributePreferredUsername)
+}
+
 func (u *ldapUser) ID() int64 {
 	return u.id
 }
@@ -196,6 +249,17 @@ func (u *ldapUser) UniqueID() string {
 func (u *ldapUser) BackendClaims() map[string]interface{} {
 	claims := make(map[string]interface{})
 	claims[konnect.IdentifiedUserIDClaim] = u.entryID
+	if len(u.groups) > 0 {
+		// Inject groups and roles as extra claims, those are only returned
+		// when the groups respectively the roles scope is authorized.
+		extraClaims := map[string]interface{}{
+			konnectoidc.GroupsClaim: u.groups,
+		}
+		if len(u.roles) > 0 {
+			extraClaims[u.rolesClaim] = u.roles
+		}
+		claims[konnect.InternalExtraAccessTokenClaimsClaim] = extraClaims
+	}
 
 	return claims
 }
@@ -213,6 +277,7 @@ func (u *ldapUser) RequiredScopes() []string {
 func NewLDAPIdentifierBackend(
 	c *config.Config,
 	tlsConfig *tls.Config,
+	startTLS bool,
 	uriString,
 	bindDN,
 	bindPassword,
@@ -221,6 +286,10 @@ func NewLDAPIdentifierBackend(
 	filter string,
 	subAttributes []string,
 	mappedAttributes map[string]string,
+	poolSize int,
+	poolIdleTimeout time.Duration,
+	rolesClaim string,
+	roleMappings []*RoleMapping,
 ) (*LDAPIdentifierBackend, error) {
 	var err error
 	var scope int
@@ -282,6 +351,55 @@ func NewLDAPIdentifierBackend(
 		attributeMapping[AttributeNumericUID] = numericUIDAttribute
 		c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", AttributeNumericUID, numericUIDAttribute)).Debugln("ldap identifier backend use attribute")
 	}
+	if phoneNumberAttribute := mappedAttributes[AttributePhoneNumber]; phoneNumberAttribute != "" {
+		supportedScopes = append(supportedScopes, konnectoidc.ScopePhone)
+		attributeMapping[AttributePhoneNumber] = phoneNumberAttribute
+		c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", AttributePhoneNumber, phoneNumberAttribute)).Debugln("ldap identifier backend use attribute")
+	}
+	if preferredUsernameAttribute := mappedAttributes[AttributePreferredUsername]; preferredUsernameAttribute != "" {
+		attributeMapping[AttributePreferredUsername] = preferredUsernameAttribute
+		c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", AttributePreferredUsername, preferredUsernameAttribute)).Debugln("ldap identifier backend use attribute")
+	}
+	for _, n := range []string{AttributeLocale, AttributeZoneinfo} {
+		if localeAttribute := mappedAttributes[n]; localeAttribute != "" {
+			attributeMapping[n] = localeAttribute
+			c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", n, localeAttribute)).Debugln("ldap identifier backend use attribute")
+		}
+	}
+	if groupsAttribute := mappedAttributes[AttributeGroups]; groupsAttribute != "" {
+		supportedScopes = append(supportedScopes, konnectoidc.ScopeGroups)
+		attributeMapping[AttributeGroups] = groupsAttribute
+		c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", AttributeGroups, groupsAttribute)).Debugln("ldap identifier backend use attribute")
+	}
+	if len(roleMappings) > 0 {
+		if attributeMapping[AttributeGroups] == "" {
+			return nil, fmt.Errorf("ldap identifier backend role mappings require a groups attribute")
+		}
+		for _, rm := range roleMappings {
+			if err = rm.initialize(); err != nil {
+				return nil, fmt.Errorf("ldap identifier backend invalid role mapping: %v", err)
+			}
+		}
+		if rolesClaim == "" {
+			rolesClaim = konnectoidc.RolesClaim
+		}
+		supportedScopes = append(supportedScopes, konnectoidc.ScopeRoles)
+		c.Logger.WithFields(logrus.Fields{
+			"claim":    rolesClaim,
+			"mappings": len(roleMappings),
+		}).Debugln("ldap identifier backend use role mappings")
+	}
+	withAddress := false
+	for _, n := range []string{AttributeStreetAddress, AttributeLocality, AttributeRegion, AttributePostalCode, AttributeCountry} {
+		if addressAttribute := mappedAttributes[n]; addressAttribute != "" {
+			withAddress = true
+			attributeMapping[n] = addressAttribute
+			c.Logger.WithField("attribute", fmt.Sprintf("%v:%v", n, addressAttribute)).Debugln("ldap identifier backend use attribute")
+		}
+	}
+	if withAddress {
+		supportedScopes = append(supportedScopes, konnectoidc.ScopeAddress)
+	}
 
 	if filter == "" {
 		filter = "(objectClass=inetOrgPerson)"
@@ -301,7 +419,19 @@ func NewLDAPIdentifierBackend(
 		if uri.Port() == "" {
 			addr += ":389"
 		}
+		if startTLS {
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if !tlsConfig.InsecureSkipVerify && tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = uri.Hostname()
+			}
+		}
 	case "ldaps":
+		if startTLS {
+			err = fmt.Errorf("starttls cannot be used with ldaps URI scheme")
+			break
+		}
 		if uri.Port() == "" {
 			addr += ":636"
 		}
@@ -328,6 +458,7 @@ func NewLDAPIdentifierBackend(
 	b := &LDAPIdentifierBackend{
 		addr:         addr,
 		isTLS:        isTLS,
+		startTLS:     startTLS,
 		bindDN:       bindDN,
 		bindPassword: bindPassword,
 		baseDN:       baseDN,
@@ -339,6 +470,9 @@ func NewLDAPIdentifierBackend(
 		attributeMapping: attributeMapping,
 		supportedScopes:  supportedScopes,
 
+		rolesClaim:   rolesClaim,
+		roleMappings: roleMappings,
+
 		logger: c.Logger,
 		dialer: &net.Dialer{
 			Timeout:   ldap.DefaultTimeout,
@@ -348,15 +482,25 @@ func NewLDAPIdentifierBackend(
 
 		timeout: 60,                        //XXX(longsleep): make timeout configuration.
 		limiter: rate.NewLimiter(100, 200), //XXX(longsleep): make rate limits configuration.
+		pool:    newLDAPConnPool(poolSize, poolIdleTimeout),
 	}
 
-	b.logger.WithField("ldap", fmt.Sprintf("%s://%s ", uri.Scheme, addr)).Infoln("ldap server identifier backend set up")
+	b.logger.WithFields(logrus.Fields{
+		"ldap":      fmt.Sprintf("%s://%s ", uri.Scheme, addr),
+		"starttls":  startTLS,
+		"pool_size": poolSize,
+	}).Infoln("ldap server identifier backend set up")
 
 	return b, nil
 }
 
 // RunWithContext implements the Backend interface.
 func (b *LDAPIdentifierBackend) RunWithContext(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		b.pool.close()
+	}()
+
 	return nil
 }
 
@@ -368,11 +512,13 @@ func (b *LDAPIdentifierBackend) Logon(ctx context.Context, audience, username, p
 		return false, nil, nil, nil, fmt.Errorf("ldap identifier backend logon impossible as no login attribute is set")
 	}
 
-	l, err := b.connect(ctx)
+	l, err := b.acquire(ctx)
 	if err != nil {
 		return false, nil, nil, nil, fmt.Errorf("ldap identifier backend logon connect error: %v", err)
 	}
-	defer l.Close()
+	// The connection is bound as the user after logon. It can only be reused
+	// when it gets bound with the general user again before use.
+	defer b.release(l, b.bindDN != "")
 
 	// Search for the given username.
 	entry, err := b.searchUsername(l, username, b.attributeMapping.attributes())
@@ -403,7 +549,7 @@ func (b *LDAPIdentifierBackend) Logon(ctx context.Context, audience, username, p
 		return false, nil, nil, nil, fmt.Errorf("ldap identifier backend logon entry without entry ID: %v", entry.DN)
 	}
 
-	user, err := newLdapUser(entryID, b.attributeMapping, entry)
+	user, err := b.newUser(entryID, entry)
 	if err != nil {
 		return false, nil, nil, nil, fmt.Errorf("ldap identifier backend logon entry data error: %v", err)
 	}
@@ -427,11 +573,11 @@ func (b *LDAPIdentifierBackend) ResolveUserByUsername(ctx context.Context, usern
 		return nil, fmt.Errorf("ldap identifier backend resolve impossible as no login attribute is set")
 	}
 
-	l, err := b.connect(ctx)
+	l, err := b.acquire(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("ldap identifier backend resolve connect error: %v", err)
 	}
-	defer l.Close()
+	defer b.release(l, true)
 
 	// Search for the given username.
 	entry, err := b.searchUsername(l, username, b.attributeMapping.attributes())
@@ -448,7 +594,7 @@ func (b *LDAPIdentifierBackend) ResolveUserByUsername(ctx context.Context, usern
 
 	newEntryID := b.entryIDFromEntry(b.attributeMapping, entry)
 
-	user, err := newLdapUser(newEntryID, b.attributeMapping, entry)
+	user, err := b.newUser(newEntryID, entry)
 	if err != nil {
 		return nil, fmt.Errorf("ldap identifier backend resolve entry data error: %v", err)
 	}
@@ -460,11 +606,11 @@ func (b *LDAPIdentifierBackend) ResolveUserByUsername(ctx context.Context, usern
 // for the user specified by the userID. Requests are bound to the provided
 // context.
 func (b *LDAPIdentifierBackend) GetUser(ctx context.Context, entryID string, sessionRef *string, requestedScopes map[string]bool) (backends.UserFromBackend, error) {
-	l, err := b.connect(ctx)
+	l, err := b.acquire(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("ldap identifier backend get user connect error: %v", err)
 	}
-	defer l.Close()
+	defer b.release(l, true)
 
 	entry, err := b.getUser(l, entryID, b.attributeMapping.attributes())
 	if err != nil {
@@ -476,7 +622,7 @@ func (b *LDAPIdentifierBackend) GetUser(ctx context.Context, entryID string, ses
 		return nil, fmt.Errorf("ldap identifier backend get user returned wrong user")
 	}
 
-	user, err := newLdapUser(newEntryID, b.attributeMapping, entry)
+	user, err := b.newUser(newEntryID, entry)
 	if err != nil {
 		return nil, fmt.Errorf("ldap identifier backend get user entry data error: %v", err)
 	}
@@ -506,6 +652,18 @@ func (b *LDAPIdentifierBackend) ScopesSupported() []string {
 	return b.supportedScopes
 }
 
+// ClaimScopes implements the BackendWithClaimScopes interface, providing the
+// scope of the configurable roles claim.
+func (b *LDAPIdentifierBackend) ClaimScopes() map[string]string {
+	if len(b.roleMappings) == 0 {
+		return nil
+	}
+
+	return map[string]string{
+		b.rolesClaim: konnectoidc.ScopeRoles,
+	}
+}
+
 // ScopesMeta implements the Backend interface, providing meta data for
 // supported scopes.
 func (b *LDAPIdentifierBackend) ScopesMeta() *scopes.Scopes {
@@ -517,6 +675,54 @@ func (b *LDAPIdentifierBackend) Name() string {
 	return ldapIdentifierBackendName
 }
 
+// acquire returns a validated connection from the pool or connects a new one
+// when the pool has no usable connection. Pooled connections which fail
+// validation, for example because the LDAP server was restarted, are closed
+// and replaced transparently.
+func (b *LDAPIdentifierBackend) acquire(ctx context.Context) (*ldap.Conn, error) {
+	for {
+		l := b.pool.get()
+		if l == nil {
+			break
+		}
+		err := b.probe(l)
+		if err == nil {
+			return l, nil
+		}
+		b.logger.WithError(err).Debugln("ldap identifier backend pooled connection is unusable, reconnecting")
+		l.Close()
+	}
+
+	return b.connect(ctx)
+}
+
+// release returns the provided connection to the pool if reusable is true and
+// the pool has room for it. Otherwise the connection is closed.
+func (b *LDAPIdentifierBackend) release(l *ldap.Conn, reusable bool) {
+	if reusable && !l.IsClosing() && b.pool.put(l) {
+		return
+	}
+	l.Close()
+}
+
+// probe validates the provided connection. With a general user, the probe
+// binds again which also resets the connection identity. Otherwise the root
+// DSE is read.
+func (b *LDAPIdentifierBackend) probe(l *ldap.Conn) error {
+	if b.bindDN != "" {
+		return l.Bind(b.bindDN, b.bindPassword)
+	}
+
+	_, err := l.Search(ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, b.timeout, false,
+		"(objectClass=*)",
+		[]string{"1.1"},
+		nil,
+	))
+	return err
+}
+
 func (b *LDAPIdentifierBackend) connect(parentCtx context.Context) (*ldap.Conn, error) {
 	// A timeout for waiting for a limiter slot. The timeout also includes the
 	// time to connect to the LDAP server which as a consequence means that both
@@ -550,10 +756,19 @@ func (b *LDAPIdentifierBackend) connect(parentCtx context.Context) (*ldap.Conn,
 
 	l.Start()
 
+	if b.startTLS {
+		err = l.StartTLS(b.tlsConfig)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
 	// Bind with general user (which is preferably read only).
 	if b.bindDN != "" {
 		err = l.Bind(b.bindDN, b.bindPassword)
 		if err != nil {
+			l.Close()
 			return nil, err
 		}
 	}
@@ -561,6 +776,19 @@ func (b *LDAPIdentifierBackend) connect(parentCtx context.Context) (*ldap.Conn,
 	return l, nil
 }
 
+func (b *LDAPIdentifierBackend) newUser(entryID string, entry *ldap.Entry) (*ldapUser, error) {
+	user, err := newLdapUser(entryID, b.attributeMapping, entry)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.roleMappings) > 0 {
+		user.rolesClaim = b.rolesClaim
+		user.roles = rolesFromGroups(b.roleMappings, user.groups)
+	}
+
+	return user, nil
+}
+
 func (b *LDAPIdentifierBackend) searchUsername(l *ldap.Conn, username string, attributes []string) (*ldap.Entry, error) {
 	base, filter := b.baseAndSearchFilterFromUsername(username)
 	// Search for the given username.
@@ -0,0 +1,683 @@
+package ldap
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/config"
+	konnectoidc "github.com/libregraph/lico/oidc"
+)
+
+// testLDAPEntry is an entry served by the testLDAPServer.
+type testLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testLDAPServer is a minimal LDAP server which supports simple binds,
+// searches for uid or by base DN and StartTLS.
+type testLDAPServer struct {
+	sync.Mutex
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	entries   []*testLDAPEntry
+
+	conns    []net.Conn
+	binds    []string
+	startTLS int
+}
+
+func newTestLDAPServer(t *testing.T, tlsConfig *tls.Config, entries ...*testLDAPEntry) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testLDAPServer{
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		entries:   entries,
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.Lock()
+			s.conns = append(s.conns, conn)
+			s.Unlock()
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *testLDAPServer) URI() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// accepted returns the number of connections accepted so far.
+func (s *testLDAPServer) accepted() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.conns)
+}
+
+// drop closes all connections, like a restarting LDAP server would.
+func (s *testLDAPServer) drop() {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *testLDAPServer) serve(conn net.Conn) {
+	defer func() {
+		conn.Close()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			s.Lock()
+			s.binds = append(s.binds, dn)
+			s.Unlock()
+			resultCode := int64(ldap.LDAPResultInvalidCredentials)
+			for _, entry := range s.entries {
+				if entry.dn == dn && entry.password == password {
+					resultCode = ldap.LDAPResultSuccess
+				}
+			}
+			conn.Write(testLDAPResult(messageID, ldap.ApplicationBindResponse, resultCode).Bytes())
+
+		case ldap.ApplicationSearchRequest:
+			base, _ := request.Children[0].Value.(string)
+			scope, _ := request.Children[1].Value.(int64)
+			filter, _ := ldap.DecompileFilter(request.Children[6])
+			for _, entry := range s.entries {
+				if scope == ldap.ScopeBaseObject {
+					if !strings.EqualFold(entry.dn, base) {
+						continue
+					}
+				} else if !strings.Contains(filter, "(uid="+entry.attributes["uid"][0]+")") {
+					continue
+				}
+				conn.Write(testLDAPSearchResultEntry(messageID, entry).Bytes())
+			}
+			conn.Write(testLDAPResult(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+
+		case ldap.ApplicationExtendedRequest:
+			if s.tlsConfig == nil {
+				conn.Write(testLDAPResult(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError).Bytes())
+				continue
+			}
+			conn.Write(testLDAPResult(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess).Bytes())
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err = tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.Lock()
+			s.startTLS++
+			s.Unlock()
+			conn = tlsConn
+
+		case ldap.ApplicationUnbindRequest:
+			return
+
+		default:
+			return
+		}
+	}
+}
+
+func testLDAPResult(messageID int64, tag ber.Tag, resultCode int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(response)
+
+	return packet
+}
+
+func testLDAPSearchResultEntry(messageID int64, entry *testLDAPEntry) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(vals)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+	packet.AppendChild(response)
+
+	return packet
+}
+
+func newTestLDAPTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, &tls.Config{
+		RootCAs: pool,
+	}
+}
+
+var testLDAPUser1 = &testLDAPEntry{
+	dn:       "uid=user1,ou=users,dc=example,dc=net",
+	password: "secret1",
+	attributes: map[string][]string{
+		"uid":      {"user1"},
+		"cn":       {"User One"},
+		"mail":     {"user1@example.net"},
+		"memberOf": {"cn=admins,ou=groups,dc=example,dc=net", "cn=staff,ou=groups,dc=example,dc=net"},
+	},
+}
+
+var testLDAPServiceAccount = &testLDAPEntry{
+	dn:       "cn=readonly,dc=example,dc=net",
+	password: "readonly",
+	attributes: map[string][]string{
+		"uid": {"readonly"},
+	},
+}
+
+func TestLogon(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1, testLDAPServiceAccount)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), testLDAPServiceAccount.dn, testLDAPServiceAccount.password, "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	success, userID, _, user, err := b.Logon(context.Background(), "", "user1", "secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success || user == nil {
+		t.Fatal("logon with valid credentials failed")
+	}
+	if *userID != testLDAPUser1.dn {
+		t.Errorf("logon returned wrong user id, got %s, want %s", *userID, testLDAPUser1.dn)
+	}
+	if name := user.(*ldapUser).Name(); name != "User One" {
+		t.Errorf("logon returned wrong name, got %s", name)
+	}
+
+	success, _, _, user, err = b.Logon(context.Background(), "", "user1", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success || user != nil {
+		t.Error("logon with wrong password succeeded")
+	}
+
+	success, _, _, _, err = b.Logon(context.Background(), "", "unknown", "secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success {
+		t.Error("logon of unknown user succeeded")
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if len(server.binds) == 0 || server.binds[0] != testLDAPServiceAccount.dn {
+		t.Errorf("searches did not bind with service account: %v", server.binds)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), "", "", "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := b.GetUser(context.Background(), testLDAPUser1.dn, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email := user.(*ldapUser).Email(); email != "user1@example.net" {
+		t.Errorf("get user returned wrong email, got %s", email)
+	}
+
+	resolved, err := b.ResolveUserByUsername(context.Background(), "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Subject() != user.Subject() {
+		t.Errorf("resolved user has wrong subject, got %s, want %s", resolved.Subject(), user.Subject())
+	}
+}
+
+func TestLogonStartTLS(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	serverTLSConfig, clientTLSConfig := newTestLDAPTLSConfigs(t)
+	server := newTestLDAPServer(t, serverTLSConfig, testLDAPUser1)
+
+	b, err := NewLDAPIdentifierBackend(cfg, clientTLSConfig, true, server.URI(), "", "", "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	success, _, _, _, err := b.Logon(context.Background(), "", "user1", "secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success {
+		t.Fatal("logon with valid credentials and starttls failed")
+	}
+
+	server.Lock()
+	defer server.Unlock()
+	if server.startTLS == 0 {
+		t.Error("logon did not use starttls")
+	}
+
+	if _, err = NewLDAPIdentifierBackend(cfg, clientTLSConfig, true, "ldaps://127.0.0.1", "", "", "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil); err == nil {
+		t.Error("starttls with ldaps URI must fail")
+	}
+}
+
+func TestGroupsAttributeMapping(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+		AttributeGroups: "memberOf",
+	}, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, scope := range b.ScopesSupported() {
+		if scope == konnectoidc.ScopeGroups {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("groups scope not supported with groups attribute mapping: %v", b.ScopesSupported())
+	}
+
+	success, _, _, user, err := b.Logon(context.Background(), "", "user1", "secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success {
+		t.Fatal("logon with valid credentials failed")
+	}
+	extraClaims, _ := user.BackendClaims()[konnect.InternalExtraAccessTokenClaimsClaim].(map[string]interface{})
+	if groups := extraClaims[konnectoidc.GroupsClaim]; !reflect.DeepEqual(groups, testLDAPUser1.attributes["memberOf"]) {
+		t.Errorf("groups claim was incorrect, got %v", groups)
+	}
+}
+
+func TestPhoneNumberAttributeMapping(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+		AttributePhoneNumber: "telephoneNumber",
+	}, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, scope := range b.ScopesSupported() {
+		if scope == konnectoidc.ScopePhone {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("phone scope not supported with phone number attribute mapping: %v", b.ScopesSupported())
+	}
+
+	entry := ldap.NewEntry("uid=user,dc=example,dc=net", map[string][]string{
+		"uid":             {"user"},
+		"telephoneNumber": {"+49 30 1234567"},
+	})
+	user, err := newLdapUser("user", b.attributeMapping, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PhoneNumber() != "+49 30 1234567" {
+		t.Errorf("phone number was incorrect, got %s, want +49 30 1234567", user.PhoneNumber())
+	}
+
+	b, err = NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scope := range b.ScopesSupported() {
+		if scope == konnectoidc.ScopePhone {
+			t.Error("phone scope must not be supported without phone number attribute mapping")
+		}
+	}
+}
+
+func TestLocaleAttributeMapping(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+		AttributeLocale:   "preferredLanguage",
+		AttributeZoneinfo: "timezone",
+	}, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := ldap.NewEntry("uid=user,dc=example,dc=net", map[string][]string{
+		"uid":               {"user"},
+		"preferredLanguage": {"de-DE"},
+	})
+	user, err := newLdapUser("user", b.attributeMapping, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Locale() != "de-DE" {
+		t.Errorf("locale was incorrect, got %s, want de-DE", user.Locale())
+	}
+	if user.Zoneinfo() != "" {
+		t.Errorf("zoneinfo must be empty for user without value, got %s", user.Zoneinfo())
+	}
+
+	b, err = NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, nil, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = newLdapUser("user", b.attributeMapping, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Locale() != "" {
+		t.Errorf("locale must be empty without locale attribute mapping, got %s", user.Locale())
+	}
+}
+
+func TestAddressAttributeMapping(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+		AttributeLocality:   "l",
+		AttributePostalCode: "postalCode",
+		AttributeCountry:    "c",
+	}, 0, 0, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, scope := range b.ScopesSupported() {
+		if scope == konnectoidc.ScopeAddress {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("address scope not supported with address attribute mapping: %v", b.ScopesSupported())
+	}
+
+	entry := ldap.NewEntry("uid=user,dc=example,dc=net", map[string][]string{
+		"uid":        {"user"},
+		"l":          {"Berlin"},
+		"postalCode": {"10115"},
+	})
+	user, err := newLdapUser("user", b.attributeMapping, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := user.Address()
+	if address == nil {
+		t.Fatal("address missing for user with partial address data")
+	}
+	expected := konnectoidc.Address{
+		Locality:   "Berlin",
+		PostalCode: "10115",
+	}
+	if *address != expected {
+		t.Errorf("address was incorrect, got %+v, want %+v", *address, expected)
+	}
+
+	entry = ldap.NewEntry("uid=user,dc=example,dc=net", map[string][]string{
+		"uid": {"user"},
+	})
+	user, err = newLdapUser("user", b.attributeMapping, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := user.Address(); address != nil {
+		t.Errorf("address must be nil for user without address data, got %+v", address)
+	}
+}
+
+func TestLogonPooledReconnect(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1, testLDAPServiceAccount)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), testLDAPServiceAccount.dn, testLDAPServiceAccount.password, "dc=example,dc=net", "", "", nil, nil, 2, time.Minute, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logon := func() {
+		success, _, _, _, err := b.Logon(context.Background(), "", "user1", "secret1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !success {
+			t.Fatal("logon with valid credentials failed")
+		}
+	}
+
+	logon()
+	logon()
+	if accepted := server.accepted(); accepted != 1 {
+		t.Errorf("pooled connection was not reused, got %d connections", accepted)
+	}
+
+	// Dropped connections must be replaced on the next logon.
+	server.drop()
+	logon()
+	if accepted := server.accepted(); accepted != 2 {
+		t.Errorf("dropped connection was not replaced, got %d connections", accepted)
+	}
+	logon()
+	if accepted := server.accepted(); accepted != 2 {
+		t.Errorf("replaced connection was not reused, got %d connections", accepted)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), "", "", "dc=example,dc=net", "", "", nil, nil, 2, time.Minute, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = b.GetUser(context.Background(), testLDAPUser1.dn, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	b.pool.idle[0].lastUsed = time.Now().Add(-2 * time.Minute)
+	if _, err = b.GetUser(context.Background(), testLDAPUser1.dn, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if accepted := server.accepted(); accepted != 2 {
+		t.Errorf("idle connection was reused after idle timeout, got %d connections", accepted)
+	}
+
+	// Without general user, connections bound as a user are not reused.
+	if success, _, _, _, _ := b.Logon(context.Background(), "", "user1", "secret1"); !success {
+		t.Fatal("logon with valid credentials failed")
+	}
+	if _, err = b.GetUser(context.Background(), testLDAPUser1.dn, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if accepted := server.accepted(); accepted != 3 {
+		t.Errorf("connection bound as user was reused, got %d connections", accepted)
+	}
+}
+
+func TestRoleMappings(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+	server := newTestLDAPServer(t, nil, testLDAPUser1)
+
+	b, err := NewLDAPIdentifierBackend(cfg, nil, false, server.URI(), "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+		AttributeGroups: "memberOf",
+	}, 0, 0, "app_roles", []*RoleMapping{
+		{Role: "admin", Group: "CN=admins,ou=groups,dc=example,dc=net"},
+		{Role: "employee", Pattern: "^cn=(staff|interns),ou=groups,"},
+		{Role: "admin", Pattern: "^cn=staff,"},
+		{Role: "contractor", Group: "cn=contractors,ou=groups,dc=example,dc=net"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, scope := range b.ScopesSupported() {
+		if scope == konnectoidc.ScopeRoles {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("roles scope not supported with role mappings: %v", b.ScopesSupported())
+	}
+
+	success, _, _, user, err := b.Logon(context.Background(), "", "user1", "secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success {
+		t.Fatal("logon with valid credentials failed")
+	}
+	extraClaims, _ := user.BackendClaims()[konnect.InternalExtraAccessTokenClaimsClaim].(map[string]interface{})
+	if roles := extraClaims["app_roles"]; !reflect.DeepEqual(roles, []string{"admin", "employee"}) {
+		t.Errorf("roles claim was incorrect, got %v", roles)
+	}
+
+	// The roles claim must only be returned with the roles scope.
+	if claimScopes := b.ClaimScopes(); !reflect.DeepEqual(claimScopes, map[string]string{"app_roles": konnectoidc.ScopeRoles}) {
+		t.Errorf("roles claim scope was incorrect, got %v", claimScopes)
+	}
+}
+
+func TestRoleMappingsInvalid(t *testing.T) {
+	cfg := &config.Config{
+		Logger: logrus.New(),
+	}
+
+	for _, tc := range []struct {
+		groupsAttribute string
+		mapping         *RoleMapping
+	}{
+		{"", &RoleMapping{Role: "admin", Group: "cn=admins,dc=example,dc=net"}},
+		{"memberOf", &RoleMapping{Role: "admin"}},
+		{"memberOf", &RoleMapping{Group: "cn=admins,dc=example,dc=net"}},
+		{"memberOf", &RoleMapping{Role: "admin", Pattern: "(cn=admins"}},
+	} {
+		_, err := NewLDAPIdentifierBackend(cfg, nil, false, "ldap://localhost", "", "", "dc=example,dc=net", "", "", nil, map[string]string{
+			AttributeGroups: tc.groupsAttribute,
+		}, 0, 0, "", []*RoleMapping{tc.mapping})
+		if err == nil {
+			t.Errorf("invalid role mapping %+v was accepted", tc.mapping)
+		}
+	}
+}
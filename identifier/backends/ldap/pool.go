@@ -0,0 +1,98 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ldap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapConnPool keeps idle LDAP connections for reuse. Connections are handed
+// out most recently used first and connections which have been idle for longer
+// than the idle timeout are closed instead of being reused.
+type ldapConnPool struct {
+	sync.Mutex
+
+	size        int
+	idleTimeout time.Duration
+
+	idle   []*ldapPooledConn
+	closed bool
+}
+
+type ldapPooledConn struct {
+	conn     *ldap.Conn
+	lastUsed time.Time
+}
+
+func newLDAPConnPool(size int, idleTimeout time.Duration) *ldapConnPool {
+	return &ldapConnPool{
+		size:        size,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// get returns an idle connection or nil if the pool has none.
+func (p *ldapConnPool) get() *ldap.Conn {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	for len(p.idle) > 0 {
+		pc := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if pc.conn.IsClosing() || (p.idleTimeout > 0 && now.Sub(pc.lastUsed) > p.idleTimeout) {
+			pc.conn.Close()
+			continue
+		}
+		return pc.conn
+	}
+
+	return nil
+}
+
+// put adds the provided connection to the pool. It returns false when the
+// pool is full or closed, leaving the connection to the caller.
+func (p *ldapConnPool) put(conn *ldap.Conn) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed || len(p.idle) >= p.size {
+		return false
+	}
+	p.idle = append(p.idle, &ldapPooledConn{
+		conn:     conn,
+		lastUsed: time.Now(),
+	})
+
+	return true
+}
+
+// close closes all idle connections and disables the pool.
+func (p *ldapConnPool) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, pc := range p.idle {
+		pc.conn.Close()
+	}
+	p.idle = nil
+	p.closed = true
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ldap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RoleMapping maps LDAP groups to a role name. A group matches if it either
+// equals Group or matches the regular expression Pattern.
+type RoleMapping struct {
+	Role    string `json:"role"`
+	Group   string `json:"group,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+func (rm *RoleMapping) initialize() error {
+	if rm.Role == "" {
+		return fmt.Errorf("role must not be empty")
+	}
+	if rm.Group == "" && rm.Pattern == "" {
+		return fmt.Errorf("role %v has neither group nor pattern", rm.Role)
+	}
+	if rm.Pattern != "" {
+		pattern, err := regexp.Compile(rm.Pattern)
+		if err != nil {
+			return fmt.Errorf("role %v pattern is invalid: %w", rm.Role, err)
+		}
+		rm.pattern = pattern
+	}
+
+	return nil
+}
+
+func (rm *RoleMapping) matches(group string) bool {
+	if rm.Group != "" && strings.EqualFold(rm.Group, group) {
+		return true
+	}
+	if rm.pattern != nil && rm.pattern.MatchString(group) {
+		return true
+	}
+
+	return false
+}
+
+// rolesFromGroups returns the names of the roles which match any of the
+// provided groups, in the order of the provided mappings and without
+// duplicates.
+func rolesFromGroups(mappings []*RoleMapping, groups []string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for _, rm := range mappings {
+		if seen[rm.Role] {
+			continue
+		}
+		for _, group := range groups {
+			if rm.matches(group) {
+				roles = append(roles, rm.Role)
+				seen[rm.Role] = true
+				break
+			}
+		}
+	}
+
+	return roles
+}
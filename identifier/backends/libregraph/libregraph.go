@@ -85,6 +85,7 @@ type libreGraphUser struct {
 	RawGivenName      string `json:"givenName"`
 	ID                string `json:"id"`
 	Mail              string `json:"mail"`
+	PreferredLanguage string `json:"preferredLanguage"`
 	Surname           string `json:"surname"`
 	UserPrincipalName string `json:"userPrincipalName"`
 
@@ -207,6 +208,14 @@ func (u *libreGraphUser) GivenName() string {
 	return u.RawGivenName
 }
 
+func (u *libreGraphUser) Locale() string {
+	return u.PreferredLanguage
+}
+
+func (u *libreGraphUser) Zoneinfo() string {
+	return ""
+}
+
 func (u *libreGraphUser) Username() string {
 	return u.UserPrincipalName
 }
@@ -259,7 +268,7 @@ func withSelectQuery(r *http.Request) {
 	if r.Form == nil {
 		r.Form = make(url.Values)
 	}
-	r.Form.Set("$select", "accountEnabled,displayName,givenName,id,mail,surname,userPrincipalName,extensions")
+	r.Form.Set("$select", "accountEnabled,displayName,givenName,id,mail,preferredLanguage,surname,userPrincipalName,extensions")
 }
 
 func NewLibreGraphIdentifierBackend(
@@ -23,6 +23,15 @@ const (
 	LogonRefClaim            = "lref"
 	ExternalAuthorityIDClaim = "eaid"
 	LockedScopesClaim        = "lscp"
+
+	ExternalAuthorityClaimsClaim = "eacl"
+	ExternalAuthorityScopesClaim = "eascp"
+
+	PersistentSessionIDClaim    = "psid"
+	PersistentSessionTokenClaim = "pstk"
+
+	LogonSessionIDClaim = "lsid"
+	LastUsedAtClaim     = "luat"
 )
 
 // History claims previously used by the identifier in its own tokens.
@@ -19,6 +19,7 @@ package identifier
 
 import (
 	"net/url"
+	"time"
 
 	"github.com/libregraph/lico/config"
 	"github.com/libregraph/lico/identifier/backends"
@@ -44,5 +45,9 @@ type Config struct {
 	DefaultUsernameHintText *string
 	UILocales               []string
 
+	PersistentSessionDuration time.Duration
+
+	AdminSecret []byte
+
 	Backend backends.Backend
 }
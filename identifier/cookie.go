@@ -20,6 +20,7 @@ package identifier
 import (
 	"encoding/base64"
 	"net/http"
+	"time"
 
 	"golang.org/x/crypto/blake2b"
 )
@@ -27,6 +28,8 @@ import (
 const (
 	consentCookieNamePrefix = "__Secure-KKTC" // Kopano Konnect Temorary Consent
 	stateCookieNamePrefix   = "__Secure-KKTS" // Kopano Konnect Temporary State
+
+	persistentCookieName = "__Secure-KKRM" // Kopano Konnect Remember Me
 )
 
 func (i *Identifier) setLogonCookie(rw http.ResponseWriter, value string) error {
@@ -37,7 +40,8 @@ func (i *Identifier) setLogonCookie(rw http.ResponseWriter, value string) error
 		Path:     i.pathPrefix + "/identifier/_/",
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 	}
 	http.SetCookie(rw, &cookie)
 
@@ -55,7 +59,47 @@ func (i *Identifier) removeLogonCookie(rw http.ResponseWriter) error {
 		Path:     i.pathPrefix + "/identifier/_/",
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
+
+		Expires: farPastExpiryTime,
+	}
+	http.SetCookie(rw, &cookie)
+
+	return nil
+}
+
+func (i *Identifier) setPersistentCookie(rw http.ResponseWriter, value string, expires time.Time) error {
+	cookie := http.Cookie{
+		Name:  persistentCookieName,
+		Value: value,
+
+		Path:     i.pathPrefix + "/identifier/_/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
+
+		Expires: expires,
+	}
+	http.SetCookie(rw, &cookie)
+
+	return nil
+}
+
+func (i *Identifier) getPersistentCookie(req *http.Request) (*http.Cookie, error) {
+	return req.Cookie(persistentCookieName)
+}
+
+func (i *Identifier) removePersistentCookie(rw http.ResponseWriter) error {
+	cookie := http.Cookie{
+		Name: persistentCookieName,
+
+		Path:     i.pathPrefix + "/identifier/_/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 
 		Expires: farPastExpiryTime,
 	}
@@ -78,7 +122,8 @@ func (i *Identifier) setConsentCookie(rw http.ResponseWriter, cr *ConsentRequest
 		Path:     i.pathPrefix + "/identifier/_/",
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 	}
 	http.SetCookie(rw, &cookie)
 
@@ -106,7 +151,8 @@ func (i *Identifier) removeConsentCookie(rw http.ResponseWriter, req *http.Reque
 		Path:     i.pathPrefix + "/identifier/_/",
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 
 		Expires: farPastExpiryTime,
 	}
@@ -150,7 +196,8 @@ func (i *Identifier) setStateCookie(rw http.ResponseWriter, scope string, state
 		Path:     i.pathPrefix + "/identifier/" + scope,
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 	}
 	http.SetCookie(rw, &cookie)
 
@@ -178,7 +225,8 @@ func (i *Identifier) removeStateCookie(rw http.ResponseWriter, req *http.Request
 		Path:     i.pathPrefix + "/identifier/" + scope,
 		Secure:   true,
 		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
+		SameSite: i.cookieSameSite,
+		Domain:   i.cookieDomain,
 
 		Expires: farPastExpiryTime,
 	}
@@ -0,0 +1,89 @@
+package identifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestLogonCookieAttributes(t *testing.T) {
+	tests := []struct {
+		sameSite http.SameSite
+		domain   string
+
+		expectedSameSite http.SameSite
+	}{
+		{0, "", http.SameSiteNoneMode},
+		{http.SameSiteLaxMode, "example.com", http.SameSiteLaxMode},
+		{http.SameSiteStrictMode, "", http.SameSiteStrictMode},
+	}
+
+	for _, test := range tests {
+		i, err := NewIdentifier(&Config{
+			Config: &config.Config{
+				Logger: &logrus.Logger{
+					Out:       os.Stderr,
+					Formatter: &logrus.TextFormatter{DisableColors: true},
+					Level:     logrus.DebugLevel,
+				},
+				CookieSameSite: test.sameSite,
+				CookieDomain:   test.domain,
+			},
+
+			BaseURI:         &url.URL{Scheme: "https", Host: "localhost"},
+			LogonCookieName: "__Secure-KKT",
+			WebAppDisabled:  true,
+
+			PersistentSessionDuration: time.Hour,
+
+			Backend: &testBackend{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = i.SetKey(make([]byte, 32)); err != nil {
+			t.Fatal(err)
+		}
+
+		user := &IdentifiedUser{
+			sub:      "user1",
+			username: "user1",
+			backend:  i.backend,
+			logonAt:  time.Now(),
+		}
+		rr := httptest.NewRecorder()
+		if err = i.SetUserToLogonCookie(context.Background(), rr, user); err != nil {
+			t.Fatal(err)
+		}
+		if err = i.SetUserToPersistentCookie(context.Background(), rr, httptest.NewRequest(http.MethodPost, "/identifier/_/logon", nil), user); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{i.logonCookieName, persistentCookieName} {
+			cookie := findCookie(rr.Result().Cookies(), name)
+			if cookie == nil {
+				t.Fatalf("expected cookie %s to be set: %v", name, rr.Header().Values("Set-Cookie"))
+			}
+			if !cookie.Secure {
+				t.Errorf("expected cookie %s to be Secure: %v", name, cookie)
+			}
+			if !cookie.HttpOnly {
+				t.Errorf("expected cookie %s to be HttpOnly: %v", name, cookie)
+			}
+			if cookie.SameSite != test.expectedSameSite {
+				t.Errorf("expected cookie %s SameSite %v, got %v", name, test.expectedSameSite, cookie.SameSite)
+			}
+			if cookie.Domain != test.domain {
+				t.Errorf("expected cookie %s Domain %q, got %q", name, test.domain, cookie.Domain)
+			}
+		}
+	}
+}
@@ -28,6 +28,8 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/authorities"
 	"github.com/libregraph/lico/utils"
 )
@@ -192,6 +194,7 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 	// but its interpretation depends on the third field ($mode). The rest of the
 	// fields are mode specific.
 	params := r.Params
+	var logonUsername string
 	for {
 		paramSize := len(params)
 		if paramSize == 0 {
@@ -202,7 +205,7 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 		if paramSize >= 3 && params[1] == "" && params[2] == ModeLogonUsernameEmptyPasswordCookie {
 			// Special mode to allow when same user is logged in via cookie. This
 			// is used in the select account page logon flow with empty password.
-			identifiedUser, cookieErr := i.GetUserFromLogonCookie(req.Context(), req, 0, true)
+			identifiedUser, cookieErr := i.GetUserFromLogonCookie(req.Context(), rw, req, 0, true)
 			if cookieErr != nil {
 				i.logger.WithError(cookieErr).Debugln("identifier failed to decode logon cookie in logon request")
 			}
@@ -231,9 +234,29 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 		switch params[2] {
 		case ModeLogonUsernamePassword:
 			// Username and password validation mode.
+			logonUsername = params[0]
+			if i.lockout.locked(logonUsername) {
+				i.logger.WithField("username", logonUsername).Warnln("identifier logon rejected, username is locked out")
+				i.auditLog(req, &audit.Event{
+					Type:     audit.EventTypeLogon,
+					Outcome:  audit.OutcomeFailure,
+					Username: logonUsername,
+					ClientID: audience,
+					Reason:   "locked out",
+				})
+				i.ErrorPage(rw, http.StatusTooManyRequests, "", "too many failed logons")
+				return
+			}
 			logonedUser, logonErr := i.logonUser(req.Context(), audience, params[0], params[1])
 			if logonErr != nil {
 				i.logger.WithError(logonErr).Errorln("identifier failed to logon with backend")
+				i.auditLog(req, &audit.Event{
+					Type:     audit.EventTypeLogon,
+					Outcome:  audit.OutcomeFailure,
+					Username: logonUsername,
+					ClientID: audience,
+					Reason:   "backend error",
+				})
 				i.ErrorPage(rw, http.StatusInternalServerError, "", "failed to logon")
 				return
 			}
@@ -246,11 +269,29 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 		break
 	}
 
+	var clientID string
+	if r.Hello != nil {
+		clientID = r.Hello.ClientID
+	}
+
 	if user == nil || user.Subject() == "" {
+		if logonUsername != "" {
+			i.lockout.fail(logonUsername)
+			i.auditLog(req, &audit.Event{
+				Type:     audit.EventTypeLogon,
+				Outcome:  audit.OutcomeFailure,
+				Username: logonUsername,
+				ClientID: clientID,
+				Reason:   "invalid credentials",
+			})
+		}
 		rw.Header().Set("Kopano-Konnect-State", response.State)
 		rw.WriteHeader(http.StatusNoContent)
 		return
 	}
+	if logonUsername != "" {
+		i.lockout.reset(logonUsername)
+	}
 
 	// Get user meta data.
 	// TODO(longsleep): This is an additional request to the backend. This
@@ -268,6 +309,14 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 		hello, errHello := i.writeHelloResponse(rw, req, r.Hello, user)
 		if errHello != nil {
 			i.logger.WithError(errHello).Debugln("rejecting identifier logon request")
+			i.auditLog(req, &audit.Event{
+				Type:     audit.EventTypeLogon,
+				Outcome:  audit.OutcomeFailure,
+				Subject:  user.Subject(),
+				Username: user.Username(),
+				ClientID: clientID,
+				Reason:   "rejected",
+			})
 			i.ErrorPage(rw, http.StatusBadRequest, "", errHello.Error())
 			return
 		}
@@ -280,6 +329,29 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 		response.Hello = hello
 	}
 
+	err = i.startSession(req.Context(), req, user)
+	if err == ErrTooManySessions {
+		i.logger.WithField("sub", user.Subject()).Warnln("identifier logon rejected, too many concurrent sessions")
+		i.auditLog(req, &audit.Event{
+			Type:     audit.EventTypeLogon,
+			Outcome:  audit.OutcomeFailure,
+			Subject:  user.Subject(),
+			Username: user.Username(),
+			ClientID: clientID,
+			Reason:   "too many sessions",
+		})
+		i.ErrorPage(rw, http.StatusTooManyRequests, "", "too many concurrent sessions")
+		return
+	}
+
+	if r.RememberMe {
+		err = i.SetUserToPersistentCookie(req.Context(), rw, req, user)
+		if err != nil {
+			i.logger.WithError(err).Errorln("failed to serialize persistent logon ticket")
+			i.ErrorPage(rw, http.StatusInternalServerError, "", "failed to serialize persistent logon ticket")
+			return
+		}
+	}
 	err = i.SetUserToLogonCookie(req.Context(), rw, user)
 	if err != nil {
 		i.logger.WithError(err).Errorln("failed to serialize logon ticket")
@@ -289,6 +361,14 @@ func (i *Identifier) handleLogon(rw http.ResponseWriter, req *http.Request) {
 
 	response.Success = true
 
+	i.auditLog(req, &audit.Event{
+		Type:     audit.EventTypeLogon,
+		Outcome:  audit.OutcomeSuccess,
+		Subject:  user.Subject(),
+		Username: user.Username(),
+		ClientID: clientID,
+	})
+
 	err = utils.WriteJSON(rw, http.StatusOK, response, "")
 	if err != nil {
 		i.logger.WithError(err).Errorln("logon request failed writing response")
@@ -307,17 +387,30 @@ func (i *Identifier) handleLogoff(rw http.ResponseWriter, req *http.Request) {
 
 	addNoCacheResponseHeaders(rw.Header())
 
-	ctx := req.Context()
-	u, err := i.GetUserFromLogonCookie(ctx, req, 0, false)
+	ctx := identity.NewSessionDestroyReasonContext(req.Context(), identity.SessionDestroyReasonLogoff)
+	u, err := i.GetUserFromLogonCookie(ctx, rw, req, 0, false)
 	if err != nil {
 		i.logger.WithError(err).Warnln("identifier logoff failed to get logon from ticket")
 	}
+	err = i.UnsetPersistentCookie(ctx, rw, req)
+	if err != nil {
+		i.logger.WithError(err).Warnln("identifier logoff failed to unset persistent ticket")
+	}
 	err = i.UnsetLogonCookie(ctx, u, rw)
 	if err != nil {
 		i.logger.WithError(err).Errorln("identifier failed to set logoff ticket")
 		i.ErrorPage(rw, http.StatusInternalServerError, "", "failed to set logoff ticket")
 		return
 	}
+	if u != nil {
+		i.auditLog(req, &audit.Event{
+			Type:     audit.EventTypeSessionDestroyed,
+			Outcome:  audit.OutcomeSuccess,
+			Subject:  u.Subject(),
+			Username: u.Username(),
+			Reason:   string(identity.SessionDestroyReasonLogoff),
+		})
+	}
 
 	response := &StateResponse{
 		State:   r.State,
@@ -36,6 +36,7 @@ import (
 	jwt "gopkg.in/square/go-jose.v2/jwt"
 
 	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/audit"
 	"github.com/libregraph/lico/identifier/backends"
 	"github.com/libregraph/lico/identifier/meta"
 	"github.com/libregraph/lico/identifier/meta/scopes"
@@ -63,6 +64,8 @@ type Identifier struct {
 	pathPrefix      string
 	staticFolder    string
 	logonCookieName string
+	cookieSameSite  http.SameSite
+	cookieDomain    string
 	scopesConf      string
 	webappIndexHTML []byte
 
@@ -72,10 +75,23 @@ type Identifier struct {
 
 	encrypter   jose.Encrypter
 	recipient   *jose.Recipient
+	keys        map[string][]byte
 	backend     backends.Backend
 	clients     *clients.Registry
 	authorities *authorities.Registry
 
+	subjectPrefix string
+
+	persistentSessions        *persistentSessions
+	persistentSessionDuration time.Duration
+
+	sessionActivity *sessionActivity
+	sessionLimit    *sessionLimit
+
+	lockout *logonLockout
+
+	adminSecret []byte
+
 	meta *meta.Meta
 
 	defaultBannerLogo *string
@@ -83,7 +99,10 @@ type Identifier struct {
 	onSetLogonCallbacks   []func(ctx context.Context, rw http.ResponseWriter, user identity.User) error
 	onUnsetLogonCallbacks []func(ctx context.Context, rw http.ResponseWriter) error
 
-	logger logrus.FieldLogger
+	onRevokeConsentCallbacks []func(ctx context.Context, sub string, clientID string) error
+
+	logger      logrus.FieldLogger
+	auditLogger audit.Logger
 
 	router *mux.Router
 }
@@ -115,6 +134,8 @@ func NewIdentifier(c *Config) (*Identifier, error) {
 		pathPrefix:      c.PathPrefix,
 		staticFolder:    staticFolder,
 		logonCookieName: c.LogonCookieName,
+		cookieSameSite:  c.Config.CookieSameSite,
+		cookieDomain:    c.Config.CookieDomain,
 		scopesConf:      c.ScopesConf,
 		webappIndexHTML: webappIndexHTML,
 
@@ -122,12 +143,37 @@ func NewIdentifier(c *Config) (*Identifier, error) {
 		signedOutEndpointURI:     c.SignedOutEndpointURI,
 		oauth2CbEndpointURI:      oauth2CbEndpointURI,
 
-		backend: c.Backend,
+		backend:       c.Backend,
+		subjectPrefix: c.Config.SubjectPrefix,
+
+		adminSecret: c.AdminSecret,
+
+		lockout: newLogonLockout(c.Config.LogonLockoutAttempts, c.Config.LogonLockoutDuration, c.Config.LogonLockoutExempt),
 
 		onSetLogonCallbacks:   make([]func(ctx context.Context, rw http.ResponseWriter, user identity.User) error, 0),
 		onUnsetLogonCallbacks: make([]func(ctx context.Context, rw http.ResponseWriter) error, 0),
 
-		logger: c.Config.Logger,
+		logger:      c.Config.Logger,
+		auditLogger: c.Config.AuditLogger,
+	}
+	if i.cookieSameSite == 0 {
+		i.cookieSameSite = http.SameSiteNoneMode
+	}
+	if i.auditLogger == nil {
+		i.auditLogger = audit.NopLogger
+	}
+	if c.PersistentSessionDuration > 0 {
+		i.persistentSessions = newPersistentSessions(c.Config.SessionIdleTimeout)
+		i.persistentSessionDuration = c.PersistentSessionDuration
+	}
+	if c.Config.SessionIdleTimeout > 0 {
+		i.sessionActivity = newSessionActivity(c.Config.SessionIdleTimeout)
+	}
+	if c.Config.MaxConcurrentSessions > 0 {
+		if i.sessionActivity == nil {
+			return nil, fmt.Errorf("identifier session limit requires a session idle timeout")
+		}
+		i.sessionLimit = newSessionLimit(c.Config.MaxConcurrentSessions, c.Config.SessionLimitPolicy)
 	}
 
 	var err error
@@ -189,6 +235,12 @@ func (i *Identifier) AddRoutes(ctx context.Context, router *mux.Router) {
 	r.Handle("/identifier/_/saml2/slo", http.HandlerFunc(i.handleSAML2SingleLogoutService)).Methods(http.MethodGet).Name("saml2/slo")
 	r.Handle("/identifier/trampolin", http.HandlerFunc(i.handleTrampolin)).Methods(http.MethodGet).Name("trampolin")
 	r.Handle("/identifier/trampolin/trampolin.js", http.HandlerFunc(i.handleTrampolin)).Methods(http.MethodGet)
+	if len(i.adminSecret) > 0 {
+		r.Handle("/identifier/_/admin/sessions", i.adminHandler(http.HandlerFunc(i.handleAdminSessions))).Methods(http.MethodGet)
+		r.Handle("/identifier/_/admin/sessions/{id}", i.adminHandler(http.HandlerFunc(i.handleAdminSessionRevoke))).Methods(http.MethodDelete)
+		r.Handle("/identifier/_/admin/consents/{client_id}", i.adminHandler(http.HandlerFunc(i.handleAdminConsentRevoke))).Methods(http.MethodDelete)
+		r.Handle("/identifier/_/admin/authorities/{id}/jwks", i.adminHandler(http.HandlerFunc(i.handleAdminAuthorityJWKSRefresh))).Methods(http.MethodPost)
+	}
 
 	i.router = r
 
@@ -208,31 +260,93 @@ func (i *Identifier) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 // SetKey sets the provided key for the accociated identifier.
 func (i *Identifier) SetKey(key []byte) error {
-	var ce jose.ContentEncryption
-	var algo jose.KeyAlgorithm
+	encrypter, recipient, err := newEncrypter(key, "")
+	if err != nil {
+		return err
+	}
+
+	if len(key) < 32 {
+		i.logger.Warnf("identifier using encryption key size with %d bytes which is below 32 bytes", len(key))
+	} else {
+		ce, algo, _ := encryptionForKey(key)
+		i.logger.WithField("security", fmt.Sprintf("%s:%s", ce, algo)).Infoln("identifier set up")
+	}
+
+	i.encrypter = encrypter
+	i.recipient = recipient
+	return nil
+}
+
+// AddKeyWithID adds the provided key with the provided key id to the key ring
+// of the identifier. Added keys are used to decrypt cookies which carry their
+// key id.
+func (i *Identifier) AddKeyWithID(kid string, key []byte) error {
+	if kid == "" {
+		return fmt.Errorf("identifier encryption key id must not be empty")
+	}
+	if _, _, err := newEncrypter(key, kid); err != nil {
+		return err
+	}
+
+	if i.keys == nil {
+		i.keys = make(map[string][]byte)
+	}
+	i.keys[kid] = key
+	return nil
+}
+
+// SetKeyID selects the key with the provided key id from the key ring of the
+// identifier to encrypt cookies. Cookies encrypted from now on carry the key
+// id.
+func (i *Identifier) SetKeyID(kid string) error {
+	key, ok := i.keys[kid]
+	if !ok {
+		return fmt.Errorf("identifier encryption key with id %s not found", kid)
+	}
+	encrypter, _, err := newEncrypter(key, kid)
+	if err != nil {
+		return err
+	}
+
+	i.encrypter = encrypter
+	return nil
+}
+
+// decryptionKey returns the key to decrypt the provided token with, which is
+// the key ring key of the token's key id. Tokens without key id are
+// decrypted with the key set with SetKey.
+func (i *Identifier) decryptionKey(token *jwt.JSONWebToken) interface{} {
+	if len(token.Headers) > 0 && token.Headers[0].KeyID != "" {
+		if key, ok := i.keys[token.Headers[0].KeyID]; ok {
+			return key
+		}
+	}
+
+	return i.recipient.Key
+}
+
+func encryptionForKey(key []byte) (jose.ContentEncryption, jose.KeyAlgorithm, error) {
 	switch len(key) {
 	case 16:
-		ce = jose.A128GCM
-		algo = jose.A128GCMKW
+		return jose.A128GCM, jose.A128GCMKW, nil
 	case 24:
-		ce = jose.A192GCM
-		algo = jose.A192GCMKW
+		return jose.A192GCM, jose.A192GCMKW, nil
 	case 32:
-		ce = jose.A256GCM
-		algo = jose.A256GCMKW
+		return jose.A256GCM, jose.A256GCMKW, nil
 	default:
-		return fmt.Errorf("identifier invalid encryption key size. Need 16, 24 or 32 bytes")
+		return "", "", fmt.Errorf("identifier invalid encryption key size. Need 16, 24 or 32 bytes")
 	}
+}
 
-	if len(key) < 32 {
-		i.logger.Warnf("identifier using encryption key size with %d bytes which is below 32 bytes", len(key))
-	} else {
-		i.logger.WithField("security", fmt.Sprintf("%s:%s", ce, algo)).Infoln("identifier set up")
+func newEncrypter(key []byte, kid string) (jose.Encrypter, *jose.Recipient, error) {
+	ce, algo, err := encryptionForKey(key)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	recipient := jose.Recipient{
 		Algorithm: algo,
-		KeyID:     "",
+		KeyID:     kid,
 		Key:       key,
 	}
 	encrypter, err := jose.NewEncrypter(
@@ -241,12 +355,10 @@ func (i *Identifier) SetKey(key []byte) error {
 		nil,
 	)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	i.encrypter = encrypter
-	i.recipient = &recipient
-	return nil
+	return encrypter, &recipient, nil
 }
 
 // ErrorPage writes a HTML error page to the provided ResponseWriter.
@@ -257,9 +369,45 @@ func (i *Identifier) ErrorPage(rw http.ResponseWriter, code int, title string, m
 // SetUserToLogonCookie serializes the provided user into an encrypted string
 // and sets it as cookie on the provided http.ResponseWriter.
 func (i *Identifier) SetUserToLogonCookie(ctx context.Context, rw http.ResponseWriter, user *IdentifiedUser) error {
+	if user.logonSessionID == "" {
+		user.logonSessionID = rndm.GenerateRandomString(32)
+	}
+
+	// Set cookie.
+	err := i.refreshLogonCookie(rw, user)
+	if err != nil {
+		return err
+	}
+	if i.sessionActivity != nil {
+		i.sessionActivity.use(user)
+	}
+	// Trigger callbacks.
+	for _, f := range i.onSetLogonCallbacks {
+		err = f(ctx, rw, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refreshLogonCookie sets the logon cookie of the provided user on the
+// provided http.ResponseWriter, recording now as its last use.
+func (i *Identifier) refreshLogonCookie(rw http.ResponseWriter, user *IdentifiedUser) error {
+	user.lastUsedAt = time.Now()
+	serialized, err := i.serializeLogonToken(user, user.expiresAfter, nil)
+	if err != nil {
+		return err
+	}
+
+	return i.setLogonCookie(rw, serialized)
+}
+
+func (i *Identifier) serializeLogonToken(user *IdentifiedUser, expiresAfter *time.Time, extraClaims map[string]interface{}) (string, error) {
 	loggedOn, logonAt := user.LoggedOn()
 	if !loggedOn {
-		return fmt.Errorf("refused to set cookie for not logged on user")
+		return "", fmt.Errorf("refused to set cookie for not logged on user")
 	}
 
 	// Add standard claims.
@@ -270,49 +418,50 @@ func (i *Identifier) SetUserToLogonCookie(ctx context.Context, rw http.ResponseW
 		IssuedAt: jwt.NewNumericDate(logonAt),
 	}
 	// Add expiration, if set.
-	if user.expiresAfter != nil {
-		claims.Expiry = jwt.NewNumericDate(*user.expiresAfter)
+	if expiresAfter != nil {
+		claims.Expiry = jwt.NewNumericDate(*expiresAfter)
 	}
 
 	// Additional claims.
-	userClaims := map[string]interface{}(user.Claims())
+	userClaims := make(map[string]interface{})
+	for k, v := range user.Claims() {
+		userClaims[k] = v
+	}
 	if sessionRef := user.SessionRef(); sessionRef != nil {
 		userClaims[SessionIDClaim] = *sessionRef
 	}
 	if logonRef := user.LogonRef(); logonRef != nil {
 		userClaims[LogonRefClaim] = *logonRef
 	}
+	if user.persistentSessionID != "" {
+		userClaims[PersistentSessionIDClaim] = user.persistentSessionID
+	}
+	if !user.lastUsedAt.IsZero() {
+		userClaims[LastUsedAtClaim] = user.lastUsedAt.Unix()
+	}
 	if externalAuthorityID := user.ExternalAuthorityID(); externalAuthorityID != nil {
 		userClaims[ExternalAuthorityIDClaim] = *externalAuthorityID
 	}
 	if lockedScopes := user.LockedScopes(); lockedScopes != nil {
 		userClaims[LockedScopesClaim] = strings.Join(lockedScopes, " ")
 	}
-
-	// Serialize and encrypt cookie value.
-	serialized, err := jwt.Encrypted(i.encrypter).Claims(claims).Claims(userClaims).CompactSerialize()
-	if err != nil {
-		return err
+	if len(user.authorityClaims) > 0 {
+		userClaims[ExternalAuthorityClaimsClaim] = user.authorityClaims
 	}
-
-	// Set cookie.
-	err = i.setLogonCookie(rw, serialized)
-	if err != nil {
-		return err
+	if len(user.authorityScopes) > 0 {
+		userClaims[ExternalAuthorityScopesClaim] = strings.Join(user.authorityScopes, " ")
 	}
-	// Trigger callbacks.
-	for _, f := range i.onSetLogonCallbacks {
-		err = f(ctx, rw, user)
-		if err != nil {
-			return err
-		}
+	for k, v := range extraClaims {
+		userClaims[k] = v
 	}
 
-	return nil
+	// Serialize and encrypt cookie value.
+	return jwt.Encrypted(i.encrypter).Claims(claims).Claims(userClaims).CompactSerialize()
 }
 
 // UnsetLogonCookie adds cookie remove headers to the provided http.ResponseWriter
-// effectively implementing logout.
+// effectively implementing logout. The identity.SessionDestroyReason of the
+// provided context, if any, is passed on to the backend.
 func (i *Identifier) UnsetLogonCookie(ctx context.Context, user *IdentifiedUser, rw http.ResponseWriter) error {
 	// Remove cookie.
 	err := i.removeLogonCookie(rw)
@@ -321,6 +470,9 @@ func (i *Identifier) UnsetLogonCookie(ctx context.Context, user *IdentifiedUser,
 	}
 	// Destroy backend user session if any.
 	if user != nil {
+		if i.sessionActivity != nil {
+			i.sessionActivity.end(user.logonSessionID)
+		}
 		if sessionRef := user.SessionRef(); sessionRef != nil {
 			err = i.backend.DestroySession(ctx, sessionRef)
 			if err != nil {
@@ -343,6 +495,9 @@ func (i *Identifier) UnsetLogonCookie(ctx context.Context, user *IdentifiedUser,
 // based on the provided user. It optionally returns an uri which shall be used
 // as redirection target or an error.
 func (i *Identifier) EndSession(ctx context.Context, user *IdentifiedUser, rw http.ResponseWriter, postRedirectURI *url.URL, state string) (*url.URL, error) {
+	if _, ok := identity.SessionDestroyReasonFromContext(ctx); !ok {
+		ctx = identity.NewSessionDestroyReasonContext(ctx, identity.SessionDestroyReasonEndSession)
+	}
 	err := i.UnsetLogonCookie(ctx, user, rw)
 	if err != nil {
 		return nil, err
@@ -409,8 +564,11 @@ func (i *Identifier) EndSession(ctx context.Context, user *IdentifiedUser, rw ht
 
 // GetUserFromLogonCookie looks up the associated cookie name from the provided
 // request, parses it and returns the user containing the information found in
-// the coookie payload data.
-func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Request, maxAge time.Duration, refreshSession bool) (*IdentifiedUser, error) {
+// the coookie payload data. When refreshSession is true and a session idle
+// timeout is set, logons which have been idle for too long are removed and
+// otherwise the logon cookie is set again on the provided http.ResponseWriter
+// to record its use.
+func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, rw http.ResponseWriter, req *http.Request, maxAge time.Duration, refreshSession bool) (*IdentifiedUser, error) {
 	cookie, err := i.getLogonCookie(req)
 	if err != nil {
 		if err == http.ErrNoCookie {
@@ -419,17 +577,60 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 		return nil, err
 	}
 
+	user, _, err := i.parseLogonToken(ctx, cookie.Value, maxAge, refreshSession)
+	if err != nil || user == nil {
+		return user, err
+	}
+	if refreshSession && user.persistentSessionID != "" && i.persistentSessions != nil {
+		if !i.persistentSessions.valid(user.persistentSessionID, user.Subject()) {
+			// Ignore logons of persistent sessions which have been revoked.
+			i.logger.WithField("sub", user.Subject()).Debugln("identifier logon persistent session is no longer valid")
+			return nil, nil
+		}
+	}
+	if refreshSession && i.sessionActivity != nil {
+		if user.logonSessionID == "" {
+			// Logons from before logon session ids were added get one now.
+			user.logonSessionID = rndm.GenerateRandomString(32)
+		}
+		if i.sessionActivity.isRevoked(user.logonSessionID) {
+			// Remove logons which have been evicted by the session limit.
+			i.logger.WithField("sub", user.Subject()).Debugln("identifier logon session has been evicted")
+			i.removeLogonCookie(rw)
+			return nil, nil
+		}
+		if !i.sessionActivity.use(user) {
+			// Remove logons which have been idle for too long.
+			i.logger.WithField("sub", user.Subject()).Debugln("identifier logon session is idle")
+			i.removeLogonCookie(rw)
+			i.destroyIdleSession(ctx, req, user.Subject(), user.Username(), user.SessionRef())
+			return nil, nil
+		}
+		if user.persistentSessionID != "" && i.persistentSessions != nil {
+			i.persistentSessions.touch(user.persistentSessionID)
+		}
+		// Record the use in the logon cookie, so it survives restarts and
+		// is seen by all instances.
+		if err = i.refreshLogonCookie(rw, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (i *Identifier) parseLogonToken(ctx context.Context, value string, maxAge time.Duration, refreshSession bool) (*IdentifiedUser, map[string]interface{}, error) {
 	// Decrypt and parse cookie value.
-	token, err := jwt.ParseEncrypted(cookie.Value)
+	token, err := jwt.ParseEncrypted(value)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Parse claims.
 	var claims jwt.Claims
 	var userClaims map[string]interface{}
-	if claimsErr := token.Claims(i.recipient.Key, &claims, &userClaims); claimsErr != nil {
-		return nil, claimsErr
+	if claimsErr := token.Claims(i.decryptionKey(token), &claims, &userClaims); claimsErr != nil {
+		return nil, nil, claimsErr
 	}
 
 	// Validate claims.
@@ -442,13 +643,13 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 		Audience: jwt.Audience{audienceMarker[0]},
 	}); claimsErr != nil {
 		i.logger.WithError(claimsErr).Debugln("logon token claims validation failed")
-		return nil, nil
+		return nil, nil, nil
 	}
 	if claims.Subject == "" {
-		return nil, fmt.Errorf("invalid subject in logon token")
+		return nil, nil, fmt.Errorf("invalid subject in logon token")
 	}
 	if userClaims == nil {
-		return nil, fmt.Errorf("invalid user claims in logon token")
+		return nil, nil, fmt.Errorf("invalid user claims in logon token")
 	}
 
 	// New user with details from claims.
@@ -458,7 +659,8 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 		// TODO(longsleep): It is not verified here that the user still exists at
 		// our current backend. We still assign the backend happily here - probably
 		// needs some sort of veritification / lookup.
-		backend: i.backend,
+		backend:       i.backend,
+		subjectPrefix: i.subjectPrefix,
 
 		logonAt: claims.IssuedAt.Time(),
 	}
@@ -470,12 +672,12 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 	loggedOn, logonAt := user.LoggedOn()
 	if !loggedOn {
 		// Ignore logons which are not valid.
-		return nil, nil
+		return nil, nil, nil
 	}
 	if maxAge > 0 {
 		if logonAt.Add(maxAge).Before(time.Now()) {
 			// Ignore logon as it is no longer valid within maxAge.
-			return nil, nil
+			return nil, nil, nil
 		}
 	}
 
@@ -487,10 +689,10 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 			user.sessionRef = &sessionRef
 			// Ensure the session is still valid, by refreshing it.
 			if refreshSession {
-				err = i.backend.RefreshSession(ctx, user.Subject(), &sessionRef, userClaims)
+				err = i.backend.RefreshSession(ctx, user.backendSubject(), &sessionRef, userClaims)
 				if err != nil {
 					// Ignore logons which fail session refresh.
-					return nil, nil
+					return nil, nil, nil
 				}
 			}
 		}
@@ -502,19 +704,32 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 			user.logonRef = &logonRef
 		}
 	}
+	if v, ok := userClaims[PersistentSessionIDClaim].(string); ok && v != "" {
+		// Remember persistent session of the logon in user.
+		user.persistentSessionID = v
+	}
+	if v, ok := userClaims[LogonSessionIDClaim].(string); ok && v != "" {
+		user.logonSessionID = v
+	}
+	if v, ok := userClaims[LastUsedAtClaim].(float64); ok && v > 0 {
+		user.lastUsedAt = time.Unix(int64(v), 0)
+	} else {
+		// Logons from before last use was recorded were last used at logon.
+		user.lastUsedAt = logonAt
+	}
 	if v := userClaims[ExternalAuthorityIDClaim]; v != nil {
 		externalAuthorityID := v.(string)
 		if externalAuthorityID != "" {
 			authority, err := i.authorities.Lookup(ctx, externalAuthorityID)
 			if err != nil {
 				// Ignore logons which have set an unknown external authority.
-				return nil, nil
+				return nil, nil, nil
 			}
 			// TODO(longsleep): Check if authority is actually enabled. For now
 			// we check if it is ready.
 			if !authority.IsReady() {
 				// Ignore logons which have sent an authority which is not ready.
-				return nil, nil
+				return nil, nil, nil
 			}
 			user.externalAuthority = authority
 		}
@@ -526,6 +741,12 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 			user.lockedScopes = strings.Split(lockedScopes, " ")
 		}
 	}
+	if v, ok := userClaims[ExternalAuthorityClaimsClaim].(map[string]interface{}); ok && len(v) > 0 {
+		user.authorityClaims = v
+	}
+	if v, ok := userClaims[ExternalAuthorityScopesClaim].(string); ok && v != "" {
+		user.authorityScopes = strings.Split(v, " ")
+	}
 
 	// Fill additional claim.
 	user.claims = make(map[string]interface{})
@@ -548,6 +769,15 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 		case LockedScopesClaim:
 			// Already handled above.
 			continue
+		case ExternalAuthorityClaimsClaim, ExternalAuthorityScopesClaim:
+			// Already handled above.
+			continue
+		case PersistentSessionIDClaim, PersistentSessionTokenClaim:
+			// Handled by persistent session.
+			continue
+		case LogonSessionIDClaim, LastUsedAtClaim:
+			// Already handled above.
+			continue
 		case ObsoleteUserClaimsClaim:
 			// Keep and ignore for history reasons.
 			continue
@@ -562,13 +792,17 @@ func (i *Identifier) GetUserFromLogonCookie(ctx context.Context, req *http.Reque
 		}
 	}
 
-	return user, nil
+	return user, userClaims, nil
 }
 
 // GetUserFromID looks up the user identified by the provided userID by
 // requesting the associated backend.
 func (i *Identifier) GetUserFromID(ctx context.Context, userID string, sessionRef *string, requestedScopes map[string]bool) (*IdentifiedUser, error) {
-	user, err := i.backend.GetUser(ctx, userID, sessionRef, requestedScopes)
+	backendUserID, ok := i.backendSubject(userID)
+	if !ok {
+		return nil, nil
+	}
+	user, err := i.backend.GetUser(ctx, backendUserID, sessionRef, requestedScopes)
 	if err != nil {
 		return nil, err
 	}
@@ -579,14 +813,15 @@ func (i *Identifier) GetUserFromID(ctx context.Context, userID string, sessionRe
 	// XXX(longsleep): This is quite crappy. Move IdentifiedUser to a package
 	// which can be imported by backends so they directly can return that shit.
 	identifiedUser := &IdentifiedUser{
-		sub: user.Subject(),
+		sub: i.subjectPrefix + user.Subject(),
 
 		username: user.Username(),
 
-		backend: i.backend,
+		backend:       i.backend,
+		subjectPrefix: i.subjectPrefix,
 
 		sessionRef: sessionRef,
-		claims:     user.BackendClaims(),
+		claims:     i.withSubjectPrefixClaims(user.BackendClaims()),
 		scopes:     user.BackendScopes(),
 
 		lockedScopes: user.RequiredScopes(),
@@ -595,17 +830,31 @@ func (i *Identifier) GetUserFromID(ctx context.Context, userID string, sessionRe
 		identifiedUser.email = userWithEmail.Email()
 		identifiedUser.emailVerified = userWithEmail.EmailVerified()
 	}
+	if userWithPhone, ok := user.(identity.UserWithPhone); ok {
+		identifiedUser.phoneNumber = userWithPhone.PhoneNumber()
+		identifiedUser.phoneVerified = userWithPhone.PhoneNumberVerified()
+	}
+	if userWithAddress, ok := user.(identity.UserWithAddress); ok {
+		identifiedUser.address = userWithAddress.Address()
+	}
 	if userWithProfile, ok := user.(identity.UserWithProfile); ok {
 		identifiedUser.displayName = userWithProfile.Name()
 		identifiedUser.familyName = userWithProfile.FamilyName()
 		identifiedUser.givenName = userWithProfile.GivenName()
 	}
+	if userWithLocale, ok := user.(identity.UserWithLocale); ok {
+		identifiedUser.locale = userWithLocale.Locale()
+		identifiedUser.zoneinfo = userWithLocale.Zoneinfo()
+	}
 	if userWithID, ok := user.(identity.UserWithID); ok {
 		identifiedUser.id = userWithID.ID()
 	}
 	if userWithUniqueID, ok := user.(identity.UserWithUniqueID); ok {
 		identifiedUser.uid = userWithUniqueID.UniqueID()
 	}
+	if userWithPreferredUsername, ok := user.(identity.UserWithPreferredUsername); ok {
+		identifiedUser.preferredUsername = userWithPreferredUsername.PreferredUsername()
+	}
 
 	return identifiedUser, nil
 }
@@ -653,7 +902,7 @@ func (i *Identifier) GetConsentFromConsentCookie(ctx context.Context, rw http.Re
 	}
 
 	var consent Consent
-	if err = token.Claims(i.recipient.Key, &consent); err != nil {
+	if err = token.Claims(i.decryptionKey(token), &consent); err != nil {
 		return nil, err
 	}
 
@@ -695,7 +944,7 @@ func (i *Identifier) GetStateFromStateCookie(ctx context.Context, rw http.Respon
 	}
 
 	sd := &StateData{}
-	if err = token.Claims(i.recipient.Key, sd); err != nil {
+	if err = token.Claims(i.decryptionKey(token), sd); err != nil {
 		return nil, err
 	}
 
@@ -731,6 +980,16 @@ func (i *Identifier) ScopesSupported() []string {
 	return supportedScopes
 }
 
+// ClaimScopes returns the claims with configurable names of the accociated
+// Identifier's backend together with the scope they belong to.
+func (i *Identifier) ClaimScopes() map[string]string {
+	if backend, ok := i.backend.(backends.BackendWithClaimScopes); ok {
+		return backend.ClaimScopes()
+	}
+
+	return nil
+}
+
 // OnSetLogon implements a way to register hooks whenever logon information is
 // set by the accociated Identifier.
 func (i *Identifier) OnSetLogon(cb func(ctx context.Context, rw http.ResponseWriter, user identity.User) error) error {
@@ -745,6 +1004,13 @@ func (i *Identifier) OnUnsetLogon(cb func(ctx context.Context, rw http.ResponseW
 	return nil
 }
 
+// OnRevokeConsent implements a way to register hooks which revoke remembered
+// consent of a user for a client, used by the admin API.
+func (i *Identifier) OnRevokeConsent(cb func(ctx context.Context, sub string, clientID string) error) error {
+	i.onRevokeConsentCallbacks = append(i.onRevokeConsentCallbacks, cb)
+	return nil
+}
+
 func (i *Identifier) absoluteURLForRoute(name string) (*url.URL, error) {
 	uri, _ := url.Parse(i.Config.BaseURI.String())
 
@@ -0,0 +1,90 @@
+package identifier
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestLogonCookieKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIdentifier(t, 0)
+
+	setCookie := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		if err := i.SetUserToLogonCookie(ctx, rr, &IdentifiedUser{
+			sub:      "user1",
+			username: "user1",
+			backend:  i.backend,
+			logonAt:  time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return rr
+	}
+	valid := func(rr *httptest.ResponseRecorder) bool {
+		user, err := i.GetUserFromLogonCookie(ctx, httptest.NewRecorder(), newRequestWithCookies(rr.Result().Cookies()), 0, false)
+		return err == nil && user != nil
+	}
+	keyID := func(rr *httptest.ResponseRecorder) string {
+		token, err := jwt.ParseEncrypted(findCookie(rr.Result().Cookies(), i.logonCookieName).Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token.Headers[0].KeyID
+	}
+
+	legacy := setCookie()
+	if kid := keyID(legacy); kid != "" {
+		t.Errorf("expected no key id without key ring, got %q", kid)
+	}
+
+	// Rotate to a key ring key.
+	if err := i.AddKeyWithID("k1", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddKeyWithID("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.SetKeyID("k1"); err != nil {
+		t.Fatal(err)
+	}
+	first := setCookie()
+	if kid := keyID(first); kid != "k1" {
+		t.Errorf("expected cookie with key id k1, got %q", kid)
+	}
+
+	// Rotate again, all cookies remain valid.
+	if err := i.SetKeyID("k2"); err != nil {
+		t.Fatal(err)
+	}
+	second := setCookie()
+	if kid := keyID(second); kid != "k2" {
+		t.Errorf("expected cookie with key id k2, got %q", kid)
+	}
+	for name, rr := range map[string]*httptest.ResponseRecorder{"legacy": legacy, "k1": first, "k2": second} {
+		if !valid(rr) {
+			t.Errorf("expected %s cookie to be valid after rotation", name)
+		}
+	}
+
+	// Cookies of removed keys are no longer valid.
+	delete(i.keys, "k1")
+	if valid(first) {
+		t.Errorf("expected cookie of removed key to be invalid")
+	}
+
+	if err := i.SetKeyID("unknown"); err == nil {
+		t.Errorf("expected error for unknown key id")
+	}
+	if err := i.AddKeyWithID("", bytes.Repeat([]byte{3}, 32)); err == nil {
+		t.Errorf("expected error for empty key id")
+	}
+	if err := i.AddKeyWithID("k3", []byte("short")); err == nil {
+		t.Errorf("expected error for invalid key size")
+	}
+}
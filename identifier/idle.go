@@ -0,0 +1,208 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+)
+
+// sessionActivity tracks the last use of logon sessions, identified by their
+// logon session id, to expire sessions which have been idle for longer than
+// the idle timeout. The last use seen with the browser is also recorded in
+// the logon cookie, so logon sessions which are not tracked, for example
+// after a restart or on another instance, continue from there. Only uses
+// without the browser, like userinfo requests, are solely kept here. Logon
+// sessions evicted to stay within the session limit are remembered as
+// revoked until they would have expired for being idle anyway.
+type sessionActivity struct {
+	sync.Mutex
+
+	timeout time.Duration
+
+	table     map[string]*activeSession
+	revoked   map[string]time.Time
+	lastPurge time.Time
+}
+
+type activeSession struct {
+	sub                 string
+	persistentSessionID string
+	sessionRef          *string
+	logonAt             time.Time
+	lastUsedAt          time.Time
+}
+
+func newSessionActivity(timeout time.Duration) *sessionActivity {
+	return &sessionActivity{
+		timeout: timeout,
+
+		table:     make(map[string]*activeSession),
+		revoked:   make(map[string]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+// use marks the logon session of the provided user as used if it has not
+// been idle for too long since the last use recorded with the user or its
+// last tracked use, whichever is later. Returns false if the session is idle.
+func (sa *sessionActivity) use(user *IdentifiedUser) bool {
+	now := time.Now()
+
+	sa.Lock()
+	defer sa.Unlock()
+
+	if now.Sub(sa.lastPurge) > sa.timeout {
+		sa.purge(now)
+	}
+
+	lastUsedAt := user.lastUsedAt
+	session, ok := sa.table[user.logonSessionID]
+	if ok && session.sub != user.Subject() {
+		ok = false
+	}
+	if ok && session.lastUsedAt.After(lastUsedAt) {
+		lastUsedAt = session.lastUsedAt
+	}
+	if lastUsedAt.Add(sa.timeout).Before(now) {
+		delete(sa.table, user.logonSessionID)
+		return false
+	}
+	if !ok {
+		session = &activeSession{
+			sub:     user.Subject(),
+			logonAt: user.logonAt,
+		}
+		sa.table[user.logonSessionID] = session
+	}
+	session.persistentSessionID = user.persistentSessionID
+	session.sessionRef = user.SessionRef()
+	session.lastUsedAt = now
+
+	return true
+}
+
+// touch marks the tracked logon session identified by the provided id and
+// sub as used, if it is not yet idle. It returns the id of the persistent
+// session the logon session belongs to, if any.
+func (sa *sessionActivity) touch(id string, sub string) string {
+	now := time.Now()
+
+	sa.Lock()
+	defer sa.Unlock()
+
+	session, ok := sa.table[id]
+	if !ok || session.sub != sub || session.lastUsedAt.Add(sa.timeout).Before(now) {
+		return ""
+	}
+	session.lastUsedAt = now
+
+	return session.persistentSessionID
+}
+
+// end stops tracking the logon session identified by the provided id.
+func (sa *sessionActivity) end(id string) {
+	sa.Lock()
+	delete(sa.table, id)
+	sa.Unlock()
+}
+
+// list returns copies of the tracked logon sessions of the provided sub which
+// are not idle, keyed by logon session id.
+func (sa *sessionActivity) list(sub string) map[string]activeSession {
+	now := time.Now()
+
+	sa.Lock()
+	defer sa.Unlock()
+
+	sessions := make(map[string]activeSession)
+	for id, session := range sa.table {
+		if session.sub == sub && !session.lastUsedAt.Add(sa.timeout).Before(now) {
+			sessions[id] = *session
+		}
+	}
+
+	return sessions
+}
+
+// revoke stops tracking the logon session identified by the provided id and
+// refuses further uses of it.
+func (sa *sessionActivity) revoke(id string) {
+	sa.Lock()
+	delete(sa.table, id)
+	sa.revoked[id] = time.Now()
+	sa.Unlock()
+}
+
+// isRevoked returns true if the logon session identified by the provided id
+// has been revoked.
+func (sa *sessionActivity) isRevoked(id string) bool {
+	sa.Lock()
+	defer sa.Unlock()
+
+	_, ok := sa.revoked[id]
+	return ok
+}
+
+func (sa *sessionActivity) purge(now time.Time) {
+	for id, session := range sa.table {
+		if session.lastUsedAt.Add(sa.timeout).Before(now) {
+			delete(sa.table, id)
+		}
+	}
+	for id, revokedAt := range sa.revoked {
+		if revokedAt.Add(sa.timeout).Before(now) {
+			delete(sa.revoked, id)
+		}
+	}
+	sa.lastPurge = now
+}
+
+// TouchSession marks the logon session identified by the provided sub and
+// logon session id as used, together with the persistent session it belongs
+// to. Does nothing if no session idle timeout is set.
+func (i *Identifier) TouchSession(sub string, logonSessionID string) {
+	if i.sessionActivity == nil || logonSessionID == "" {
+		return
+	}
+	if persistentSessionID := i.sessionActivity.touch(logonSessionID, sub); persistentSessionID != "" && i.persistentSessions != nil {
+		i.persistentSessions.touch(persistentSessionID)
+	}
+}
+
+// destroyIdleSession destroys the backend session referenced by the provided
+// sessionRef of a session which expired for being idle and audits it.
+func (i *Identifier) destroyIdleSession(ctx context.Context, req *http.Request, sub string, username string, sessionRef *string) {
+	if sessionRef != nil {
+		if err := i.backend.DestroySession(identity.NewSessionDestroyReasonContext(ctx, identity.SessionDestroyReasonIdleTimeout), sessionRef); err != nil {
+			i.logger.WithError(err).Warnln("identifier failed to destroy idle backend session")
+		}
+	}
+	i.auditLog(req, &audit.Event{
+		Type:     audit.EventTypeSessionDestroyed,
+		Outcome:  audit.OutcomeSuccess,
+		Subject:  sub,
+		Username: username,
+		Reason:   string(identity.SessionDestroyReasonIdleTimeout),
+	})
+}
@@ -0,0 +1,261 @@
+package identifier
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+)
+
+func setIdleTestLogonCookie(t *testing.T, i *Identifier, logonAt time.Time) *http.Request {
+	rr := httptest.NewRecorder()
+	user := &IdentifiedUser{
+		sub:      "user1",
+		username: "user1",
+		backend:  i.backend,
+		logonAt:  logonAt,
+	}
+	if err := i.SetUserToLogonCookie(context.Background(), rr, user); err != nil {
+		t.Fatal(err)
+	}
+
+	return newRequestWithCookies(rr.Result().Cookies())
+}
+
+// useIdleTestLogonCookie gets the user of the logon cookie of the provided
+// request with session refresh and returns it together with a request with
+// the logon cookie as it was set again.
+func useIdleTestLogonCookie(t *testing.T, i *Identifier, req *http.Request) (*IdentifiedUser, *http.Request) {
+	rr := httptest.NewRecorder()
+	user, err := i.GetUserFromLogonCookie(context.Background(), rr, req, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie := findCookie(rr.Result().Cookies(), i.logonCookieName); cookie != nil && cookie.Value != "" {
+		req = newRequestWithCookies([]*http.Cookie{cookie})
+	}
+
+	return user, req
+}
+
+// ageIdleTestLogonCookie moves the last use recorded in the logon cookie of
+// the provided request back by the provided age.
+func ageIdleTestLogonCookie(t *testing.T, i *Identifier, req *http.Request, age time.Duration) *http.Request {
+	cookie, err := i.getLogonCookie(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := i.parseLogonToken(context.Background(), cookie.Value, 0, false)
+	if err != nil || user == nil {
+		t.Fatalf("failed to parse logon cookie: %v", err)
+	}
+	user.lastUsedAt = user.lastUsedAt.Add(-age)
+	serialized, err := i.serializeLogonToken(user, user.expiresAfter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newRequestWithCookies([]*http.Cookie{{Name: i.logonCookieName, Value: serialized}})
+}
+
+// ageIdleTestLogonSession moves the last use recorded in the logon cookie of
+// the provided request and the tracked last use of all logon sessions back
+// by the provided age.
+func ageIdleTestLogonSession(t *testing.T, i *Identifier, req *http.Request, age time.Duration) *http.Request {
+	ageSessionActivity(i, age)
+	return ageIdleTestLogonCookie(t, i, req, age)
+}
+
+func ageSessionActivity(i *Identifier, age time.Duration) {
+	for _, session := range i.sessionActivity.table {
+		session.lastUsedAt = session.lastUsedAt.Add(-age)
+	}
+}
+
+func TestSessionIdleTimeoutExpires(t *testing.T) {
+	i := newTestIdentifier(t, 0)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+
+	req := setIdleTestLogonCookie(t, i, time.Now())
+
+	user, req := useIdleTestLogonCookie(t, i, req)
+	if user == nil {
+		t.Fatal("expected logon session to be valid")
+	}
+
+	req = ageIdleTestLogonSession(t, i, req, 11*time.Minute)
+	rr := httptest.NewRecorder()
+	user, err := i.GetUserFromLogonCookie(context.Background(), rr, req, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != nil {
+		t.Error("expected idle logon session to be expired")
+	}
+	if cookie := findCookie(rr.Result().Cookies(), i.logonCookieName); cookie == nil || cookie.Expires.After(time.Now()) {
+		t.Errorf("expected idle logon cookie to be removed, got %v", cookie)
+	}
+
+	// Parsing without refreshing the session, for example on logoff, still
+	// returns the user.
+	user, err = i.GetUserFromLogonCookie(context.Background(), httptest.NewRecorder(), req, 0, false)
+	if err != nil || user == nil {
+		t.Errorf("expected logon cookie to be readable without session refresh: %v", err)
+	}
+}
+
+func TestSessionIdleTimeoutActiveSurvives(t *testing.T) {
+	i := newTestIdentifier(t, 0)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+
+	req := setIdleTestLogonCookie(t, i, time.Now().Add(-time.Hour))
+
+	// Used every 6 minutes, the session lives longer than the idle timeout.
+	var user *IdentifiedUser
+	for idx := 0; idx < 5; idx++ {
+		req = ageIdleTestLogonSession(t, i, req, 6*time.Minute)
+		if user, req = useIdleTestLogonCookie(t, i, req); user == nil {
+			t.Fatalf("expected actively used logon session to be valid after %d uses", idx)
+		}
+	}
+
+	// Userinfo and other fetches on behalf of the logon session count as use.
+	req = ageIdleTestLogonSession(t, i, req, 6*time.Minute)
+	i.TouchSession("user1", user.logonSessionID)
+	req = ageIdleTestLogonSession(t, i, req, 6*time.Minute)
+	if user, _ = useIdleTestLogonCookie(t, i, req); user == nil {
+		t.Error("expected touched logon session to be valid")
+	}
+}
+
+func TestSessionIdleTimeoutTouchSessionOnly(t *testing.T) {
+	i := newTestIdentifier(t, 0)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+
+	// Two logon sessions of the same user within the same second.
+	logonAt := time.Now()
+	req1 := setIdleTestLogonCookie(t, i, logonAt)
+	req2 := setIdleTestLogonCookie(t, i, logonAt)
+	user1, req1 := useIdleTestLogonCookie(t, i, req1)
+	user2, req2 := useIdleTestLogonCookie(t, i, req2)
+	if user1 == nil || user2 == nil {
+		t.Fatal("expected logon sessions to be valid")
+	}
+	if user1.logonSessionID == user2.logonSessionID {
+		t.Fatal("expected logon sessions to have different ids")
+	}
+
+	// Only the touched logon session is kept alive.
+	ageSessionActivity(i, 6*time.Minute)
+	i.TouchSession("user1", user1.logonSessionID)
+	ageSessionActivity(i, 6*time.Minute)
+	req1 = ageIdleTestLogonCookie(t, i, req1, 12*time.Minute)
+	req2 = ageIdleTestLogonCookie(t, i, req2, 12*time.Minute)
+	if user, _ := useIdleTestLogonCookie(t, i, req1); user == nil {
+		t.Error("expected touched logon session to be valid")
+	}
+	if user, _ := useIdleTestLogonCookie(t, i, req2); user != nil {
+		t.Error("expected other logon session of the same user to be expired")
+	}
+
+	// Ending a logon session does not end others of the same user.
+	req3 := setIdleTestLogonCookie(t, i, logonAt)
+	req4 := setIdleTestLogonCookie(t, i, logonAt)
+	user3, _ := useIdleTestLogonCookie(t, i, req3)
+	if err := i.UnsetLogonCookie(context.Background(), user3, httptest.NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := useIdleTestLogonCookie(t, i, req4); user == nil {
+		t.Error("expected logon session to be valid after another one ended")
+	}
+}
+
+func TestSessionIdleTimeoutUntracked(t *testing.T) {
+	i := newTestIdentifier(t, 0)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+
+	req := setIdleTestLogonCookie(t, i, time.Now().Add(-time.Hour))
+	idleReq := ageIdleTestLogonCookie(t, i, setIdleTestLogonCookie(t, i, time.Now().Add(-time.Hour)), 11*time.Minute)
+
+	// Logon sessions which are not tracked, for example from before a
+	// restart or from another instance, continue from the last use recorded
+	// in their logon cookie.
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+	if user, _ := useIdleTestLogonCookie(t, i, req); user == nil {
+		t.Error("expected untracked logon session to be valid")
+	}
+	if user, _ := useIdleTestLogonCookie(t, i, idleReq); user != nil {
+		t.Error("expected untracked idle logon session to be expired")
+	}
+}
+
+func TestPersistentSessionIdleTimeout(t *testing.T) {
+	i := newTestIdentifier(t, time.Hour)
+	i.persistentSessions = newPersistentSessions(10 * time.Minute)
+
+	id, token := i.persistentSessions.create("user1", nil, time.Now().Add(time.Hour))
+	token, _, ok := i.persistentSessions.rotate(id, "user1", token)
+	if !ok {
+		t.Fatal("expected persistent session to be valid")
+	}
+
+	i.persistentSessions.table[id].lastUsedAt = time.Now().Add(-11 * time.Minute)
+	if _, _, ok = i.persistentSessions.rotate(id, "user1", token); ok {
+		t.Error("expected idle persistent session to be expired")
+	}
+}
+
+func TestSessionIdleTimeoutDestroysSession(t *testing.T) {
+	i := newTestIdentifier(t, time.Hour)
+	backend := &destroyRecordingBackend{}
+	i.backend = backend
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+	i.persistentSessions = newPersistentSessions(10 * time.Minute)
+
+	logonRef := "ref-logon"
+	user := &IdentifiedUser{
+		sub:        "user1",
+		username:   "user1",
+		backend:    i.backend,
+		logonAt:    time.Now(),
+		sessionRef: &logonRef,
+	}
+	rr := httptest.NewRecorder()
+	if err := i.SetUserToLogonCookie(context.Background(), rr, user); err != nil {
+		t.Fatal(err)
+	}
+	req := newRequestWithCookies(rr.Result().Cookies())
+
+	req = ageIdleTestLogonSession(t, i, req, 11*time.Minute)
+	if user, err := i.GetUserFromLogonCookie(context.Background(), httptest.NewRecorder(), req, 0, true); err != nil || user != nil {
+		t.Fatalf("expected idle logon session to be expired: %v", err)
+	}
+
+	// Idle persistent sessions are destroyed when the user signs in again.
+	persistentRef := "ref-persistent"
+	id, _ := i.persistentSessions.create("user1", &persistentRef, time.Now().Add(time.Hour))
+	i.persistentSessions.table[id].lastUsedAt = time.Now().Add(-11 * time.Minute)
+	if err := i.SetUserToPersistentCookie(context.Background(), httptest.NewRecorder(), req, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backend.destroyed) != 2 || backend.destroyed[0] != logonRef || backend.destroyed[1] != persistentRef {
+		t.Errorf("expected idle backend sessions to be destroyed once, got %v", backend.destroyed)
+	}
+	for _, reason := range backend.reasons {
+		if reason != identity.SessionDestroyReasonIdleTimeout {
+			t.Errorf("expected backend to receive reason %v, got %v", identity.SessionDestroyReasonIdleTimeout, reason)
+		}
+	}
+	if count := strings.Count(buf.String(), `"reason":"idle timeout"`); count != 2 {
+		t.Errorf("expected 2 idle timeout audit events, got %d: %s", count, buf.String())
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/longsleep/rndm"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+)
+
+// Session limit policies, selecting what happens when a user already has
+// the maximum number of concurrent sessions.
+const (
+	SessionLimitPolicyReject      = "reject"
+	SessionLimitPolicyEvictOldest = "evict-oldest"
+)
+
+// ErrTooManySessions is returned when a new session is refused because the
+// user already has the maximum number of concurrent sessions.
+var ErrTooManySessions = errors.New("too many concurrent sessions")
+
+// sessionLimit caps the number of concurrent active sessions per sub. Active
+// sessions are the logon sessions tracked by the session activity which are
+// not idle, and the persistent sessions. A persistent session and the logon
+// sessions restored from it count as one session.
+type sessionLimit struct {
+	sync.Mutex
+
+	max         int
+	evictOldest bool
+}
+
+func newSessionLimit(max int, policy string) *sessionLimit {
+	return &sessionLimit{
+		max:         max,
+		evictOldest: policy == SessionLimitPolicyEvictOldest,
+	}
+}
+
+// A limitedSession groups the logon sessions and the persistent session,
+// if any, which count as one session of a user.
+type limitedSession struct {
+	logonSessionIDs     []string
+	persistentSessionID string
+	sessionRefs         []*string
+	createdAt           time.Time
+}
+
+func (ls *limitedSession) addSessionRef(sessionRef *string) {
+	if sessionRef == nil {
+		return
+	}
+	for _, ref := range ls.sessionRefs {
+		if *ref == *sessionRef {
+			return
+		}
+	}
+	ls.sessionRefs = append(ls.sessionRefs, sessionRef)
+}
+
+// activeSessions returns the active sessions of the provided sub.
+func (i *Identifier) activeSessions(sub string) []*limitedSession {
+	grouped := make(map[string]*limitedSession)
+	for id, session := range i.sessionActivity.list(sub) {
+		key := "l:" + id
+		if session.persistentSessionID != "" {
+			key = "p:" + session.persistentSessionID
+		}
+		ls, ok := grouped[key]
+		if !ok {
+			ls = &limitedSession{
+				persistentSessionID: session.persistentSessionID,
+				createdAt:           session.logonAt,
+			}
+			grouped[key] = ls
+		}
+		ls.logonSessionIDs = append(ls.logonSessionIDs, id)
+		ls.addSessionRef(session.sessionRef)
+		if session.logonAt.Before(ls.createdAt) {
+			ls.createdAt = session.logonAt
+		}
+	}
+	if i.persistentSessions != nil {
+		for id, session := range i.persistentSessions.list(sub) {
+			key := "p:" + id
+			ls, ok := grouped[key]
+			if !ok {
+				ls = &limitedSession{
+					persistentSessionID: id,
+					createdAt:           session.createdAt,
+				}
+				grouped[key] = ls
+			}
+			ls.addSessionRef(session.sessionRef)
+			if session.createdAt.Before(ls.createdAt) {
+				ls.createdAt = session.createdAt
+			}
+		}
+	}
+
+	sessions := make([]*limitedSession, 0, len(grouped))
+	for _, ls := range grouped {
+		sessions = append(sessions, ls)
+	}
+
+	return sessions
+}
+
+// startSession starts a new logon session for the provided user, who just
+// signed in. If a session limit is set and the user already has the maximum
+// number of concurrent sessions, either ErrTooManySessions is returned or the
+// oldest sessions are evicted. Evicted sessions have their backend session
+// destroyed and are audited.
+func (i *Identifier) startSession(ctx context.Context, req *http.Request, user *IdentifiedUser) error {
+	user.logonSessionID = rndm.GenerateRandomString(32)
+	if i.sessionLimit == nil {
+		return nil
+	}
+
+	i.sessionLimit.Lock()
+	defer i.sessionLimit.Unlock()
+
+	sessions := i.activeSessions(user.Subject())
+	for len(sessions) >= i.sessionLimit.max {
+		if !i.sessionLimit.evictOldest {
+			return ErrTooManySessions
+		}
+		oldest := 0
+		for idx, session := range sessions {
+			if session.createdAt.Before(sessions[oldest].createdAt) {
+				oldest = idx
+			}
+		}
+		i.evictSession(ctx, req, user, sessions[oldest])
+		sessions = append(sessions[:oldest], sessions[oldest+1:]...)
+	}
+
+	// Track the new session right away, so that it is counted by further
+	// logons of the user.
+	user.lastUsedAt = time.Now()
+	i.sessionActivity.use(user)
+
+	return nil
+}
+
+func (i *Identifier) evictSession(ctx context.Context, req *http.Request, user *IdentifiedUser, session *limitedSession) {
+	for _, id := range session.logonSessionIDs {
+		i.sessionActivity.revoke(id)
+	}
+	if session.persistentSessionID != "" && i.persistentSessions != nil {
+		i.persistentSessions.revoke(session.persistentSessionID)
+	}
+	for _, sessionRef := range session.sessionRefs {
+		if err := i.backend.DestroySession(identity.NewSessionDestroyReasonContext(ctx, identity.SessionDestroyReasonEvicted), sessionRef); err != nil {
+			i.logger.WithError(err).Warnln("identifier failed to destroy evicted backend session")
+		}
+	}
+	i.logger.WithField("sub", user.Subject()).Debugln("identifier evicted oldest session")
+	i.auditLog(req, &audit.Event{
+		Type:     audit.EventTypeSessionDestroyed,
+		Outcome:  audit.OutcomeSuccess,
+		Subject:  user.Subject(),
+		Username: user.Username(),
+		Reason:   string(identity.SessionDestroyReasonEvicted),
+	})
+}
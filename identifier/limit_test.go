@@ -0,0 +1,147 @@
+package identifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
+)
+
+func newTestLimitedIdentifier(t *testing.T, max int, policy string) *Identifier {
+	i := newTestIdentifier(t, time.Hour)
+	i.sessionActivity = newSessionActivity(10 * time.Minute)
+	i.persistentSessions = newPersistentSessions(10 * time.Minute)
+	i.sessionLimit = newSessionLimit(max, policy)
+
+	return i
+}
+
+func testLogon(t *testing.T, i *Identifier, username string, rememberMe bool) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(&LogonRequest{
+		State:      "state",
+		Params:     []string{username, testPassword, ModeLogonUsernamePassword},
+		RememberMe: rememberMe,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/identifier/_/logon", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	i.handleLogon(rr, req)
+	if rr.Code == http.StatusOK && rememberMe && findCookie(rr.Result().Cookies(), persistentCookieName) == nil {
+		t.Fatal("persistent cookie not set")
+	}
+
+	return rr
+}
+
+func TestSessionLimitReject(t *testing.T) {
+	i := newTestLimitedIdentifier(t, 2, SessionLimitPolicyReject)
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+
+	for idx := 0; idx < 2; idx++ {
+		if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+			t.Fatalf("expected logon %d to succeed, got status %d", idx, rr.Code)
+		}
+	}
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected logon above the session limit to be rejected, got status %d", rr.Code)
+	}
+	if rr := testLogon(t, i, "user1", true); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected remember me logon above the session limit to be rejected, got status %d", rr.Code)
+	}
+	if rr := testLogon(t, i, "user2", false); rr.Code != http.StatusOK {
+		t.Errorf("expected session limit to be per user, got status %d", rr.Code)
+	}
+	if count := len(i.persistentSessions.list("user1")); count != 0 {
+		t.Errorf("expected no persistent session for rejected logon, got %d", count)
+	}
+	if !strings.Contains(buf.String(), `"reason":"too many sessions"`) {
+		t.Errorf("expected rejected logon to be audited: %s", buf.String())
+	}
+
+	// Idle sessions do not count.
+	ageSessionActivity(i, 11*time.Minute)
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+		t.Errorf("expected logon to succeed after sessions became idle, got status %d", rr.Code)
+	}
+}
+
+func TestSessionLimitCountsRememberMe(t *testing.T) {
+	i := newTestLimitedIdentifier(t, 2, SessionLimitPolicyReject)
+
+	// A remember me logon is one session, together with its logon session.
+	if rr := testLogon(t, i, "user1", true); rr.Code != http.StatusOK {
+		t.Fatalf("expected remember me logon to succeed, got status %d", rr.Code)
+	}
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+		t.Fatalf("expected logon to succeed, got status %d", rr.Code)
+	}
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected logon above the session limit to be rejected, got status %d", rr.Code)
+	}
+
+	// The persistent session still counts when its logon session is gone.
+	ageSessionActivity(i, 11*time.Minute)
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+		t.Fatalf("expected logon to succeed, got status %d", rr.Code)
+	}
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected persistent session to be counted, got status %d", rr.Code)
+	}
+}
+
+func TestSessionLimitEvictOldest(t *testing.T) {
+	i := newTestLimitedIdentifier(t, 2, SessionLimitPolicyEvictOldest)
+	backend := &destroyRecordingBackend{}
+	i.backend = backend
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+
+	oldest := testLogon(t, i, "user1", false)
+	if oldest.Code != http.StatusOK {
+		t.Fatalf("expected logon to succeed, got status %d", oldest.Code)
+	}
+	sessionRef := "ref1"
+	for _, session := range i.sessionActivity.table {
+		session.sessionRef = &sessionRef
+		session.logonAt = session.logonAt.Add(-time.Minute)
+	}
+	if rr := testLogon(t, i, "user1", true); rr.Code != http.StatusOK {
+		t.Fatalf("expected remember me logon to succeed, got status %d", rr.Code)
+	}
+
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+		t.Fatalf("expected logon above the session limit to succeed, got status %d", rr.Code)
+	}
+	if count := len(i.sessionActivity.list("user1")); count != 2 {
+		t.Errorf("expected 2 logon sessions, got %d", count)
+	}
+	rr := httptest.NewRecorder()
+	req := newRequestWithCookies(oldest.Result().Cookies())
+	if user, err := i.GetUserFromLogonCookie(context.Background(), rr, req, 0, true); err != nil || user != nil {
+		t.Errorf("expected logon of the oldest session to be rejected: %v", err)
+	}
+	if cookie := findCookie(rr.Result().Cookies(), i.logonCookieName); cookie == nil || cookie.Value != "" {
+		t.Error("expected logon cookie of the oldest session to be removed")
+	}
+	if len(backend.destroyed) != 1 || backend.destroyed[0] != sessionRef || backend.reasons[0] != identity.SessionDestroyReasonEvicted {
+		t.Errorf("expected backend session of evicted session to be destroyed, got %v", backend.destroyed)
+	}
+
+	// Evicting a remember me session revokes its persistent session.
+	if rr := testLogon(t, i, "user1", false); rr.Code != http.StatusOK {
+		t.Fatalf("expected logon above the session limit to succeed, got status %d", rr.Code)
+	}
+	if count := len(i.persistentSessions.list("user1")); count != 0 {
+		t.Errorf("expected persistent session of the evicted session to be revoked, got %d", count)
+	}
+	if !strings.Contains(buf.String(), `"reason":"evicted"`) {
+		t.Errorf("expected eviction to be audited: %s", buf.String())
+	}
+}
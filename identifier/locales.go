@@ -0,0 +1,71 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"strings"
+)
+
+// DefaultUILocale is the locale used when none of the requested ui_locales
+// match the available locales.
+const DefaultUILocale = "en"
+
+// supportedUILocales lists the locales for which the identifier web app
+// ships translations.
+var supportedUILocales = []string{
+	"en",
+	"de",
+	"es",
+	"fr",
+	"it",
+	"nl",
+	"zh-CN",
+}
+
+// MatchUILocale returns the available locale which best matches the provided
+// ui_locales values in order of preference. Exact matches take precedence
+// over matches of the base language. If nothing matches, the default locale
+// is returned.
+func (i *Identifier) MatchUILocale(uiLocales []string) string {
+	available := i.Config.UILocales
+	if len(available) == 0 {
+		available = supportedUILocales
+	}
+
+	for _, requested := range uiLocales {
+		for _, locale := range available {
+			if strings.EqualFold(requested, locale) {
+				return locale
+			}
+		}
+		base := strings.SplitN(requested, "-", 2)[0]
+		for _, locale := range available {
+			if strings.EqualFold(base, strings.SplitN(locale, "-", 2)[0]) {
+				return locale
+			}
+		}
+	}
+
+	for _, locale := range available {
+		if locale == DefaultUILocale {
+			return locale
+		}
+	}
+
+	return available[0]
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logonLockoutMaxEntries is the maximum number of usernames for which failed
+// logons are tracked at the same time.
+const logonLockoutMaxEntries = 100000
+
+// logonLockout tracks failed logons per username and locks out a username
+// after too many failed logons within the lockout duration. Usernames which
+// match any of the exempt patterns are never locked out. Expired entries are
+// purged at most once per lockout duration and no more than maxEntries
+// usernames are tracked. When full, the oldest entry with the fewest failures
+// is evicted to make room. Flooding the table with new usernames thus cannot
+// undo the failures of a username under attack, unless it has only one and
+// the table is replaced entirely before the next attempt.
+type logonLockout struct {
+	sync.Mutex
+
+	maxAttempts int
+	duration    time.Duration
+	exempt      []string
+	maxEntries  int
+
+	failures  map[string]*logonFailures
+	lastPurge time.Time
+}
+
+type logonFailures struct {
+	count int
+	first time.Time
+}
+
+func newLogonLockout(maxAttempts int, duration time.Duration, exempt []string) *logonLockout {
+	patterns := make([]string, len(exempt))
+	for idx, pattern := range exempt {
+		patterns[idx] = strings.ToLower(pattern)
+	}
+
+	return &logonLockout{
+		maxAttempts: maxAttempts,
+		duration:    duration,
+		exempt:      patterns,
+		maxEntries:  logonLockoutMaxEntries,
+
+		failures:  make(map[string]*logonFailures),
+		lastPurge: time.Now(),
+	}
+}
+
+// isExempt returns true if the provided username matches any of the exempt
+// patterns of the associated lockout.
+func (l *logonLockout) isExempt(username string) bool {
+	username = strings.ToLower(username)
+	for _, pattern := range l.exempt {
+		if matched, _ := path.Match(pattern, username); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// locked returns true if the provided username is currently locked out.
+func (l *logonLockout) locked(username string) bool {
+	if l.maxAttempts <= 0 || l.isExempt(username) {
+		return false
+	}
+	username = strings.ToLower(username)
+
+	l.Lock()
+	defer l.Unlock()
+
+	failures, ok := l.failures[username]
+	if !ok {
+		return false
+	}
+	if time.Since(failures.first) > l.duration {
+		delete(l.failures, username)
+		return false
+	}
+
+	return failures.count >= l.maxAttempts
+}
+
+// fail records a failed logon for the provided username.
+func (l *logonLockout) fail(username string) {
+	if l.maxAttempts <= 0 || l.isExempt(username) {
+		return
+	}
+	username = strings.ToLower(username)
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPurge) > l.duration {
+		l.purge(now)
+	}
+
+	failures, ok := l.failures[username]
+	if !ok || now.Sub(failures.first) > l.duration {
+		if !ok && len(l.failures) >= l.maxEntries {
+			l.evict()
+		}
+		failures = &logonFailures{
+			first: now,
+		}
+		l.failures[username] = failures
+	}
+	failures.count++
+}
+
+// purge removes the failed logons which are older than the lockout duration.
+func (l *logonLockout) purge(now time.Time) {
+	for username, failures := range l.failures {
+		if now.Sub(failures.first) > l.duration {
+			delete(l.failures, username)
+		}
+	}
+	l.lastPurge = now
+}
+
+// evict removes the entry with the fewest failed logons, preferring the
+// oldest one if there are several.
+func (l *logonLockout) evict() {
+	var evictUsername string
+	var evictFailures *logonFailures
+	for username, failures := range l.failures {
+		if evictFailures == nil ||
+			failures.count < evictFailures.count ||
+			(failures.count == evictFailures.count && failures.first.Before(evictFailures.first)) {
+			evictUsername = username
+			evictFailures = failures
+		}
+	}
+	if evictFailures != nil {
+		delete(l.failures, evictUsername)
+	}
+}
+
+// reset clears the failed logons of the provided username.
+func (l *logonLockout) reset(username string) {
+	if l.maxAttempts <= 0 {
+		return
+	}
+	username = strings.ToLower(username)
+
+	l.Lock()
+	delete(l.failures, username)
+	l.Unlock()
+}
@@ -0,0 +1,180 @@
+package identifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libregraph/lico/audit"
+)
+
+func TestLogonLockout(t *testing.T) {
+	l := newLogonLockout(3, time.Minute, []string{"monitor-*", "Backup"})
+
+	for idx := 0; idx < 3; idx++ {
+		if l.locked("user1") {
+			t.Fatalf("user locked out after %d failed logons", idx)
+		}
+		l.fail("user1")
+	}
+	if !l.locked("user1") {
+		t.Errorf("user not locked out after 3 failed logons")
+	}
+	if !l.locked("USER1") {
+		t.Errorf("lockout must not depend on username case")
+	}
+	l.reset("user1")
+	if l.locked("user1") {
+		t.Errorf("user still locked out after reset")
+	}
+
+	for _, username := range []string{"monitor-probe", "backup"} {
+		for idx := 0; idx < 10; idx++ {
+			l.fail(username)
+		}
+		if l.locked(username) {
+			t.Errorf("exempt username %s was locked out", username)
+		}
+	}
+}
+
+func TestLogonLockoutPurge(t *testing.T) {
+	l := newLogonLockout(3, time.Minute, nil)
+	l.maxEntries = 3
+
+	for _, username := range []string{"user1", "user2", "user3"} {
+		l.fail(username)
+	}
+	// The limit is reached, further usernames evict the entry with the
+	// fewest failures.
+	l.fail("user2")
+	l.fail("user4")
+	if len(l.failures) != 3 {
+		t.Fatalf("expected 3 tracked usernames, got %d", len(l.failures))
+	}
+	if _, ok := l.failures["user1"]; ok {
+		t.Errorf("oldest username with the fewest failures was not evicted")
+	}
+	if _, ok := l.failures["user4"]; !ok {
+		t.Errorf("new username not tracked at the limit")
+	}
+	// Known usernames are still counted.
+	l.fail("user2")
+	if !l.locked("user2") {
+		t.Errorf("known username not locked out at the limit")
+	}
+
+	// Expired entries are purged on the next failure after the duration.
+	for _, failures := range l.failures {
+		failures.first = failures.first.Add(-2 * time.Minute)
+	}
+	l.lastPurge = l.lastPurge.Add(-2 * time.Minute)
+	l.fail("user5")
+	if len(l.failures) != 1 {
+		t.Errorf("expected expired usernames to be purged, got %d tracked", len(l.failures))
+	}
+	if _, ok := l.failures["user5"]; !ok {
+		t.Errorf("username not tracked after purge")
+	}
+}
+
+func TestLogonLockoutFull(t *testing.T) {
+	l := newLogonLockout(3, time.Minute, nil)
+	l.maxEntries = 10
+
+	flood := func(prefix string, count int) {
+		for idx := 0; idx < count; idx++ {
+			l.fail(fmt.Sprintf("%s-%d", prefix, idx))
+		}
+	}
+
+	// Fill the table with junk usernames, then attack the target while
+	// continuing to flood the table in between attempts.
+	flood("junk", l.maxEntries)
+	for idx := 0; idx < 3; idx++ {
+		if l.locked("target") {
+			t.Fatalf("target locked out after %d failed logons", idx)
+		}
+		l.fail("target")
+		if idx == 0 {
+			flood("junk0", l.maxEntries-1)
+		} else {
+			// Once the target has more failures than the junk usernames,
+			// no amount of them evicts it.
+			flood(fmt.Sprintf("junk%d", idx), 2*l.maxEntries)
+		}
+	}
+	if !l.locked("target") {
+		t.Errorf("target not locked out with a full table")
+	}
+	if len(l.failures) > l.maxEntries {
+		t.Errorf("tracked usernames exceed the limit: %d", len(l.failures))
+	}
+}
+
+func TestLogonLockoutExpiration(t *testing.T) {
+	l := newLogonLockout(1, time.Minute, nil)
+
+	l.fail("user1")
+	if !l.locked("user1") {
+		t.Fatalf("user not locked out")
+	}
+	l.failures["user1"].first = time.Now().Add(-2 * time.Minute)
+	if l.locked("user1") {
+		t.Errorf("user still locked out after lockout duration")
+	}
+}
+
+func TestLogonLockoutDisabled(t *testing.T) {
+	l := newLogonLockout(0, time.Minute, nil)
+
+	for idx := 0; idx < 10; idx++ {
+		l.fail("user1")
+	}
+	if l.locked("user1") {
+		t.Errorf("user locked out with disabled lockout")
+	}
+}
+
+func TestLogonHandlerLockoutExempt(t *testing.T) {
+	i := newTestIdentifier(t, time.Duration(0))
+	i.lockout = newLogonLockout(2, time.Minute, []string{"svc-*"})
+	var buf bytes.Buffer
+	i.auditLogger = audit.NewJSONLogger(&buf)
+
+	logon := func(username string, password string) int {
+		body, _ := json.Marshal(&LogonRequest{
+			State:  "state",
+			Params: []string{username, password, ModeLogonUsernamePassword},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/identifier/_/logon", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		i.handleLogon(rr, req)
+		return rr.Code
+	}
+
+	for idx := 0; idx < 5; idx++ {
+		logon("user1", "wrong-password")
+		logon("svc-monitor", "wrong-password")
+	}
+
+	if code := logon("user1", testPassword); code != http.StatusTooManyRequests {
+		t.Errorf("expected locked out user to be rejected, got status %d", code)
+	}
+	if code := logon("svc-monitor", testPassword); code != http.StatusOK {
+		t.Errorf("expected exempt user to logon, got status %d", code)
+	}
+
+	// Failed logons of exempt users are still audited.
+	if count := strings.Count(buf.String(), `"username":"svc-monitor"`); count != 6 {
+		t.Errorf("expected 6 audit records for exempt user, got %d", count)
+	}
+	if !strings.Contains(buf.String(), `"reason":"locked out"`) {
+		t.Errorf("expected lockout to be audited: %s", buf.String())
+	}
+}
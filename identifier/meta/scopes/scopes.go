@@ -25,6 +25,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	konnect "github.com/libregraph/lico"
+	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
 const (
@@ -42,6 +43,11 @@ var defaultScopesMap = map[string]string{
 	oidc.ScopeEmail:   scopeAliasBasic,
 	oidc.ScopeProfile: scopeAliasBasic,
 
+	konnectoidc.ScopePhone:   scopeAliasBasic,
+	konnectoidc.ScopeAddress: scopeAliasBasic,
+	konnectoidc.ScopeGroups:  scopeAliasBasic,
+	konnectoidc.ScopeRoles:   scopeAliasBasic,
+
 	konnect.ScopeNumericID:    scopeAliasBasic,
 	konnect.ScopeUniqueUserID: scopeAliasBasic,
 	konnect.ScopeRawSubject:   scopeAliasBasic,
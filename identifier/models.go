@@ -33,8 +33,9 @@ import (
 type LogonRequest struct {
 	State string `json:"state"`
 
-	Params []string      `json:"params"`
-	Hello  *HelloRequest `json:"hello"`
+	Params     []string      `json:"params"`
+	Hello      *HelloRequest `json:"hello"`
+	RememberMe bool          `json:"remember_me"`
 }
 
 // A LogonResponse holds a response as sent by the logon endpoint.
@@ -107,6 +108,8 @@ type HelloResponse struct {
 	ClientDetails *clients.Details `json:"client,omitempty"`
 	Meta          *meta.Meta       `json:"meta,omitempty"`
 	Branding      *meta.Branding   `json:"branding,omitempty"`
+
+	RememberMe bool `json:"remember_me,omitempty"`
 }
 
 // A StateRequest is a general request with a state.
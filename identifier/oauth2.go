@@ -100,7 +100,9 @@ func (i *Identifier) writeOAuth2Start(rw http.ResponseWriter, req *http.Request,
 	}
 	query.Add("scope", strings.Join(authority.Scopes, " "))
 	query.Add("redirect_uri", i.oauth2CbEndpointURI.String())
-	query.Add("nonce", rndm.GenerateRandomString(32))
+	nonce := rndm.GenerateRandomString(32)
+	sd.Extra["nonce"] = nonce
+	query.Add("nonce", nonce)
 	if authority.CodeChallengeMethod != "" {
 		codeVerifier := rndm.GenerateRandomString(32)
 		sd.Extra["code_verifier"] = codeVerifier
@@ -230,12 +232,7 @@ func (i *Identifier) writeOAuth2Cb(rw http.ResponseWriter, req *http.Request) {
 
 				Scopes: authority.Scopes,
 			}
-			var httpClient *http.Client
-			if authority.Insecure {
-				httpClient = utils.InsecureHTTPClient
-			} else {
-				httpClient = utils.DefaultHTTPClient
-			}
+			httpClient := authority.HTTPClient()
 			t, exchangeErr := config.Exchange(
 				context.WithValue(req.Context(), oauth2.HTTPClient, httpClient),
 				req.Form.Get("code"),
@@ -286,8 +283,19 @@ func (i *Identifier) writeOAuth2Cb(rw http.ResponseWriter, req *http.Request) {
 			break
 		}
 
-		// Parse and validate IDToken.
-		idToken, idTokenParseErr := jwt.ParseWithClaims(authenticationSuccess.IDToken, userInfoClaims, authority.JWTKeyfunc())
+		// Parse and validate IDToken. Time based claims are validated with
+		// leeway to tolerate clock skew between the authority and us.
+		idTokenClaims := jwt.MapClaims{}
+		idToken, idTokenParseErr := jwt.NewParser(jwt.WithoutClaimsValidation()).ParseWithClaims(authenticationSuccess.IDToken, idTokenClaims, authority.JWTKeyfunc())
+		if idTokenParseErr == nil {
+			idTokenParseErr = utils.ValidateTimeClaims(idTokenClaims)
+			if idTokenParseErr == nil {
+				// Pin issuer, audience and nonce to prevent substitution
+				// of tokens issued for other clients or requests.
+				nonce, _ := sd.Extra["nonce"].(string)
+				idTokenParseErr = authority.ValidateIDTokenClaims(idTokenClaims, nonce)
+			}
+		}
 		if idTokenParseErr != nil {
 			if authority.Insecure {
 				i.logger.WithField("client_id", sd.ClientID).WithError(idTokenParseErr).Warnln("identifier ignoring validation error for insecure authority")
@@ -297,10 +305,17 @@ func (i *Identifier) writeOAuth2Cb(rw http.ResponseWriter, req *http.Request) {
 				break
 			}
 		}
-		if claims, _ := idToken.Claims.(jwt.MapClaims); claims == nil {
+		if idToken == nil {
 			err = errors.New("invalid id token claims")
 			break
 		}
+		// Add the userinfo claims only after validation, the claims of the
+		// ID token take precedence.
+		for claim, value := range userInfoClaims {
+			if _, ok := idTokenClaims[claim]; !ok {
+				idTokenClaims[claim] = value
+			}
+		}
 
 		// Lookup username and user.
 		un, extra, claimsErr := authority.IdentityClaimValue(idToken)
@@ -345,9 +360,21 @@ func (i *Identifier) writeOAuth2Cb(rw http.ResponseWriter, req *http.Request) {
 			i.logger.WithError(err).Debugln("identifier failed to update user data in oauth2 cb request")
 		}
 
+		// Map claims and scopes of the validated authority tokens to local ones.
+		if claims, ok := idToken.Claims.(jwt.MapClaims); ok {
+			user.authorityClaims, user.authorityScopes = authority.MapClaims(claims)
+		}
+
 		// Set logon time.
 		user.logonAt = time.Now()
 
+		err = i.startSession(req.Context(), req, user)
+		if err == ErrTooManySessions {
+			i.logger.WithField("sub", user.Subject()).Warnln("identifier oauth2 cb rejected, too many concurrent sessions")
+			i.ErrorPage(rw, http.StatusTooManyRequests, "", "too many concurrent sessions")
+			return
+		}
+
 		err = i.SetUserToLogonCookie(req.Context(), rw, user)
 		if err != nil {
 			i.logger.WithError(err).Errorln("identifier failed to serialize logon ticket in oauth2 cb")
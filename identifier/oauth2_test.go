@@ -0,0 +1,168 @@
+package identifier
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/libregraph/lico/identifier/backends"
+	"github.com/libregraph/lico/identity/authorities"
+)
+
+type resolvingTestBackend struct {
+	testBackend
+}
+
+func (b *resolvingTestBackend) ResolveUserByUsername(ctx context.Context, username string) (backends.UserFromBackend, error) {
+	return &testUser{username}, nil
+}
+
+func newTestOAuth2Identifier(t *testing.T) (*Identifier, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := json.Marshal(map[string]interface{}{
+		"authorities": []interface{}{
+			map[string]interface{}{
+				"id":                     "idp",
+				"name":                   "idp",
+				"authority_type":         authorities.AuthorityTypeOIDC,
+				"default":                true,
+				"iss":                    "https://idp.example.com",
+				"client_id":              "client",
+				"discover":               false,
+				"authorization_endpoint": "https://idp.example.com/authorize",
+				"response_type":          "id_token",
+				"jwks": &jose.JSONWebKeySet{
+					Keys: []jose.JSONWebKey{
+						{Key: &key.PublicKey, KeyID: "key1", Use: "sig", Algorithm: "RS256"},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confFilepath := filepath.Join(t.TempDir(), "authorities.yaml")
+	if err = os.WriteFile(confFilepath, conf, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	registry, err := authorities.NewRegistry(ctx, &url.URL{Scheme: "https", Host: "localhost"}, confFilepath, 0, 0, nil, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Authorities are initialized in the background.
+	for idx := 0; ; idx++ {
+		if authority, _ := registry.Lookup(ctx, "idp"); authority != nil && authority.IsReady() {
+			break
+		}
+		if idx == 100 {
+			t.Fatal("authority did not become ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	i := newTestIdentifier(t, 0)
+	i.backend = &resolvingTestBackend{}
+	i.authorities = registry
+	i.authorizationEndpointURI = &url.URL{Scheme: "https", Host: "localhost", Path: "/signin/v1/identifier/_/authorize"}
+
+	return i, key
+}
+
+func oauth2Cb(t *testing.T, i *Identifier, key *rsa.PrivateKey, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	if err := i.SetStateToStateCookie(context.Background(), rr, "oauth2/cb", &StateData{
+		State:    "state1",
+		ClientID: "client",
+		Ref:      "idp",
+		Extra: map[string]interface{}{
+			"nonce": "nonce1",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key1"
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := url.Values{}
+	query.Set("state", "state1")
+	query.Set("id_token", idToken)
+	req := httptest.NewRequest(http.MethodGet, "/identifier/oauth2/cb?"+query.Encode(), nil)
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rr = httptest.NewRecorder()
+	i.handleOAuth2Cb(rr, req)
+
+	return rr
+}
+
+func TestOAuth2CbValidatesIDToken(t *testing.T) {
+	i, key := newTestOAuth2Identifier(t)
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                "https://idp.example.com",
+			"aud":                "client",
+			"nonce":              "nonce1",
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"preferred_username": "user1",
+		}
+	}
+
+	rr := oauth2Cb(t, i, key, validClaims())
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect, got status %d", rr.Code)
+	}
+	if location, _ := url.Parse(rr.Header().Get("Location")); location == nil || location.Query().Get("error") != "" {
+		t.Errorf("expected valid id token to be accepted, got %v", rr.Header().Get("Location"))
+	}
+	if cookie := findCookie(rr.Result().Cookies(), i.logonCookieName); cookie == nil || cookie.Value == "" {
+		t.Error("expected logon cookie for valid id token")
+	}
+
+	for name, modify := range map[string]func(jwt.MapClaims){
+		"iss":   func(claims jwt.MapClaims) { claims["iss"] = "https://other.example.com" },
+		"aud":   func(claims jwt.MapClaims) { claims["aud"] = "other" },
+		"nonce": func(claims jwt.MapClaims) { claims["nonce"] = "other" },
+		"exp":   func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+	} {
+		claims := validClaims()
+		modify(claims)
+		rr = oauth2Cb(t, i, key, claims)
+		location, _ := url.Parse(rr.Header().Get("Location"))
+		if location == nil || location.Query().Get("error") != "server_error" {
+			t.Errorf("expected id token with invalid %s to be rejected, got %v", name, rr.Header().Get("Location"))
+		}
+		if cookie := findCookie(rr.Result().Cookies(), i.logonCookieName); cookie != nil && cookie.Value != "" {
+			t.Errorf("expected no logon cookie for id token with invalid %s", name)
+		}
+	}
+}
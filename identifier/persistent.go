@@ -0,0 +1,329 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/longsleep/rndm"
+)
+
+// A persistentSession is a long lived remember me session of a user. Its
+// token is rotated whenever the session is used.
+type persistentSession struct {
+	sub        string
+	sessionRef *string
+	token      string
+	createdAt  time.Time
+	lastUsedAt time.Time
+	expiresAt  time.Time
+}
+
+// persistentSessions keeps persistent sessions in memory, so they are lost on
+// restart and not shared between instances. If idleTimeout is larger than
+// zero, sessions which have not been used within the idle timeout expire.
+type persistentSessions struct {
+	sync.Mutex
+
+	idleTimeout time.Duration
+
+	table map[string]*persistentSession
+}
+
+func newPersistentSessions(idleTimeout time.Duration) *persistentSessions {
+	return &persistentSessions{
+		idleTimeout: idleTimeout,
+
+		table: make(map[string]*persistentSession),
+	}
+}
+
+// create adds a new persistent session for the provided sub and returns its
+// id and token.
+func (ps *persistentSessions) create(sub string, sessionRef *string, expiresAt time.Time) (string, string) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	id := rndm.GenerateRandomString(32)
+	token := rndm.GenerateRandomString(32)
+	now := time.Now()
+	ps.table[id] = &persistentSession{
+		sub:        sub,
+		sessionRef: sessionRef,
+		token:      token,
+		createdAt:  now,
+		lastUsedAt: now,
+		expiresAt:  expiresAt,
+	}
+
+	return id, token
+}
+
+// rotate validates the provided token of the persistent session identified
+// by id and replaces it with a new token. A token mismatch means that an old
+// token was replayed, in that case the persistent session is revoked.
+func (ps *persistentSessions) rotate(id string, sub string, token string) (string, time.Time, bool) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	session, ok := ps.table[id]
+	if !ok {
+		return "", time.Time{}, false
+	}
+	if session.sub != sub || subtle.ConstantTimeCompare([]byte(session.token), []byte(token)) != 1 {
+		delete(ps.table, id)
+		return "", time.Time{}, false
+	}
+	now := time.Now()
+	if session.expiresAt.Before(now) || ps.idle(session, now) {
+		delete(ps.table, id)
+		return "", time.Time{}, false
+	}
+
+	session.token = rndm.GenerateRandomString(32)
+	session.lastUsedAt = now
+	return session.token, session.expiresAt, true
+}
+
+func (ps *persistentSessions) idle(session *persistentSession, now time.Time) bool {
+	return ps.idleTimeout > 0 && session.lastUsedAt.Add(ps.idleTimeout).Before(now)
+}
+
+// purgeIdle removes the persistent sessions of the provided sub which have
+// been idle for too long and returns them.
+func (ps *persistentSessions) purgeIdle(sub string) []*persistentSession {
+	if ps.idleTimeout <= 0 {
+		return nil
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	now := time.Now()
+	var purged []*persistentSession
+	for id, session := range ps.table {
+		if session.sub == sub && ps.idle(session, now) {
+			purged = append(purged, session)
+			delete(ps.table, id)
+		}
+	}
+
+	return purged
+}
+
+// touch marks the persistent session identified by id as used, if it is not
+// yet idle. Does nothing if no idle timeout is set.
+func (ps *persistentSessions) touch(id string) {
+	if ps.idleTimeout <= 0 {
+		return
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	now := time.Now()
+	if session, ok := ps.table[id]; ok && !ps.idle(session, now) {
+		session.lastUsedAt = now
+	}
+}
+
+// valid returns true if the persistent session identified by id exists, is
+// not expired and belongs to the provided sub.
+func (ps *persistentSessions) valid(id string, sub string) bool {
+	ps.Lock()
+	defer ps.Unlock()
+
+	session, ok := ps.table[id]
+	return ok && session.sub == sub && session.expiresAt.After(time.Now())
+}
+
+func (ps *persistentSessions) revoke(id string) {
+	ps.Lock()
+	delete(ps.table, id)
+	ps.Unlock()
+}
+
+// list returns copies of the unexpired persistent sessions of the provided sub
+// keyed by id.
+func (ps *persistentSessions) list(sub string) map[string]persistentSession {
+	ps.Lock()
+	defer ps.Unlock()
+
+	now := time.Now()
+	sessions := make(map[string]persistentSession)
+	for id, session := range ps.table {
+		if session.sub == sub && session.expiresAt.After(now) && !ps.idle(session, now) {
+			sessions[id] = *session
+		}
+	}
+
+	return sessions
+}
+
+// revokeForSub revokes the persistent session identified by id if it belongs
+// to the provided sub and returns the revoked session.
+func (ps *persistentSessions) revokeForSub(id string, sub string) (*persistentSession, bool) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	session, ok := ps.table[id]
+	if !ok || session.sub != sub {
+		return nil, false
+	}
+	delete(ps.table, id)
+
+	return session, true
+}
+
+func (ps *persistentSessions) revokeAll(sub string) int {
+	ps.Lock()
+	defer ps.Unlock()
+
+	count := 0
+	for id, session := range ps.table {
+		if session.sub == sub {
+			delete(ps.table, id)
+			count++
+		}
+	}
+
+	return count
+}
+
+// SetUserToPersistentCookie creates a new persistent session for the provided
+// user and sets it as cookie on the provided http.ResponseWriter. Does
+// nothing if persistent sessions are not enabled. Sessions expired for being
+// idle are destroyed and audited.
+func (i *Identifier) SetUserToPersistentCookie(ctx context.Context, rw http.ResponseWriter, req *http.Request, user *IdentifiedUser) error {
+	if i.persistentSessions == nil {
+		return nil
+	}
+
+	for _, session := range i.persistentSessions.purgeIdle(user.Subject()) {
+		i.destroyIdleSession(ctx, req, session.sub, user.Username(), session.sessionRef)
+	}
+
+	expiresAt := time.Now().Add(i.persistentSessionDuration)
+	id, token := i.persistentSessions.create(user.Subject(), user.SessionRef(), expiresAt)
+
+	// Bind the logon cookie of the user to the persistent session, so that it
+	// ends when the persistent session is revoked.
+	user.persistentSessionID = id
+
+	return i.setUserToPersistentCookie(rw, user, id, token, expiresAt)
+}
+
+func (i *Identifier) setUserToPersistentCookie(rw http.ResponseWriter, user *IdentifiedUser, id string, token string, expiresAt time.Time) error {
+	serialized, err := i.serializeLogonToken(user, &expiresAt, map[string]interface{}{
+		PersistentSessionIDClaim:    id,
+		PersistentSessionTokenClaim: token,
+	})
+	if err != nil {
+		i.persistentSessions.revoke(id)
+		return err
+	}
+
+	return i.setPersistentCookie(rw, serialized, expiresAt)
+}
+
+// GetUserFromPersistentCookie looks up the persistent session cookie from
+// the provided request and returns the user it belongs to. On success the
+// persistent session token is rotated and the logon cookie is set again on
+// the provided http.ResponseWriter. Persistent sessions older than the
+// provided maxAge are ignored.
+func (i *Identifier) GetUserFromPersistentCookie(ctx context.Context, rw http.ResponseWriter, req *http.Request, maxAge time.Duration) (*IdentifiedUser, error) {
+	if i.persistentSessions == nil {
+		return nil, nil
+	}
+
+	cookie, err := i.getPersistentCookie(req)
+	if err != nil {
+		if err == http.ErrNoCookie {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	user, userClaims, err := i.parseLogonToken(ctx, cookie.Value, maxAge, true)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	id, _ := userClaims[PersistentSessionIDClaim].(string)
+	token, _ := userClaims[PersistentSessionTokenClaim].(string)
+	if id == "" || token == "" {
+		i.removePersistentCookie(rw)
+		return nil, nil
+	}
+	for _, session := range i.persistentSessions.purgeIdle(user.Subject()) {
+		i.destroyIdleSession(ctx, req, session.sub, user.Username(), session.sessionRef)
+	}
+	nextToken, expiresAt, ok := i.persistentSessions.rotate(id, user.Subject(), token)
+	if !ok {
+		i.logger.WithField("sub", user.Subject()).Debugln("identifier persistent session is no longer valid")
+		i.removePersistentCookie(rw)
+		return nil, nil
+	}
+
+	err = i.setUserToPersistentCookie(rw, user, id, nextToken, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	user.persistentSessionID = id
+	// Restoring starts a new logon session.
+	user.logonSessionID = ""
+	err = i.SetUserToLogonCookie(ctx, rw, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UnsetPersistentCookie revokes the persistent session of the provided
+// request if any and adds cookie remove headers to the provided
+// http.ResponseWriter.
+func (i *Identifier) UnsetPersistentCookie(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+	if i.persistentSessions == nil {
+		return nil
+	}
+
+	if cookie, err := i.getPersistentCookie(req); err == nil {
+		if _, userClaims, parseErr := i.parseLogonToken(ctx, cookie.Value, 0, false); parseErr == nil && userClaims != nil {
+			if id, _ := userClaims[PersistentSessionIDClaim].(string); id != "" {
+				i.persistentSessions.revoke(id)
+			}
+		}
+	}
+
+	return i.removePersistentCookie(rw)
+}
+
+// RevokePersistentSessions revokes all persistent sessions of the user
+// identified by the provided sub and returns how many have been revoked.
+func (i *Identifier) RevokePersistentSessions(ctx context.Context, sub string) int {
+	if i.persistentSessions == nil {
+		return 0
+	}
+
+	return i.persistentSessions.revokeAll(sub)
+}
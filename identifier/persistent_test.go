@@ -0,0 +1,251 @@
+package identifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identifier/backends"
+	"github.com/libregraph/lico/identifier/meta/scopes"
+)
+
+const testPassword = "secret"
+
+type testUser struct {
+	username string
+}
+
+func (u *testUser) Subject() string {
+	return u.username
+}
+
+func (u *testUser) Username() string {
+	return u.username
+}
+
+func (u *testUser) BackendClaims() map[string]interface{} {
+	return nil
+}
+
+func (u *testUser) BackendScopes() []string {
+	return nil
+}
+
+func (u *testUser) RequiredScopes() []string {
+	return nil
+}
+
+type testBackend struct{}
+
+func (b *testBackend) RunWithContext(ctx context.Context) error {
+	return nil
+}
+
+func (b *testBackend) Logon(ctx context.Context, audience string, username string, password string) (bool, *string, *string, backends.UserFromBackend, error) {
+	if password != testPassword {
+		return false, nil, nil, nil, nil
+	}
+	return true, &username, nil, &testUser{username}, nil
+}
+
+func (b *testBackend) GetUser(ctx context.Context, userID string, sessionRef *string, requestedScopes map[string]bool) (backends.UserFromBackend, error) {
+	return nil, nil
+}
+
+func (b *testBackend) ResolveUserByUsername(ctx context.Context, username string) (backends.UserFromBackend, error) {
+	return nil, nil
+}
+
+func (b *testBackend) RefreshSession(ctx context.Context, userID string, sessionRef *string, claims map[string]interface{}) error {
+	return nil
+}
+
+func (b *testBackend) DestroySession(ctx context.Context, sessionRef *string) error {
+	return nil
+}
+
+func (b *testBackend) UserClaims(userID string, authorizedScopes map[string]bool) map[string]interface{} {
+	return nil
+}
+
+func (b *testBackend) ScopesSupported() []string {
+	return nil
+}
+
+func (b *testBackend) ScopesMeta() *scopes.Scopes {
+	return &scopes.Scopes{}
+}
+
+func (b *testBackend) Name() string {
+	return "test"
+}
+
+func newTestIdentifier(t *testing.T, persistentSessionDuration time.Duration) *Identifier {
+	i, err := NewIdentifier(&Config{
+		Config: &config.Config{
+			Logger: &logrus.Logger{
+				Out:       os.Stderr,
+				Formatter: &logrus.TextFormatter{DisableColors: true},
+				Level:     logrus.DebugLevel,
+			},
+		},
+
+		BaseURI:         &url.URL{Scheme: "https", Host: "localhost"},
+		LogonCookieName: "__Secure-KKT",
+		WebAppDisabled:  true,
+
+		PersistentSessionDuration: persistentSessionDuration,
+
+		Backend: &testBackend{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.SetKey(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	return i
+}
+
+func newRequestWithCookies(cookies []*http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/identifier/_/hello", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	return req
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func rememberTestUser(t *testing.T, i *Identifier, logonAt time.Time) *http.Cookie {
+	user := &IdentifiedUser{
+		sub:      "user1",
+		username: "user1",
+		backend:  i.backend,
+		logonAt:  logonAt,
+	}
+
+	rr := httptest.NewRecorder()
+	if err := i.SetUserToPersistentCookie(context.Background(), rr, httptest.NewRequest(http.MethodPost, "/identifier/_/logon", nil), user); err != nil {
+		t.Fatal(err)
+	}
+	cookie := findCookie(rr.Result().Cookies(), persistentCookieName)
+	if cookie == nil {
+		t.Fatal("persistent cookie not set")
+	}
+	if cookie.Expires.IsZero() {
+		t.Error("persistent cookie must have an expiry")
+	}
+
+	return cookie
+}
+
+func TestPersistentSessionReuse(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIdentifier(t, time.Hour)
+
+	first := rememberTestUser(t, i, time.Now())
+
+	// Resume with persistent cookie only.
+	rr := httptest.NewRecorder()
+	user, err := i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{first}), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.Subject() != "user1" {
+		t.Fatalf("expected user1 from persistent session, got %v", user)
+	}
+	cookies := rr.Result().Cookies()
+	if findCookie(cookies, i.logonCookieName) == nil {
+		t.Error("expected logon cookie to be set when resuming persistent session")
+	}
+	second := findCookie(cookies, persistentCookieName)
+	if second == nil || second.Value == first.Value {
+		t.Fatal("expected persistent cookie to be rotated")
+	}
+
+	// The rotated cookie can be used again.
+	rr = httptest.NewRecorder()
+	user, _ = i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{second}), 0)
+	if user == nil {
+		t.Fatal("expected rotated persistent cookie to be valid")
+	}
+	third := findCookie(rr.Result().Cookies(), persistentCookieName)
+
+	// Replaying an old cookie revokes the persistent session.
+	rr = httptest.NewRecorder()
+	user, _ = i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{first}), 0)
+	if user != nil {
+		t.Error("expected replayed persistent cookie to be rejected")
+	}
+	rr = httptest.NewRecorder()
+	user, _ = i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{third}), 0)
+	if user != nil {
+		t.Error("expected persistent session to be revoked after replay")
+	}
+}
+
+func TestPersistentSessionForcedReauth(t *testing.T) {
+	ctx := context.Background()
+	i := newTestIdentifier(t, time.Hour)
+
+	cookie := rememberTestUser(t, i, time.Now().Add(-10*time.Minute))
+
+	// Logon is older than max_age.
+	rr := httptest.NewRecorder()
+	user, err := i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{cookie}), 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != nil {
+		t.Error("expected persistent session to be ignored when max_age is exceeded")
+	}
+
+	// Still valid without max_age.
+	rr = httptest.NewRecorder()
+	user, _ = i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{cookie}), 0)
+	if user == nil {
+		t.Fatal("expected persistent session to be valid without max_age")
+	}
+	cookie = findCookie(rr.Result().Cookies(), persistentCookieName)
+
+	// Revoked sessions cannot be resumed.
+	if n := i.RevokePersistentSessions(ctx, "user1"); n != 1 {
+		t.Errorf("expected 1 revoked persistent session, got %d", n)
+	}
+	rr = httptest.NewRecorder()
+	user, _ = i.GetUserFromPersistentCookie(ctx, rr, newRequestWithCookies([]*http.Cookie{cookie}), 0)
+	if user != nil {
+		t.Error("expected revoked persistent session to be rejected")
+	}
+}
+
+func TestPersistentSessionDisabled(t *testing.T) {
+	i := newTestIdentifier(t, 0)
+
+	rr := httptest.NewRecorder()
+	err := i.SetUserToPersistentCookie(context.Background(), rr, httptest.NewRequest(http.MethodPost, "/identifier/_/logon", nil), &IdentifiedUser{sub: "user1", backend: i.backend, logonAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findCookie(rr.Result().Cookies(), persistentCookieName) != nil {
+		t.Error("expected no persistent cookie when persistent sessions are disabled")
+	}
+}
@@ -29,6 +29,8 @@ import (
 	"github.com/longsleep/rndm"
 	"github.com/sirupsen/logrus"
 
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/authorities"
 	konnectoidc "github.com/libregraph/lico/oidc"
 
@@ -195,6 +197,13 @@ func (i *Identifier) writeSAML2AssertionConsumerService(rw http.ResponseWriter,
 		// Set logon time.
 		user.logonAt = time.Now()
 
+		err = i.startSession(req.Context(), req, user)
+		if err == ErrTooManySessions {
+			i.logger.WithField("sub", user.Subject()).Warnln("identifier saml2 acs rejected, too many concurrent sessions")
+			i.ErrorPage(rw, http.StatusTooManyRequests, "", "too many concurrent sessions")
+			return
+		}
+
 		err = i.SetUserToLogonCookie(req.Context(), rw, user)
 		if err != nil {
 			i.logger.WithError(err).Errorln("identifier failed to serialize logon ticket in saml2 acs")
@@ -322,7 +331,7 @@ func (i *Identifier) writeSAMLSingleLogoutServiceRequest(rw http.ResponseWriter,
 		authorityDetails.Trusted = false
 	}
 
-	user, _ := i.GetUserFromLogonCookie(req.Context(), req, 0, false)
+	user, _ := i.GetUserFromLogonCookie(req.Context(), rw, req, 0, false)
 	if user != nil {
 		// Compare signed in SAML SessionIndex with the on provided in the LogoutRequest.
 		if user.SessionRef() != nil {
@@ -340,12 +349,19 @@ func (i *Identifier) writeSAMLSingleLogoutServiceRequest(rw http.ResponseWriter,
 
 		if authorityDetails != nil && authorityDetails.Trusted {
 			// Directly clear identifier session when a trusted authority requests it.
-			err = i.UnsetLogonCookie(req.Context(), user, rw)
+			err = i.UnsetLogonCookie(identity.NewSessionDestroyReasonContext(req.Context(), identity.SessionDestroyReasonSAML2SLO), user, rw)
 			if err != nil {
 				i.logger.WithError(err).Errorln("identifier saml2 slo failed to unset logon cookie")
 				i.ErrorPage(rw, http.StatusInternalServerError, "", "saml2 slo logout failed")
 				return
 			}
+			i.auditLog(req, &audit.Event{
+				Type:     audit.EventTypeSessionDestroyed,
+				Outcome:  audit.OutcomeSuccess,
+				Subject:  user.Subject(),
+				Username: user.Username(),
+				Reason:   string(identity.SessionDestroyReasonSAML2SLO),
+			})
 		}
 	} else {
 		// Ignore when not signed in, for end session.
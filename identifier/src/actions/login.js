@@ -69,7 +69,7 @@ export function receiveConsent(logon) {
   };
 }
 
-export function executeLogon(username, password, mode=ModeLogonUsernamePassword) {
+export function executeLogon(username, password, mode=ModeLogonUsernamePassword, rememberMe=false) {
   return function(dispatch, getState) {
     dispatch(requestLogon(username, password));
     dispatch(receiveHello({
@@ -96,7 +96,8 @@ export function executeLogon(username, password, mode=ModeLogonUsernamePassword)
 
     const r = withClientRequestState({
       params: params,
-      hello: newHelloRequest(flow, query)
+      hello: newHelloRequest(flow, query),
+      remember_me: rememberMe
     });
     return axios.post('./identifier/_/logon', r, {
       headers: {
@@ -227,13 +228,13 @@ export function validateUsernamePassword(username, password, isSignedIn) {
   };
 }
 
-export function executeLogonIfFormValid(username, password, isSignedIn) {
+export function executeLogonIfFormValid(username, password, isSignedIn, rememberMe=false) {
   return (dispatch) => {
     return dispatch(
       validateUsernamePassword(username, password, isSignedIn)
     ).then(() => {
       const mode = isSignedIn ? ModeLogonUsernameEmptyPasswordCookie : ModeLogonUsernamePassword;
-      return dispatch(executeLogon(username, password, mode));
+      return dispatch(executeLogon(username, password, mode, rememberMe));
     }).catch((errors) => {
       return {
         success: false,
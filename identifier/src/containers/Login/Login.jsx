@@ -8,12 +8,14 @@ import renderIf from 'render-if';
 
 import { withStyles } from '@material-ui/core/styles';
 import Button from '@material-ui/core/Button';
+import Checkbox from '@material-ui/core/Checkbox';
 import CircularProgress from '@material-ui/core/CircularProgress';
 import green from '@material-ui/core/colors/green';
 import TextField from '@material-ui/core/TextField';
 import Typography from '@material-ui/core/Typography';
 import DialogActions from '@material-ui/core/DialogActions';
 import DialogContent from '@material-ui/core/DialogContent';
+import FormControlLabel from '@material-ui/core/FormControlLabel';
 
 import { updateInput, executeLogonIfFormValid, advanceLogonFlow } from '../../actions/login';
 import { ErrorMessage } from '../../errors';
@@ -63,6 +65,8 @@ function Login(props) {
     classes,
     username,
     password,
+    rememberMe,
+    rememberMeEnabled,
   } = props;
 
   const { t } = useTranslation();
@@ -79,14 +83,25 @@ function Login(props) {
     }
   }, [ /* no dependencies */ ]); // eslint-disable-line react-hooks/exhaustive-deps
 
+  useEffect(() => {
+    if (query.login_hint && !username) {
+      // Prefill username from login_hint, it is only a hint.
+      dispatch(updateInput('username', query.login_hint));
+    }
+  }, [ /* no dependencies */ ]); // eslint-disable-line react-hooks/exhaustive-deps
+
   const handleChange = (name) => (event) => {
     dispatch(updateInput(name, event.target.value));
   };
 
+  const handleRememberMeChange = (event) => {
+    dispatch(updateInput('rememberMe', event.target.checked));
+  };
+
   const handleNextClick = (event) => {
     event.preventDefault();
 
-    dispatch(executeLogonIfFormValid(username, password, false)).then((response) => {
+    dispatch(executeLogonIfFormValid(username, password, false, rememberMeEnabled && rememberMe)).then((response) => {
       if (response.success) {
         dispatch(advanceLogonFlow(response.success, history));
       }
@@ -143,6 +158,18 @@ function Login(props) {
           autoComplete="kopano-account current-password"
           variant="outlined"
         />
+        {renderIf(rememberMeEnabled)(() => (
+          <FormControlLabel
+            control={
+              <Checkbox
+                checked={rememberMe}
+                onChange={handleRememberMeChange}
+                color="primary"
+              />
+            }
+            label={t("konnect.login.rememberMe.label", "Remember me")}
+          />
+        ))}
         <DialogActions>
           <div className={classes.wrapper}>
             <Button
@@ -177,6 +204,8 @@ Login.propTypes = {
   loading: PropTypes.string.isRequired,
   username: PropTypes.string.isRequired,
   password: PropTypes.string.isRequired,
+  rememberMe: PropTypes.bool.isRequired,
+  rememberMeEnabled: PropTypes.bool.isRequired,
   errors: PropTypes.object.isRequired,
   branding: PropTypes.object,
   hello: PropTypes.object,
@@ -187,13 +216,15 @@ Login.propTypes = {
 };
 
 const mapStateToProps = (state) => {
-  const { loading, username, password, errors} = state.login;
-  const { branding, hello, query } = state.common;
+  const { loading, username, password, rememberMe, errors} = state.login;
+  const { branding, hello, query, rememberMe: rememberMeEnabled } = state.common;
 
   return {
     loading,
     username,
     password,
+    rememberMe,
+    rememberMeEnabled,
     errors,
     branding,
     hello,
@@ -23,6 +23,7 @@ const defaultPathPrefix = (() => {
 const defaultState = {
   hello: null,
   branding: null,
+  rememberMe: false,
   error: null,
   flow: flow,
   query: query,
@@ -51,7 +52,8 @@ function commonReducer(state = defaultState, action) {
           displayName: action.displayName,
           details: action.hello
         },
-        branding: action.hello.branding ? action.hello.branding : state.branding
+        branding: action.hello.branding ? action.hello.branding : state.branding,
+        rememberMe: action.hello.remember_me !== undefined ? !!action.hello.remember_me : state.rememberMe
       });
 
     case SERVICE_WORKER_NEW_CONTENT:
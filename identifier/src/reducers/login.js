@@ -13,6 +13,7 @@ function loginReducer(state = {
   loading: '',
   username: '',
   password: '',
+  rememberMe: false,
   errors: {}
 }, action) {
   switch (action.type) {
@@ -43,7 +44,8 @@ function loginReducer(state = {
     case RECEIVE_LOGOFF:
       return Object.assign({}, state, {
         username: '',
-        password: ''
+        password: '',
+        rememberMe: false
       });
 
     case UPDATE_INPUT:
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identifier
+
+import (
+	"strings"
+
+	konnect "github.com/libregraph/lico"
+)
+
+// backendSubject returns the subject of the backend user for the provided
+// subject by removing the subject prefix of the associated Identifier.
+// Returns false if the provided subject does not have the prefix.
+func (i *Identifier) backendSubject(sub string) (string, bool) {
+	if i.subjectPrefix == "" {
+		return sub, true
+	}
+	if !strings.HasPrefix(sub, i.subjectPrefix) {
+		return "", false
+	}
+
+	return sub[len(i.subjectPrefix):], true
+}
+
+// withSubjectPrefixClaims adds the subject prefix of the associated
+// Identifier to the user ID claim of the provided backend claims, so it can
+// be used to look up the user again.
+func (i *Identifier) withSubjectPrefixClaims(claims map[string]interface{}) map[string]interface{} {
+	if i.subjectPrefix == "" || claims == nil {
+		return claims
+	}
+	if userID, ok := claims[konnect.IdentifiedUserIDClaim].(string); ok {
+		prefixed := make(map[string]interface{}, len(claims))
+		for k, v := range claims {
+			prefixed[k] = v
+		}
+		prefixed[konnect.IdentifiedUserIDClaim] = i.subjectPrefix + userID
+		return prefixed
+	}
+
+	return claims
+}
+
+// backendSubject returns the subject of the associated user without subject
+// prefix, as known by its backend.
+func (u *IdentifiedUser) backendSubject() string {
+	return strings.TrimPrefix(u.sub, u.subjectPrefix)
+}
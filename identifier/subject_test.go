@@ -0,0 +1,96 @@
+package identifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/identifier/backends"
+)
+
+type testSubjectUser struct {
+	testUser
+}
+
+func (u *testSubjectUser) BackendClaims() map[string]interface{} {
+	return map[string]interface{}{
+		konnect.IdentifiedUserIDClaim: u.username,
+	}
+}
+
+type testSubjectBackend struct {
+	testBackend
+
+	userIDs []string
+}
+
+func (b *testSubjectBackend) GetUser(ctx context.Context, userID string, sessionRef *string, requestedScopes map[string]bool) (backends.UserFromBackend, error) {
+	b.userIDs = append(b.userIDs, userID)
+	if userID != "user1" {
+		return nil, nil
+	}
+	return &testSubjectUser{testUser{userID}}, nil
+}
+
+func (b *testSubjectBackend) ResolveUserByUsername(ctx context.Context, username string) (backends.UserFromBackend, error) {
+	return &testSubjectUser{testUser{username}}, nil
+}
+
+func TestSubjectPrefixRoundTrip(t *testing.T) {
+	i := newTestIdentifier(t, time.Duration(0))
+	backend := &testSubjectBackend{}
+	i.backend = backend
+	i.subjectPrefix = "test:"
+
+	resolved, err := i.resolveUser(context.Background(), "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := resolved.Subject(); sub != "test:user1" {
+		t.Fatalf("unexpected subject of resolved user: %v", sub)
+	}
+	if userID := resolved.Claims()[konnect.IdentifiedUserIDClaim]; userID != "test:user1" {
+		t.Errorf("unexpected id claim of resolved user: %v", userID)
+	}
+
+	user, err := i.GetUserFromID(context.Background(), resolved.Subject(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil {
+		t.Fatalf("expected user from prefixed subject")
+	}
+	if sub := user.Subject(); sub != resolved.Subject() {
+		t.Errorf("subject did not round trip: got %v want %v", sub, resolved.Subject())
+	}
+	if len(backend.userIDs) != 1 || backend.userIDs[0] != "user1" {
+		t.Errorf("backend was not called with the subject without prefix: %v", backend.userIDs)
+	}
+
+	// Subjects without the prefix are unknown.
+	user, err = i.GetUserFromID(context.Background(), "user1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != nil {
+		t.Errorf("expected no user for subject without prefix, got %v", user.Subject())
+	}
+	if len(backend.userIDs) != 1 {
+		t.Errorf("backend must not be called for subject without prefix: %v", backend.userIDs)
+	}
+}
+
+func TestSubjectPrefixDisabled(t *testing.T) {
+	i := newTestIdentifier(t, time.Duration(0))
+	backend := &testSubjectBackend{}
+	i.backend = backend
+
+	user, err := i.GetUserFromID(context.Background(), "user1", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil || user.Subject() != "user1" {
+		t.Fatalf("unexpected user without subject prefix: %v", user)
+	}
+}
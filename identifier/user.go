@@ -28,6 +28,7 @@ import (
 	"github.com/libregraph/lico/identifier/backends"
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/authorities"
+	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
 // A IdentifiedUser is a user with meta data.
@@ -35,14 +36,21 @@ type IdentifiedUser struct {
 	sub string
 
 	backend           backends.Backend
+	subjectPrefix     string
 	externalAuthority *authorities.Details
 
-	username      string
-	email         string
-	emailVerified bool
-	displayName   string
-	familyName    string
-	givenName     string
+	username          string
+	preferredUsername string
+	email             string
+	emailVerified     bool
+	phoneNumber       string
+	phoneVerified     bool
+	address           *konnectoidc.Address
+	displayName       string
+	familyName        string
+	givenName         string
+	locale            string
+	zoneinfo          string
 
 	id  int64
 	uid string
@@ -54,8 +62,15 @@ type IdentifiedUser struct {
 
 	logonAt      time.Time
 	expiresAfter *time.Time
+	lastUsedAt   time.Time
+
+	logonSessionID      string
+	persistentSessionID string
 
 	lockedScopes []string
+
+	authorityClaims map[string]interface{}
+	authorityScopes []string
 }
 
 // Subject returns the associated users subject field. The subject is the main
@@ -74,6 +89,22 @@ func (u *IdentifiedUser) EmailVerified() bool {
 	return u.emailVerified
 }
 
+// PhoneNumber returns the associated users phone number field.
+func (u *IdentifiedUser) PhoneNumber() string {
+	return u.phoneNumber
+}
+
+// PhoneNumberVerified returns true if the associated users phone number field
+// was verified.
+func (u *IdentifiedUser) PhoneNumberVerified() bool {
+	return u.phoneVerified
+}
+
+// Address returns the associated users postal address or nil.
+func (u *IdentifiedUser) Address() *konnectoidc.Address {
+	return u.address
+}
+
 // Name returns the associated users name field. This is the display name of
 // the accociated user.
 func (u *IdentifiedUser) Name() string {
@@ -90,6 +121,16 @@ func (u *IdentifiedUser) GivenName() string {
 	return u.givenName
 }
 
+// Locale returns the associated users locale field.
+func (u *IdentifiedUser) Locale() string {
+	return u.locale
+}
+
+// Zoneinfo returns the associated users time zone field.
+func (u *IdentifiedUser) Zoneinfo() string {
+	return u.zoneinfo
+}
+
 // ID returns the associated users numeric user id. If it is 0, it means that
 // this user does not have a numeric ID. Do not use this field to identify a
 // user - always use the subject instead. The numeric ID is kept for compatibility
@@ -113,6 +154,12 @@ func (u *IdentifiedUser) Username() string {
 	return u.username
 }
 
+// PreferredUsername returns the accociated users preferred username. When
+// empty, the username is used as preferred username.
+func (u *IdentifiedUser) PreferredUsername() string {
+	return u.preferredUsername
+}
+
 // Claims returns extra claims of the accociated user.
 func (u *IdentifiedUser) Claims() jwt.MapClaims {
 	claims := make(map[string]interface{})
@@ -122,6 +169,30 @@ func (u *IdentifiedUser) Claims() jwt.MapClaims {
 	for k, v := range u.claims {
 		claims[k] = v
 	}
+	if u.logonSessionID != "" {
+		claims[LogonSessionIDClaim] = u.logonSessionID
+	}
+	if len(u.authorityClaims) > 0 {
+		// Claims mapped from the external authority are added to the extra
+		// claims of ID and access tokens.
+		for _, extraClaim := range []string{konnect.InternalExtraIDTokenClaimsClaim, konnect.InternalExtraAccessTokenClaimsClaim} {
+			extraClaims := make(map[string]interface{})
+			switch v := claims[extraClaim].(type) {
+			case map[string]interface{}:
+				for k, value := range v {
+					extraClaims[k] = value
+				}
+			case jwt.MapClaims:
+				for k, value := range v {
+					extraClaims[k] = value
+				}
+			}
+			for k, v := range u.authorityClaims {
+				extraClaims[k] = v
+			}
+			claims[extraClaim] = extraClaims
+		}
+	}
 
 	return jwt.MapClaims(claims)
 }
@@ -132,7 +203,7 @@ func (u *IdentifiedUser) ScopedClaims(authorizedScopes map[string]bool) jwt.MapC
 		return nil
 	}
 
-	claims := u.backend.UserClaims(u.Subject(), authorizedScopes)
+	claims := u.backend.UserClaims(u.backendSubject(), authorizedScopes)
 	return jwt.MapClaims(claims)
 }
 
@@ -173,6 +244,19 @@ func (u *IdentifiedUser) LockedScopes() []string {
 	return u.lockedScopes
 }
 
+// AuthorityScopes returns the local scopes mapped from the scopes granted by
+// the external authority the associated user signed in with.
+func (u *IdentifiedUser) AuthorityScopes() []string {
+	return u.authorityScopes
+}
+
+// InheritAuthorityMappings copies the claims and scopes which were mapped from
+// the external authority of the provided user to the associated user.
+func (u *IdentifiedUser) InheritAuthorityMappings(from *IdentifiedUser) {
+	u.authorityClaims = from.authorityClaims
+	u.authorityScopes = from.authorityScopes
+}
+
 func (i *Identifier) logonUser(ctx context.Context, audience, username, password string) (*IdentifiedUser, error) {
 	success, subject, sessionRef, u, err := i.backend.Logon(ctx, audience, username, password)
 	if err != nil {
@@ -184,14 +268,15 @@ func (i *Identifier) logonUser(ctx context.Context, audience, username, password
 	}
 
 	user := &IdentifiedUser{
-		sub: *subject,
+		sub: i.subjectPrefix + *subject,
 
 		username: u.Username(),
 
-		backend: i.backend,
+		backend:       i.backend,
+		subjectPrefix: i.subjectPrefix,
 
 		sessionRef: sessionRef,
-		claims:     u.BackendClaims(),
+		claims:     i.withSubjectPrefixClaims(u.BackendClaims()),
 
 		lockedScopes: u.RequiredScopes(),
 	}
@@ -211,13 +296,14 @@ func (i *Identifier) resolveUser(ctx context.Context, username string) (*Identif
 
 	// Construct user from resolved result.
 	user := &IdentifiedUser{
-		sub: u.Subject(),
+		sub: i.subjectPrefix + u.Subject(),
 
 		username: u.Username(),
 
-		backend: i.backend,
+		backend:       i.backend,
+		subjectPrefix: i.subjectPrefix,
 
-		claims: u.BackendClaims(),
+		claims: i.withSubjectPrefixClaims(u.BackendClaims()),
 
 		lockedScopes: u.RequiredScopes(),
 	}
@@ -235,7 +321,11 @@ func (i *Identifier) updateUser(ctx context.Context, user *IdentifiedUser, exter
 		return errors.New("no id claim in user identity claims")
 	}
 
-	u, err := i.backend.GetUser(ctx, userID, user.sessionRef, nil)
+	backendUserID, ok := i.backendSubject(userID)
+	if !ok {
+		return errors.New("id claim in user identity claims has no subject prefix")
+	}
+	u, err := i.backend.GetUser(ctx, backendUserID, user.sessionRef, nil)
 	if err != nil {
 		return err
 	}
@@ -245,6 +335,7 @@ func (i *Identifier) updateUser(ctx context.Context, user *IdentifiedUser, exter
 	}
 
 	user.backend = i.backend
+	user.subjectPrefix = i.subjectPrefix
 	user.externalAuthority = externalAuthority
 
 	return nil
@@ -0,0 +1,66 @@
+package identifier
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	konnect "github.com/libregraph/lico"
+)
+
+func TestAuthorityMappingsLogonTokenRoundTrip(t *testing.T) {
+	i := newTestIdentifier(t, time.Duration(0))
+
+	user := &IdentifiedUser{
+		sub:      "user1",
+		username: "user1",
+		backend:  i.backend,
+		logonAt:  time.Now(),
+
+		authorityClaims: map[string]interface{}{
+			"app_roles": []interface{}{"admin"},
+		},
+		authorityScopes: []string{"local.admin", "local.read"},
+	}
+	serialized, err := i.serializeLogonToken(user, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _, err := i.parseLogonToken(context.Background(), serialized, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed == nil {
+		t.Fatalf("expected user from logon token")
+	}
+	if !reflect.DeepEqual(parsed.AuthorityScopes(), user.authorityScopes) {
+		t.Errorf("unexpected authority scopes: %v", parsed.AuthorityScopes())
+	}
+
+	// Mapped claims are only passed on as extra token claims.
+	fetched := &IdentifiedUser{
+		sub: "user1",
+		claims: map[string]interface{}{
+			konnect.InternalExtraAccessTokenClaimsClaim: map[string]interface{}{
+				"groups": []interface{}{"users"},
+			},
+		},
+	}
+	fetched.InheritAuthorityMappings(parsed)
+	claims := fetched.Claims()
+	if _, ok := claims["app_roles"]; ok {
+		t.Errorf("mapped claim must not be a top level identity claim")
+	}
+	expected := map[string]interface{}{
+		"groups":    []interface{}{"users"},
+		"app_roles": []interface{}{"admin"},
+	}
+	if extra := claims[konnect.InternalExtraAccessTokenClaimsClaim]; !reflect.DeepEqual(extra, expected) {
+		t.Errorf("unexpected extra access token claims: %v", extra)
+	}
+	if extra := claims[konnect.InternalExtraIDTokenClaimsClaim]; !reflect.DeepEqual(extra, map[string]interface{}{"app_roles": []interface{}{"admin"}}) {
+		t.Errorf("unexpected extra ID token claims: %v", extra)
+	}
+}
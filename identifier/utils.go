@@ -25,6 +25,9 @@ import (
 	"time"
 
 	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/utils"
 )
 
 var (
@@ -53,3 +56,10 @@ func encodeImageAsDataURL(b []byte) (string, error) {
 
 	return "data:" + mt.String() + ";base64," + base64.StdEncoding.EncodeToString(b), nil
 }
+
+// auditLog records the provided audit event with the client address of the
+// provided request.
+func (i *Identifier) auditLog(req *http.Request, event *audit.Event) {
+	event.RemoteAddr = utils.RemoteAddrFromRequest(req, i.Config.Config.TrustedProxyIPs, i.Config.Config.TrustedProxyNets)
+	i.auditLogger.Log(event)
+}
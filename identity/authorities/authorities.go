@@ -19,13 +19,19 @@ package authorities
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/libregraph/oidc-go"
+
+	"github.com/libregraph/lico/utils"
 )
 
 // Details hold immutable information about external authorities identified by ID.
@@ -47,7 +53,13 @@ type Details struct {
 
 	EndSessionEnabled bool
 
+	// ClaimMappings maps claim names of the authority to local claim names.
+	ClaimMappings map[string]string
+	// ScopeMappings maps scopes granted by the authority to local scopes.
+	ScopeMappings map[string][]string
+
 	registration AuthorityRegistration
+	httpClient   *http.Client
 
 	ready bool
 
@@ -60,11 +72,67 @@ func (d *Details) IsReady() bool {
 	return d.ready
 }
 
+// HTTPClient returns the http.Client to use for requests to the associated
+// authority, as configured by its registry.
+func (d *Details) HTTPClient() *http.Client {
+	if d.httpClient != nil {
+		return d.httpClient
+	}
+	if d.Insecure {
+		return utils.InsecureHTTPClient
+	}
+	return utils.DefaultHTTPClient
+}
+
 // IdentityClaimValue returns the identity claim value from the provided data.
 func (d *Details) IdentityClaimValue(claims interface{}) (string, map[string]interface{}, error) {
 	return d.registration.IdentityClaimValue(claims)
 }
 
+// MapClaims returns the local claims and scopes for the provided claims of a
+// validated authority token according to the claim and scope mappings of the
+// associated authority. Upstream scopes are read from the scope claim, either
+// as space separated string or as list.
+func (d *Details) MapClaims(claims map[string]interface{}) (map[string]interface{}, []string) {
+	var mappedClaims map[string]interface{}
+	for upstream, local := range d.ClaimMappings {
+		if value, ok := claims[upstream]; ok {
+			if mappedClaims == nil {
+				mappedClaims = make(map[string]interface{})
+			}
+			mappedClaims[local] = value
+		}
+	}
+
+	var mappedScopes []string
+	if len(d.ScopeMappings) > 0 {
+		var upstreamScopes []string
+		switch v := claims["scope"].(type) {
+		case string:
+			upstreamScopes = strings.Fields(v)
+		case []interface{}:
+			for _, entry := range v {
+				if scope, ok := entry.(string); ok {
+					upstreamScopes = append(upstreamScopes, scope)
+				}
+			}
+		case []string:
+			upstreamScopes = v
+		}
+		seen := make(map[string]bool)
+		for _, upstream := range upstreamScopes {
+			for _, local := range d.ScopeMappings[upstream] {
+				if !seen[local] {
+					seen[local] = true
+					mappedScopes = append(mappedScopes, local)
+				}
+			}
+		}
+	}
+
+	return mappedClaims, mappedScopes
+}
+
 // MakeRedirectAuthenticationRequestURL returns the authentication request
 // URL which can be used to initiate authentication with the associated
 // authority. It takes a state as parameter and in addition to the URL it also
@@ -96,6 +164,23 @@ func (d *Details) ParseStateResponse(req *http.Request, state string, extra map[
 	return d.registration.ParseStateResponse(req, state, extra)
 }
 
+// ValidateIDTokenClaims validates that the provided ID token claims were issued
+// by the associated authority for its client id and that the nonce claim
+// matches the provided nonce which was sent with the authentication request.
+func (d *Details) ValidateIDTokenClaims(claims jwt.MapClaims, nonce string) error {
+	if !claims.VerifyIssuer(d.registration.Issuer(), true) {
+		return errors.New("iss does not match authority")
+	}
+	if !claims.VerifyAudience(d.ClientID, true) {
+		return errors.New("aud does not contain client id")
+	}
+	if value, _ := claims["nonce"].(string); nonce == "" || subtle.ConstantTimeCompare([]byte(value), []byte(nonce)) != 1 {
+		return errors.New("nonce does not match")
+	}
+
+	return nil
+}
+
 // JWTKeyfunc returns a key func to validate JWTs with the keys of the associated
 // authority registration.
 func (d *Details) JWTKeyfunc() jwt.Keyfunc {
@@ -111,7 +196,8 @@ func (d *Details) validateJWT(token *jwt.Token) (interface{}, error) {
 	if !ok {
 		return nil, errors.New("invalid alg value")
 	}
-	switch jwt.GetSigningMethod(alg).(type) {
+	method := jwt.GetSigningMethod(alg)
+	switch method.(type) {
 	case *jwt.SigningMethodRSA:
 	case *jwt.SigningMethodECDSA:
 	case *jwt.SigningMethodRSAPSS:
@@ -128,12 +214,32 @@ func (d *Details) validateJWT(token *jwt.Token) (interface{}, error) {
 	}
 
 	if key, ok := d.validationKeys[kid]; ok {
+		// Ensure that the key matches the alg, to prevent that a token
+		// chooses how the key is used.
+		if !isKeyForSigningMethod(key, method) {
+			return nil, fmt.Errorf("key %v does not match alg %v", kid, alg)
+		}
 		return key, nil
 	}
 
 	return nil, errors.New("no key available")
 }
 
+// isKeyForSigningMethod returns true if the provided public key can be used
+// to verify signatures of the provided signing method.
+func isKeyForSigningMethod(key crypto.PublicKey, method jwt.SigningMethod) bool {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		ecdsaKey, ok := key.(*ecdsa.PublicKey)
+		return ok && ecdsaKey.Curve.Params().BitSize == m.CurveBits
+	default:
+		return false
+	}
+}
+
 func (d *Details) Metadata() interface{} {
 	return d.registration.Metadata()
 }
@@ -0,0 +1,218 @@
+package authorities
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestDetailsValidateJWTKeyMatchesAlg(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Details{
+		validationKeys: map[string]crypto.PublicKey{
+			"rsa": &rsaKey.PublicKey,
+			"ec":  &ecKey.PublicKey,
+		},
+	}
+
+	tests := []struct {
+		alg   string
+		kid   string
+		valid bool
+	}{
+		{"RS256", "rsa", true},
+		{"PS256", "rsa", true},
+		{"ES256", "ec", true},
+		{"ES256", "rsa", false},
+		{"ES384", "ec", false},
+		{"RS256", "ec", false},
+		{"PS256", "ec", false},
+		{"HS256", "rsa", false},
+		{"none", "rsa", false},
+	}
+	for _, test := range tests {
+		token := &jwt.Token{
+			Header: map[string]interface{}{
+				"alg": test.alg,
+				"kid": test.kid,
+			},
+		}
+		key, err := d.validateJWT(token)
+		if test.valid && (err != nil || key == nil) {
+			t.Errorf("%s with %s key: unexpected error: %v", test.alg, test.kid, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s with %s key: expected error, got key %T", test.alg, test.kid, key)
+		}
+	}
+}
+
+func TestDetailsValidateJWTRejectsHMACWithPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Details{
+		validationKeys: map[string]crypto.PublicKey{
+			"rsa": &rsaKey.PublicKey,
+		},
+	}
+
+	// Token signed with HS256, using the public key as secret.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "attacker"})
+	token.Header["kid"] = "rsa"
+	tokenString, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(tokenString, d.validateJWT); err == nil {
+		t.Errorf("expected HS256 token to be rejected")
+	}
+
+	token = jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user"})
+	token.Header["kid"] = "rsa"
+	tokenString, err = token.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(tokenString, d.validateJWT); err != nil {
+		t.Errorf("unexpected error for RS256 token: %v", err)
+	}
+}
+
+func TestDetailsMapClaims(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ar := &oidcAuthorityRegistration{
+		data: &authorityRegistrationData{
+			ID:            "idp",
+			AuthorityType: AuthorityTypeOIDC,
+			ClientID:      "client",
+			ClaimMappings: map[string]string{
+				"roles":      "app_roles",
+				"department": "department",
+			},
+			ScopeMappings: map[string][]string{
+				"upstream.admin": {"local.admin", "local.read"},
+				"upstream.read":  {"local.read"},
+			},
+		},
+		validationKeys: map[string]crypto.PublicKey{
+			"rsa": &rsaKey.PublicKey,
+		},
+		ready: true,
+	}
+	if err = ar.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d := ar.Authority()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":   "user1",
+		"roles": []string{"admin", "user"},
+		"scope": "openid upstream.admin upstream.read",
+	})
+	token.Header["kid"] = "rsa"
+	signed, err := token.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwt.Parse(signed, d.JWTKeyfunc())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, scopes := d.MapClaims(parsed.Claims.(jwt.MapClaims))
+	expectedClaims := map[string]interface{}{
+		"app_roles": []interface{}{"admin", "user"},
+	}
+	if !reflect.DeepEqual(claims, expectedClaims) {
+		t.Errorf("unexpected mapped claims: got %v want %v", claims, expectedClaims)
+	}
+	if expectedScopes := []string{"local.admin", "local.read"}; !reflect.DeepEqual(scopes, expectedScopes) {
+		t.Errorf("unexpected mapped scopes: got %v want %v", scopes, expectedScopes)
+	}
+}
+
+func TestOIDCAuthorityRegistrationValidateMappings(t *testing.T) {
+	for _, mappings := range []map[string]string{
+		{"roles": "sub"},
+		{"roles": "scp"},
+		{"roles": "acr"},
+		{"roles": "cnf"},
+		{"roles": "lg.i"},
+		{"roles": "$lico.id.extra"},
+		{"roles": ""},
+		{"": "roles"},
+	} {
+		ar := &oidcAuthorityRegistration{
+			data: &authorityRegistrationData{
+				ID:            "idp",
+				AuthorityType: AuthorityTypeOIDC,
+				ClientID:      "client",
+				ClaimMappings: mappings,
+			},
+		}
+		if err := ar.Validate(); err == nil {
+			t.Errorf("expected claim mappings %v to be rejected", mappings)
+		}
+	}
+}
+
+func TestDetailsValidateIDTokenClaims(t *testing.T) {
+	d := &Details{
+		ClientID: "client",
+		registration: &oidcAuthorityRegistration{
+			data: &authorityRegistrationData{
+				Iss: "https://idp.example.com",
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+		valid  bool
+	}{
+		{"valid", jwt.MapClaims{"iss": "https://idp.example.com", "aud": "client", "nonce": "nonce"}, "nonce", true},
+		{"valid audience list", jwt.MapClaims{"iss": "https://idp.example.com", "aud": []interface{}{"other", "client"}, "nonce": "nonce"}, "nonce", true},
+		{"iss mismatch", jwt.MapClaims{"iss": "https://evil.example.com", "aud": "client", "nonce": "nonce"}, "nonce", false},
+		{"iss missing", jwt.MapClaims{"aud": "client", "nonce": "nonce"}, "nonce", false},
+		{"aud mismatch", jwt.MapClaims{"iss": "https://idp.example.com", "aud": "other", "nonce": "nonce"}, "nonce", false},
+		{"nonce mismatch", jwt.MapClaims{"iss": "https://idp.example.com", "aud": "client", "nonce": "other"}, "nonce", false},
+		{"nonce missing", jwt.MapClaims{"iss": "https://idp.example.com", "aud": "client"}, "nonce", false},
+		{"no nonce sent", jwt.MapClaims{"iss": "https://idp.example.com", "aud": "client", "nonce": ""}, "", false},
+	}
+	for _, test := range tests {
+		err := d.ValidateIDTokenClaims(test.claims, test.nonce)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
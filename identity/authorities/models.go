@@ -19,6 +19,7 @@ package authorities
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/url"
 
@@ -65,6 +66,9 @@ type authorityRegistrationData struct {
 	IdentityAliases       map[string]string `json:"identity_aliases"`
 	IdentityAliasRequired bool              `json:"identity_alias_required"`
 
+	ClaimMappings map[string]string   `json:"claim_mappings"`
+	ScopeMappings map[string][]string `json:"scope_mappings"`
+
 	EndSessionEnabled bool `json:"end_session_enabled"`
 }
 
@@ -99,5 +103,15 @@ type AuthorityRegistration interface {
 	Metadata() AuthorityMetadata
 }
 
+// ValidationKeysRefresher is implemented by authority registrations which
+// support to refresh their validation keys on demand.
+type ValidationKeysRefresher interface {
+	RefreshValidationKeys(ctx context.Context) ([]string, error)
+}
+
+// ErrValidationKeysRefreshNotSupported is returned when the validation keys
+// of an authority cannot be refreshed on demand.
+var ErrValidationKeysRefreshNotSupported = errors.New("authority does not support refreshing validation keys")
+
 type AuthorityMetadata interface {
 }
@@ -20,10 +20,12 @@ package authorities
 import (
 	"context"
 	"crypto"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 
@@ -32,6 +34,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/square/go-jose.v2"
 
+	konnect "github.com/libregraph/lico"
 	konnectoidc "github.com/libregraph/lico/oidc"
 	"github.com/libregraph/lico/oidc/payload"
 	"github.com/libregraph/lico/utils"
@@ -180,9 +183,16 @@ func (ar *oidcAuthorityRegistration) Authority() *Details {
 
 		EndSessionEnabled: ar.data.EndSessionEnabled,
 
+		ClaimMappings: ar.data.ClaimMappings,
+		ScopeMappings: ar.data.ScopeMappings,
+
 		registration: ar,
 	}
 
+	if ar.registry != nil {
+		details.httpClient = ar.registry.httpClient(ar.data.Insecure)
+	}
+
 	ar.mutex.RLock()
 	details.ready = ar.ready
 	if ar.ready {
@@ -225,6 +235,55 @@ func (ar *oidcAuthorityRegistration) setValidationKeysFromJWKS(jwks *jose.JSONWe
 	return nil
 }
 
+// RefreshValidationKeys implements the ValidationKeysRefresher interface. It
+// fetches the JWKS of the accociated authority immediately and replaces its
+// validation keys.
+func (ar *oidcAuthorityRegistration) RefreshValidationKeys(ctx context.Context) ([]string, error) {
+	ar.mutex.RLock()
+	var jwksURI string
+	if ar.wellKnown != nil {
+		jwksURI = ar.wellKnown.JwksURI
+	}
+	ar.mutex.RUnlock()
+	if jwksURI == "" {
+		return nil, errors.New("authority has no jwks_uri")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwks request: %w", err)
+	}
+	req.Header.Set("User-Agent", utils.DefaultHTTPUserAgent)
+	response, err := ar.registry.httpClient(ar.data.Insecure).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status %d", response.StatusCode)
+	}
+	jwks := &jose.JSONWebKeySet{}
+	if err = json.NewDecoder(response.Body).Decode(jwks); err != nil {
+		return nil, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	if err = ar.setValidationKeysFromJWKS(jwks, true); err != nil {
+		ar.registry.logger.WithField("id", ar.data.ID).Warnf("failed to set some authority keys from refreshed jwks: %v", err)
+	}
+	ar.ready = ar.authorizationEndpoint != nil && ar.validationKeys != nil
+
+	kids := make([]string, 0, len(ar.validationKeys))
+	for kid := range ar.validationKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	return kids, nil
+}
+
 func (ar *oidcAuthorityRegistration) Validate() error {
 	if ar.data.ClientID == "" {
 		return errors.New("invalid authority client_id")
@@ -247,6 +306,25 @@ func (ar *oidcAuthorityRegistration) Validate() error {
 		ar.data.IdentityClaimName = oidcAuthorityDefaultIdentityClaimName
 	}
 
+	for upstream, local := range ar.data.ClaimMappings {
+		if upstream == "" || local == "" {
+			return errors.New("invalid authority claim_mappings, empty claim name")
+		}
+		if konnect.IsReservedClaim(local) {
+			return fmt.Errorf("invalid authority claim_mappings, claim %s is reserved", local)
+		}
+	}
+	for upstream, locals := range ar.data.ScopeMappings {
+		if upstream == "" {
+			return errors.New("invalid authority scope_mappings, empty scope")
+		}
+		for _, local := range locals {
+			if local == "" || strings.Contains(local, " ") {
+				return fmt.Errorf("invalid authority scope_mappings, invalid scope for %s", upstream)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -405,11 +483,7 @@ func initializeOIDC(ctx context.Context, logger logrus.FieldLogger, ar *oidcAuth
 		Logger:     &oidcProviderLogger{providerLogger},
 		HTTPHeader: http.Header{},
 	}
-	if ar.data.Insecure {
-		config.HTTPClient = utils.InsecureHTTPClient
-	} else {
-		config.HTTPClient = utils.DefaultHTTPClient
-	}
+	config.HTTPClient = ar.registry.httpClient(ar.data.Insecure)
 	config.HTTPHeader.Set("User-Agent", utils.DefaultHTTPUserAgent)
 
 	issuer, err := url.Parse(ar.data.Iss)
@@ -0,0 +1,382 @@
+package authorities
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libregraph/oidc-go"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/libregraph/lico/utils"
+)
+
+func TestOIDCAuthorityRefreshValidationKeys(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{Key: &newKey.PublicKey, KeyID: "new", Use: "sig", Algorithm: "RS256"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	registry := &Registry{
+		authorities: make(map[string]AuthorityRegistration),
+		logger:      logrus.New(),
+	}
+	authorizationEndpoint, _ := url.Parse("https://idp.example.com/authorize")
+	ar := &oidcAuthorityRegistration{
+		registry: registry,
+		data: &authorityRegistrationData{
+			ID:            "idp",
+			AuthorityType: AuthorityTypeOIDC,
+			ClientID:      "client",
+		},
+		authorizationEndpoint: authorizationEndpoint,
+		validationKeys: map[string]crypto.PublicKey{
+			"old": &oldKey.PublicKey,
+		},
+		ready: true,
+		wellKnown: &oidc.WellKnown{
+			JwksURI: server.URL,
+		},
+	}
+	if err = registry.Register(ar); err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := registry.Lookup(context.Background(), "idp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := details.validationKeys["old"]; !ok {
+		t.Fatalf("expected old key before refresh: %v", details.validationKeys)
+	}
+
+	kids, err := registry.RefreshValidationKeys(context.Background(), "idp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kids, []string{"new"}) {
+		t.Errorf("unexpected key ids after refresh: %v", kids)
+	}
+
+	details, err = registry.Lookup(context.Background(), "idp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := details.validationKeys["old"]; ok {
+		t.Error("expected old key to be replaced by refresh")
+	}
+	if _, ok := details.validationKeys["new"]; !ok {
+		t.Errorf("expected new key after refresh: %v", details.validationKeys)
+	}
+	if !details.IsReady() {
+		t.Error("expected authority to stay ready after refresh")
+	}
+
+	if _, err = registry.RefreshValidationKeys(context.Background(), "unknown"); err == nil {
+		t.Error("expected refresh of unknown authority to fail")
+	}
+}
+
+func TestOIDCAuthorityRefreshValidationKeysLimits(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oversized", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"keys":[`))
+		rw.(http.Flusher).Flush()
+		rw.Write([]byte(strings.Repeat(" ", 4096)))
+		json.NewEncoder(rw).Encode(jose.JSONWebKey{Key: &key.PublicKey, KeyID: "new", Use: "sig", Algorithm: "RS256"})
+		rw.Write([]byte(`]}`))
+	})
+	mux.HandleFunc("/slow", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"keys":[`))
+		rw.(http.Flusher).Flush()
+		select {
+		case <-req.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/oversized", "/slow"} {
+		registry := &Registry{
+			authorities: make(map[string]AuthorityRegistration),
+
+			fetchMaxSize: 1024,
+			fetchTimeout: 200 * time.Millisecond,
+
+			logger: logrus.New(),
+		}
+		ar := &oidcAuthorityRegistration{
+			registry: registry,
+			data: &authorityRegistrationData{
+				ID:            "idp",
+				AuthorityType: AuthorityTypeOIDC,
+				ClientID:      "client",
+			},
+			wellKnown: &oidc.WellKnown{
+				JwksURI: server.URL + path,
+			},
+		}
+		if err = registry.Register(ar); err != nil {
+			t.Fatal(err)
+		}
+
+		started := time.Now()
+		if kids, err := registry.RefreshValidationKeys(context.Background(), "idp"); err == nil {
+			t.Errorf("%s: expected error, got key ids %v", path, kids)
+		}
+		if elapsed := time.Since(started); elapsed > 2*time.Second {
+			t.Errorf("%s: refresh took too long: %v", path, elapsed)
+		}
+		if len(ar.validationKeys) != 0 {
+			t.Errorf("%s: unexpected validation keys after failed refresh: %v", path, ar.validationKeys)
+		}
+	}
+}
+
+func TestOIDCAuthorityRefreshValidationKeysTLSMinVersion(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{Key: &key.PublicKey, KeyID: "key", Use: "sig", Algorithm: "RS256"},
+			},
+		})
+	}))
+	server.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	for _, tc := range []struct {
+		minVersion uint16
+		ok         bool
+	}{
+		{tls.VersionTLS12, true},
+		{tls.VersionTLS13, false},
+	} {
+		registry := &Registry{
+			authorities: make(map[string]AuthorityRegistration),
+			logger:      logrus.New(),
+		}
+		registry.client, registry.insecureClient = newHTTPClients(&tls.Config{
+			MinVersion: tc.minVersion,
+			RootCAs:    rootCAs,
+		}, nil)
+		ar := &oidcAuthorityRegistration{
+			registry: registry,
+			data: &authorityRegistrationData{
+				ID:            "idp",
+				AuthorityType: AuthorityTypeOIDC,
+				ClientID:      "client",
+			},
+			wellKnown: &oidc.WellKnown{
+				JwksURI: server.URL,
+			},
+		}
+		if err = registry.Register(ar); err != nil {
+			t.Fatal(err)
+		}
+
+		kids, err := registry.RefreshValidationKeys(context.Background(), "idp")
+		if tc.ok {
+			if err != nil {
+				t.Errorf("min version %x: unexpected error: %v", tc.minVersion, err)
+			} else if !reflect.DeepEqual(kids, []string{"key"}) {
+				t.Errorf("min version %x: unexpected key ids: %v", tc.minVersion, kids)
+			}
+		} else if err == nil {
+			t.Errorf("min version %x: expected server TLS version to be rejected", tc.minVersion)
+		}
+	}
+}
+
+func TestOIDCAuthorityRefreshValidationKeysProxy(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Requests through a forward proxy carry the absolute target URL.
+		proxied = append(proxied, req.URL.String())
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{Key: &key.PublicKey, KeyID: "key", Use: "sig", Algorithm: "RS256"},
+			},
+		})
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	registry := &Registry{
+		authorities: make(map[string]AuthorityRegistration),
+		logger:      logrus.New(),
+	}
+	registry.client, registry.insecureClient = newHTTPClients(nil, proxyURL)
+	ar := &oidcAuthorityRegistration{
+		registry: registry,
+		data: &authorityRegistrationData{
+			ID:            "idp",
+			AuthorityType: AuthorityTypeOIDC,
+			ClientID:      "client",
+		},
+		wellKnown: &oidc.WellKnown{
+			JwksURI: "http://idp.example.com/jwks",
+		},
+	}
+	if err = registry.Register(ar); err != nil {
+		t.Fatal(err)
+	}
+
+	kids, err := registry.RefreshValidationKeys(context.Background(), "idp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(kids, []string{"key"}) {
+		t.Errorf("unexpected key ids: %v", kids)
+	}
+	if !reflect.DeepEqual(proxied, []string{"http://idp.example.com/jwks"}) {
+		t.Errorf("expected fetch to be sent through proxy, got %v", proxied)
+	}
+}
+
+func TestOIDCAuthorityDetailsHTTPClientTLSMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"access_token":"token","token_type":"Bearer"}`))
+	}))
+	server.TLS = &tls.Config{
+		MaxVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	for _, tc := range []struct {
+		minVersion uint16
+		ok         bool
+	}{
+		{tls.VersionTLS12, true},
+		{tls.VersionTLS13, false},
+	} {
+		registry := &Registry{
+			authorities: make(map[string]AuthorityRegistration),
+			logger:      logrus.New(),
+		}
+		registry.client, registry.insecureClient = newHTTPClients(&tls.Config{
+			MinVersion: tc.minVersion,
+			RootCAs:    rootCAs,
+		}, nil)
+		ar := &oidcAuthorityRegistration{
+			registry: registry,
+			data: &authorityRegistrationData{
+				ID:            "idp",
+				AuthorityType: AuthorityTypeOIDC,
+				ClientID:      "client",
+			},
+		}
+
+		// Code exchange and userinfo requests of the identifier use this client.
+		response, err := ar.Authority().HTTPClient().Post(server.URL, "application/x-www-form-urlencoded", nil)
+		if response != nil {
+			response.Body.Close()
+		}
+		if tc.ok {
+			if err != nil {
+				t.Errorf("min version %x: unexpected error: %v", tc.minVersion, err)
+			}
+		} else if err == nil {
+			t.Errorf("min version %x: expected server TLS version to be rejected", tc.minVersion)
+		}
+	}
+}
+
+func TestOIDCAuthorityDetailsHTTPClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		proxied = append(proxied, req.URL.String())
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"sub":"` + strings.Repeat("a", 2048) + `"}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	registry := &Registry{
+		authorities: make(map[string]AuthorityRegistration),
+		logger:      logrus.New(),
+
+		fetchMaxSize: 1024,
+	}
+	registry.client, registry.insecureClient = newHTTPClients(nil, proxyURL)
+	ar := &oidcAuthorityRegistration{
+		registry: registry,
+		data: &authorityRegistrationData{
+			ID:            "idp",
+			AuthorityType: AuthorityTypeOIDC,
+			ClientID:      "client",
+		},
+	}
+
+	response, err := ar.Authority().HTTPClient().Get("http://idp.example.com/userinfo")
+	if err == nil {
+		_, err = io.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	if !errors.Is(err, utils.ErrResponseBodyTooLarge) {
+		t.Errorf("expected fetch size limit to apply, got %v", err)
+	}
+	if !reflect.DeepEqual(proxied, []string{"http://idp.example.com/userinfo"}) {
+		t.Errorf("expected fetch to be sent through proxy, got %v", proxied)
+	}
+}
@@ -19,15 +19,20 @@ package authorities
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
+
+	"github.com/libregraph/lico/utils"
 )
 
 // Registry implements the registry for registered authorities.
@@ -39,11 +44,21 @@ type Registry struct {
 	defaultID   string
 	authorities map[string]AuthorityRegistration
 
+	fetchMaxSize int64
+	fetchTimeout time.Duration
+
+	client         *http.Client
+	insecureClient *http.Client
+
 	logger logrus.FieldLogger
 }
 
 // NewRegistry creates a new authorizations Registry with the provided parameters.
-func NewRegistry(ctx context.Context, baseURI *url.URL, registrationConfFilepath string, logger logrus.FieldLogger) (*Registry, error) {
+// Meta data and key set fetches of the authorities are limited to fetchMaxSize
+// bytes and fetchTimeout, if larger than zero. If tlsClientConfig is not nil,
+// it is used for all TLS connections to the authorities, with certificate
+// validation controlled by the per authority insecure setting.
+func NewRegistry(ctx context.Context, baseURI *url.URL, registrationConfFilepath string, fetchMaxSize int64, fetchTimeout time.Duration, tlsClientConfig *tls.Config, proxyURL *url.URL, logger logrus.FieldLogger) (*Registry, error) {
 	registryData := &authorityRegistryData{}
 
 	if registrationConfFilepath != "" {
@@ -72,8 +87,14 @@ func NewRegistry(ctx context.Context, baseURI *url.URL, registrationConfFilepath
 
 		authorities: make(map[string]AuthorityRegistration),
 
+		fetchMaxSize: fetchMaxSize,
+		fetchTimeout: fetchTimeout,
+
 		logger: logger,
 	}
+	if tlsClientConfig != nil || proxyURL != nil {
+		r.client, r.insecureClient = newHTTPClients(tlsClientConfig, proxyURL)
+	}
 
 	var defaultAuthorityRegistrationData *authorityRegistrationData
 	var defaultAuthority AuthorityRegistration
@@ -178,6 +199,31 @@ func (r *Registry) Register(authority AuthorityRegistration) error {
 	return nil
 }
 
+// RefreshValidationKeys forces the authority identified by the provided
+// authorityID to fetch its validation keys immediately and returns the key
+// ids of the new keys.
+func (r *Registry) RefreshValidationKeys(ctx context.Context, authorityID string) ([]string, error) {
+	registration, ok := r.Get(ctx, authorityID)
+	if !ok {
+		return nil, fmt.Errorf("unknown authority id: %v", authorityID)
+	}
+	refresher, ok := registration.(ValidationKeysRefresher)
+	if !ok {
+		return nil, ErrValidationKeysRefreshNotSupported
+	}
+
+	kids, err := refresher.RefreshValidationKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.logger.WithFields(logrus.Fields{
+		"id":   authorityID,
+		"kids": kids,
+	}).Infoln("refreshed authority validation keys")
+
+	return kids, nil
+}
+
 // Lookup returns and validates the authority Detail information for the provided
 // parameters from the accociated authority registry.
 func (r *Registry) Lookup(ctx context.Context, authorityID string) (*Details, error) {
@@ -223,3 +269,52 @@ func (r *Registry) Default(ctx context.Context) *Details {
 	authority, _ := r.Lookup(ctx, r.defaultID)
 	return authority
 }
+
+// newHTTPClients creates a verifying and a non-verifying http.Client using the
+// provided tls.Config as base. If proxyURL is set, requests are sent through
+// that proxy, otherwise the proxy environment variables are used.
+func newHTTPClients(tlsClientConfig *tls.Config, proxyURL *url.URL) (*http.Client, *http.Client) {
+	if tlsClientConfig == nil {
+		tlsClientConfig = utils.DefaultTLSConfig()
+	}
+	secureConfig := tlsClientConfig.Clone()
+	secureConfig.InsecureSkipVerify = false
+	insecureConfig := tlsClientConfig.Clone()
+	insecureConfig.InsecureSkipVerify = true
+
+	secureTransport := utils.HTTPTransportWithTLSClientConfig(secureConfig)
+	secureTransport.Proxy = utils.HTTPProxyFunc(proxyURL)
+	insecureTransport := utils.HTTPTransportWithTLSClientConfig(insecureConfig)
+	insecureTransport.Proxy = utils.HTTPProxyFunc(proxyURL)
+
+	client := &http.Client{
+		Timeout:   utils.DefaultHTTPClient.Timeout,
+		Transport: secureTransport,
+	}
+	insecureClient := &http.Client{
+		Timeout:   utils.DefaultHTTPClient.Timeout,
+		Transport: insecureTransport,
+	}
+
+	return client, insecureClient
+}
+
+// httpClient returns the http.Client to use for requests to authorities,
+// with the associated registry's fetch limits applied.
+func (r *Registry) httpClient(insecure bool) *http.Client {
+	client := r.client
+	if insecure {
+		client = r.insecureClient
+	}
+	if client == nil {
+		client = utils.DefaultHTTPClient
+		if insecure {
+			client = utils.InsecureHTTPClient
+		}
+	}
+	if r.fetchMaxSize <= 0 && r.fetchTimeout <= 0 {
+		return client
+	}
+
+	return utils.HTTPClientWithLimits(client, r.fetchTimeout, r.fetchMaxSize)
+}
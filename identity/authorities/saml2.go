@@ -114,6 +114,10 @@ func (ar *saml2AuthorityRegistration) Authority() *Details {
 		registration: ar,
 	}
 
+	if ar.registry != nil {
+		details.httpClient = ar.registry.httpClient(ar.data.Insecure)
+	}
+
 	ar.mutex.RLock()
 	details.ready = ar.ready
 	ar.mutex.RUnlock()
@@ -149,12 +153,7 @@ func (ar *saml2AuthorityRegistration) Initialize(ctx context.Context, registry *
 		"type": AuthorityTypeSAML2,
 	})
 
-	var client *http.Client
-	if ar.data.Insecure {
-		client = utils.InsecureHTTPClient
-	} else {
-		client = utils.DefaultHTTPClient
-	}
+	client := registry.httpClient(ar.data.Insecure)
 
 	baseURIString := registry.baseURI.String()
 	acsURL, _ := url.Parse(baseURIString + "/identifier/saml2/acs")   // Assertion Consumer Service
@@ -19,6 +19,8 @@ package clients
 
 import (
 	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/libregraph/lico/utils"
 )
 
 // RegistrationClaims are claims used to with dynamic clients.
@@ -30,5 +32,5 @@ type RegistrationClaims struct {
 
 // Valid implements the jwt claims interface.
 func (crc RegistrationClaims) Valid() error {
-	return crc.StandardClaims.Valid()
+	return utils.ValidateTimeClaims(&crc.StandardClaims)
 }
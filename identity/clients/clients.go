@@ -61,3 +61,18 @@ func IsLocalNativeHostURI(uri *url.URL) bool {
 	hostname := uri.Hostname()
 	return hostname == "localhost" || hostname == "127.0.0.1" || hostname == "::1"
 }
+
+// IsNativeRedirectURI returns true if the provided URI qualifies as redirect
+// URI for a native client which either uses a custom URI scheme or http with
+// a loopback host, see https://tools.ietf.org/html/rfc8252#section-7.
+func IsNativeRedirectURI(uri *url.URL) bool {
+	switch uri.Scheme {
+	case "":
+		return false
+	case "http":
+		return IsLocalNativeHTTPURI(uri)
+	case "https":
+		return false
+	}
+	return true
+}
@@ -23,9 +23,12 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
 	"github.com/longsleep/rndm"
 	"github.com/mendsley/gojwk"
 	"golang.org/x/crypto/blake2b"
@@ -40,7 +43,8 @@ const (
 
 // RegistryData is the base structur of our client registry configuration file.
 type RegistryData struct {
-	Clients []*ClientRegistration `yaml:"clients,flow"`
+	Clients         []*ClientRegistration `yaml:"clients,flow"`
+	ResourceServers []*ResourceServer     `yaml:"resource_servers,flow"`
 }
 
 // ClientRegistration defines a client with its properties.
@@ -53,6 +57,16 @@ type ClientRegistration struct {
 	Insecure      bool     `yaml:"insecure" json:"-"`
 
 	ImplicitScopes []string `yaml:"implicit_scopes" json:"-"`
+	AllowedScopes  []string `yaml:"allowed_scopes,flow" json:"-"`
+
+	ResourceServers []string `yaml:"resource_servers,flow" json:"-"`
+
+	RequirePKCE *bool `yaml:"require_pkce" json:"-"`
+	MaxAuthAge  int64 `yaml:"max_auth_age" json:"-"`
+
+	AccessTokenLifetime  int64 `yaml:"access_token_lifetime" json:"-"`
+	IDTokenLifetime      int64 `yaml:"id_token_lifetime" json:"-"`
+	RefreshTokenLifetime int64 `yaml:"refresh_token_lifetime" json:"-"`
 
 	Dynamic         bool  `yaml:"-" json:"-"`
 	IDIssuedAt      int64 `yaml:"-" json:"-"`
@@ -62,6 +76,7 @@ type ClientRegistration struct {
 	Name            string   `yaml:"name" json:"name,omitempty"`
 	URI             string   `yaml:"uri"  json:"uri,omitempty"`
 	GrantTypes      []string `yaml:"grant_types,flow" json:"grant_types,omitempty"`
+	ResponseTypes   []string `yaml:"response_types,flow" json:"response_types,omitempty"`
 	ApplicationType string   `yaml:"application_type"  json:"application_type,omitempty"`
 
 	RedirectURIs []string `yaml:"redirect_uris,flow" json:"redirect_uris,omitempty"`
@@ -76,6 +91,8 @@ type ClientRegistration struct {
 	RawTokenEndpointAuthSigningAlg string `yaml:"token_endpoint_auth_signing_alg"  json:"token_endpoint_auth_signing_alg,omitempty"`
 
 	PostLogoutRedirectURIs []string `yaml:"post_logout_redirect_uris,flow" json:"post_logout_redirect_uris,omitempty"`
+
+	RequestURIs []string `yaml:"request_uris,flow" json:"request_uris,omitempty"`
 }
 
 // Validate validates the associated client registration data and returns error
@@ -189,6 +206,99 @@ func (cr *ClientRegistration) SetDynamic(ctx context.Context, creator func(ctx c
 	return nil
 }
 
+// AllowsGrantType returns true if the associated registration allows the
+// provided grant type. Registrations without grant types allow all of them.
+func (cr *ClientRegistration) AllowsGrantType(grantType string) bool {
+	if len(cr.GrantTypes) == 0 {
+		return true
+	}
+	for _, registered := range cr.GrantTypes {
+		if registered == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublic returns true if the associated registration has neither a secret
+// nor keys to authenticate the client at the token endpoint.
+func (cr *ClientRegistration) IsPublic() bool {
+	return cr.Secret == "" && cr.JWKS == nil
+}
+
+// HasClientSecret returns true if the associated registration authenticates
+// with a client secret. Dynamic clients always carry a secret but do not use
+// it when registered with the none token_endpoint_auth_method.
+func (cr *ClientRegistration) HasClientSecret() bool {
+	if cr.Dynamic {
+		return cr.RawTokenEndpointAuthMethod != oidc.AuthMethodNone
+	}
+	return cr.Secret != ""
+}
+
+// RequiresPKCE returns true if the associated registration requires PKCE. If
+// not set explicitly in the registration, public clients require PKCE when
+// the provided publicDefault is true.
+func (cr *ClientRegistration) RequiresPKCE(publicDefault bool) bool {
+	if cr.RequirePKCE != nil {
+		return *cr.RequirePKCE
+	}
+	return publicDefault && cr.IsPublic()
+}
+
+// AllowsResponseTypes returns true if the provided set of response types is
+// one of the response_type values of the associated registration.
+// Registrations without response types allow all of them.
+func (cr *ClientRegistration) AllowsResponseTypes(responseTypes map[string]bool) bool {
+	if len(cr.ResponseTypes) == 0 {
+		return true
+	}
+	for _, registered := range cr.ResponseTypes {
+		fields := strings.Fields(registered)
+		if len(fields) != len(responseTypes) {
+			continue
+		}
+		match := true
+		for _, field := range fields {
+			if !responseTypes[field] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// DisallowedScopes returns the enabled scopes of the provided scopes map which
+// are not allowed for the associated registration. Registrations without
+// allowed scopes allow all scopes.
+func (cr *ClientRegistration) DisallowedScopes(scopes map[string]bool) []string {
+	if len(cr.AllowedScopes) == 0 {
+		return nil
+	}
+	var disallowed []string
+	for scope, enabled := range scopes {
+		if !enabled {
+			continue
+		}
+		allowed := false
+		for _, allowedScope := range cr.AllowedScopes {
+			if allowedScope == scope {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			disallowed = append(disallowed, scope)
+		}
+	}
+	sort.Strings(disallowed)
+	return disallowed
+}
+
 // ApplyImplicitScopes apples the associated registration's implicit scopes to
 // the provided scopes map.
 func (cr *ClientRegistration) ApplyImplicitScopes(scopes map[string]bool) error {
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package clients
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/libregraph/oidc-go"
+)
+
+// ParseRedirectURI parses the provided string as redirect URI and returns it
+// normalized with NormalizeRedirectURI.
+func ParseRedirectURI(uriString string) (*url.URL, error) {
+	uri, err := url.Parse(uriString)
+	if err != nil {
+		return nil, err
+	}
+
+	return NormalizeRedirectURI(uri)
+}
+
+// NormalizeRedirectURI returns a normalized copy of the provided redirect URI
+// as used for comparison. Scheme and host are lower cased, the default ports
+// of http and https are removed and an empty http or https path becomes "/".
+// Nothing else is normalized. The path is compared in its escaped form, thus
+// percent-encoding and trailing slashes stay significant. Returns error for
+// URIs which must never be used as redirect URI, which are relative URIs, URIs
+// with user info or fragment and http or https URIs without host.
+func NormalizeRedirectURI(uri *url.URL) (*url.URL, error) {
+	if uri == nil || !uri.IsAbs() {
+		return nil, errors.New("redirect uri must be absolute")
+	}
+	if uri.User != nil {
+		return nil, errors.New("redirect uri must not contain user info")
+	}
+	if uri.Fragment != "" {
+		return nil, errors.New("redirect uri must not contain a fragment")
+	}
+
+	normalized := &url.URL{
+		Scheme:     strings.ToLower(uri.Scheme),
+		Opaque:     uri.Opaque,
+		Host:       strings.ToLower(uri.Host),
+		Path:       uri.Path,
+		RawPath:    uri.RawPath,
+		RawQuery:   uri.RawQuery,
+		ForceQuery: uri.ForceQuery,
+	}
+
+	switch normalized.Scheme {
+	case "http", "https":
+		if normalized.Opaque != "" || normalized.Host == "" {
+			return nil, errors.New("redirect uri must have a host")
+		}
+		hostname, port := normalized.Hostname(), normalized.Port()
+		if hostname == "" {
+			return nil, errors.New("redirect uri must have a host")
+		}
+		if strings.Contains(hostname, ":") != strings.HasPrefix(normalized.Host, "[") {
+			return nil, errors.New("redirect uri has an invalid host")
+		}
+		if port == "" || (normalized.Scheme == "http" && port == "80") || (normalized.Scheme == "https" && port == "443") {
+			port = ""
+		}
+		normalized.Host = hostname
+		if strings.Contains(hostname, ":") {
+			normalized.Host = "[" + hostname + "]"
+		}
+		if port != "" {
+			normalized.Host += ":" + port
+		}
+		if normalized.Path == "" {
+			normalized.Path = "/"
+			normalized.RawPath = ""
+		}
+	}
+
+	return normalized, nil
+}
+
+// MatchRedirectURI returns true if the provided requested redirect URI
+// matches the provided registered redirect URI. Both URIs are normalized with
+// NormalizeRedirectURI and then need to match exactly, with two exceptions.
+// For native clients, registered http loopback redirect URIs match requested
+// loopback URIs with any port as specified in
+// https://tools.ietf.org/html/rfc8252#section-7.3 and with any path if the
+// registered URI has no path. If the registered URI has no query, the query of
+// the requested URI is not compared.
+func MatchRedirectURI(registered *url.URL, requested *url.URL, native bool) bool {
+	r, err := NormalizeRedirectURI(registered)
+	if err != nil {
+		return false
+	}
+	q, err := NormalizeRedirectURI(requested)
+	if err != nil {
+		return false
+	}
+
+	if r.Scheme != q.Scheme || r.Opaque != q.Opaque {
+		return false
+	}
+
+	if native && IsLocalNativeHTTPURI(r) {
+		if !IsLocalNativeHTTPURI(q) {
+			return false
+		}
+		if registered.Path != "" && r.EscapedPath() != q.EscapedPath() {
+			return false
+		}
+	} else {
+		if r.Host != q.Host || r.EscapedPath() != q.EscapedPath() {
+			return false
+		}
+	}
+
+	if r.RawQuery != "" || r.ForceQuery {
+		return r.RawQuery == q.RawQuery
+	}
+
+	return true
+}
+
+// MatchPostLogoutRedirectURI returns true if the provided URI matches one of
+// the post logout redirect URIs of the associated client registration.
+func (cr *ClientRegistration) MatchPostLogoutRedirectURI(uri *url.URL) bool {
+	native := cr.ApplicationType == oidc.ApplicationTypeNative
+	for _, registeredURIString := range cr.PostLogoutRedirectURIs {
+		registeredURI, err := url.Parse(registeredURIString)
+		if err != nil {
+			continue
+		}
+		if MatchRedirectURI(registeredURI, uri, native) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsRequestURI returns true if the provided request_uri is one of the
+// request URIs of the associated client registration. Fragments are ignored
+// when comparing.
+func (cr *ClientRegistration) AllowsRequestURI(uri string) bool {
+	uri = strings.SplitN(uri, "#", 2)[0]
+	for _, registered := range cr.RequestURIs {
+		if strings.SplitN(registered, "#", 2)[0] == uri {
+			return true
+		}
+	}
+
+	return false
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -40,6 +41,8 @@ type Registry struct {
 	trustedURI *url.URL
 	clients    map[string]*ClientRegistration
 
+	resourceServers map[string]*ResourceServer
+
 	allowDynamicClientRegistration bool
 	dynamicClientSecretDuration    time.Duration
 
@@ -79,12 +82,25 @@ func NewRegistry(ctx context.Context, trustedURI *url.URL, registrationConfFilep
 		trustedURI: trustedURI,
 		clients:    make(map[string]*ClientRegistration),
 
+		resourceServers: make(map[string]*ResourceServer),
+
 		allowDynamicClientRegistration: allowDynamicClientRegistration,
 		dynamicClientSecretDuration:    dynamicClientSecretDuration,
 
 		logger: logger,
 	}
 
+	for _, resourceServer := range registryData.ResourceServers {
+		if err := r.RegisterResourceServer(resourceServer); err != nil {
+			logger.WithError(err).WithField("id", resourceServer.ID).Warnln("skipped registration of invalid resource server")
+			continue
+		}
+		logger.WithFields(logrus.Fields{
+			"id":     resourceServer.ID,
+			"scopes": resourceServer.Scopes,
+		}).Debugln("registered resource server")
+	}
+
 	for _, client := range registryData.Clients {
 		validateErr := client.Validate()
 		registerErr := r.Register(client)
@@ -134,6 +150,15 @@ func (r *Registry) Register(client *ClientRegistration) error {
 		return errors.New("no redirect_uris")
 	}
 
+	r.mutex.RLock()
+	for _, id := range client.ResourceServers {
+		if _, ok := r.resourceServers[id]; !ok {
+			r.mutex.RUnlock()
+			return fmt.Errorf("unknown resource server %v", id)
+		}
+	}
+	r.mutex.RUnlock()
+
 	switch client.ApplicationType {
 	case "":
 		client.ApplicationType = oidc.ApplicationTypeWeb
@@ -141,8 +166,10 @@ func (r *Registry) Register(client *ClientRegistration) error {
 	case oidc.ApplicationTypeWeb:
 		for _, urlString := range client.RedirectURIs {
 			// http://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
-			parsed, _ := url.Parse(urlString)
-			if parsed == nil || parsed.Host == "" {
+			parsed, err := ParseRedirectURI(urlString)
+			if err != nil {
+				return fmt.Errorf("invalid redirect_uri %v - %v", urlString, err)
+			} else if parsed.Host == "" {
 				return fmt.Errorf("invalid redirect_uri %v - invalid or no hostname", urlString)
 			} else if !client.Insecure && parsed.Scheme != "https" {
 				return fmt.Errorf("invalid redirect_uri %v - make sure to use https when application_type is web", parsed)
@@ -165,8 +192,10 @@ func (r *Registry) Register(client *ClientRegistration) error {
 		// breaks
 		for _, urlString := range client.RedirectURIs {
 			// http://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
-			parsed, _ := url.Parse(urlString)
-			if parsed == nil || parsed.Host == "" {
+			parsed, err := ParseRedirectURI(urlString)
+			if err != nil {
+				return fmt.Errorf("invalid redirect_uri %v - %v", urlString, err)
+			} else if parsed.Host == "" {
 				return fmt.Errorf("invalid redirect_uri %v - invalid uri or no hostname", urlString)
 			} else if parsed.Scheme == "https" {
 				return fmt.Errorf("invalid redirect_uri %v - scheme must not be https when application_type is native", parsed)
@@ -209,26 +238,17 @@ func (r *Registry) Validate(client *ClientRegistration, clientSecret string, red
 		// Make sure to validate the redirect URI unless client is marked insecure
 		// and has no configured redirect URIs.
 		redirectURIOK := false
-		for _, registeredURIString := range client.RedirectURIs {
-			if client.ApplicationType == oidc.ApplicationTypeNative {
-				registeredURI, _ := url.Parse(registeredURIString)
-				if IsLocalNativeHTTPURI(registeredURI) {
-					redirectURI, err := url.Parse(redirectURIString)
-					if err != nil {
-						break
-					}
-					if IsLocalNativeHTTPURI(redirectURI) {
-						if registeredURI.Path == "" || redirectURI.Path == registeredURI.Path {
-							redirectURIOK = true
-							break
-						}
-					}
+		if redirectURI, err := url.Parse(redirectURIString); err == nil {
+			native := client.ApplicationType == oidc.ApplicationTypeNative
+			for _, registeredURIString := range client.RedirectURIs {
+				registeredURI, err := url.Parse(registeredURIString)
+				if err != nil {
 					continue
 				}
-			}
-			if registeredURIString == redirectURIString {
-				redirectURIOK = true
-				break
+				if MatchRedirectURI(registeredURI, redirectURI, native) {
+					redirectURIOK = true
+					break
+				}
 			}
 		}
 		if !redirectURIOK {
@@ -290,12 +310,7 @@ func (r *Registry) Lookup(ctx context.Context, clientID string, clientSecret str
 	}
 
 	if registration != nil {
-		redirectURIBase := &url.URL{
-			Scheme: redirectURI.Scheme,
-			Host:   redirectURI.Host,
-			Path:   redirectURI.Path,
-		}
-		err = r.Validate(registration, clientSecret, redirectURIBase.String(), originURIString, withoutSecret)
+		err = r.Validate(registration, clientSecret, redirectURI.String(), originURIString, withoutSecret)
 		displayName = registration.Name
 		trusted = registration.Trusted
 	} else {
@@ -330,6 +345,95 @@ func (r *Registry) Lookup(ctx context.Context, clientID string, clientSecret str
 	}, nil
 }
 
+// RegisterResourceServer adds the provided resource server to the accociated
+// registry. Returns error if invalid.
+func (r *Registry) RegisterResourceServer(resourceServer *ResourceServer) error {
+	if resourceServer.ID == "" {
+		return errors.New("invalid resource server id")
+	}
+	if len(resourceServer.Scopes) == 0 {
+		return errors.New("no scopes")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.resourceServers == nil {
+		r.resourceServers = make(map[string]*ResourceServer)
+	}
+	if _, ok := r.resourceServers[resourceServer.ID]; ok {
+		return fmt.Errorf("duplicate resource server %v", resourceServer.ID)
+	}
+	r.resourceServers[resourceServer.ID] = resourceServer
+
+	return nil
+}
+
+// ResourceServers returns all resource servers of the accociated registry,
+// sorted by ID.
+func (r *Registry) ResourceServers() []*ResourceServer {
+	r.mutex.RLock()
+	resourceServers := make([]*ResourceServer, 0, len(r.resourceServers))
+	for _, resourceServer := range r.resourceServers {
+		resourceServers = append(resourceServers, resourceServer)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(resourceServers, func(i, j int) bool {
+		return resourceServers[i].ID < resourceServers[j].ID
+	})
+
+	return resourceServers
+}
+
+// ResourceServersForScopes returns the resource servers the provided client
+// registration is allowed to use which accept any of the provided scopes, in
+// the order of the client registration.
+func (r *Registry) ResourceServersForScopes(registration *ClientRegistration, scopes map[string]bool) []*ResourceServer {
+	if registration == nil || len(registration.ResourceServers) == 0 {
+		return nil
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var resourceServers []*ResourceServer
+	for _, id := range registration.ResourceServers {
+		if resourceServer, ok := r.resourceServers[id]; ok && resourceServer.HasAnyScope(scopes) {
+			resourceServers = append(resourceServers, resourceServer)
+		}
+	}
+
+	return resourceServers
+}
+
+// IsClientOrigin returns true if the provided origin is the origin of a
+// redirect URI of a registered web client.
+func (r *Registry) IsClientOrigin(origin string) bool {
+	originURI, err := ParseRedirectURI(origin)
+	if err != nil || originURI.Host == "" || originURI.Path != "/" || originURI.RawQuery != "" {
+		return false
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, client := range r.clients {
+		if client.ApplicationType != oidc.ApplicationTypeWeb {
+			continue
+		}
+		for _, urlString := range client.RedirectURIs {
+			redirectURI, err := ParseRedirectURI(urlString)
+			if err != nil {
+				continue
+			}
+			if redirectURI.Scheme == originURI.Scheme && redirectURI.Host == originURI.Host {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // Get returns the registered clients registration for the provided client ID.
 func (r *Registry) Get(ctx context.Context, clientID string) (*ClientRegistration, bool) {
 	// Lookup client registration.
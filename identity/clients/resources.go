@@ -0,0 +1,37 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package clients
+
+// ResourceServer defines a resource server with the scopes it accepts. The ID
+// is used as audience of access tokens issued for the resource server.
+type ResourceServer struct {
+	ID     string   `yaml:"id" json:"id"`
+	Scopes []string `yaml:"scopes,flow" json:"scopes"`
+}
+
+// HasAnyScope returns true if the accociated resource server accepts any of
+// the provided scopes.
+func (rs *ResourceServer) HasAnyScope(scopes map[string]bool) bool {
+	for _, scope := range rs.Scopes {
+		if scopes[scope] {
+			return true
+		}
+	}
+
+	return false
+}
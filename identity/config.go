@@ -26,9 +26,14 @@ import (
 // Config defines a IdentityManager's configuration settings.
 type Config struct {
 	SignInFormURI *url.URL
+	SignUpFormURI *url.URL
 	SignedOutURI  *url.URL
 
 	ScopesSupported []string
 
+	RememberConsent bool
+
+	ClientScopedGuestSubjects bool
+
 	Logger logrus.FieldLogger
 }
@@ -46,3 +46,17 @@ type Manager interface {
 	OnSetLogon(func(ctx context.Context, rw http.ResponseWriter, user User) error) error
 	OnUnsetLogon(func(ctx context.Context, rw http.ResponseWriter) error) error
 }
+
+// ManagerWithPrompts is a Manager which supports prompt values in addition to
+// the ones defined by OpenID Connect Core.
+type ManagerWithPrompts interface {
+	Manager
+	PromptsSupported() []string
+}
+
+// ManagerWithClaimScopes is a Manager which provides claims with configurable
+// names which belong to a scope, in addition to the known scoped claims.
+type ManagerWithClaimScopes interface {
+	Manager
+	ClaimScopes() map[string]string
+}
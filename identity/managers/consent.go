@@ -0,0 +1,103 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package managers
+
+import (
+	"context"
+	"sync"
+)
+
+// ConsentStore defines the interface for storage of remembered consent,
+// keyed by user and client.
+type ConsentStore interface {
+	Remember(ctx context.Context, sub string, clientID string, scopes map[string]bool) error
+	Lookup(ctx context.Context, sub string, clientID string) (map[string]bool, error)
+	Revoke(ctx context.Context, sub string, clientID string) error
+}
+
+// MemoryConsentStore implements a ConsentStore which keeps remembered consent
+// in memory.
+type MemoryConsentStore struct {
+	sync.RWMutex
+
+	table map[string]map[string]bool
+}
+
+// NewMemoryConsentStore creates a new MemoryConsentStore.
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{
+		table: make(map[string]map[string]bool),
+	}
+}
+
+func consentStoreKey(sub string, clientID string) string {
+	return sub + "\x00" + clientID
+}
+
+// Remember implements the ConsentStore interface. Provided scopes are added
+// to the already remembered scopes of the provided sub and clientID.
+func (cs *MemoryConsentStore) Remember(ctx context.Context, sub string, clientID string, scopes map[string]bool) error {
+	key := consentStoreKey(sub, clientID)
+
+	cs.Lock()
+	defer cs.Unlock()
+
+	remembered, ok := cs.table[key]
+	if !ok {
+		remembered = make(map[string]bool)
+		cs.table[key] = remembered
+	}
+	for scope, enabled := range scopes {
+		if enabled {
+			remembered[scope] = true
+		}
+	}
+
+	return nil
+}
+
+// Lookup implements the ConsentStore interface. It returns a copy of the
+// remembered scopes or nil if nothing is remembered.
+func (cs *MemoryConsentStore) Lookup(ctx context.Context, sub string, clientID string) (map[string]bool, error) {
+	key := consentStoreKey(sub, clientID)
+
+	cs.RLock()
+	defer cs.RUnlock()
+
+	remembered, ok := cs.table[key]
+	if !ok {
+		return nil, nil
+	}
+	scopes := make(map[string]bool, len(remembered))
+	for scope := range remembered {
+		scopes[scope] = true
+	}
+
+	return scopes, nil
+}
+
+// Revoke implements the ConsentStore interface.
+func (cs *MemoryConsentStore) Revoke(ctx context.Context, sub string, clientID string) error {
+	key := consentStoreKey(sub, clientID)
+
+	cs.Lock()
+	delete(cs.table, key)
+	cs.Unlock()
+
+	return nil
+}
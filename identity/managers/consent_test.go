@@ -0,0 +1,322 @@
+package managers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/libregraph/oidc-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/identifier"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+func TestRememberedConsent(t *testing.T) {
+	ctx := context.Background()
+	im := &IdentifierIdentityManager{
+		consentStore: NewMemoryConsentStore(),
+		logger:       logrus.New(),
+	}
+
+	ar := &payload.AuthenticationRequest{
+		ClientID: "client",
+		Scopes:   map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeEmail: true},
+		Prompts:  map[string]bool{},
+	}
+
+	if scopes := im.getRememberedConsent(ctx, ar, "sub"); scopes != nil {
+		t.Fatalf("expected no remembered consent before consent was given, got %v", scopes)
+	}
+
+	err := im.consentStore.Remember(ctx, "sub", "client", map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeEmail: true, oidc.ScopeProfile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scopes := im.getRememberedConsent(ctx, ar, "sub")
+	if scopes == nil {
+		t.Fatal("expected remembered consent to skip consent")
+	}
+	if len(scopes) != 2 || !scopes[oidc.ScopeOpenID] || !scopes[oidc.ScopeEmail] {
+		t.Errorf("unexpected approved scopes: %v", scopes)
+	}
+
+	if scopes := im.getRememberedConsent(ctx, ar, "other-sub"); scopes != nil {
+		t.Errorf("expected no remembered consent for other user, got %v", scopes)
+	}
+
+	ar.Scopes[oidc.ScopeOfflineAccess] = true
+	if scopes := im.getRememberedConsent(ctx, ar, "sub"); scopes != nil {
+		t.Errorf("expected consent for scopes which were not consented before, got %v", scopes)
+	}
+	delete(ar.Scopes, oidc.ScopeOfflineAccess)
+
+	ar.Prompts[oidc.PromptConsent] = true
+	if scopes := im.getRememberedConsent(ctx, ar, "sub"); scopes != nil {
+		t.Errorf("expected prompt=consent to override remembered consent, got %v", scopes)
+	}
+	delete(ar.Prompts, oidc.PromptConsent)
+
+	err = im.RevokeConsent(ctx, "sub", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scopes := im.getRememberedConsent(ctx, ar, "sub"); scopes != nil {
+		t.Errorf("expected no remembered consent after revoke, got %v", scopes)
+	}
+}
+
+type consentTestUser struct {
+	sub string
+}
+
+func (u *consentTestUser) Subject() string {
+	return u.sub
+}
+
+func (u *consentTestUser) Raw() string {
+	return u.sub
+}
+
+func newConsentTestAuthRecord(im identity.Manager, sub string) identity.AuthRecord {
+	auth := identity.NewAuthRecord(im, sub, nil, nil, nil)
+	auth.SetUser(&consentTestUser{sub})
+	return auth
+}
+
+func TestIncrementalConsent(t *testing.T) {
+	ctx := context.Background()
+	im := newTestIdentifierIdentityManager(t, nil)
+	im.scopesSupported = append(im.scopesSupported, oidc.ScopeEmail, oidc.ScopeProfile)
+	im.consentStore = NewMemoryConsentStore()
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.Register(&clients.ClientRegistration{ID: "client", RedirectURIs: []string{"https://client.example.com/cb"}}); err != nil {
+		t.Fatal(err)
+	}
+	im.clients = registry
+
+	err = im.consentStore.Remember(ctx, "sub", "client", map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeEmail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := url.Values{}
+	params.Set("scope", strings.Join([]string{oidc.ScopeOpenID, oidc.ScopeEmail, oidc.ScopeProfile}, " "))
+
+	// Only the scope which was not consented before is prompted for.
+	req, ar := newTestAuthenticationRequest(t, params)
+	rr := httptest.NewRecorder()
+	_, err = im.Authorize(ctx, rr, req, ar, newConsentTestAuthRecord(im, "sub"))
+	if _, ok := err.(*identity.IsHandledError); !ok {
+		t.Fatalf("expected consent redirect, got %v", err)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow := location.Query().Get("flow"); flow != identifier.FlowConsent {
+		t.Errorf("expected consent flow, got %q", flow)
+	}
+	if scope := location.Query().Get("scope"); scope != oidc.ScopeProfile {
+		t.Errorf("expected consent prompt only for new scope, got %q", scope)
+	}
+
+	// Consent for the new scope results in the union of scopes.
+	rr = httptest.NewRecorder()
+	err = im.identifier.SetConsentToConsentCookie(ctx, rr, &identifier.ConsentRequest{
+		State:          "consent-state",
+		ClientID:       "client",
+		RawRedirectURI: "https://client.example.com/cb",
+	}, &identifier.Consent{
+		Allow:    true,
+		RawScope: oidc.ScopeProfile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Set("konnect", "consent-state")
+	req, ar = newTestAuthenticationRequest(t, params)
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	auth, err := im.Authorize(ctx, httptest.NewRecorder(), req, ar, newConsentTestAuthRecord(im, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved := auth.AuthorizedScopes()
+	if len(approved) != 3 || !approved[oidc.ScopeOpenID] || !approved[oidc.ScopeEmail] || !approved[oidc.ScopeProfile] {
+		t.Errorf("expected union of remembered and new scopes to be approved, got %v", approved)
+	}
+
+	// The union is remembered, so the next request needs no consent.
+	if scopes := im.getRememberedConsent(ctx, ar, "sub"); len(scopes) != 3 {
+		t.Errorf("expected union of scopes to be remembered, got %v", scopes)
+	}
+}
+
+func TestRevokedConsentPromptsAgain(t *testing.T) {
+	ctx := context.Background()
+	adminSecret := "0123456789abcdef0123456789abcdef"
+	im := newTestIdentifierIdentityManagerWithConfig(t, &identifier.Config{
+		AdminSecret: []byte(adminSecret),
+	})
+	im.consentStore = NewMemoryConsentStore()
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.Register(&clients.ClientRegistration{ID: "client", RedirectURIs: []string{"https://client.example.com/cb"}}); err != nil {
+		t.Fatal(err)
+	}
+	im.clients = registry
+
+	router := mux.NewRouter()
+	im.AddRoutes(ctx, router)
+
+	err = im.consentStore.Remember(ctx, "sub", "client", map[string]bool{oidc.ScopeOpenID: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, ar := newTestAuthenticationRequest(t, nil)
+	if _, err = im.Authorize(ctx, httptest.NewRecorder(), req, ar, newConsentTestAuthRecord(im, "sub")); err != nil {
+		t.Fatalf("expected remembered consent to skip consent, got %v", err)
+	}
+
+	revoke := httptest.NewRequest(http.MethodDelete, "/identifier/_/admin/consents/client?sub=sub", nil)
+	revoke.Header.Set("Authorization", "Bearer "+adminSecret)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, revoke)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("admin consent revoke returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+
+	req, ar = newTestAuthenticationRequest(t, nil)
+	rr = httptest.NewRecorder()
+	_, err = im.Authorize(ctx, rr, req, ar, newConsentTestAuthRecord(im, "sub"))
+	if _, ok := err.(*identity.IsHandledError); !ok {
+		t.Fatalf("expected consent redirect after revoke, got %v", err)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow := location.Query().Get("flow"); flow != identifier.FlowConsent {
+		t.Errorf("expected consent flow after revoke, got %q", flow)
+	}
+}
+
+func TestTrustedClientSkipsConsent(t *testing.T) {
+	ctx := context.Background()
+	im := &IdentifierIdentityManager{
+		logger: logrus.New(),
+	}
+
+	ar := &payload.AuthenticationRequest{
+		ClientID: "client",
+		Scopes:   map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeEmail: true},
+		Prompts:  map[string]bool{},
+	}
+
+	trusted := &clients.Details{
+		ID:           "client",
+		Trusted:      true,
+		Registration: &clients.ClientRegistration{ID: "client", Trusted: true},
+	}
+	if scopes, _ := im.getPreApprovedScopes(ctx, trusted, ar, "sub"); scopes == nil {
+		t.Error("expected trusted client to skip consent")
+	} else if len(scopes) != 2 {
+		t.Errorf("unexpected approved scopes for trusted client: %v", scopes)
+	}
+
+	normal := &clients.Details{
+		ID:           "client",
+		Registration: &clients.ClientRegistration{ID: "client"},
+	}
+	if scopes, _ := im.getPreApprovedScopes(ctx, normal, ar, "sub"); scopes != nil {
+		t.Errorf("expected normal client to require consent, got %v", scopes)
+	}
+}
+
+func TestTrustedClientAuthorize(t *testing.T) {
+	ctx := context.Background()
+	im := newTestIdentifierIdentityManager(t, nil)
+	im.scopesSupported = append(im.scopesSupported, oidc.ScopeEmail)
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, registration := range []*clients.ClientRegistration{
+		{ID: "client", RedirectURIs: []string{"https://client.example.com/cb"}, Trusted: true},
+		{ID: "client-trusted-scopes", RedirectURIs: []string{"https://client.example.com/cb"}, Trusted: true, TrustedScopes: []string{"Other.Scope"}},
+		{ID: "client-normal", RedirectURIs: []string{"https://client.example.com/cb"}},
+	} {
+		if err = registry.Register(registration); err != nil {
+			t.Fatal(err)
+		}
+	}
+	im.clients = registry
+
+	params := url.Values{}
+	params.Set("scope", strings.Join([]string{oidc.ScopeOpenID, oidc.ScopeEmail, "Other.Scope"}, " "))
+
+	// Trusted clients get the requested scopes which are supported without
+	// consent. Trusted scopes only apply to guests and change nothing here.
+	for _, clientID := range []string{"client", "client-trusted-scopes"} {
+		params.Set("client_id", clientID)
+		req, ar := newTestAuthenticationRequest(t, params)
+		auth, err := im.Authorize(ctx, httptest.NewRecorder(), req, ar, newConsentTestAuthRecord(im, "sub"))
+		if err != nil {
+			t.Fatalf("%s: expected trusted client to skip consent, got %v", clientID, err)
+		}
+		approved := auth.AuthorizedScopes()
+		if len(approved) != 2 || !approved[oidc.ScopeOpenID] || !approved[oidc.ScopeEmail] {
+			t.Errorf("%s: unexpected approved scopes for trusted client: %v", clientID, approved)
+		}
+	}
+
+	// Normal clients are sent to the consent screen.
+	params.Set("client_id", "client-normal")
+	req, ar := newTestAuthenticationRequest(t, params)
+	rr := httptest.NewRecorder()
+	_, err = im.Authorize(ctx, rr, req, ar, newConsentTestAuthRecord(im, "sub"))
+	if _, ok := err.(*identity.IsHandledError); !ok {
+		t.Fatalf("expected consent redirect for normal client, got %v", err)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow := location.Query().Get("flow"); flow != identifier.FlowConsent {
+		t.Errorf("expected consent flow, got %q", flow)
+	}
+}
+
+func TestTrustedApprovedScopes(t *testing.T) {
+	requested := map[string]bool{
+		oidc.ScopeOpenID: true,
+		oidc.ScopeEmail:  true,
+		"Trusted.Scope":  true,
+		"Other.Scope":    true,
+	}
+
+	scopes := getTrustedApprovedScopes(requested, []string{"Trusted.Scope"}, []string{oidc.ScopeEmail})
+	for _, scope := range []string{oidc.ScopeOpenID, oidc.ScopeEmail, "Trusted.Scope"} {
+		if !scopes[scope] {
+			t.Errorf("expected scope %v to be approved", scope)
+		}
+	}
+	if scopes["Other.Scope"] {
+		t.Error("expected scope Other.Scope not to be approved")
+	}
+}
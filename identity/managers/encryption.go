@@ -18,28 +18,92 @@
 package managers
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 
 	"github.com/libregraph/lico/encryption"
 )
 
+// keyIDFrameMagic is the prefix of ciphertexts which are framed with the id
+// of the key used to seal them. The magic is followed by a single byte with
+// the length of the key id and the key id itself.
+var keyIDFrameMagic = []byte("lk1")
+
 // EncryptionManager implements string encryption functions with a key.
+// Additional keys with key id can be added to a key ring, allowing rotation
+// of the key used to seal while values sealed with previous keys can still be
+// opened.
 type EncryptionManager struct {
-	key *[encryption.KeySize]byte
+	key   *[encryption.KeySize]byte
+	keyID string
+
+	legacyKey *[encryption.KeySize]byte
+	keys      map[string]*[encryption.KeySize]byte
 }
 
 // NewEncryptionManager creates a new EncryptionManager with the provided key.
 func NewEncryptionManager(key *[encryption.KeySize]byte) (*EncryptionManager, error) {
 	em := &EncryptionManager{
-		key: key,
+		key:       key,
+		legacyKey: key,
+		keys:      make(map[string]*[encryption.KeySize]byte),
 	}
 
 	return em, nil
 }
 
-// SetKey sets the provided key for the accociated manager.
+// SetKey sets the provided key for the associated manager. The key has no key
+// id and values sealed with it are not framed.
 func (em *EncryptionManager) SetKey(key []byte) error {
+	k, err := decodeEncryptionKey(key)
+	if err != nil {
+		return err
+	}
+
+	em.key = k
+	em.keyID = ""
+	em.legacyKey = k
+	return nil
+}
+
+// AddKeyWithID adds the provided key with the provided key id to the key ring
+// of the associated manager. Added keys are used to open values which were
+// sealed with the key id framing.
+func (em *EncryptionManager) AddKeyWithID(kid string, key []byte) error {
+	if kid == "" {
+		return fmt.Errorf("encryption key id must not be empty")
+	}
+	if len(kid) > 255 {
+		return fmt.Errorf("encryption key id too long")
+	}
+	k, err := decodeEncryptionKey(key)
+	if err != nil {
+		return err
+	}
+
+	if em.keys == nil {
+		em.keys = make(map[string]*[encryption.KeySize]byte)
+	}
+	em.keys[kid] = k
+	return nil
+}
+
+// SetKeyID selects the key with the provided key id from the key ring of the
+// associated manager as primary key. Values sealed from now on are framed
+// with the key id.
+func (em *EncryptionManager) SetKeyID(kid string) error {
+	k, ok := em.keys[kid]
+	if !ok {
+		return fmt.Errorf("encryption key with id %s not found", kid)
+	}
+
+	em.key = k
+	em.keyID = kid
+	return nil
+}
+
+func decodeEncryptionKey(key []byte) (*[encryption.KeySize]byte, error) {
 	switch len(key) {
 	case encryption.KeySize:
 		// all good, breaks
@@ -51,12 +115,12 @@ func (em *EncryptionManager) SetKey(key []byte) error {
 		}
 	}
 	if len(key) != encryption.KeySize {
-		return fmt.Errorf("encryption key size error, is %d, want %d", len(key), encryption.KeySize)
+		return nil, fmt.Errorf("encryption key size error, is %d, want %d", len(key), encryption.KeySize)
 	}
 
-	em.key = new([encryption.KeySize]byte)
-	copy(em.key[:], key[:encryption.KeySize])
-	return nil
+	k := new([encryption.KeySize]byte)
+	copy(k[:], key[:encryption.KeySize])
+	return k, nil
 }
 
 // GetKeySize returns the size of the accociated manager's key.
@@ -76,14 +140,25 @@ func (em *EncryptionManager) EncryptStringToHexString(plaintext string) (string,
 }
 
 // Encrypt encrypts plaintext []byte with the accociated key and returns
-// ciphertext []byte.
+// ciphertext []byte. If the associated key has a key id, the ciphertext is
+// prefixed with the key id.
 func (em *EncryptionManager) Encrypt(plaintext []byte) ([]byte, error) {
 	ciphertext, err := encryption.Encrypt(plaintext, em.key)
 	if err != nil {
 		return nil, err
 	}
 
-	return ciphertext, nil
+	if em.keyID == "" {
+		return ciphertext, nil
+	}
+
+	framed := make([]byte, 0, len(keyIDFrameMagic)+1+len(em.keyID)+len(ciphertext))
+	framed = append(framed, keyIDFrameMagic...)
+	framed = append(framed, byte(len(em.keyID)))
+	framed = append(framed, em.keyID...)
+	framed = append(framed, ciphertext...)
+
+	return framed, nil
 }
 
 // DecryptHexToString decrypts a hex encoded string with the accociated key
@@ -102,12 +177,43 @@ func (em *EncryptionManager) DecryptHexToString(ciphertextHex string) (string, e
 }
 
 // Decrypt decrypts ciphertext []byte with the accociated key and returns
-// plaintext []byte.
+// plaintext []byte. Ciphertext prefixed with a key id is decrypted with the
+// matching key of the key ring. Unprefixed legacy ciphertext is decrypted
+// with the primary key and the key without key id.
 func (em *EncryptionManager) Decrypt(ciphertext []byte) ([]byte, error) {
+	if kid, framed, ok := parseKeyIDFrame(ciphertext); ok {
+		if key, found := em.keys[kid]; found {
+			if plaintext, err := encryption.Decrypt(framed, key); err == nil {
+				return plaintext, nil
+			}
+		}
+		// Fall through, a legacy nonce might start with the magic by chance.
+	}
+
 	plaintext, err := encryption.Decrypt(ciphertext, em.key)
+	if err != nil && em.legacyKey != nil && em.legacyKey != em.key {
+		plaintext, err = encryption.Decrypt(ciphertext, em.legacyKey)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return plaintext, nil
 }
+
+func parseKeyIDFrame(ciphertext []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(ciphertext, keyIDFrameMagic) {
+		return "", nil, false
+	}
+	rest := ciphertext[len(keyIDFrameMagic):]
+	if len(rest) < 1 {
+		return "", nil, false
+	}
+	size := int(rest[0])
+	rest = rest[1:]
+	if size == 0 || len(rest) < size {
+		return "", nil, false
+	}
+
+	return string(rest[:size]), rest[size:], true
+}
@@ -0,0 +1,104 @@
+package managers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/libregraph/lico/encryption"
+)
+
+func newTestEncryptionKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, encryption.KeySize)
+}
+
+func TestEncryptionManagerKeyRotation(t *testing.T) {
+	em, err := NewEncryptionManager(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = em.SetKey(newTestEncryptionKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = em.AddKeyWithID("key-1", newTestEncryptionKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err = em.SetKeyID("key-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := em.EncryptStringToHexString("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a new primary, the value sealed with key-1 must still open.
+	if err = em.AddKeyWithID("key-2", newTestEncryptionKey(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err = em.SetKeyID("key-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := em.DecryptHexToString(sealed)
+	if err != nil {
+		t.Fatalf("failed to unseal value sealed with previous primary: %v", err)
+	}
+	if plaintext != "hello" {
+		t.Errorf("unexpected plaintext: %s", plaintext)
+	}
+
+	sealed2, err := em.Encrypt([]byte("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid, _, ok := parseKeyIDFrame(sealed2); !ok || kid != "key-2" {
+		t.Errorf("expected value to be framed with key-2, got %v %s", ok, kid)
+	}
+
+	// Without key-2 in the key ring the new value must not open.
+	other, _ := NewEncryptionManager(nil)
+	_ = other.SetKey(newTestEncryptionKey(1))
+	_ = other.AddKeyWithID("key-1", newTestEncryptionKey(2))
+	if _, err = other.Decrypt(sealed2); err == nil {
+		t.Errorf("expected error when unsealing with unknown key id")
+	}
+}
+
+func TestEncryptionManagerLegacyValues(t *testing.T) {
+	em, _ := NewEncryptionManager(nil)
+	if err := em.SetKey(newTestEncryptionKey(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := em.Encrypt([]byte("legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = em.AddKeyWithID("key-1", newTestEncryptionKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err = em.SetKeyID("key-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := em.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("failed to unseal unprefixed legacy value: %v", err)
+	}
+	if string(plaintext) != "legacy" {
+		t.Errorf("unexpected plaintext: %s", plaintext)
+	}
+}
+
+func TestEncryptionManagerKeyIDErrors(t *testing.T) {
+	em, _ := NewEncryptionManager(nil)
+	if err := em.AddKeyWithID("", newTestEncryptionKey(1)); err == nil {
+		t.Errorf("expected error for empty key id")
+	}
+	if err := em.AddKeyWithID("short", []byte("short")); err == nil {
+		t.Errorf("expected error for invalid key size")
+	}
+	if err := em.SetKeyID("unknown"); err == nil {
+		t.Errorf("expected error for unknown key id")
+	}
+}
@@ -38,11 +38,21 @@ import (
 
 const guestIdentitityManagerName = "guest"
 
+// guestScopesSupported is the subset of scopes which guest identities can ever
+// support. Configured supported scopes are limited to this subset.
+var guestScopesSupported = []string{
+	konnect.ScopeNumericID,
+	oidc.ScopeProfile,
+	oidc.ScopeEmail,
+}
+
 // GuestIdentityManager implements an identity manager for guest users.
 type GuestIdentityManager struct {
 	scopesSupported []string
 	claimsSupported []string
 
+	clientScopedSubjects bool
+
 	logger  logrus.FieldLogger
 	clients *clients.Registry
 
@@ -54,11 +64,7 @@ type GuestIdentityManager struct {
 // provided parameters.
 func NewGuestIdentityManager(c *identity.Config) *GuestIdentityManager {
 	im := &GuestIdentityManager{
-		scopesSupported: setupSupportedScopes([]string{}, []string{
-			konnect.ScopeNumericID,
-			oidc.ScopeProfile,
-			oidc.ScopeEmail,
-		}, c.ScopesSupported),
+		scopesSupported: limitGuestScopesSupported(setupSupportedScopes([]string{}, guestScopesSupported, c.ScopesSupported)),
 		claimsSupported: []string{
 			oidc.NameClaim,
 			oidc.FamilyNameClaim,
@@ -67,6 +73,8 @@ func NewGuestIdentityManager(c *identity.Config) *GuestIdentityManager {
 			oidc.EmailVerifiedClaim,
 		},
 
+		clientScopedSubjects: c.ClientScopedGuestSubjects,
+
 		logger: c.Logger,
 
 		onSetLogonCallbacks:   make([]func(ctx context.Context, rw http.ResponseWriter, user identity.User) error, 0),
@@ -76,8 +84,25 @@ func NewGuestIdentityManager(c *identity.Config) *GuestIdentityManager {
 	return im
 }
 
+// limitGuestScopesSupported returns the provided scopes which are in the
+// subset of scopes supported for guests.
+func limitGuestScopesSupported(scopes []string) []string {
+	limited := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		for _, safe := range guestScopesSupported {
+			if scope == safe {
+				limited = append(limited, scope)
+				break
+			}
+		}
+	}
+
+	return limited
+}
+
 type guestUser struct {
 	raw           string
+	clientID      string
 	email         string
 	emailVerified bool
 	name          string
@@ -111,6 +136,8 @@ func newGuestUserFromClaims(claims jwt.MapClaims) *guestUser {
 	data, _ := dataClaim.(map[string]interface{})
 	for name, value := range data {
 		switch name {
+		case "c":
+			user.clientID, _ = value.(string)
 		case "e":
 			user.email, _ = value.(string)
 		case "ev":
@@ -130,6 +157,7 @@ func newGuestUserFromClaims(claims jwt.MapClaims) *guestUser {
 }
 
 type minimalGuestUserData struct {
+	C  string `json:"c,omitempty"`
 	E  string `json:"e,omitempty"`
 	EV int    `json:"ev,omitempty"`
 	N  string `json:"n,omitempty"`
@@ -141,8 +169,15 @@ func (u *guestUser) Raw() string {
 	return u.raw
 }
 
+// Subject returns the public subject of the guest user. Guest subjects of
+// guests with a client are scoped to that client, so the same guest value
+// results in different subjects for different clients.
 func (u *guestUser) Subject() string {
-	sub, _ := getPublicSubject([]byte(u.raw), []byte(guestIdentitityManagerName))
+	extra := guestIdentitityManagerName
+	if u.clientID != "" {
+		extra += " " + u.clientID
+	}
+	sub, _ := getPublicSubject([]byte(u.raw), []byte(extra))
 	return sub
 }
 
@@ -172,6 +207,7 @@ func (u *guestUser) Claims() jwt.MapClaims {
 	claims[konnect.IdentifiedUserIsGuest] = true
 
 	m := &minimalGuestUserData{
+		C:  u.clientID,
 		E:  u.email,
 		N:  u.name,
 		NF: u.familyName,
@@ -256,6 +292,9 @@ func (im *GuestIdentityManager) Authenticate(ctx context.Context, rw http.Respon
 		familyName:    familyName,
 		givenName:     givenName,
 	}
+	if im.clientScopedSubjects {
+		user.clientID = ar.ClientID
+	}
 
 	// TODO(longsleep): Add additional claims to user from the claims request
 	// after filtering.
@@ -348,29 +387,9 @@ func (im *GuestIdentityManager) Authorize(ctx context.Context, rw http.ResponseW
 		// the trusted client configuration.
 		approvedScopes = ar.Scopes
 	} else {
-		supportedScopes := make(map[string]bool)
-		for _, scope := range im.ScopesSupported(nil) {
-			supportedScopes[scope] = true
-		}
-
-		// Auto approve all supported scopes.
-		approvedScopes = make(map[string]bool)
-		for scope := range ar.Scopes {
-			if _, ok := supportedScopes[scope]; ok {
-				approvedScopes[scope] = true
-			}
-		}
-		// Approve all additional scopes which are allowed by the trusted
-		// client.
-		for _, scope := range securedDetails.TrustedScopes {
-			if _, ok := ar.Scopes[scope]; ok {
-				approvedScopes[scope] = true
-			}
-		}
-		// Always approve openid scope.
-		if _, ok := ar.Scopes[oidc.ScopeOpenID]; ok {
-			approvedScopes[oidc.ScopeOpenID] = true
-		}
+		// Auto approve all supported scopes and all additional scopes which
+		// are allowed by the trusted client.
+		approvedScopes = getTrustedApprovedScopes(ar.Scopes, securedDetails.TrustedScopes, im.ScopesSupported(nil))
 
 		// Ensure that guest scope was approved.
 		if ok, _ := approvedScopes[konnect.ScopeGuestOK]; !ok {
@@ -0,0 +1,179 @@
+package managers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+	"github.com/sirupsen/logrus"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/managers"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+func newTestGuestIdentityManager(t *testing.T, scopesSupported []string) *GuestIdentityManager {
+	logger := logrus.New()
+
+	registry, err := clients.NewRegistry(context.Background(), &url.URL{Scheme: "https", Host: "localhost"}, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgrs := managers.New()
+	mgrs.Set("clients", registry)
+
+	im := NewGuestIdentityManager(&identity.Config{
+		ScopesSupported: scopesSupported,
+
+		Logger: logger,
+	})
+	if err = im.RegisterManagers(mgrs); err != nil {
+		t.Fatal(err)
+	}
+
+	return im
+}
+
+func newTestGuestAuthenticationRequest(t *testing.T, clientID string, guest string, scopes []string, trustedScopes []string) *payload.AuthenticationRequest {
+	roc := &payload.RequestObjectClaims{
+		ClientID: clientID,
+		Claims:   &payload.ClaimsRequest{},
+	}
+	if err := roc.SetSecure(&clients.Secured{
+		ID:            clientID,
+		TrustedScopes: trustedScopes,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ar := &payload.AuthenticationRequest{
+		ClientID:    clientID,
+		RedirectURI: &url.URL{Scheme: "https", Host: "localhost", Path: "/cb"},
+		Scopes:      make(map[string]bool),
+		Prompts:     make(map[string]bool),
+		Claims: &payload.ClaimsRequest{
+			IDToken: &payload.ClaimsRequestMap{
+				"guest": &payload.ClaimsRequestValue{Value: guest},
+			},
+		},
+		Request: &jwt.Token{
+			Method: jwt.SigningMethodRS256,
+			Claims: roc,
+		},
+	}
+	for _, scope := range scopes {
+		ar.Scopes[scope] = true
+	}
+
+	return ar
+}
+
+func TestGuestSession(t *testing.T) {
+	im := newTestGuestIdentityManager(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/konnect/v1/authorize", nil)
+	ar := newTestGuestAuthenticationRequest(t, "client-a", "guest-1", []string{
+		oidc.ScopeOpenID,
+		oidc.ScopeProfile,
+		oidc.ScopeOfflineAccess,
+		konnect.ScopeGuestOK,
+		"admin",
+	}, []string{konnect.ScopeGuestOK})
+
+	auth, err := im.Authenticate(req.Context(), httptest.NewRecorder(), req, ar, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err = im.Authorize(req.Context(), httptest.NewRecorder(), req, ar, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approved := auth.AuthorizedScopes()
+	for _, scope := range []string{oidc.ScopeOpenID, oidc.ScopeProfile, konnect.ScopeGuestOK} {
+		if !approved[scope] {
+			t.Errorf("expected scope %s to be approved", scope)
+		}
+	}
+	for _, scope := range []string{oidc.ScopeOfflineAccess, "admin"} {
+		if approved[scope] {
+			t.Errorf("unexpected approved scope %s", scope)
+		}
+	}
+
+	// Guest subjects are not scoped to the requesting client by default.
+	unscoped, _ := getPublicSubject([]byte("guest-1"), []byte(guestIdentitityManagerName))
+	if auth.Subject() != unscoped {
+		t.Errorf("expected unscoped guest subject, got %v", auth.Subject())
+	}
+	other := newTestGuestAuthenticationRequest(t, "client-b", "guest-1", []string{oidc.ScopeOpenID, konnect.ScopeGuestOK}, nil)
+	otherAuth, err := im.Authenticate(req.Context(), httptest.NewRecorder(), req, other, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherAuth.Subject() != auth.Subject() {
+		t.Errorf("expected same guest subject for different clients")
+	}
+
+	// Subject survives the round trip through identity claims.
+	claimsJSON, err := json.Marshal(auth.User().(*guestUser).Claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := make(jwt.MapClaims)
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	user := newGuestUserFromClaims(claims)
+	if user == nil || user.Subject() != auth.Subject() {
+		t.Errorf("guest subject changed after claims round trip")
+	}
+}
+
+func TestGuestSessionClientScopedSubjects(t *testing.T) {
+	im := newTestGuestIdentityManager(t, nil)
+	im.clientScopedSubjects = true
+
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/konnect/v1/authorize", nil)
+	auth, err := im.Authenticate(req.Context(), httptest.NewRecorder(), req, newTestGuestAuthenticationRequest(t, "client-a", "guest-1", []string{oidc.ScopeOpenID, konnect.ScopeGuestOK}, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAuth, err := im.Authenticate(req.Context(), httptest.NewRecorder(), req, newTestGuestAuthenticationRequest(t, "client-b", "guest-1", []string{oidc.ScopeOpenID, konnect.ScopeGuestOK}, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherAuth.Subject() == auth.Subject() {
+		t.Errorf("expected different guest subjects for different clients")
+	}
+
+	// Subject survives the round trip through identity claims.
+	claimsJSON, err := json.Marshal(auth.User().(*guestUser).Claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := make(jwt.MapClaims)
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	user := newGuestUserFromClaims(claims)
+	if user == nil || user.Subject() != auth.Subject() {
+		t.Errorf("client scoped guest subject changed after claims round trip")
+	}
+}
+
+func TestGuestScopesSupportedLimited(t *testing.T) {
+	im := newTestGuestIdentityManager(t, []string{oidc.ScopeEmail, "admin", oidc.ScopeOfflineAccess})
+
+	supported := im.ScopesSupported(nil)
+	if len(supported) != 1 || supported[0] != oidc.ScopeEmail {
+		t.Errorf("unexpected guest scopes supported: %v", supported)
+	}
+}
@@ -22,13 +22,16 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/libregraph/oidc-go"
 	"github.com/longsleep/rndm"
 	"github.com/sirupsen/logrus"
 
+	konnect "github.com/libregraph/lico"
 	"github.com/libregraph/lico/identifier"
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/clients"
@@ -42,14 +45,17 @@ import (
 // Konnect its identifier to provide identity.
 type IdentifierIdentityManager struct {
 	signInFormURI string
+	signUpFormURI string
 	signedOutURI  string
 
 	scopesSupported []string
+	scopesAllowed   map[string]bool
 	claimsSupported []string
 
-	identifier *identifier.Identifier
-	clients    *clients.Registry
-	logger     logrus.FieldLogger
+	identifier   *identifier.Identifier
+	clients      *clients.Registry
+	consentStore ConsentStore
+	logger       logrus.FieldLogger
 }
 
 type identifierUser struct {
@@ -112,6 +118,21 @@ func NewIdentifierIdentityManager(c *identity.Config, i *identifier.Identifier)
 		logger:     c.Logger,
 	}
 
+	if c.SignUpFormURI != nil {
+		im.signUpFormURI = c.SignUpFormURI.String()
+	}
+	if c.RememberConsent {
+		im.consentStore = NewMemoryConsentStore()
+	}
+	i.OnRevokeConsent(im.RevokeConsent)
+	if len(c.ScopesSupported) > 0 {
+		// Explicitly allowed scopes limit what the backend can add.
+		im.scopesAllowed = make(map[string]bool)
+		for _, scope := range c.ScopesSupported {
+			im.scopesAllowed[scope] = true
+		}
+	}
+
 	return im
 }
 
@@ -132,7 +153,16 @@ func (im *IdentifierIdentityManager) Authenticate(ctx context.Context, rw http.R
 		return nil, ar.NewError(authenticationErrorID, req.Form.Get("error_description"))
 	}
 
-	u, _ := im.identifier.GetUserFromLogonCookie(ctx, req, ar.MaxAge, true)
+	if ar.Prompts[konnectoidc.PromptCreate] {
+		// Account creation requested, redirect to sign-up form.
+		return nil, im.redirectToSignUpForm(rw, req, ar)
+	}
+
+	u, _ := im.identifier.GetUserFromLogonCookie(ctx, rw, req, ar.MaxAge, true)
+	if u == nil && !ar.Prompts[oidc.PromptLogin] && !ar.Prompts[oidc.PromptSelectAccount] {
+		// Not signed in, try to resume a persistent session.
+		u, _ = im.identifier.GetUserFromPersistentCookie(ctx, rw, req, ar.MaxAge)
+	}
 	if u != nil {
 		// TODO(longsleep): Add other user meta data.
 		user = asIdentifierUser(u)
@@ -226,6 +256,15 @@ func (im *IdentifierIdentityManager) Authenticate(ctx context.Context, rw http.R
 						}
 						ar.Scopes = expanded
 					}
+					// Keep claims and scopes mapped from the external authority
+					// the user signed in with.
+					bu.IdentifiedUser.InheritAuthorityMappings(user.IdentifiedUser)
+					for _, scope := range user.AuthorityScopes() {
+						if enabled, ok := ar.Scopes[scope]; ok && !enabled {
+							continue
+						}
+						ar.Scopes[scope] = true
+					}
 				}
 			}
 		}
@@ -243,6 +282,21 @@ func (im *IdentifierIdentityManager) Authenticate(ctx context.Context, rw http.R
 			return nil, err
 		}
 		query.Set("flow", identifier.FlowOIDC)
+		if len(ar.UILocales) > 0 {
+			// Forward the best matching locale to the sign-in form.
+			query.Set("ui_locales", im.identifier.MatchUILocale(ar.UILocales))
+		}
+		// Only forward validated login_hint values. The hint is used to prefill
+		// the sign-in form and never identifies the user.
+		query.Del("login_hint")
+		if ar.LoginHint != "" {
+			query.Set("login_hint", ar.LoginHint)
+		}
+		if ar.MaxAge > 0 {
+			// Forward the effective max_age which can be stricter than the
+			// requested one, so the sign-in form does not reuse older logons.
+			query.Set("max_age", strconv.FormatInt(int64(ar.MaxAge/time.Second), 10))
+		}
 		if ar.Claims != nil {
 			// Add derived scope list from claims request.
 			claimsScopes := ar.Claims.Scopes(ar.Scopes)
@@ -272,19 +326,39 @@ func (im *IdentifierIdentityManager) Authenticate(ctx context.Context, rw http.R
 	return auth, nil
 }
 
-// Authorize implements the identity.Manager interface.
-func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.ResponseWriter, req *http.Request, ar *payload.AuthenticationRequest, auth identity.AuthRecord) (identity.AuthRecord, error) {
-	promptConsent := false
-	var approvedScopes map[string]bool
+func (im *IdentifierIdentityManager) redirectToSignUpForm(rw http.ResponseWriter, req *http.Request, ar *payload.AuthenticationRequest) error {
+	if im.signUpFormURI == "" {
+		return ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "IdentifierIdentityManager: prompt=create is not supported")
+	}
 
-	// Check prompt value.
-	switch {
-	case ar.Prompts[oidc.PromptConsent] == true:
-		promptConsent = true
-	default:
-		// Let all other prompt values pass.
+	// Build sign-up URL.
+	query, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+	// Remove create from prompt, so the sign-up form can continue the
+	// authorization request with the provided query once done.
+	var prompts []string
+	for _, prompt := range strings.Fields(query.Get("prompt")) {
+		if prompt != konnectoidc.PromptCreate {
+			prompts = append(prompts, prompt)
+		}
+	}
+	if len(prompts) > 0 {
+		query.Set("prompt", strings.Join(prompts, " "))
+	} else {
+		query.Del("prompt")
 	}
+	query.Set("flow", identifier.FlowOIDC)
+	u, _ := url.Parse(im.signUpFormURI)
+	u.RawQuery = query.Encode()
+	utils.WriteRedirect(rw, http.StatusFound, u, nil, false)
+
+	return &identity.IsHandledError{}
+}
 
+// Authorize implements the identity.Manager interface.
+func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.ResponseWriter, req *http.Request, ar *payload.AuthenticationRequest, auth identity.AuthRecord) (identity.AuthRecord, error) {
 	origin := ""
 	if false {
 		// TODO(longsleep): find a condition when this can be enabled.
@@ -295,11 +369,21 @@ func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.Resp
 		return nil, ar.NewError(oidc.ErrorCodeOAuth2AccessDenied, err.Error())
 	}
 
-	// If not trusted, always force consent.
-	if clientDetails.Trusted {
-		approvedScopes = ar.Scopes
-	} else {
-		promptConsent = true
+	// Check if consent can be skipped, always force consent otherwise.
+	approvedScopes, remembered := im.getPreApprovedScopes(ctx, clientDetails, ar, auth.Subject())
+	promptConsent := approvedScopes == nil
+	var rememberedScopes, consentScopes map[string]bool
+	if promptConsent {
+		// With remembered consent, only ask for the scopes which were not
+		// consented to before (incremental authorization).
+		rememberedScopes, consentScopes = im.getRememberedConsentScopes(ctx, ar, auth.Subject())
+	}
+	if remembered && ar.Claims != nil {
+		// Filter claims request by remembered approved scopes.
+		err = ar.Claims.ApplyScopes(approvedScopes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Check given consent.
@@ -309,11 +393,28 @@ func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.Resp
 	}
 	if consent != nil {
 		if !consent.Allow {
+			// Keep remembered consent when only additional scopes were denied.
+			if im.consentStore != nil && rememberedScopes == nil {
+				if revokeErr := im.consentStore.Revoke(ctx, auth.Subject(), ar.ClientID); revokeErr != nil {
+					im.logger.WithError(revokeErr).Errorln("IdentifierIdentityManager: failed to revoke remembered consent")
+				}
+			}
 			return auth, ar.NewError(oidc.ErrorCodeOAuth2AccessDenied, "consent denied")
 		}
 
 		promptConsent = false
 		filteredApprovedScopes, allApprovedScopes := consent.Scopes(ar.Scopes)
+		for scope := range rememberedScopes {
+			// Approve the union of remembered and newly consented scopes.
+			filteredApprovedScopes[scope] = true
+			allApprovedScopes[scope] = true
+		}
+
+		if im.consentStore != nil && !clientDetails.Trusted {
+			if rememberErr := im.consentStore.Remember(ctx, auth.Subject(), ar.ClientID, filteredApprovedScopes); rememberErr != nil {
+				im.logger.WithError(rememberErr).Errorln("IdentifierIdentityManager: failed to remember consent")
+			}
+		}
 
 		// Filter claims request by approved scopes.
 		if ar.Claims != nil {
@@ -344,9 +445,12 @@ func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.Resp
 				query.Set("claims_scope", strings.Join(claimsScopes, " "))
 			}
 		}
-		if ar.Scopes != nil {
+		if consentScopes == nil {
+			consentScopes = ar.Scopes
+		}
+		if consentScopes != nil {
 			scopes := make([]string, 0)
-			for scope, ok := range ar.Scopes {
+			for scope, ok := range consentScopes {
 				if ok {
 					scopes = append(scopes, scope)
 				}
@@ -379,7 +483,7 @@ func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.Resp
 				break
 			}
 
-			if ok, _ := ar.Prompts[oidc.PromptConsent]; !ok && consent == nil {
+			if ok, _ := ar.Prompts[oidc.PromptConsent]; !ok && consent == nil && !remembered {
 				// Ensure that the prompt parameter contains consent unless
 				// other conditions for processing the request permitting offline
 				// access to the requested resources are in place; unless one or
@@ -403,6 +507,90 @@ func (im *IdentifierIdentityManager) Authorize(ctx context.Context, rw http.Resp
 	return auth, nil
 }
 
+// getPreApprovedScopes returns the approved scopes for the provided request
+// when no consent needs to be asked from the user. Trusted clients never need
+// consent, other clients only when consent was remembered. Nil is returned if
+// consent is required. The returned bool is true when remembered consent was
+// used.
+func (im *IdentifierIdentityManager) getPreApprovedScopes(ctx context.Context, clientDetails *clients.Details, ar *payload.AuthenticationRequest, sub string) (map[string]bool, bool) {
+	if ok, _ := ar.Prompts[oidc.PromptConsent]; ok {
+		return nil, false
+	}
+
+	if clientDetails.Trusted {
+		// Trusted clients get all requested scopes, which are limited to the
+		// supported scopes when authorized.
+		return ar.Scopes, false
+	}
+
+	if rememberedScopes := im.getRememberedConsent(ctx, ar, sub); rememberedScopes != nil {
+		return rememberedScopes, true
+	}
+
+	return nil, false
+}
+
+// getRememberedConsent returns the approved scopes for the provided request
+// if all of its requested scopes have been consented to before by the user
+// with the provided sub. Nil is returned if consent is required.
+func (im *IdentifierIdentityManager) getRememberedConsent(ctx context.Context, ar *payload.AuthenticationRequest, sub string) map[string]bool {
+	approvedScopes, missingScopes := im.getRememberedConsentScopes(ctx, ar, sub)
+	if len(missingScopes) > 0 {
+		// Requested scopes were not consented before.
+		return nil
+	}
+
+	return approvedScopes
+}
+
+// getRememberedConsentScopes splits the requested scopes of the provided
+// request into the scopes which have been consented to before by the user
+// with the provided sub and the scopes which have not. Both are nil if there
+// is no remembered consent.
+func (im *IdentifierIdentityManager) getRememberedConsentScopes(ctx context.Context, ar *payload.AuthenticationRequest, sub string) (map[string]bool, map[string]bool) {
+	if im.consentStore == nil || sub == "" {
+		return nil, nil
+	}
+	if ok, _ := ar.Prompts[oidc.PromptConsent]; ok {
+		// Always ask again when consent is requested explicitly.
+		return nil, nil
+	}
+
+	rememberedScopes, err := im.consentStore.Lookup(ctx, sub, ar.ClientID)
+	if err != nil {
+		im.logger.WithError(err).Errorln("IdentifierIdentityManager: failed to lookup remembered consent")
+		return nil, nil
+	}
+	if rememberedScopes == nil {
+		return nil, nil
+	}
+
+	approvedScopes := make(map[string]bool)
+	missingScopes := make(map[string]bool)
+	for scope, requested := range ar.Scopes {
+		if !requested {
+			continue
+		}
+		if ok, _ := rememberedScopes[scope]; !ok {
+			missingScopes[scope] = true
+			continue
+		}
+		approvedScopes[scope] = true
+	}
+
+	return approvedScopes, missingScopes
+}
+
+// RevokeConsent removes the remembered consent of the user with the provided
+// sub for the client with the provided clientID.
+func (im *IdentifierIdentityManager) RevokeConsent(ctx context.Context, sub string, clientID string) error {
+	if im.consentStore == nil {
+		return nil
+	}
+
+	return im.consentStore.Revoke(ctx, sub, clientID)
+}
+
 // EndSession implements the identity.Manager interface.
 func (im *IdentifierIdentityManager) EndSession(ctx context.Context, rw http.ResponseWriter, req *http.Request, esr *payload.EndSessionRequest) error {
 	var err error
@@ -427,7 +615,7 @@ func (im *IdentifierIdentityManager) EndSession(ctx context.Context, rw http.Res
 	}
 
 	var user *identifierUser
-	u, _ := im.identifier.GetUserFromLogonCookie(ctx, req, 0, false)
+	u, _ := im.identifier.GetUserFromLogonCookie(ctx, rw, req, 0, false)
 	if u != nil {
 		user = asIdentifierUser(u)
 		// More checks.
@@ -442,6 +630,10 @@ func (im *IdentifierIdentityManager) EndSession(ctx context.Context, rw http.Res
 			// Directly end identifier session when a trusted client requests
 			// and honor redirect wish if any.
 			var uri *url.URL
+			err = im.identifier.UnsetPersistentCookie(ctx, rw, req)
+			if err != nil {
+				im.logger.WithError(err).Warnln("IdentifierIdentityManager: failed to unset persistent session")
+			}
 			uri, err = im.identifier.EndSession(ctx, u, rw, esr.PostLogoutRedirectURI, esr.State)
 			if err != nil {
 				// Do nothing if err.
@@ -469,6 +661,19 @@ func (im *IdentifierIdentityManager) EndSession(ctx context.Context, rw http.Res
 		if esrClaims != nil {
 			query.Add("client_id", esrClaims.Audience)
 		}
+		if esr.IDTokenHint != nil {
+			// Pass on the client name and a validated continue URI, so the
+			// signed-out page can refer to the client and offer a way back.
+			hintClaims := esr.IDTokenHint.Claims.(*konnectoidc.IDTokenClaims)
+			if registration, ok := im.clients.Get(ctx, hintClaims.Audience); ok {
+				if registration.Name != "" {
+					query.Add("client_name", registration.Name)
+				}
+				if esr.PostLogoutRedirectURI != nil && registration.MatchPostLogoutRedirectURI(esr.PostLogoutRedirectURI) {
+					query.Add("continue_uri", esr.MakeRedirectEndSessionRequestURL().String())
+				}
+			}
+		}
 
 		u.RawQuery = query.Encode()
 		return identity.NewRedirectError(oidc.ErrorCodeOIDCInteractionRequired, u)
@@ -506,6 +711,16 @@ func (im *IdentifierIdentityManager) Fetch(ctx context.Context, userID string, s
 		return nil, false, fmt.Errorf("IdentifierIdentityManager: no user")
 	}
 
+	// Fetching with an access token, for example for userinfo, happens on
+	// behalf of the user, so it counts as activity of the logon session the
+	// token was issued in.
+	if claims, ok := konnect.FromClaimsContext(ctx); ok {
+		if accessTokenClaims, ok := claims.(*konnect.AccessTokenClaims); ok {
+			logonSessionID, _ := accessTokenClaims.IdentityClaims[identifier.LogonSessionIDClaim].(string)
+			im.identifier.TouchSession(u.Subject(), logonSessionID)
+		}
+	}
+
 	user := asIdentifierUser(u)
 	authorizedScopes, _ := identity.AuthorizeScopes(im, user, scopes)
 	claims := identity.GetUserClaimsForScopes(user, authorizedScopes, requestedClaimsMaps)
@@ -521,13 +736,30 @@ func (im *IdentifierIdentityManager) Name() string {
 	return im.identifier.Name()
 }
 
-// ScopesSupported implements the identity.Manager interface.
+// ScopesSupported implements the identity.Manager interface. The result is
+// used both for discovery and to authorize scopes, so both always match. When
+// allowed scopes are configured, the scopes of the backend are limited to
+// those.
 func (im *IdentifierIdentityManager) ScopesSupported(scopes map[string]bool) []string {
-	scopesSupported := make([]string, len(im.scopesSupported))
-	copy(scopesSupported, im.scopesSupported)
+	if im.scopesAllowed == nil {
+		scopesSupported := make([]string, len(im.scopesSupported))
+		copy(scopesSupported, im.scopesSupported)
 
+		return append(scopesSupported, im.identifier.ScopesSupported()...)
+	}
+
+	available := map[string]bool{
+		oidc.ScopeOpenID:        true,
+		oidc.ScopeOfflineAccess: true,
+	}
 	for _, scope := range im.identifier.ScopesSupported() {
-		scopesSupported = append(scopesSupported, scope)
+		available[scope] = true
+	}
+	scopesSupported := make([]string, 0, len(im.scopesSupported))
+	for _, scope := range im.scopesSupported {
+		if available[scope] {
+			scopesSupported = append(scopesSupported, scope)
+		}
 	}
 
 	return scopesSupported
@@ -538,6 +770,20 @@ func (im *IdentifierIdentityManager) ClaimsSupported(claims []string) []string {
 	return im.claimsSupported
 }
 
+// PromptsSupported implements the identity.ManagerWithPrompts interface.
+func (im *IdentifierIdentityManager) PromptsSupported() []string {
+	if im.signUpFormURI == "" {
+		return nil
+	}
+
+	return []string{konnectoidc.PromptCreate}
+}
+
+// ClaimScopes implements the identity.ManagerWithClaimScopes interface.
+func (im *IdentifierIdentityManager) ClaimScopes() map[string]string {
+	return im.identifier.ClaimScopes()
+}
+
 // AddRoutes implements the identity.Manager interface.
 func (im *IdentifierIdentityManager) AddRoutes(ctx context.Context, router *mux.Router) {
 	im.identifier.AddRoutes(ctx, router)
@@ -0,0 +1,329 @@
+package managers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identifier"
+	"github.com/libregraph/lico/identifier/backends"
+	"github.com/libregraph/lico/identifier/meta/scopes"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+type testBackend struct{}
+
+func (b *testBackend) RunWithContext(ctx context.Context) error {
+	return nil
+}
+
+func (b *testBackend) Logon(ctx context.Context, audience string, username string, password string) (bool, *string, *string, backends.UserFromBackend, error) {
+	return false, nil, nil, nil, nil
+}
+
+func (b *testBackend) GetUser(ctx context.Context, userID string, sessionRef *string, requestedScopes map[string]bool) (backends.UserFromBackend, error) {
+	return nil, nil
+}
+
+func (b *testBackend) ResolveUserByUsername(ctx context.Context, username string) (backends.UserFromBackend, error) {
+	return nil, nil
+}
+
+func (b *testBackend) RefreshSession(ctx context.Context, userID string, sessionRef *string, claims map[string]interface{}) error {
+	return nil
+}
+
+func (b *testBackend) DestroySession(ctx context.Context, sessionRef *string) error {
+	return nil
+}
+
+func (b *testBackend) UserClaims(userID string, authorizedScopes map[string]bool) map[string]interface{} {
+	return nil
+}
+
+func (b *testBackend) ScopesSupported() []string {
+	return nil
+}
+
+func (b *testBackend) ScopesMeta() *scopes.Scopes {
+	return &scopes.Scopes{}
+}
+
+func (b *testBackend) Name() string {
+	return "test"
+}
+
+func newTestIdentifierIdentityManager(t *testing.T, uiLocales []string) *IdentifierIdentityManager {
+	return newTestIdentifierIdentityManagerWithConfig(t, &identifier.Config{
+		UILocales: uiLocales,
+	})
+}
+
+func newTestIdentifierIdentityManagerWithConfig(t *testing.T, c *identifier.Config) *IdentifierIdentityManager {
+	logger := logrus.New()
+
+	c.Config = &config.Config{
+		Logger: logger,
+	}
+	c.BaseURI = &url.URL{Scheme: "https", Host: "localhost"}
+	c.LogonCookieName = "__Secure-KKT"
+	c.WebAppDisabled = true
+	c.Backend = &testBackend{}
+
+	i, err := identifier.NewIdentifier(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.SetKey(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewIdentifierIdentityManager(&identity.Config{
+		SignInFormURI: &url.URL{Scheme: "https", Host: "localhost", Path: "/signin/v1/identifier"},
+		SignedOutURI:  &url.URL{Scheme: "https", Host: "localhost", Path: "/signin/v1/goodbye"},
+
+		Logger: logger,
+	}, i)
+}
+
+func newTestAuthenticationRequest(t *testing.T, params url.Values) (*http.Request, *payload.AuthenticationRequest) {
+	query := url.Values{}
+	query.Set("client_id", "client")
+	query.Set("scope", "openid")
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", "https://client.example.com/cb")
+	for key, values := range params {
+		query[key] = values
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://localhost/konnect/v1/authorize?"+query.Encode(), nil)
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := payload.DecodeAuthenticationRequest(req, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req, ar
+}
+
+func authenticateSignInRedirect(t *testing.T, im *IdentifierIdentityManager, params url.Values) *url.URL {
+	req, ar := newTestAuthenticationRequest(t, params)
+
+	rr := httptest.NewRecorder()
+	_, err := im.Authenticate(req.Context(), rr, req, ar, nil)
+	if _, ok := err.(*identity.IsHandledError); !ok {
+		t.Fatalf("expected sign-in redirect, got %v", err)
+	}
+
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location
+}
+
+func TestAuthenticateForwardsUILocale(t *testing.T) {
+	tests := []struct {
+		available []string
+		uiLocales string
+		expected  string
+	}{
+		{nil, "de", "de"},
+		{nil, "de-AT en", "de"},
+		{nil, "pt-BR zh-cn", "zh-CN"},
+		{nil, "pt-BR", "en"},
+		{[]string{"fr", "nl"}, "de nl", "nl"},
+		{[]string{"fr", "nl"}, "de", "fr"},
+		{nil, "", ""},
+	}
+
+	for _, test := range tests {
+		im := newTestIdentifierIdentityManager(t, test.available)
+
+		params := url.Values{}
+		if test.uiLocales != "" {
+			params.Set("ui_locales", test.uiLocales)
+		}
+		location := authenticateSignInRedirect(t, im, params)
+
+		if location.Path != "/signin/v1/identifier" {
+			t.Errorf("unexpected sign-in redirect: %v", location)
+		}
+		if v := location.Query().Get("ui_locales"); v != test.expected {
+			t.Errorf("wrong ui_locales for %q with %v: got %q want %q", test.uiLocales, test.available, v, test.expected)
+		}
+	}
+}
+
+func TestAuthenticateForwardsLoginHint(t *testing.T) {
+	tests := []struct {
+		loginHint string
+		expected  string
+	}{
+		{"user1@example.com", "user1@example.com"},
+		{"  user1  ", "user1"},
+		{strings.Repeat("a", payload.MaxLoginHintLength+1), ""},
+		{"user1\nuser2", ""},
+		{"", ""},
+	}
+
+	im := newTestIdentifierIdentityManager(t, nil)
+	for _, test := range tests {
+		params := url.Values{}
+		if test.loginHint != "" {
+			params.Set("login_hint", test.loginHint)
+		}
+		location := authenticateSignInRedirect(t, im, params)
+
+		if v := location.Query().Get("login_hint"); v != test.expected {
+			t.Errorf("wrong login_hint for %q: got %q want %q", test.loginHint, v, test.expected)
+		}
+	}
+}
+
+func TestAuthenticateForwardsMaxAge(t *testing.T) {
+	im := newTestIdentifierIdentityManager(t, nil)
+
+	params := url.Values{}
+	params.Set("max_age", "3600")
+	req, ar := newTestAuthenticationRequest(t, params)
+	// Step-up tightens the max age of the request.
+	ar.MaxAge = 5 * time.Minute
+
+	rr := httptest.NewRecorder()
+	if _, err := im.Authenticate(req.Context(), rr, req, ar, nil); err == nil {
+		t.Fatalf("expected sign-in redirect")
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := location.Query().Get("max_age"); v != "300" {
+		t.Errorf("wrong max_age forwarded to sign-in: got %q want %q", v, "300")
+	}
+}
+
+func TestAuthenticatePromptCreate(t *testing.T) {
+	im := newTestIdentifierIdentityManager(t, nil)
+
+	params := url.Values{}
+	params.Set("prompt", "create")
+
+	// Without sign-up form, prompt=create is an error for the client.
+	req, ar := newTestAuthenticationRequest(t, params)
+	rr := httptest.NewRecorder()
+	_, err := im.Authenticate(req.Context(), rr, req, ar, nil)
+	if authErr, ok := err.(*payload.AuthenticationError); !ok || authErr.ErrorID != oidc.ErrorCodeOAuth2InvalidRequest {
+		t.Errorf("expected invalid_request error without sign-up form, got %v", err)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Errorf("unexpected redirect without sign-up form: %v", location)
+	}
+	if prompts := im.PromptsSupported(); len(prompts) != 0 {
+		t.Errorf("unexpected supported prompts without sign-up form: %v", prompts)
+	}
+
+	// With sign-up form, redirect there instead of the sign-in form.
+	im.signUpFormURI = "https://localhost/signup"
+	if prompts := im.PromptsSupported(); !reflect.DeepEqual(prompts, []string{konnectoidc.PromptCreate}) {
+		t.Errorf("expected create prompt to be supported with sign-up form, got %v", prompts)
+	}
+	location := authenticateSignInRedirect(t, im, params)
+	if location.Host != "localhost" || location.Path != "/signup" {
+		t.Errorf("unexpected sign-up redirect: %v", location)
+	}
+	if v := location.Query().Get("client_id"); v != "client" {
+		t.Errorf("sign-up redirect is missing authorization request parameters: %v", location)
+	}
+	if v, ok := location.Query()["prompt"]; ok {
+		t.Errorf("sign-up redirect still contains prompt=create: %v", v)
+	}
+}
+
+func TestEndSessionSignedOutLanding(t *testing.T) {
+	im := newTestIdentifierIdentityManager(t, nil)
+	registry, err := clients.NewRegistry(context.Background(), nil, "", false, 0, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	im.clients = registry
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:                     "client",
+		Name:                   "Example App",
+		RedirectURIs:           []string{"https://client.example.com/cb"},
+		PostLogoutRedirectURIs: []string{"https://client.example.com/signed-out"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("secret")
+	idTokenHint, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &konnectoidc.IDTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience: "client",
+			Subject:  "user1",
+		},
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		postLogoutRedirectURI string
+		expected              string
+	}{
+		{"https://client.example.com/signed-out", "https://client.example.com/signed-out?state=xyz"},
+		{"https://evil.example.com/signed-out", ""},
+		{"https://client.example.com/cb", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("id_token_hint", idTokenHint)
+		values.Set("state", "xyz")
+		if test.postLogoutRedirectURI != "" {
+			values.Set("post_logout_redirect_uri", test.postLogoutRedirectURI)
+		}
+		esr, err := payload.NewEndSessionRequest(values, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = esr.Validate(func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "https://localhost/konnect/v1/endsession", nil)
+		rr := httptest.NewRecorder()
+		err = im.EndSession(req.Context(), rr, req, esr)
+		redirectErr, ok := err.(*identity.RedirectError)
+		if !ok {
+			t.Fatalf("expected redirect to signed-out page, got %v", err)
+		}
+		location := redirectErr.RedirectURI()
+		if location.Path != "/signin/v1/goodbye" {
+			t.Errorf("unexpected signed-out redirect: %v", location)
+		}
+		if v := location.Query().Get("client_name"); v != "Example App" {
+			t.Errorf("wrong client_name: got %q", v)
+		}
+		if v := location.Query().Get("continue_uri"); v != test.expected {
+			t.Errorf("wrong continue_uri for %q: got %q want %q", test.postLogoutRedirectURI, v, test.expected)
+		}
+	}
+}
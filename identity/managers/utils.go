@@ -20,6 +20,7 @@ package managers
 import (
 	"encoding/base64"
 
+	"github.com/libregraph/oidc-go"
 	"golang.org/x/crypto/blake2b"
 
 	konnectoidc "github.com/libregraph/lico/oidc"
@@ -50,3 +51,26 @@ func getPublicSubject(sub []byte, extra []byte) (string, error) {
 	s := base64.RawURLEncoding.EncodeToString(hasher.Sum(nil))
 	return s[:16] + "@" + s[16:], nil
 }
+
+// getTrustedApprovedScopes returns the scopes of the provided requested scopes
+// which are either supported or explicitly allowed by the provided trusted
+// scopes. The openid scope is always approved if requested.
+func getTrustedApprovedScopes(requestedScopes map[string]bool, trustedScopes []string, supportedScopes []string) map[string]bool {
+	allowed := make(map[string]bool)
+	for _, scope := range supportedScopes {
+		allowed[scope] = true
+	}
+	for _, scope := range trustedScopes {
+		allowed[scope] = true
+	}
+	allowed[oidc.ScopeOpenID] = true
+
+	approvedScopes := make(map[string]bool)
+	for scope, requested := range requestedScopes {
+		if requested && allowed[scope] {
+			approvedScopes[scope] = true
+		}
+	}
+
+	return approvedScopes
+}
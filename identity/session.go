@@ -0,0 +1,52 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package identity
+
+import (
+	"context"
+)
+
+// SessionDestroyReason describes why a session is destroyed. Its values are
+// written to the audit log and must remain stable.
+type SessionDestroyReason string
+
+// Session destroy reasons.
+const (
+	SessionDestroyReasonLogoff      SessionDestroyReason = "logoff"
+	SessionDestroyReasonEndSession  SessionDestroyReason = "end session"
+	SessionDestroyReasonSAML2SLO    SessionDestroyReason = "saml2 slo"
+	SessionDestroyReasonAdmin       SessionDestroyReason = "admin"
+	SessionDestroyReasonEvicted     SessionDestroyReason = "evicted"
+	SessionDestroyReasonIdleTimeout SessionDestroyReason = "idle timeout"
+)
+
+// sessionDestroyReasonKey is the key for SessionDestroyReason in Contexts.
+var sessionDestroyReasonKey key = 1
+
+// NewSessionDestroyReasonContext returns a new Context that carries value
+// reason.
+func NewSessionDestroyReasonContext(ctx context.Context, reason SessionDestroyReason) context.Context {
+	return context.WithValue(ctx, sessionDestroyReasonKey, reason)
+}
+
+// SessionDestroyReasonFromContext returns the SessionDestroyReason value
+// stored in ctx, if any.
+func SessionDestroyReasonFromContext(ctx context.Context) (SessionDestroyReason, bool) {
+	reason, ok := ctx.Value(sessionDestroyReasonKey).(SessionDestroyReason)
+	return reason, ok
+}
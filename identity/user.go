@@ -19,6 +19,8 @@ package identity
 
 import (
 	"github.com/golang-jwt/jwt/v4"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
 // User defines a most simple user with an id defined as subject.
@@ -33,6 +35,19 @@ type UserWithEmail interface {
 	EmailVerified() bool
 }
 
+// UserWithPhone is a User with a phone number.
+type UserWithPhone interface {
+	User
+	PhoneNumber() string
+	PhoneNumberVerified() bool
+}
+
+// UserWithAddress is a User with a postal address.
+type UserWithAddress interface {
+	User
+	Address() *konnectoidc.Address
+}
+
 // UserWithProfile is a User with Name.
 type UserWithProfile interface {
 	User
@@ -41,6 +56,13 @@ type UserWithProfile interface {
 	GivenName() string
 }
 
+// UserWithLocale is a User with locale and time zone preferences.
+type UserWithLocale interface {
+	User
+	Locale() string
+	Zoneinfo() string
+}
+
 // UserWithID is a User with a locally unique numeric id.
 type UserWithID interface {
 	User
@@ -59,6 +81,13 @@ type UserWithUsername interface {
 	Username() string
 }
 
+// UserWithPreferredUsername is a User with a preferred username which takes
+// precedence over its username for the preferred_username claim.
+type UserWithPreferredUsername interface {
+	User
+	PreferredUsername() string
+}
+
 // UserWithClaims is a User with jwt claims.
 type UserWithClaims interface {
 	User
@@ -86,6 +86,25 @@ func GetUserClaimsForScopes(user User, scopes map[string]bool, requestedClaimsMa
 			}
 		}
 	}
+	if authorizedScope, _ := scopes[konnectoidc.ScopePhone]; authorizedScope {
+		if userWithPhone, ok := user.(UserWithPhone); ok {
+			if phoneNumber := userWithPhone.PhoneNumber(); phoneNumber != "" {
+				claims[konnectoidc.ScopePhone] = &konnectoidc.PhoneClaims{
+					PhoneNumber:         phoneNumber,
+					PhoneNumberVerified: userWithPhone.PhoneNumberVerified(),
+				}
+			}
+		}
+	}
+	if authorizedScope, _ := scopes[konnectoidc.ScopeAddress]; authorizedScope {
+		if userWithAddress, ok := user.(UserWithAddress); ok {
+			if address := userWithAddress.Address(); !address.IsEmpty() {
+				claims[konnectoidc.ScopeAddress] = &konnectoidc.AddressClaims{
+					Address: address,
+				}
+			}
+		}
+	}
 	if authorizedScope, _ := scopes[oidc.ScopeProfile]; authorizedScope {
 		var profileClaims *konnectoidc.ProfileClaims
 		if userWithProfile, ok := user.(UserWithProfile); ok {
@@ -95,13 +114,31 @@ func GetUserClaimsForScopes(user User, scopes map[string]bool, requestedClaimsMa
 				GivenName:  userWithProfile.GivenName(),
 			}
 		}
-		if userWithUsername, ok := user.(UserWithUsername); ok {
+		if userWithLocale, ok := user.(UserWithLocale); ok {
+			locale := userWithLocale.Locale()
+			zoneinfo := userWithLocale.Zoneinfo()
+			if locale != "" || zoneinfo != "" {
+				if profileClaims == nil {
+					profileClaims = &konnectoidc.ProfileClaims{}
+				}
+				profileClaims.Locale = locale
+				profileClaims.Zoneinfo = zoneinfo
+			}
+		}
+		var preferredUsername string
+		if userWithPreferredUsername, ok := user.(UserWithPreferredUsername); ok {
+			preferredUsername = userWithPreferredUsername.PreferredUsername()
+		}
+		if userWithUsername, ok := user.(UserWithUsername); ok && preferredUsername == "" {
+			preferredUsername = userWithUsername.Username()
+		}
+		if preferredUsername != "" {
 			if profileClaims == nil {
 				profileClaims = &konnectoidc.ProfileClaims{
-					PreferredUsername: userWithUsername.Username(),
+					PreferredUsername: preferredUsername,
 				}
 			} else {
-				profileClaims.PreferredUsername = userWithUsername.Username()
+				profileClaims.PreferredUsername = preferredUsername
 			}
 		}
 		if profileClaims != nil {
@@ -150,6 +187,19 @@ func GetUserClaimsForScopes(user User, scopes map[string]bool, requestedClaimsMa
 								scopeClaims.Name = userWithProfile.GivenName()
 							}
 						}
+						if userWithLocale, ok := user.(UserWithLocale); ok {
+							scopeClaims := konnectoidc.NewProfileClaims(claims[scope])
+							if scopeClaims == nil {
+								scopeClaims = &konnectoidc.ProfileClaims{}
+								claims[scope] = scopeClaims
+							}
+							switch requestedClaim {
+							case oidc.LocaleClaim:
+								scopeClaims.Locale = userWithLocale.Locale()
+							case oidc.ZoneinfoClaim:
+								scopeClaims.Zoneinfo = userWithLocale.Zoneinfo()
+							}
+						}
 					}
 				}
 			} else {
@@ -0,0 +1,180 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/libregraph/oidc-go"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
+)
+
+type testUserWithPhone struct {
+	sub         string
+	phoneNumber string
+}
+
+func (u *testUserWithPhone) Subject() string {
+	return u.sub
+}
+
+func (u *testUserWithPhone) PhoneNumber() string {
+	return u.phoneNumber
+}
+
+func (u *testUserWithPhone) PhoneNumberVerified() bool {
+	return true
+}
+
+func TestGetUserClaimsForScopesPhone(t *testing.T) {
+	user := &testUserWithPhone{"user", "+49 30 1234567"}
+
+	claims := GetUserClaimsForScopes(user, map[string]bool{konnectoidc.ScopePhone: true}, nil)
+	phoneClaims := konnectoidc.NewPhoneClaims(claims[konnectoidc.ScopePhone])
+	if phoneClaims == nil {
+		t.Fatal("phone claims missing with authorized phone scope")
+	}
+	if phoneClaims.PhoneNumber != user.phoneNumber {
+		t.Errorf("phone_number was incorrect, got %s, want %s", phoneClaims.PhoneNumber, user.phoneNumber)
+	}
+	if !phoneClaims.PhoneNumberVerified {
+		t.Errorf("phone_number_verified was incorrect, got false, want true")
+	}
+
+	claims = GetUserClaimsForScopes(user, map[string]bool{}, nil)
+	if _, ok := claims[konnectoidc.ScopePhone]; ok {
+		t.Error("phone claims must not be set without authorized phone scope")
+	}
+
+	claims = GetUserClaimsForScopes(&testUserWithPhone{sub: "user"}, map[string]bool{konnectoidc.ScopePhone: true}, nil)
+	if _, ok := claims[konnectoidc.ScopePhone]; ok {
+		t.Error("phone claims must not be set for users without phone number")
+	}
+}
+
+type testUserWithAddress struct {
+	sub     string
+	address *konnectoidc.Address
+}
+
+func (u *testUserWithAddress) Subject() string {
+	return u.sub
+}
+
+func (u *testUserWithAddress) Address() *konnectoidc.Address {
+	return u.address
+}
+
+func TestGetUserClaimsForScopesAddress(t *testing.T) {
+	user := &testUserWithAddress{"user", &konnectoidc.Address{
+		Locality: "Berlin",
+		Country:  "DE",
+	}}
+
+	claims := GetUserClaimsForScopes(user, map[string]bool{konnectoidc.ScopeAddress: true}, nil)
+	addressClaims := konnectoidc.NewAddressClaims(claims[konnectoidc.ScopeAddress])
+	if addressClaims == nil || addressClaims.Address == nil {
+		t.Fatal("address claims missing with authorized address scope")
+	}
+	if *addressClaims.Address != *user.address {
+		t.Errorf("address was incorrect, got %+v, want %+v", *addressClaims.Address, *user.address)
+	}
+
+	claims = GetUserClaimsForScopes(user, map[string]bool{}, nil)
+	if _, ok := claims[konnectoidc.ScopeAddress]; ok {
+		t.Error("address claims must not be set without authorized address scope")
+	}
+
+	claims = GetUserClaimsForScopes(&testUserWithAddress{"user", &konnectoidc.Address{}}, map[string]bool{konnectoidc.ScopeAddress: true}, nil)
+	if _, ok := claims[konnectoidc.ScopeAddress]; ok {
+		t.Error("address claims must not be set for users without address data")
+	}
+}
+
+type testUserWithUsername struct {
+	sub               string
+	username          string
+	preferredUsername string
+}
+
+func (u *testUserWithUsername) Subject() string {
+	return u.sub
+}
+
+func (u *testUserWithUsername) Username() string {
+	return u.username
+}
+
+func (u *testUserWithUsername) PreferredUsername() string {
+	return u.preferredUsername
+}
+
+func TestGetUserClaimsForScopesPreferredUsername(t *testing.T) {
+	user := &testUserWithUsername{sub: "b9a1c3e0-opaque", username: "jdoe"}
+
+	claims := GetUserClaimsForScopes(user, map[string]bool{oidc.ScopeProfile: true}, nil)
+	profileClaims := konnectoidc.NewProfileClaims(claims[oidc.ScopeProfile])
+	if profileClaims == nil {
+		t.Fatal("profile claims missing with authorized profile scope")
+	}
+	if profileClaims.PreferredUsername != user.username {
+		t.Errorf("preferred_username was incorrect, got %s, want %s", profileClaims.PreferredUsername, user.username)
+	}
+	if profileClaims.PreferredUsername == user.Subject() {
+		t.Errorf("preferred_username must differ from sub")
+	}
+
+	user.preferredUsername = "john.doe"
+	claims = GetUserClaimsForScopes(user, map[string]bool{oidc.ScopeProfile: true}, nil)
+	if profileClaims = konnectoidc.NewProfileClaims(claims[oidc.ScopeProfile]); profileClaims == nil || profileClaims.PreferredUsername != user.preferredUsername {
+		t.Errorf("preferred_username was incorrect, got %v, want %s", profileClaims, user.preferredUsername)
+	}
+
+	claims = GetUserClaimsForScopes(&testUserWithUsername{sub: "user"}, map[string]bool{oidc.ScopeProfile: true}, nil)
+	if _, ok := claims[oidc.ScopeProfile]; ok {
+		t.Error("profile claims must not be set for users without username")
+	}
+}
+
+type testUserWithLocale struct {
+	sub      string
+	locale   string
+	zoneinfo string
+}
+
+func (u *testUserWithLocale) Subject() string {
+	return u.sub
+}
+
+func (u *testUserWithLocale) Locale() string {
+	return u.locale
+}
+
+func (u *testUserWithLocale) Zoneinfo() string {
+	return u.zoneinfo
+}
+
+func TestGetUserClaimsForScopesLocale(t *testing.T) {
+	user := &testUserWithLocale{"user", "de-DE", "Europe/Berlin"}
+
+	claims := GetUserClaimsForScopes(user, map[string]bool{oidc.ScopeProfile: true}, nil)
+	profileClaims := konnectoidc.NewProfileClaims(claims[oidc.ScopeProfile])
+	if profileClaims == nil {
+		t.Fatal("profile claims missing with authorized profile scope")
+	}
+	if profileClaims.Locale != user.locale {
+		t.Errorf("locale was incorrect, got %s, want %s", profileClaims.Locale, user.locale)
+	}
+	if profileClaims.Zoneinfo != user.zoneinfo {
+		t.Errorf("zoneinfo was incorrect, got %s, want %s", profileClaims.Zoneinfo, user.zoneinfo)
+	}
+
+	claims = GetUserClaimsForScopes(user, map[string]bool{}, nil)
+	if _, ok := claims[oidc.ScopeProfile]; ok {
+		t.Error("profile claims must not be set without authorized profile scope")
+	}
+
+	claims = GetUserClaimsForScopes(&testUserWithLocale{sub: "user"}, map[string]bool{oidc.ScopeProfile: true}, nil)
+	if _, ok := claims[oidc.ScopeProfile]; ok {
+		t.Error("profile claims must not be set for users without locale and zoneinfo")
+	}
+}
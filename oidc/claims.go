@@ -19,6 +19,28 @@ package oidc
 
 import (
 	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/libregraph/lico/utils"
+)
+
+// Additional scopes and claims as defined by OIDC which are not provided by
+// the oidc-go package.
+const (
+	ScopePhone   = "phone"
+	ScopeAddress = "address"
+
+	PhoneNumberClaim         = "phone_number"
+	PhoneNumberVerifiedClaim = "phone_number_verified"
+	AddressClaim             = "address"
+)
+
+// Additional non-standard scopes and claims which are commonly used.
+const (
+	ScopeGroups = "groups"
+	ScopeRoles  = "roles"
+
+	GroupsClaim = "groups"
+	RolesClaim  = "roles"
 )
 
 // IDTokenClaims define the claims found in OIDC ID Tokens.
@@ -27,18 +49,21 @@ type IDTokenClaims struct {
 
 	Nonce           string `json:"nonce,omitempty"`
 	AuthTime        int64  `json:"auth_time,omitempty"`
+	ACR             string `json:"acr,omitempty"`
 	AccessTokenHash string `json:"at_hash,omitempty"`
 	CodeHash        string `json:"c_hash,omitempty"`
 
 	*ProfileClaims
 	*EmailClaims
+	*PhoneClaims
+	*AddressClaims
 
 	*SessionClaims
 }
 
 // Valid implements the jwt.Claims interface.
 func (c IDTokenClaims) Valid() (err error) {
-	return c.StandardClaims.Valid()
+	return utils.ValidateTimeClaims(&c.StandardClaims)
 }
 
 // ProfileClaims define the claims for the OIDC profile scope.
@@ -48,6 +73,8 @@ type ProfileClaims struct {
 	FamilyName        string `json:"family_name,omitempty"`
 	GivenName         string `json:"given_name,omitempty"`
 	PreferredUsername string `json:"preferred_username,omitempty"`
+	Locale            string `json:"locale,omitempty"`
+	Zoneinfo          string `json:"zoneinfo,omitempty"`
 }
 
 // NewProfileClaims return a new ProfileClaims set from the provided
@@ -87,6 +114,65 @@ func (c EmailClaims) Valid() error {
 	return nil
 }
 
+// PhoneClaims define the claims for the OIDC phone scope.
+// https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+type PhoneClaims struct {
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified"`
+}
+
+// NewPhoneClaims return a new PhoneClaims set from the provided
+// jwt.Claims or nil.
+func NewPhoneClaims(claims jwt.Claims) *PhoneClaims {
+	if claims == nil {
+		return nil
+	}
+
+	return claims.(*PhoneClaims)
+}
+
+// Valid implements the jwt.Claims interface.
+func (c PhoneClaims) Valid() error {
+	return nil
+}
+
+// AddressClaims define the claims for the OIDC address scope.
+// https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+type AddressClaims struct {
+	Address *Address `json:"address,omitempty"`
+}
+
+// NewAddressClaims return a new AddressClaims set from the provided
+// jwt.Claims or nil.
+func NewAddressClaims(claims jwt.Claims) *AddressClaims {
+	if claims == nil {
+		return nil
+	}
+
+	return claims.(*AddressClaims)
+}
+
+// Valid implements the jwt.Claims interface.
+func (c AddressClaims) Valid() error {
+	return nil
+}
+
+// Address defines the structured OIDC address claim value as specified at
+// https://openid.net/specs/openid-connect-core-1_0.html#AddressClaim
+type Address struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// IsEmpty returns true if none of the accociated address fields are set.
+func (a *Address) IsEmpty() bool {
+	return a == nil || *a == Address{}
+}
+
 // UserInfoClaims define the claims defined by the OIDC UserInfo
 // endpoint.
 type UserInfoClaims struct {
@@ -18,19 +18,73 @@
 package code
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/oidc/payload"
 )
 
+// Errors returned by code managers when a code cannot be redeemed.
+var (
+	ErrNotFound = errors.New("code not found")
+	ErrExpired  = errors.New("code expired")
+	ErrReplayed = errors.New("code already redeemed")
+)
+
 // Record bundles the data storedi in a code manager.
 type Record struct {
 	AuthenticationRequest *payload.AuthenticationRequest
 	Auth                  identity.AuthRecord
 	Session               *payload.Session
+
+	mutex    sync.Mutex
+	redeemed bool
+	replayed bool
+	tokens   []string
+}
+
+// Redeem marks the associated record as redeemed. It returns false if the
+// record has been redeemed before, which means its code was replayed.
+func (r *Record) Redeem() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.redeemed {
+		r.replayed = true
+		return false
+	}
+	r.redeemed = true
+
+	return true
+}
+
+// Issued adds the provided tokens to the tokens which have been issued in
+// exchange for the associated record. It returns false if the code of the
+// record was replayed in the meantime.
+func (r *Record) Issued(tokens ...string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tokens = append(r.tokens, tokens...)
+
+	return !r.replayed
+}
+
+// IssuedTokens returns all tokens which have been issued in exchange for the
+// associated record.
+func (r *Record) IssuedTokens() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string{}, r.tokens...)
 }
 
 // Manager is a interface defining a code manager.
 type Manager interface {
 	Create(record *Record) (string, error)
-	Pop(code string) (*Record, bool)
+	// Pop redeems the provided code and returns its record. Codes which are
+	// redeemed are kept until they expire, so that a replayed code returns
+	// its record together with ErrReplayed.
+	Pop(code string) (*Record, error)
 }
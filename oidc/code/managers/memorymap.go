@@ -28,7 +28,9 @@ import (
 )
 
 const (
-	codeValidDuration = 2 * time.Minute
+	// DefaultCodeDuration is the default lifetime of codes when no duration
+	// is provided.
+	DefaultCodeDuration = 2 * time.Minute
 )
 
 // Manager provides the api and state for OIDC code generation and token
@@ -46,10 +48,15 @@ type codeRequestRecord struct {
 	when time.Time
 }
 
-// NewMemoryMapManager creates a new CodeManager.
-func NewMemoryMapManager(ctx context.Context) code.Manager {
+// NewMemoryMapManager creates a new CodeManager. Codes created by the
+// manager are valid for the provided duration.
+func NewMemoryMapManager(ctx context.Context, codeDuration time.Duration) code.Manager {
+	if codeDuration <= 0 {
+		codeDuration = DefaultCodeDuration
+	}
 	cm := &memoryMapManager{
-		table: cmap.New(),
+		table:        cmap.New(),
+		codeDuration: codeDuration,
 	}
 
 	// Cleanup function.
@@ -72,7 +79,7 @@ func NewMemoryMapManager(ctx context.Context) code.Manager {
 
 func (cm *memoryMapManager) purgeExpired() {
 	var expired []string
-	deadline := time.Now().Add(-codeValidDuration)
+	deadline := time.Now().Add(-cm.codeDuration)
 	var record *codeRequestRecord
 	for entry := range cm.table.IterBuffered() {
 		record = entry.Val.(*codeRequestRecord)
@@ -100,14 +107,23 @@ func (cm *memoryMapManager) Create(record *code.Record) (string, error) {
 }
 
 // Pop looks up the provided code in the accociated CodeManagers's table. If
-// found it returns the authentication request and backend record plus true.
-// When not found, both values return as nil plus false.
-func (cm *memoryMapManager) Pop(code string) (*code.Record, bool) {
-	stored, found := cm.table.Pop(code)
+// found and not expired, the code is marked as redeemed and its record is
+// returned. Redeemed codes stay in the table until they expire, so that a
+// replayed code returns its record together with code.ErrReplayed.
+func (cm *memoryMapManager) Pop(c string) (*code.Record, error) {
+	stored, found := cm.table.Get(c)
 	if !found {
-		return nil, false
+		return nil, code.ErrNotFound
 	}
+
 	rr := stored.(*codeRequestRecord)
+	if time.Since(rr.when) > cm.codeDuration {
+		cm.table.Remove(c)
+		return nil, code.ErrExpired
+	}
+	if !rr.record.Redeem() {
+		return rr.record, code.ErrReplayed
+	}
 
-	return rr.record, true
+	return rr.record, nil
 }
@@ -24,10 +24,32 @@ import (
 	"github.com/libregraph/lico/utils"
 )
 
+// OAuth2 error codes which are not defined by the oidc-go package as
+// specified at https://tools.ietf.org/html/rfc6749#section-4.1.2.1 and
+// https://tools.ietf.org/html/rfc6749#section-5.2
+const (
+	ErrorCodeOAuth2UnauthorizedClient = "unauthorized_client"
+	ErrorCodeOAuth2InvalidClient      = "invalid_client"
+	ErrorCodeOAuth2InvalidScope       = "invalid_scope"
+)
+
+// OIDC error codes which are not defined by the oidc-go package as specified
+// at https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+const (
+	ErrorCodeOIDCInvalidRequestURI = "invalid_request_uri"
+)
+
+// OAuth2 error codes for resource indicators as specified at
+// https://tools.ietf.org/html/rfc8707#section-2
+const (
+	ErrorCodeOAuth2InvalidTarget = "invalid_target"
+)
+
 // OAuth2Error defines a general OAuth2 error with id and decription.
 type OAuth2Error struct {
 	ErrorID          string `json:"error"`
 	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri,omitempty"`
 }
 
 // Error implements the error interface.
@@ -42,7 +64,10 @@ func (err *OAuth2Error) Description() string {
 
 // NewOAuth2Error creates a new error with id and description.
 func NewOAuth2Error(id string, description string) utils.ErrorWithDescription {
-	return &OAuth2Error{id, description}
+	return &OAuth2Error{
+		ErrorID:          id,
+		ErrorDescription: description,
+	}
 }
 
 // WriteWWWAuthenticateError writes the provided error with the provided
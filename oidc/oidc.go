@@ -20,3 +20,8 @@ package oidc
 // LibreGraphIDTokenSubjectSaltV1 is the salt value used when hashing Subjects
 // in ID tokens created by this application.
 const LibreGraphIDTokenSubjectSaltV1 = "lico-IDToken-v1"
+
+// PromptCreate is the prompt value which signals that the end-user wants to
+// create a new account as specified at
+// https://openid.net/specs/openid-connect-prompt-create-1_0.html
+const PromptCreate = "create"
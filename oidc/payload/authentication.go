@@ -24,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/libregraph/oidc-go"
@@ -31,6 +32,9 @@ import (
 	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
+// MaxLoginHintLength is the maximum length of accepted login_hint values.
+const MaxLoginHintLength = 256
+
 // AuthenticationRequest holds the incoming parameters and request data for
 // the OpenID Connect 1.0 authorization endpoint as specified at
 // http://openid.net/specs/openid-connect-core-1_0.html#AuthRequest and
@@ -49,6 +53,9 @@ type AuthenticationRequest struct {
 	RawPrompt       string         `schema:"prompt"`
 	RawIDTokenHint  string         `schema:"id_token_hint"`
 	RawMaxAge       string         `schema:"max_age"`
+	RawUILocales    string         `schema:"ui_locales"`
+	RawLoginHint    string         `schema:"login_hint"`
+	RawACRValues    string         `schema:"acr_values"`
 
 	RawRequest      string `schema:"request"`
 	RawRequestURI   string `schema:"request_uri"`
@@ -64,6 +71,10 @@ type AuthenticationRequest struct {
 	IDTokenHint   *jwt.Token      `schema:"-"`
 	MaxAge        time.Duration   `schema:"-"`
 	Request       *jwt.Token      `schema:"-"`
+	UILocales     []string        `schema:"-"`
+	LoginHint     string          `schema:"-"`
+	ACRValues     []string        `schema:"-"`
+	ACR           string          `schema:"-"`
 
 	UseFragment bool   `schema:"-"`
 	Flow        string `schema:"-"`
@@ -135,6 +146,17 @@ func NewAuthenticationRequest(values url.Values, providerMetadata *oidc.WellKnow
 			ar.Prompts[prompt] = true
 		}
 	}
+	if ar.RawUILocales != "" {
+		ar.UILocales = strings.Fields(ar.RawUILocales)
+	}
+	if ar.RawACRValues != "" {
+		ar.ACRValues = strings.Fields(ar.RawACRValues)
+	}
+	if ar.RawLoginHint != "" {
+		// The login_hint is only a hint to prefill the sign-in form, invalid
+		// values are ignored.
+		ar.LoginHint = validLoginHint(ar.RawLoginHint)
+	}
 
 	switch ar.RawResponseType {
 	case oidc.ResponseTypeCode:
@@ -250,6 +272,9 @@ func (ar *AuthenticationRequest) ApplyRequestObject(roc *RequestObjectClaims, me
 	if roc.RawMaxAge != "" {
 		ar.RawMaxAge = roc.RawMaxAge
 	}
+	if roc.RawACRValues != "" {
+		ar.RawACRValues = roc.RawACRValues
+	}
 	if roc.RawRegistration != "" {
 		ar.RawRegistration = roc.RawRegistration
 	}
@@ -263,6 +288,27 @@ func (ar *AuthenticationRequest) ApplyRequestObject(roc *RequestObjectClaims, me
 	return nil
 }
 
+// ApplyDefaultScopes sets the provided scopes as the requested scopes of the
+// accociated authentication request, if the request has no scope. The openid
+// scope is always included. Returns true if the default scopes were applied.
+func (ar *AuthenticationRequest) ApplyDefaultScopes(scopes []string) bool {
+	if len(ar.Scopes) > 0 {
+		return false
+	}
+
+	ar.Scopes[oidc.ScopeOpenID] = true
+	rawScopes := []string{oidc.ScopeOpenID}
+	for _, scope := range scopes {
+		if !ar.Scopes[scope] {
+			ar.Scopes[scope] = true
+			rawScopes = append(rawScopes, scope)
+		}
+	}
+	ar.RawScope = strings.Join(rawScopes, " ")
+
+	return true
+}
+
 // Validate validates the request data of the accociated authentication request.
 func (ar *AuthenticationRequest) Validate(keyFunc jwt.Keyfunc) error {
 	if _, ok := ar.Scopes[oidc.ScopeOpenID]; !ok {
@@ -414,6 +460,10 @@ type AuthenticationSuccess struct {
 	Scope string `url:"scope,omitempty"`
 
 	SessionState string `url:"session_state,omitempty"`
+
+	// Iss is the issuer identifier as specified in
+	// https://www.rfc-editor.org/rfc/rfc9207.html#section-2.
+	Iss string `url:"iss,omitempty"`
 }
 
 // AuthenticationError holds the outgoind data for a failed OpenID
@@ -423,7 +473,12 @@ type AuthenticationSuccess struct {
 type AuthenticationError struct {
 	ErrorID          string `url:"error" json:"error"`
 	ErrorDescription string `url:"error_description,omitempty" json:"error_description,omitempty"`
+	ErrorURI         string `url:"error_uri,omitempty" json:"error_uri,omitempty"`
 	State            string `url:"state,omitempty" json:"state,omitempty"`
+
+	// Iss is the issuer identifier as specified in
+	// https://www.rfc-editor.org/rfc/rfc9207.html#section-2.
+	Iss string `url:"iss,omitempty" json:"-"`
 }
 
 // Error interface implementation.
@@ -442,6 +497,7 @@ func (ae *AuthenticationError) Description() string {
 type AuthenticationBadRequest struct {
 	ErrorID          string `url:"error" json:"error"`
 	ErrorDescription string `url:"error_description,omitempty" json:"error_description,omitempty"`
+	ErrorURI         string `url:"error_uri,omitempty" json:"error_uri,omitempty"`
 	State            string `url:"state,omitempty" json:"state,omitempty"`
 }
 
@@ -454,3 +510,20 @@ func (ae *AuthenticationBadRequest) Error() string {
 func (ae *AuthenticationBadRequest) Description() string {
 	return ae.ErrorDescription
 }
+
+// validLoginHint returns the provided login_hint value with surrounding
+// whitespace removed, or an empty string if the value is too long or
+// contains control characters.
+func validLoginHint(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > MaxLoginHintLength {
+		return ""
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return ""
+		}
+	}
+
+	return value
+}
@@ -22,6 +22,8 @@ import (
 	"strings"
 
 	"github.com/libregraph/oidc-go"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
 var scopedClaims = map[string]string{
@@ -36,10 +38,19 @@ var scopedClaims = map[string]string{
 	oidc.GenderClaim:            oidc.ScopeProfile,
 	oidc.BirthdateClaim:         oidc.ScopeProfile,
 	oidc.ZoneinfoClaim:          oidc.ScopeProfile,
+	oidc.LocaleClaim:            oidc.ScopeProfile,
 	oidc.UpdatedAtClaim:         oidc.ScopeProfile,
 
 	oidc.EmailClaim:         oidc.ScopeEmail,
 	oidc.EmailVerifiedClaim: oidc.ScopeEmail,
+
+	konnectoidc.PhoneNumberClaim:         konnectoidc.ScopePhone,
+	konnectoidc.PhoneNumberVerifiedClaim: konnectoidc.ScopePhone,
+
+	konnectoidc.AddressClaim: konnectoidc.ScopeAddress,
+
+	konnectoidc.GroupsClaim: konnectoidc.ScopeGroups,
+	konnectoidc.RolesClaim:  konnectoidc.ScopeRoles,
 }
 
 // GetScopeForClaim returns the known scope if any for the provided claim name.
@@ -48,6 +59,28 @@ func GetScopeForClaim(claim string) (string, bool) {
 	return scope, ok
 }
 
+// FilterClaimsByScopes removes all claims from the provided claims map which
+// belong to a known scope, unless that scope is included in the provided
+// authorized scopes or the claim is explicitly included in the provided
+// authorized claims request map. Claims without a known scope are kept.
+func FilterClaimsByScopes(claims map[string]interface{}, authorizedScopes map[string]bool, authorizedClaimsRequestMap *ClaimsRequestMap) {
+	for claim := range claims {
+		scope, ok := scopedClaims[claim]
+		if !ok {
+			continue
+		}
+		if authorized, _ := authorizedScopes[scope]; authorized {
+			continue
+		}
+		if authorizedClaimsRequestMap != nil {
+			if _, requested := (*authorizedClaimsRequestMap)[claim]; requested {
+				continue
+			}
+		}
+		delete(claims, claim)
+	}
+}
+
 // ClaimsRequest define the base claims structure for OpenID Connect claims
 // request parameter value as specified at
 // https://openid.net/specs/openid-connect-core-1_0.html#ClaimsParameter - in
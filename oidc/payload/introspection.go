@@ -0,0 +1,93 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package payload
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// IntrospectionRequest holds the incoming parameters and request data for the
+// OAuth 2.0 token introspection endpoint as specified at
+// https://tools.ietf.org/html/rfc7662#section-2.1
+type IntrospectionRequest struct {
+	Token         string
+	TokenTypeHint string
+
+	ClientID     string
+	ClientSecret string
+}
+
+// DecodeIntrospectionRequest returns an IntrospectionRequest holding the
+// provided request's form data. The client credentials are taken from the
+// Basic authorization header or from the form data.
+func DecodeIntrospectionRequest(req *http.Request) (*IntrospectionRequest, error) {
+	ir := &IntrospectionRequest{
+		Token:         req.PostForm.Get("token"),
+		TokenTypeHint: req.PostForm.Get("token_type_hint"),
+
+		ClientID:     req.PostForm.Get("client_id"),
+		ClientSecret: req.PostForm.Get("client_secret"),
+	}
+
+	if username, password, ok := req.BasicAuth(); ok {
+		// Data is encoded application/x-www-form-urlencoded UTF-8. See
+		// https://tools.ietf.org/html/rfc6749#appendix-B for details.
+		clientID, err := url.QueryUnescape(username)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Basic authorization value: %w", err)
+		}
+		clientSecret, err := url.QueryUnescape(password)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Basic authorization value: %w", err)
+		}
+		if ir.ClientID != "" && ir.ClientID != clientID {
+			return nil, fmt.Errorf("client_id mismatch")
+		}
+		ir.ClientID = clientID
+		ir.ClientSecret = clientSecret
+	}
+
+	if ir.ClientID == "" {
+		return nil, fmt.Errorf("client_id is missing")
+	}
+	if ir.Token == "" {
+		return nil, fmt.Errorf("token is missing")
+	}
+
+	return ir, nil
+}
+
+// IntrospectionResponse holds the outgoing data for a token introspection
+// response as specified at https://tools.ietf.org/html/rfc7662#section-2.2
+type IntrospectionResponse struct {
+	Active bool `json:"active"`
+
+	Scope     string      `json:"scope,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Username  string      `json:"username,omitempty"`
+	TokenType string      `json:"token_type,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  interface{} `json:"aud,omitempty"`
+	Issuer    string      `json:"iss,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+}
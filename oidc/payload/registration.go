@@ -56,6 +56,8 @@ type ClientRegistrationRequest struct {
 
 	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
 
+	RequestURIs []string `json:"request_uris,omitempty"`
+
 	JWKS *gojwk.Key `json:"-"`
 }
 
@@ -71,7 +73,7 @@ func DecodeClientRegistrationRequest(req *http.Request) (*ClientRegistrationRequ
 	var crr ClientRegistrationRequest
 	err := decoder.Decode(&crr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode client registration request: %v", err)
+		return nil, fmt.Errorf("failed to decode client registration request: %w", err)
 	}
 
 	if crr.RawJWKS != nil {
@@ -88,9 +90,27 @@ func DecodeClientRegistrationRequest(req *http.Request) (*ClientRegistrationRequ
 	return &crr, err
 }
 
+// ClientRegistrationPolicy defines the rules which are applied when validating
+// client registration requests.
+type ClientRegistrationPolicy struct {
+	// MaxPostLogoutRedirectURIs limits the number of allowed
+	// post_logout_redirect_uris, if larger than 0.
+	MaxPostLogoutRedirectURIs int
+	// AllowNativeImplicit allows native clients to register response types
+	// which return tokens from the authorization endpoint.
+	AllowNativeImplicit bool
+	// NativeHardening requires native clients to use the code flow without
+	// client secret and only custom scheme or loopback redirect_uris.
+	NativeHardening bool
+}
+
 // Validate validates the request data of the accociated client registration
-// request and fills in default data where required.
-func (crr *ClientRegistrationRequest) Validate() error {
+// request with the provided policy and fills in default data where required.
+func (crr *ClientRegistrationRequest) Validate(policy *ClientRegistrationPolicy) error {
+	if policy == nil {
+		policy = &ClientRegistrationPolicy{}
+	}
+
 	if len(crr.RedirectURIs) == 0 {
 		return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "redirect_uris required")
 	}
@@ -167,9 +187,9 @@ func (crr *ClientRegistrationRequest) Validate() error {
 		// URLs using the https scheme as redirect_uris; they MUST NOT use
 		// localhost as the hostname.
 		for _, uriString := range crr.RedirectURIs {
-			uri, err := url.Parse(uriString)
+			uri, err := clients.ParseRedirectURI(uriString)
 			if err != nil {
-				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "failed to parse redirect_uris")
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "invalid redirect_uris: "+err.Error())
 			}
 			if ok := registeredGrantTypes[oidc.GrantTypeImplicit]; ok {
 				if uri.Scheme != "https" {
@@ -182,19 +202,50 @@ func (crr *ClientRegistrationRequest) Validate() error {
 		}
 
 	case oidc.ApplicationTypeNative:
+		// Native clients should use code flow with PKCE instead of having
+		// tokens delivered to their redirect_uris, see
+		// https://tools.ietf.org/html/rfc8252#section-8.2.
+		if !policy.AllowNativeImplicit || policy.NativeHardening {
+			for _, responseType := range crr.ResponseTypes {
+				if responseType != oidc.ResponseTypeCode {
+					return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "native clients must use code response_types")
+				}
+			}
+			if ok := registeredGrantTypes[oidc.GrantTypeImplicit]; ok {
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "native clients must not use implicit grant_types")
+			}
+		}
+
 		// Native Clients MUST only register redirect_uris using custom URI
 		// schemes or URLs using the http: scheme with localhost as the hostname.
 		for _, uriString := range crr.RedirectURIs {
-			uri, err := url.Parse(uriString)
+			uri, err := clients.ParseRedirectURI(uriString)
 			if err != nil {
-				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "failed to parse redirect_uris")
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "invalid redirect_uris: "+err.Error())
 			}
 
+			if policy.NativeHardening {
+				if !clients.IsNativeRedirectURI(uri) {
+					return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "native clients must only use custom scheme or loopback redirect_uris")
+				}
+				continue
+			}
 			if !clients.IsLocalNativeHTTPURI(uri) {
 				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidRedirectURI, "native clients must only use localhost redirect_uris with http")
 			}
 		}
 
+		// Native clients cannot keep a client secret confidential, see
+		// https://tools.ietf.org/html/rfc8252#section-8.5.
+		if policy.NativeHardening {
+			if crr.RawTokenEndpointAuthMethod == "" {
+				crr.RawTokenEndpointAuthMethod = oidc.AuthMethodNone
+			}
+			if crr.RawTokenEndpointAuthMethod != oidc.AuthMethodNone {
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "native clients must use token_endpoint_auth_method none")
+			}
+		}
+
 	default:
 		return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "unknown application_type")
 	}
@@ -225,7 +276,7 @@ func (crr *ClientRegistrationRequest) Validate() error {
 	}
 	if crr.RawTokenEndpointAuthMethod != "" {
 		switch crr.RawTokenEndpointAuthMethod {
-		case oidc.AuthMethodClientSecretBasic:
+		case oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost:
 			// breaks
 		case oidc.AuthMethodNone:
 			// breaks
@@ -240,11 +291,46 @@ func (crr *ClientRegistrationRequest) Validate() error {
 		}
 	}
 
+	if policy.MaxPostLogoutRedirectURIs > 0 && len(crr.PostLogoutRedirectURIs) > policy.MaxPostLogoutRedirectURIs {
+		return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "too many post_logout_redirect_uris")
+	}
 	for _, uriString := range crr.PostLogoutRedirectURIs {
-		_, err := url.Parse(uriString)
+		uri, err := url.Parse(uriString)
 		if err != nil {
 			return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "failed to parse post_logout_redirect_uris")
 		}
+		if !uri.IsAbs() {
+			return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "post_logout_redirect_uris must be absolute")
+		}
+		if uri.Fragment != "" || strings.Contains(uriString, "#") {
+			return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "post_logout_redirect_uris must not contain a fragment")
+		}
+		switch crr.ApplicationType {
+		case oidc.ApplicationTypeWeb:
+			if uri.Scheme != "https" || uri.Host == "" {
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "web clients must use https post_logout_redirect_uris")
+			}
+		case oidc.ApplicationTypeNative:
+			// Native clients can use localhost with http or custom URI schemes.
+			switch uri.Scheme {
+			case "http":
+				if !clients.IsLocalNativeHTTPURI(uri) {
+					return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "native clients must only use localhost post_logout_redirect_uris with http")
+				}
+			case "https":
+				return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "native clients must use localhost or custom scheme post_logout_redirect_uris")
+			}
+		}
+	}
+
+	for _, uriString := range crr.RequestURIs {
+		uri, err := url.Parse(uriString)
+		if err != nil {
+			return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "failed to parse request_uris")
+		}
+		if uri.Scheme != "https" || uri.Host == "" {
+			return konnectoidc.NewOAuth2Error(oidc.ErrorCodeOIDCInvalidClientMetadata, "request_uris must use https")
+		}
 	}
 
 	if crr.JWKS != nil {
@@ -282,6 +368,7 @@ func (crr *ClientRegistrationRequest) ClientRegistration() (*clients.ClientRegis
 		Name:            crr.ClientName,
 		URI:             crr.ClientURI,
 		GrantTypes:      crr.GrantTypes,
+		ResponseTypes:   crr.ResponseTypes,
 		ApplicationType: crr.ApplicationType,
 
 		RedirectURIs: crr.RedirectURIs,
@@ -295,6 +382,8 @@ func (crr *ClientRegistrationRequest) ClientRegistration() (*clients.ClientRegis
 		RawTokenEndpointAuthSigningAlg: crr.RawTokenEndpointAuthSigningAlg,
 
 		PostLogoutRedirectURIs: crr.PostLogoutRedirectURIs,
+
+		RequestURIs: crr.RequestURIs,
 	}
 
 	return cr, nil
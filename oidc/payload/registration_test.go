@@ -0,0 +1,174 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package payload
+
+import (
+	"testing"
+
+	"github.com/libregraph/oidc-go"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
+)
+
+func TestClientRegistrationRequestPostLogoutRedirectURIs(t *testing.T) {
+	tests := []struct {
+		applicationType        string
+		postLogoutRedirectURIs []string
+		valid                  bool
+	}{
+		{oidc.ApplicationTypeWeb, []string{"https://app.example.com/logged-out"}, true},
+		{oidc.ApplicationTypeWeb, []string{"/logged-out"}, false},
+		{oidc.ApplicationTypeWeb, []string{"http://app.example.com/logged-out"}, false},
+		{oidc.ApplicationTypeWeb, []string{"https://app.example.com/logged-out#fragment"}, false},
+		{oidc.ApplicationTypeNative, []string{"http://localhost:12345/logged-out"}, true},
+		{oidc.ApplicationTypeNative, []string{"com.example.app:/logged-out"}, true},
+		{oidc.ApplicationTypeNative, []string{"http://app.example.com/logged-out"}, false},
+		{oidc.ApplicationTypeWeb, []string{"https://app.example.com/1", "https://app.example.com/2", "https://app.example.com/3", "https://app.example.com/4"}, false},
+	}
+
+	for _, test := range tests {
+		redirectURI := "https://app.example.com/cb"
+		if test.applicationType == oidc.ApplicationTypeNative {
+			redirectURI = "http://localhost:12345/cb"
+		}
+		crr := &ClientRegistrationRequest{
+			RedirectURIs:           []string{redirectURI},
+			ApplicationType:        test.applicationType,
+			PostLogoutRedirectURIs: test.postLogoutRedirectURIs,
+		}
+
+		err := crr.Validate(&ClientRegistrationPolicy{
+			MaxPostLogoutRedirectURIs: 3,
+		})
+		if test.valid {
+			if err != nil {
+				t.Errorf("expected %v to be valid for %s, got %v", test.postLogoutRedirectURIs, test.applicationType, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("expected %v to be rejected for %s", test.postLogoutRedirectURIs, test.applicationType)
+			continue
+		}
+		if oauth2Err, ok := err.(*konnectoidc.OAuth2Error); !ok || oauth2Err.ErrorID != oidc.ErrorCodeOIDCInvalidClientMetadata {
+			t.Errorf("expected invalid_client_metadata error for %v, got %v", test.postLogoutRedirectURIs, err)
+		}
+	}
+}
+
+func TestClientRegistrationRequestNativeResponseTypes(t *testing.T) {
+	tests := []struct {
+		responseTypes []string
+		grantTypes    []string
+		policy        *ClientRegistrationPolicy
+		valid         bool
+	}{
+		{[]string{oidc.ResponseTypeCode}, nil, nil, true},
+		{[]string{oidc.ResponseTypeToken}, nil, nil, false},
+		{[]string{oidc.ResponseTypeIDTokenToken}, []string{oidc.GrantTypeAuthorizationCode, oidc.GrantTypeImplicit}, nil, false},
+		{[]string{oidc.ResponseTypeCode}, []string{oidc.GrantTypeAuthorizationCode, oidc.GrantTypeImplicit}, nil, false},
+		{[]string{oidc.ResponseTypeToken}, nil, &ClientRegistrationPolicy{AllowNativeImplicit: true}, true},
+	}
+
+	for _, test := range tests {
+		crr := &ClientRegistrationRequest{
+			RedirectURIs:    []string{"http://localhost:12345/cb"},
+			ResponseTypes:   test.responseTypes,
+			GrantTypes:      test.grantTypes,
+			ApplicationType: oidc.ApplicationTypeNative,
+		}
+
+		err := crr.Validate(test.policy)
+		if test.valid && err != nil {
+			t.Errorf("expected native client with %v to be valid, got %v", test.responseTypes, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected native client with %v to be rejected", test.responseTypes)
+		}
+	}
+}
+
+func TestClientRegistrationRequestNativeHardening(t *testing.T) {
+	tests := []struct {
+		redirectURIs            []string
+		responseTypes           []string
+		tokenEndpointAuthMethod string
+		errorID                 string
+	}{
+		{[]string{"http://127.0.0.1:12345/cb", "com.example.app:/cb"}, nil, "", ""},
+		{[]string{"http://localhost/cb"}, nil, oidc.AuthMethodNone, ""},
+		{[]string{"https://app.example.com/cb"}, nil, "", oidc.ErrorCodeOIDCInvalidRedirectURI},
+		{[]string{"http://app.example.com/cb"}, nil, "", oidc.ErrorCodeOIDCInvalidRedirectURI},
+		{[]string{"http://127.0.0.1/cb"}, []string{oidc.ResponseTypeToken}, "", oidc.ErrorCodeOIDCInvalidClientMetadata},
+		{[]string{"http://127.0.0.1/cb"}, nil, oidc.AuthMethodClientSecretBasic, oidc.ErrorCodeOIDCInvalidClientMetadata},
+	}
+
+	policy := &ClientRegistrationPolicy{
+		AllowNativeImplicit: true,
+		NativeHardening:     true,
+	}
+	for _, test := range tests {
+		crr := &ClientRegistrationRequest{
+			RedirectURIs:               test.redirectURIs,
+			ResponseTypes:              test.responseTypes,
+			ApplicationType:            oidc.ApplicationTypeNative,
+			RawTokenEndpointAuthMethod: test.tokenEndpointAuthMethod,
+		}
+
+		err := crr.Validate(policy)
+		if test.errorID == "" {
+			if err != nil {
+				t.Errorf("expected %v to be valid, got %v", test.redirectURIs, err)
+			} else if crr.RawTokenEndpointAuthMethod != oidc.AuthMethodNone {
+				t.Errorf("expected token_endpoint_auth_method none, got %s", crr.RawTokenEndpointAuthMethod)
+			}
+			continue
+		}
+		if oauth2Err, ok := err.(*konnectoidc.OAuth2Error); !ok || oauth2Err.ErrorID != test.errorID {
+			t.Errorf("expected %s error for %v %v %s, got %v", test.errorID, test.redirectURIs, test.responseTypes, test.tokenEndpointAuthMethod, err)
+		}
+	}
+}
+
+func TestClientRegistrationRequestRequestURIs(t *testing.T) {
+	tests := []struct {
+		requestURIs []string
+		valid       bool
+	}{
+		{[]string{"https://app.example.com/request.jwt"}, true},
+		{[]string{"https://app.example.com/request.jwt#hash"}, true},
+		{[]string{"http://app.example.com/request.jwt"}, false},
+		{[]string{"/request.jwt"}, false},
+	}
+
+	for _, test := range tests {
+		crr := &ClientRegistrationRequest{
+			RedirectURIs:    []string{"https://app.example.com/cb"},
+			ApplicationType: oidc.ApplicationTypeWeb,
+			RequestURIs:     test.requestURIs,
+		}
+
+		err := crr.Validate(nil)
+		if test.valid && err != nil {
+			t.Errorf("expected %v to be valid, got %v", test.requestURIs, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %v to be rejected", test.requestURIs)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/utils"
 )
 
 // RequestObjectClaims holds the incoming request object claims provided as
@@ -43,6 +44,7 @@ type RequestObjectClaims struct {
 	RawPrompt       string         `json:"prompt"`
 	RawIDTokenHint  string         `json:"id_token_hint"`
 	RawMaxAge       string         `json:"max_age"`
+	RawACRValues    string         `json:"acr_values"`
 
 	RawRegistration string `json:"registration"`
 
@@ -52,6 +54,11 @@ type RequestObjectClaims struct {
 	client *clients.Secured
 }
 
+// Valid implements the jwt.Claims interface.
+func (roc RequestObjectClaims) Valid() error {
+	return utils.ValidateTimeClaims(&roc.StandardClaims)
+}
+
 // SetSecure sets the provided client as owner of the accociated claims.
 func (roc *RequestObjectClaims) SetSecure(client *clients.Secured) error {
 	if roc.ClientID != client.ID {
@@ -30,6 +30,10 @@ import (
 	konnectoidc "github.com/libregraph/lico/oidc"
 )
 
+// ClientAssertionTypeJWTBearer is the client_assertion_type value for JWT
+// client assertions as specified at https://tools.ietf.org/html/rfc7523#section-2.2
+const ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
 // TokenRequest holds the incoming parameters and request data for
 // the OpenID Connect 1.0 token endpoint as specified at
 // http://openid.net/specs/openid-connect-core-1_0.html#TokenRequest
@@ -45,6 +49,9 @@ type TokenRequest struct {
 	ClientID     string `schema:"client_id"`
 	ClientSecret string `schema:"client_secret"`
 
+	ClientAssertionType string `schema:"client_assertion_type"`
+	ClientAssertion     string `schema:"client_assertion"`
+
 	CodeVerifier string `schema:"code_verifier"`
 
 	RedirectURI  *url.URL        `schema:"-"`
@@ -86,6 +93,22 @@ func DecodeTokenRequest(req *http.Request, providerMetadata *oidc.WellKnown) (*T
 		}
 	}
 
+	if tr.ClientAssertion != "" {
+		// Support client_secret_jwt authentication method. The assertion is
+		// only decoded here, its signature is validated by the caller.
+		if tr.ClientAssertionType != ClientAssertionTypeJWTBearer {
+			return nil, fmt.Errorf("unsupported client_assertion_type")
+		}
+		claims := &jwt.RegisteredClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(tr.ClientAssertion, claims); err != nil {
+			return nil, fmt.Errorf("invalid client_assertion: %w", err)
+		}
+		if claims.Subject == "" || (tr.ClientID != "" && tr.ClientID != claims.Subject) {
+			return nil, fmt.Errorf("client_assertion sub does not match client_id")
+		}
+		tr.ClientID = claims.Subject
+	}
+
 	if tr.ClientID == "" {
 		if clientID == "" {
 			return nil, fmt.Errorf("client_id is missing")
@@ -27,4 +27,6 @@ type UserInfoResponse struct {
 	oidc.UserInfoClaims
 	*oidc.ProfileClaims
 	*oidc.EmailClaims
+	*oidc.PhoneClaims
+	*oidc.AddressClaims
 }
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"context"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
+)
+
+// accessTokenAudiences returns the audiences of access tokens issued to the
+// client with the provided client ID for the provided scopes. If the scopes
+// are accepted by resource servers which the client is allowed to use, those
+// are the audiences. Otherwise the client itself is the audience. Configured
+// additional audiences are always appended, without duplicates.
+func (p *Provider) accessTokenAudiences(ctx context.Context, clientID string, scopes map[string]bool) ([]string, error) {
+	audiences, err := p.resourceAudiences(ctx, clientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, additional := range p.additionalAudiences {
+		found := false
+		for _, audience := range audiences {
+			if audience == additional {
+				found = true
+				break
+			}
+		}
+		if !found {
+			audiences = append(audiences, additional)
+		}
+	}
+
+	return audiences, nil
+}
+
+func (p *Provider) resourceAudiences(ctx context.Context, clientID string, scopes map[string]bool) ([]string, error) {
+	registration, _ := p.clients.Get(ctx, clientID)
+	resourceServers := p.clients.ResourceServersForScopes(registration, scopes)
+
+	switch len(resourceServers) {
+	case 0:
+		return []string{clientID}, nil
+	case 1:
+		return []string{resourceServers[0].ID}, nil
+	}
+
+	if !p.allowMultipleAudiences {
+		return nil, konnectoidc.NewOAuth2Error(konnectoidc.ErrorCodeOAuth2InvalidTarget, "requested scopes span multiple resource servers")
+	}
+
+	audiences := make([]string, len(resourceServers))
+	for idx, resourceServer := range resourceServers {
+		audiences[idx] = resourceServer.ID
+	}
+
+	return audiences, nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"fmt"
+
+	konnect "github.com/libregraph/lico"
+)
+
+// Claim limit policies, selecting what happens when a list claim has more
+// than the maximum number of values.
+const (
+	ClaimLimitPolicyTruncate = "truncate"
+	ClaimLimitPolicyReject   = "reject"
+)
+
+// claimTruncatedSuffix is appended to the name of a truncated claim to form
+// the name of the claim which indicates the truncation.
+const claimTruncatedSuffix = "_truncated"
+
+// limitClaims enforces the configured maximum number of values of list claims
+// like groups or roles in the provided claims map. Depending on the policy,
+// claims with too many values are either truncated and flagged with a
+// boolean <claim>_truncated claim or an error is returned. Protocol and
+// internal claims are never limited. This includes the lg.i identity claim
+// of access tokens, which the provider uses to restore the identity of the
+// token's user, so its values must be kept intact.
+func (p *Provider) limitClaims(claims map[string]interface{}) error {
+	if p.maxClaimValues <= 0 {
+		return nil
+	}
+
+	for claim, value := range claims {
+		if konnect.IsReservedClaim(claim) {
+			continue
+		}
+		var count int
+		switch v := value.(type) {
+		case []string:
+			count = len(v)
+		case []interface{}:
+			count = len(v)
+		default:
+			continue
+		}
+		if count <= p.maxClaimValues {
+			continue
+		}
+		if p.claimLimitPolicy == ClaimLimitPolicyReject {
+			return fmt.Errorf("claim %s has %d values, exceeding the maximum of %d", claim, count, p.maxClaimValues)
+		}
+		switch v := value.(type) {
+		case []string:
+			claims[claim] = v[:p.maxClaimValues]
+		case []interface{}:
+			claims[claim] = v[:p.maxClaimValues]
+		}
+		claims[claim+claimTruncatedSuffix] = true
+	}
+
+	return nil
+}
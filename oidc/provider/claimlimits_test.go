@@ -0,0 +1,73 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimitClaims(t *testing.T) {
+	newClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"aud":    []string{"client-a", "client-b", "client-c", "client-d"},
+			"groups": []interface{}{"g1", "g2", "g3", "g4"},
+			"roles":  []string{"r1", "r2"},
+			"name":   "User One",
+			"lg.i": map[string]interface{}{
+				"groups": []interface{}{"g1", "g2", "g3", "g4"},
+			},
+		}
+	}
+
+	p := &Provider{
+		maxClaimValues:   3,
+		claimLimitPolicy: ClaimLimitPolicyTruncate,
+	}
+	claims := newClaims()
+	if err := p.limitClaims(claims); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"aud":              []string{"client-a", "client-b", "client-c", "client-d"},
+		"groups":           []interface{}{"g1", "g2", "g3"},
+		"groups_truncated": true,
+		"roles":            []string{"r1", "r2"},
+		"name":             "User One",
+		"lg.i": map[string]interface{}{
+			"groups": []interface{}{"g1", "g2", "g3", "g4"},
+		},
+	}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("unexpected claims after limit: got %v want %v", claims, expected)
+	}
+
+	p.claimLimitPolicy = ClaimLimitPolicyReject
+	if err := p.limitClaims(newClaims()); err == nil {
+		t.Errorf("expected error for claim exceeding the limit with reject policy")
+	}
+
+	p.maxClaimValues = 0
+	claims = newClaims()
+	if err := p.limitClaims(claims); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(claims, newClaims()) {
+		t.Errorf("claims changed with disabled limit: %v", claims)
+	}
+}
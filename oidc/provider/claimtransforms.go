@@ -0,0 +1,159 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	konnect "github.com/libregraph/lico"
+)
+
+// Supported claim transform operations.
+const (
+	ClaimTransformOpLowercase    = "lowercase"
+	ClaimTransformOpUppercase    = "uppercase"
+	ClaimTransformOpTrim         = "trim"
+	ClaimTransformOpRegexReplace = "regex-replace"
+)
+
+// A ClaimTransform is a single operation applied to the string values of a
+// claim.
+type ClaimTransform struct {
+	Op          string `json:"op"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+
+	regexp *regexp.Regexp
+}
+
+// ClaimTransforms maps claim names to the transforms which are applied to the
+// claim in order.
+type ClaimTransforms map[string][]*ClaimTransform
+
+// Validate checks that the associated claim transforms only use supported
+// operations on claims which are not reserved and prepares them for use.
+func (ct ClaimTransforms) Validate() error {
+	for claim, transforms := range ct {
+		if claim == "" {
+			return fmt.Errorf("claim transforms contain an empty claim name")
+		}
+		if konnect.IsReservedClaim(claim) {
+			return fmt.Errorf("claim %s is reserved and cannot be transformed", claim)
+		}
+		for idx, transform := range transforms {
+			if transform == nil {
+				return fmt.Errorf("claim %s transform %d is empty", claim, idx)
+			}
+			switch transform.Op {
+			case ClaimTransformOpLowercase, ClaimTransformOpUppercase, ClaimTransformOpTrim:
+			case ClaimTransformOpRegexReplace:
+				if transform.Pattern == "" {
+					return fmt.Errorf("claim %s transform %d has no pattern", claim, idx)
+				}
+				re, err := regexp.Compile(transform.Pattern)
+				if err != nil {
+					return fmt.Errorf("claim %s transform %d has invalid pattern: %w", claim, idx, err)
+				}
+				transform.regexp = re
+			default:
+				return fmt.Errorf("claim %s transform %d has unknown op: %s", claim, idx, transform.Op)
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadClaimTransformsFromFile loads claim transforms from the provided YAML or
+// JSON file and validates them.
+func LoadClaimTransformsFromFile(fn string) (ClaimTransforms, error) {
+	transformsFile, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read claim transforms file: %w", err)
+	}
+
+	var transforms ClaimTransforms
+	if err = yaml.Unmarshal(transformsFile, &transforms); err != nil {
+		return nil, fmt.Errorf("failed to parse claim transforms file: %w", err)
+	}
+	if err = transforms.Validate(); err != nil {
+		return nil, err
+	}
+
+	return transforms, nil
+}
+
+func (transform *ClaimTransform) apply(value string) string {
+	switch transform.Op {
+	case ClaimTransformOpLowercase:
+		return strings.ToLower(value)
+	case ClaimTransformOpUppercase:
+		return strings.ToUpper(value)
+	case ClaimTransformOpTrim:
+		return strings.TrimSpace(value)
+	case ClaimTransformOpRegexReplace:
+		return transform.regexp.ReplaceAllString(value, transform.Replacement)
+	}
+
+	return value
+}
+
+// Apply transforms the string values of the claims in the provided claims
+// map, including strings in lists. Other values are left unchanged. The claim
+// transforms must have been validated before they are applied.
+func (ct ClaimTransforms) Apply(claims map[string]interface{}) {
+	for claim, transforms := range ct {
+		value, ok := claims[claim]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			for _, transform := range transforms {
+				v = transform.apply(v)
+			}
+			claims[claim] = v
+		case []string:
+			transformed := make([]string, len(v))
+			for idx, s := range v {
+				for _, transform := range transforms {
+					s = transform.apply(s)
+				}
+				transformed[idx] = s
+			}
+			claims[claim] = transformed
+		case []interface{}:
+			transformed := make([]interface{}, len(v))
+			for idx, entry := range v {
+				if s, ok := entry.(string); ok {
+					for _, transform := range transforms {
+						s = transform.apply(s)
+					}
+					entry = s
+				}
+				transformed[idx] = entry
+			}
+			claims[claim] = transformed
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestLoadClaimTransformsFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		data  string
+		valid bool
+	}{
+		{"email:\n  - op: lowercase\n", true},
+		{`{"preferred_username": [{"op": "trim"}, {"op": "regex-replace", "pattern": "@example\\.com$"}]}`, true},
+		{"email:\n  - op: unknown\n", false},
+		{"email:\n  - op: regex-replace\n", false},
+		{"email:\n  - op: regex-replace\n    pattern: \"(\"\n", false},
+		{"sub:\n  - op: lowercase\n", false},
+		{"lg.i:\n  - op: lowercase\n", false},
+	} {
+		fn := filepath.Join(dir, "transforms.yaml")
+		if err := os.WriteFile(fn, []byte(tc.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadClaimTransformsFromFile(fn)
+		if tc.valid && err != nil {
+			t.Errorf("expected claim transforms %q to load: %v", tc.data, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expected claim transforms %q to be rejected", tc.data)
+		}
+	}
+}
+
+func TestClaimTransformsApply(t *testing.T) {
+	transforms := ClaimTransforms{
+		"email": {
+			{Op: ClaimTransformOpLowercase},
+		},
+		"preferred_username": {
+			{Op: ClaimTransformOpTrim},
+			{Op: ClaimTransformOpRegexReplace, Pattern: `@example\.com$`},
+		},
+		"groups": {
+			{Op: ClaimTransformOpRegexReplace, Pattern: `^cn=([^,]+),.*$`, Replacement: "$1"},
+		},
+	}
+	if err := transforms.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{
+		"email":              "User1@Example.COM",
+		"preferred_username": " user1@example.com ",
+		"groups":             []interface{}{"cn=admins,ou=groups", "users", 1},
+		"name":               "User One",
+	}
+	transforms.Apply(claims)
+
+	expected := map[string]interface{}{
+		"email":              "user1@example.com",
+		"preferred_username": "user1",
+		"groups":             []interface{}{"admins", "users", 1},
+		"name":               "User One",
+	}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Errorf("unexpected claims after transform: got %v want %v", claims, expected)
+	}
+}
+
+func TestNewProviderPreparesClaimTransforms(t *testing.T) {
+	newConfig := func(pattern string) *Config {
+		return &Config{
+			Config: &config.Config{
+				Logger: logger,
+			},
+			ClaimTransforms: ClaimTransforms{
+				"groups": {
+					{Op: ClaimTransformOpRegexReplace, Pattern: pattern, Replacement: "$1"},
+				},
+			},
+		}
+	}
+
+	p, err := NewProvider(newConfig(`^cn=([^,]+),.*$`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]interface{}{
+		"groups": []string{"cn=admins,ou=groups"},
+	}
+	p.claimTransforms.Apply(claims)
+	if !reflect.DeepEqual(claims["groups"], []string{"admins"}) {
+		t.Errorf("unexpected groups after transform: %v", claims["groups"])
+	}
+
+	if _, err = NewProvider(newConfig(`(`)); err == nil {
+		t.Error("expected invalid claim transform pattern to be rejected")
+	}
+}
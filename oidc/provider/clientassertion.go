@@ -0,0 +1,105 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/oidc/payload"
+	"github.com/libregraph/lico/utils"
+)
+
+// validateClientSecretJWT validates the client assertion of the provided
+// token request as specified for the client_secret_jwt authentication method
+// at https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+// The HMAC key is derived from the client secret of the provided registration.
+func (p *Provider) validateClientSecretJWT(tr *payload.TokenRequest, registration *clients.ClientRegistration) error {
+	if registration == nil || registration.Dynamic {
+		return fmt.Errorf("client_secret_jwt not supported for client")
+	}
+	if registration.Secret == "" {
+		return fmt.Errorf("client_secret_jwt requires a client secret")
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.NewParser(jwt.WithoutClaimsValidation()).ParseWithClaims(tr.ClientAssertion, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected alg value")
+		}
+		if !p.clientSigningAlgs[token.Method.Alg()] {
+			return nil, fmt.Errorf("alg is not allowed")
+		}
+		if registration.RawTokenEndpointAuthSigningAlg != "" && registration.RawTokenEndpointAuthSigningAlg != token.Method.Alg() {
+			return nil, fmt.Errorf("alg does not match registration")
+		}
+		return []byte(registration.Secret), nil
+	})
+	if err == nil {
+		err = utils.ValidateRegisteredClaims(claims)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid client_assertion: %w", err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("client_assertion exp is missing")
+	}
+	if claims.Issuer != registration.ID || claims.Subject != registration.ID {
+		return fmt.Errorf("client_assertion iss and sub must match client_id")
+	}
+	if !claims.VerifyAudience(p.metadata.TokenEndpoint, true) && !claims.VerifyAudience(p.metadata.Issuer, true) {
+		return fmt.Errorf("client_assertion aud is invalid")
+	}
+	if claims.ID == "" {
+		return fmt.Errorf("client_assertion jti is missing")
+	}
+	// Keep the jti as long as the assertion is accepted, which includes the
+	// leeway after exp.
+	retention := time.Until(claims.ExpiresAt.Time) + utils.JWTLeeway()
+	if retention <= 0 {
+		return fmt.Errorf("client_assertion is expired")
+	}
+	if !p.clientAssertionIDs.use(registration.ID, claims.ID, retention) {
+		return fmt.Errorf("client_assertion jti has already been used")
+	}
+
+	return nil
+}
+
+// authenticateClientAssertion authenticates the client of the provided token
+// request with its client assertion if any. It returns true when the client
+// has been authenticated that way and no further secret check is required.
+func (p *Provider) authenticateClientAssertion(tr *payload.TokenRequest, registration *clients.ClientRegistration) (bool, error) {
+	if tr.ClientAssertion == "" {
+		if registration != nil && registration.RawTokenEndpointAuthMethod == oidc.AuthMethodClientSecretJWT {
+			return false, fmt.Errorf("client_assertion is required for client")
+		}
+		return false, nil
+	}
+
+	if err := p.validateClientSecretJWT(tr, registration); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -18,6 +18,8 @@
 package provider
 
 import (
+	"html/template"
+	"net/url"
 	"time"
 
 	"github.com/libregraph/lico/config"
@@ -29,6 +31,7 @@ type Config struct {
 
 	IssuerIdentifier       string
 	WellKnownPath          string
+	OAuthMetadataPath      string
 	JwksPath               string
 	AuthorizationPath      string
 	TokenPath              string
@@ -36,6 +39,7 @@ type Config struct {
 	EndSessionPath         string
 	CheckSessionIframePath string
 	RegistrationPath       string
+	IntrospectionPath      string
 
 	BrowserStateCookiePath string
 	BrowserStateCookieName string
@@ -46,4 +50,12 @@ type Config struct {
 	AccessTokenDuration  time.Duration
 	IDTokenDuration      time.Duration
 	RefreshTokenDuration time.Duration
+
+	JwksMaxAge time.Duration
+
+	ErrorPageTemplate     *template.Template
+	ErrorDocumentationURI *url.URL
+
+	StaticClaims    map[string]interface{}
+	ClaimTransforms ClaimTransforms
 }
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestSessionCookieAttributes(t *testing.T) {
+	tests := []struct {
+		sameSite http.SameSite
+		domain   string
+
+		expectedSameSite http.SameSite
+	}{
+		{0, "", http.SameSiteNoneMode},
+		{http.SameSiteLaxMode, "example.com", http.SameSiteLaxMode},
+		{http.SameSiteStrictMode, "", http.SameSiteStrictMode},
+	}
+
+	for _, test := range tests {
+		p, err := NewProvider(&Config{
+			Config: &config.Config{
+				Logger:         logger,
+				CookieSameSite: test.sameSite,
+				CookieDomain:   test.domain,
+			},
+
+			SessionCookieName: "__Secure-KKS",
+			SessionCookiePath: "/konnect/v1/session",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		if err = p.setSessionCookie(rr, "value"); err != nil {
+			t.Fatal(err)
+		}
+
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("expected one cookie, got %d: %v", len(cookies), rr.Header().Values("Set-Cookie"))
+		}
+		cookie := cookies[0]
+		if !cookie.Secure {
+			t.Errorf("expected cookie to be Secure: %s", rr.Header().Get("Set-Cookie"))
+		}
+		if !cookie.HttpOnly {
+			t.Errorf("expected cookie to be HttpOnly: %s", rr.Header().Get("Set-Cookie"))
+		}
+		if cookie.SameSite != test.expectedSameSite {
+			t.Errorf("expected SameSite %v, got %v: %s", test.expectedSameSite, cookie.SameSite, rr.Header().Get("Set-Cookie"))
+		}
+		if cookie.Domain != test.domain {
+			t.Errorf("expected Domain %q, got %q: %s", test.domain, cookie.Domain, rr.Header().Get("Set-Cookie"))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/cors"
+)
+
+// newClientOriginsCors creates a cors.Cors which allows the origins of the
+// redirect URIs of registered web clients and the provided allowed origins.
+// Credentials are not allowed, since the endpoints it is used for are
+// authenticated with bearer tokens or client credentials.
+func (p *Provider) newClientOriginsCors(allowedOrigins []string) *cors.Cors {
+	return cors.New(cors.Options{
+		AllowOriginFunc: func(origin string) bool {
+			for _, allowed := range allowedOrigins {
+				if matchOrigin(allowed, origin) {
+					return true
+				}
+			}
+			return p.clients != nil && p.clients.IsClientOrigin(origin)
+		},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodHead},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+	})
+}
+
+// matchOrigin returns true if the provided origin matches the provided
+// allowed origin, which can be "*" or contain a single wildcard like
+// https://*.example.com. Matching is case insensitive.
+func matchOrigin(allowed string, origin string) bool {
+	if allowed == "*" {
+		return true
+	}
+	allowed = strings.ToLower(allowed)
+	origin = strings.ToLower(origin)
+	if idx := strings.IndexByte(allowed, '*'); idx >= 0 {
+		prefix, suffix := allowed[:idx], allowed[idx+1:]
+		return len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+	}
+	return allowed == origin
+}
@@ -0,0 +1,324 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/libregraph/oidc-go"
+
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+	"github.com/libregraph/lico/utils"
+)
+
+const defaultErrorPageLocale = "en"
+
+// errorMessageCatalog holds user facing messages for well-known OAuth2 and
+// OpenID Connect error codes by locale. It covers the same locales as the
+// identifier web app ships translations for. Requests for any other locale
+// fall back to defaultErrorPageLocale.
+var errorMessageCatalog = map[string]map[string]string{
+	"en": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "The request is missing a required parameter or is otherwise malformed.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "The application requested an unsupported response type.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "Access has been denied.",
+		oidc.ErrorCodeOAuth2ServerError:             "The server encountered an unexpected problem.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "The service is temporarily unavailable. Please try again later.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "The application used an invalid redirect URI.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "The application sent an invalid request object.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "The application sent a request which is not supported.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "The application sent a request URI which is not supported.",
+		oidc.ErrorCodeOIDCLoginRequired:             "You need to sign in to continue.",
+	},
+	"de": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "Der Anfrage fehlt ein erforderlicher Parameter oder sie ist fehlerhaft.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "Die Anwendung hat einen nicht unterstützten Antworttyp angefordert.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "Der Zugriff wurde verweigert.",
+		oidc.ErrorCodeOAuth2ServerError:             "Auf dem Server ist ein unerwartetes Problem aufgetreten.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "Der Dienst ist vorübergehend nicht verfügbar. Bitte versuchen Sie es später erneut.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "Die Anwendung hat eine ungültige Weiterleitungsadresse verwendet.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "Die Anwendung hat ein ungültiges Anfrageobjekt gesendet.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "Die Anwendung hat eine nicht unterstützte Anfrage gesendet.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "Die Anwendung hat eine nicht unterstützte Anfrage-URI gesendet.",
+		oidc.ErrorCodeOIDCLoginRequired:             "Sie müssen sich anmelden, um fortzufahren.",
+	},
+	"es": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "A la solicitud le falta un parámetro obligatorio o es incorrecta.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "La aplicación ha solicitado un tipo de respuesta no admitido.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "Se ha denegado el acceso.",
+		oidc.ErrorCodeOAuth2ServerError:             "El servidor ha encontrado un problema inesperado.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "El servicio no está disponible temporalmente. Inténtelo de nuevo más tarde.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "La aplicación ha utilizado una URI de redirección no válida.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "La aplicación ha enviado un objeto de solicitud no válido.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "La aplicación ha enviado una solicitud no admitida.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "La aplicación ha enviado una URI de solicitud no admitida.",
+		oidc.ErrorCodeOIDCLoginRequired:             "Debe iniciar sesión para continuar.",
+	},
+	"fr": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "Il manque un paramètre obligatoire à la requête ou elle est mal formée.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "L’application a demandé un type de réponse non pris en charge.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "L’accès a été refusé.",
+		oidc.ErrorCodeOAuth2ServerError:             "Le serveur a rencontré un problème inattendu.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "Le service est temporairement indisponible. Veuillez réessayer plus tard.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "L’application a utilisé une URI de redirection invalide.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "L’application a envoyé un objet de requête invalide.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "L’application a envoyé une requête non prise en charge.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "L’application a envoyé une URI de requête non prise en charge.",
+		oidc.ErrorCodeOIDCLoginRequired:             "Vous devez vous connecter pour continuer.",
+	},
+	"it": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "Alla richiesta manca un parametro obbligatorio oppure non è valida.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "L’applicazione ha richiesto un tipo di risposta non supportato.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "L’accesso è stato negato.",
+		oidc.ErrorCodeOAuth2ServerError:             "Il server ha riscontrato un problema imprevisto.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "Il servizio è temporaneamente non disponibile. Riprova più tardi.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "L’applicazione ha utilizzato un URI di reindirizzamento non valido.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "L’applicazione ha inviato un oggetto di richiesta non valido.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "L’applicazione ha inviato una richiesta non supportata.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "L’applicazione ha inviato un URI di richiesta non supportato.",
+		oidc.ErrorCodeOIDCLoginRequired:             "Devi accedere per continuare.",
+	},
+	"nl": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "Het verzoek mist een verplichte parameter of is onjuist opgebouwd.",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "De applicatie heeft een niet-ondersteund antwoordtype aangevraagd.",
+		oidc.ErrorCodeOAuth2AccessDenied:            "De toegang is geweigerd.",
+		oidc.ErrorCodeOAuth2ServerError:             "De server heeft een onverwacht probleem ondervonden.",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "De dienst is tijdelijk niet beschikbaar. Probeer het later opnieuw.",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "De applicatie heeft een ongeldige omleidings-URI gebruikt.",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "De applicatie heeft een ongeldig verzoekobject verstuurd.",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "De applicatie heeft een niet-ondersteund verzoek verstuurd.",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "De applicatie heeft een niet-ondersteunde verzoek-URI verstuurd.",
+		oidc.ErrorCodeOIDCLoginRequired:             "U moet zich aanmelden om verder te gaan.",
+	},
+	"zh-CN": {
+		oidc.ErrorCodeOAuth2InvalidRequest:          "请求缺少必需的参数或格式不正确。",
+		oidc.ErrorCodeOAuth2UnsupportedResponseType: "应用程序请求了不受支持的响应类型。",
+		oidc.ErrorCodeOAuth2AccessDenied:            "访问被拒绝。",
+		oidc.ErrorCodeOAuth2ServerError:             "服务器遇到意外问题。",
+		oidc.ErrorCodeOAuth2TemporarilyUnavailable:  "服务暂时不可用，请稍后重试。",
+		oidc.ErrorCodeOIDCInvalidRedirectURI:        "应用程序使用了无效的重定向 URI。",
+		oidc.ErrorCodeOIDCInvalidRequestObject:      "应用程序发送了无效的请求对象。",
+		oidc.ErrorCodeOIDCRequestNotSupported:       "应用程序发送了不受支持的请求。",
+		oidc.ErrorCodeOIDCRequestURINotSupported:    "应用程序发送了不受支持的请求 URI。",
+		oidc.ErrorCodeOIDCLoginRequired:             "您需要登录才能继续。",
+	},
+}
+
+// documentedErrorCodes holds the well-known OAuth2 and OpenID Connect error
+// codes for which an error_uri is returned.
+var documentedErrorCodes = map[string]bool{
+	oidc.ErrorCodeOAuth2UnsupportedResponseType: true,
+	oidc.ErrorCodeOAuth2InvalidRequest:          true,
+	oidc.ErrorCodeOAuth2InvalidToken:            true,
+	oidc.ErrorCodeOAuth2InsufficientScope:       true,
+	oidc.ErrorCodeOAuth2InvalidGrant:            true,
+	oidc.ErrorCodeOAuth2UnsupportedGrantType:    true,
+	oidc.ErrorCodeOAuth2AccessDenied:            true,
+	oidc.ErrorCodeOAuth2ServerError:             true,
+	oidc.ErrorCodeOAuth2TemporarilyUnavailable:  true,
+	oidc.ErrorCodeOIDCInteractionRequired:       true,
+	oidc.ErrorCodeOIDCLoginRequired:             true,
+	oidc.ErrorCodeOIDCConsentRequired:           true,
+	oidc.ErrorCodeOIDCRequestNotSupported:       true,
+	oidc.ErrorCodeOIDCInvalidRequestObject:      true,
+	oidc.ErrorCodeOIDCRequestURINotSupported:    true,
+	oidc.ErrorCodeOIDCRegistrationNotSupported:  true,
+	oidc.ErrorCodeOIDCInvalidRedirectURI:        true,
+	oidc.ErrorCodeOIDCInvalidClientMetadata:     true,
+}
+
+// errorURI returns the documentation URL for the provided error code, or an
+// empty string if no error documentation URI is configured or the code is not
+// well-known.
+func (p *Provider) errorURI(errorID string) string {
+	if p.errorDocumentationURI == nil || !documentedErrorCodes[errorID] {
+		return ""
+	}
+
+	uri := *p.errorDocumentationURI
+	uri.Path = path.Join("/", uri.Path, url.PathEscape(errorID))
+	uri.RawPath = ""
+
+	return uri.String()
+}
+
+// withErrorURI sets the error_uri of the provided error if it is an OAuth2
+// error type which supports it and returns the error.
+func (p *Provider) withErrorURI(err error) error {
+	switch e := err.(type) {
+	case *konnectoidc.OAuth2Error:
+		e.ErrorURI = p.errorURI(e.ErrorID)
+	case *payload.AuthenticationError:
+		e.ErrorURI = p.errorURI(e.ErrorID)
+	case *payload.AuthenticationBadRequest:
+		e.ErrorURI = p.errorURI(e.ErrorID)
+	}
+
+	return err
+}
+
+// errorPageData is the data passed to error page templates.
+type errorPageData struct {
+	StatusCode  int
+	StatusText  string
+	Error       string
+	Description string
+	URI         string
+	Message     string
+	Locale      string
+}
+
+// errorPageResponse is the JSON error body for clients which prefer JSON.
+type errorPageResponse struct {
+	ErrorID          string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+}
+
+// OAuth2ErrorPage writes the provided OAuth2 error to the provided
+// ResponseWriter for errors which cannot be redirected back to the client.
+// Clients which prefer JSON get a JSON error body. Everyone else gets the
+// configured error page template, with a message localized based on the
+// ui_locales parameter and the Accept-Language header of the provided request.
+func (p *Provider) OAuth2ErrorPage(rw http.ResponseWriter, req *http.Request, code int, errorID string, description string) {
+	if prefersJSON(req) {
+		err := utils.WriteJSON(rw, code, &errorPageResponse{
+			ErrorID:          errorID,
+			ErrorDescription: description,
+			ErrorURI:         p.errorURI(errorID),
+		}, "")
+		if err != nil {
+			p.logger.WithError(err).Debugln("failed to write error response")
+		}
+		return
+	}
+
+	if p.errorPageTemplate == nil {
+		p.ErrorPage(rw, code, errorID, description)
+		return
+	}
+
+	locale := matchErrorMessageLocale(req)
+	message, ok := errorMessageCatalog[locale][errorID]
+	if !ok {
+		message = errorMessageCatalog[locale][oidc.ErrorCodeOAuth2ServerError]
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Content-Language", locale)
+	rw.WriteHeader(code)
+	err := p.errorPageTemplate.Execute(rw, &errorPageData{
+		StatusCode:  code,
+		StatusText:  http.StatusText(code),
+		Error:       errorID,
+		Description: description,
+		URI:         p.errorURI(errorID),
+		Message:     message,
+		Locale:      locale,
+	})
+	if err != nil {
+		p.logger.WithError(err).Errorln("failed to render error page template")
+	}
+}
+
+// prefersJSON returns true if the provided request's Accept header prefers
+// application/json over text/html.
+func prefersJSON(req *http.Request) bool {
+	var jsonQ, htmlQ float64 = -1, -1
+	for _, value := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, q := parseQualityValue(value)
+		switch mediaType {
+		case "application/json":
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case "text/html":
+			if q > htmlQ {
+				htmlQ = q
+			}
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// matchErrorMessageLocale returns the first locale of the error message
+// catalog which matches the ui_locales parameter or the Accept-Language
+// header of the provided request. Exact matches take precedence over matches
+// of the base language. If nothing matches, defaultErrorPageLocale is
+// returned.
+func matchErrorMessageLocale(req *http.Request) string {
+	candidates := strings.Fields(req.Form.Get("ui_locales"))
+
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	accepted := []weighted{}
+	for _, value := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		tag, q := parseQualityValue(value)
+		if tag == "" || q <= 0 {
+			continue
+		}
+		// Keep sorted by quality, stable for equal values.
+		idx := len(accepted)
+		for idx > 0 && accepted[idx-1].q < q {
+			idx--
+		}
+		accepted = append(accepted[:idx], append([]weighted{{tag, q}}, accepted[idx:]...)...)
+	}
+	for _, w := range accepted {
+		candidates = append(candidates, w.tag)
+	}
+
+	for _, candidate := range candidates {
+		for locale := range errorMessageCatalog {
+			if strings.EqualFold(candidate, locale) {
+				return locale
+			}
+		}
+		base := strings.SplitN(candidate, "-", 2)[0]
+		for locale := range errorMessageCatalog {
+			if strings.EqualFold(base, strings.SplitN(locale, "-", 2)[0]) {
+				return locale
+			}
+		}
+	}
+
+	return defaultErrorPageLocale
+}
+
+func parseQualityValue(value string) (string, float64) {
+	parts := strings.Split(value, ";")
+	q := 1.0
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+
+	return strings.TrimSpace(parts[0]), q
+}
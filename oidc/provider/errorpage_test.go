@@ -0,0 +1,169 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/libregraph/oidc-go"
+
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+func newTestErrorPageProvider(t *testing.T) *Provider {
+	p, err := NewProvider(&Config{
+		Config: &config.Config{
+			Logger: logger,
+		},
+
+		ErrorPageTemplate: template.Must(template.New("error").Parse(`<html lang="{{.Locale}}"><p>{{.StatusCode}} {{.Error}}</p><p>{{.Message}}</p></html>`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestOAuth2ErrorPageNegotiation(t *testing.T) {
+	p := newTestErrorPageProvider(t)
+
+	tests := []struct {
+		accept string
+		json   bool
+	}{
+		{"application/json", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"text/html;q=0.5, application/json", true},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		rr := httptest.NewRecorder()
+		p.OAuth2ErrorPage(rr, req, http.StatusBadRequest, oidc.ErrorCodeOIDCInvalidRedirectURI, "invalid redirect_uri")
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("wrong status code for accept %q: got %v want %v", test.accept, status, http.StatusBadRequest)
+		}
+		contentType := rr.Header().Get("Content-Type")
+		if test.json {
+			if !strings.HasPrefix(contentType, "application/json") {
+				t.Errorf("expected JSON for accept %q, got %s", test.accept, contentType)
+			}
+			var response map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response["error"] != oidc.ErrorCodeOIDCInvalidRedirectURI || response["error_description"] != "invalid redirect_uri" {
+				t.Errorf("unexpected JSON error body: %v", response)
+			}
+		} else {
+			if !strings.HasPrefix(contentType, "text/html") {
+				t.Errorf("expected HTML for accept %q, got %s", test.accept, contentType)
+			}
+			if !strings.Contains(rr.Body.String(), errorMessageCatalog["en"][oidc.ErrorCodeOIDCInvalidRedirectURI]) {
+				t.Errorf("expected rendered error page, got %s", rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestOAuth2ErrorPageLocalization(t *testing.T) {
+	p := newTestErrorPageProvider(t)
+
+	tests := []struct {
+		uiLocales      string
+		acceptLanguage string
+		locale         string
+	}{
+		{"", "de-DE,de;q=0.9,en;q=0.8", "de"},
+		{"", "pt;q=0.9, en;q=0.5, de;q=0.7", "de"},
+		{"de", "en", "de"},
+		{"fr", "", "fr"},
+		{"", "zh-cn", "zh-CN"},
+		{"zh-TW", "", "zh-CN"},
+		{"nl-BE", "", "nl"},
+		{"pt", "", "en"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?ui_locales="+test.uiLocales, nil)
+		req.ParseForm()
+		if test.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		rr := httptest.NewRecorder()
+		p.OAuth2ErrorPage(rr, req, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, "")
+
+		if v := rr.Header().Get("Content-Language"); v != test.locale {
+			t.Errorf("wrong locale for ui_locales %q and Accept-Language %q: got %v want %v", test.uiLocales, test.acceptLanguage, v, test.locale)
+		}
+		if !strings.Contains(rr.Body.String(), errorMessageCatalog[test.locale][oidc.ErrorCodeOAuth2InvalidRequest]) {
+			t.Errorf("expected localized message for %s, got %s", test.locale, rr.Body.String())
+		}
+	}
+}
+
+func TestErrorURIRedirect(t *testing.T) {
+	p := newTestErrorPageProvider(t)
+	p.errorDocumentationURI, _ = url.Parse("https://docs.example.com/lico/errors/")
+
+	redirectURI, _ := url.Parse("https://client.example.com/cb")
+	ar := &payload.AuthenticationRequest{
+		State: "xyz",
+	}
+	rr := httptest.NewRecorder()
+	p.Found(rr, redirectURI, p.withErrorURI(ar.NewError(oidc.ErrorCodeOAuth2AccessDenied, "denied")), false)
+
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := location.Query().Get("error"); v != oidc.ErrorCodeOAuth2AccessDenied {
+		t.Errorf("unexpected error in redirect: %v", v)
+	}
+	if v := location.Query().Get("error_uri"); v != "https://docs.example.com/lico/errors/access_denied" {
+		t.Errorf("unexpected error_uri in redirect: %v", v)
+	}
+
+	// Unknown error codes have no documentation.
+	if v := p.errorURI("custom_error"); v != "" {
+		t.Errorf("expected no error_uri for unknown error code, got %v", v)
+	}
+}
+
+func TestErrorMessageCatalogComplete(t *testing.T) {
+	for locale, messages := range errorMessageCatalog {
+		for errorID := range errorMessageCatalog[defaultErrorPageLocale] {
+			if messages[errorID] == "" {
+				t.Errorf("missing %s message for locale %s", errorID, locale)
+			}
+		}
+	}
+}
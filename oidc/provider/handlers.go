@@ -19,8 +19,10 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -30,6 +32,7 @@ import (
 	"gopkg.in/square/go-jose.v2"
 
 	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/audit"
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/clients"
 	konnectoidc "github.com/libregraph/lico/oidc"
@@ -40,35 +43,118 @@ import (
 
 const (
 	registrationSizeLimit = 1024 * 512
+	tokenSizeLimit        = 1024 * 64
 )
 
+// wellKnownResponse is the provider configuration response, extended with
+// custom discovery fields.
+type wellKnownResponse struct {
+	oidc.WellKnown
+
+	// ResourceServers lists the known resource servers together with the
+	// scopes they accept. Omitted when no resource servers are configured.
+	ResourceServers []*clients.ResourceServer `json:"resource_servers,omitempty"`
+
+	// AuthorizationResponseIssParameterSupported is specified in
+	// https://www.rfc-editor.org/rfc/rfc9207.html#section-3.
+	AuthorizationResponseIssParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+
+	// RequireRequestURIRegistration is specified in
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+	RequireRequestURIRegistration bool `json:"require_request_uri_registration"`
+
+	// ACRValuesSupported is specified in
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+	// Omitted when no acr values are configured.
+	ACRValuesSupported []string `json:"acr_values_supported,omitempty"`
+
+	// PromptValuesSupported is specified in
+	// https://openid.net/specs/openid-connect-prompt-create-1_0.html#section-4.
+	PromptValuesSupported []string `json:"prompt_values_supported"`
+}
+
 // WellKnownHandler implements the HTTP provider configuration endpoint
 // for OpenID Connect 1.0 as specified at https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
 func (p *Provider) WellKnownHandler(rw http.ResponseWriter, req *http.Request) {
 	// TODO(longsleep): Add caching headers.
-	wellKnown := p.metadata
+	wellKnown := *p.metadata
+	// Supported scopes can change at runtime, e.g. when the backend enables
+	// additional scopes, so always get them fresh.
+	wellKnown.ScopesSupported = p.scopesSupported()
+	// Endpoints are prefixed when mounted on a sub path by a trusted proxy.
+	// The issuer is never changed.
+	if prefix := p.forwardedPrefix(req); prefix != "" {
+		wellKnown.AuthorizationEndpoint = withPathPrefix(wellKnown.AuthorizationEndpoint, prefix)
+		wellKnown.TokenEndpoint = withPathPrefix(wellKnown.TokenEndpoint, prefix)
+		wellKnown.UserInfoEndpoint = withPathPrefix(wellKnown.UserInfoEndpoint, prefix)
+		wellKnown.EndSessionEndpoint = withPathPrefix(wellKnown.EndSessionEndpoint, prefix)
+		wellKnown.CheckSessionIframe = withPathPrefix(wellKnown.CheckSessionIframe, prefix)
+		wellKnown.JwksURI = withPathPrefix(wellKnown.JwksURI, prefix)
+		wellKnown.RegistrationEndpoint = withPathPrefix(wellKnown.RegistrationEndpoint, prefix)
+	}
+
+	response := &wellKnownResponse{
+		WellKnown: wellKnown,
+
+		AuthorizationResponseIssParameterSupported: true,
+		RequireRequestURIRegistration:              true,
 
-	err := utils.WriteJSON(rw, http.StatusOK, wellKnown, "")
+		ACRValuesSupported:    p.acrValuesSupported(),
+		PromptValuesSupported: p.promptValuesSupported(),
+	}
+	if resourceServers := p.clients.ResourceServers(); len(resourceServers) > 0 {
+		response.ResourceServers = resourceServers
+	}
+
+	err := utils.WriteJSON(rw, http.StatusOK, response, "")
 	if err != nil {
 		p.logger.WithError(err).Errorln("well-known request failed writing response")
 	}
 }
 
+// OAuthMetadataHandler implements the HTTP authorization server meta data
+// endpoint as specified at https://tools.ietf.org/html/rfc8414#section-3
+func (p *Provider) OAuthMetadataHandler(rw http.ResponseWriter, req *http.Request) {
+	oauthMetadata := *p.oauthMetadata
+	oauthMetadata.ScopesSupported = p.scopesSupported()
+	if prefix := p.forwardedPrefix(req); prefix != "" {
+		oauthMetadata.AuthorizationEndpoint = withPathPrefix(oauthMetadata.AuthorizationEndpoint, prefix)
+		oauthMetadata.TokenEndpoint = withPathPrefix(oauthMetadata.TokenEndpoint, prefix)
+		oauthMetadata.JwksURI = withPathPrefix(oauthMetadata.JwksURI, prefix)
+		oauthMetadata.RegistrationEndpoint = withPathPrefix(oauthMetadata.RegistrationEndpoint, prefix)
+		oauthMetadata.IntrospectionEndpoint = withPathPrefix(oauthMetadata.IntrospectionEndpoint, prefix)
+	}
+
+	err := utils.WriteJSON(rw, http.StatusOK, &oauthMetadata, "")
+	if err != nil {
+		p.logger.WithError(err).Errorln("oauth metadata request failed writing response")
+	}
+}
+
 // JwksHandler implements the HTTP provider JWKS endpoint for OpenID provider
 // metadata used with OpenID Connect Discovery 1.0 as specified at https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
 func (p *Provider) JwksHandler(rw http.ResponseWriter, req *http.Request) {
 	addResponseHeaders(rw.Header())
 
 	validationKeys := p.validationKeys
+	kids := make([]string, 0, len(validationKeys))
+	for kid := range validationKeys {
+		kids = append(kids, kid)
+	}
+	// Sort by kid so the ETag is stable for the same key set.
+	sort.Strings(kids)
+
 	jwks := &jose.JSONWebKeySet{
 		Keys: make([]jose.JSONWebKey, 0),
 	}
-	for kid, key := range validationKeys {
+	for _, kid := range kids {
+		key := validationKeys[kid]
 		certificates, _ := p.certificates[kid]
 		keyJwk := jose.JSONWebKey{
 			Key:          key,
 			KeyID:        kid,
-			Use:          "sig", // https://tools.ietf.org/html/rfc7517#section-4.2
+			Algorithm:    p.jwkAlgorithm(kid, key), // https://tools.ietf.org/html/rfc7517#section-4.4
+			Use:          "sig",                    // https://tools.ietf.org/html/rfc7517#section-4.2
 			Certificates: certificates,
 		}
 		if keyJwk.Valid() {
@@ -76,7 +162,23 @@ func (p *Provider) JwksHandler(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	err := utils.WriteJSON(rw, http.StatusOK, jwks, "application/jwk-set+json")
+	etag, err := makeJWKSETag(jwks)
+	if err != nil {
+		p.logger.WithError(err).Errorln("jwks request failed to create etag")
+		p.ErrorPage(rw, http.StatusInternalServerError, err.Error(), "well sorry, but there was a problem")
+		return
+	}
+	rw.Header().Set("ETag", etag)
+	if p.jwksMaxAge > 0 {
+		rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(p.jwksMaxAge.Seconds())))
+		rw.Header().Del("Pragma")
+	}
+	if matchETag(req.Header.Get("If-None-Match"), etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err = utils.WriteJSON(rw, http.StatusOK, jwks, "application/jwk-set+json")
 	if err != nil {
 		p.logger.WithError(err).Errorln("jwks request failed writing response")
 	}
@@ -98,14 +200,28 @@ func (p *Provider) AuthorizeHandler(rw http.ResponseWriter, req *http.Request) {
 	err = req.ParseForm()
 	if err != nil {
 		p.logger.WithError(err).Errorln("authorize request invalid form data")
-		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		return
 	}
+	if req.Form.Get("request_uri") != "" {
+		err = p.resolveRequestURI(req)
+		if err != nil {
+			p.logger.WithError(err).Errorln("authorize request invalid request_uri")
+			p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, konnectoidc.ErrorCodeOIDCInvalidRequestURI, err.Error())
+			return
+		}
+	}
 
 	ar, err := payload.DecodeAuthenticationRequest(req, p.metadata, func(token *jwt.Token) (interface{}, error) {
 		if claims, ok := token.Claims.(*payload.RequestObjectClaims); ok {
 			// Validate signed request tokens according to spec defined at
 			// https://openid.net/specs/openid-connect-core-1_0.html#SignedRequestObject
+			if !p.clientSigningAlgs[token.Method.Alg()] {
+				return nil, fmt.Errorf("token alg is not allowed")
+			}
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+				return nil, fmt.Errorf("token alg is not supported for request objects")
+			}
 			registration, _ := p.clients.Get(req.Context(), claims.ClientID)
 			if registration != nil {
 				if registration.RawRequestObjectSigningAlg != "" {
@@ -144,17 +260,53 @@ func (p *Provider) AuthorizeHandler(rw http.ResponseWriter, req *http.Request) {
 	})
 	if err != nil {
 		p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("authorize request invalid request data")
-		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		return
 	}
+	if len(p.defaultScopes) > 0 {
+		ar.ApplyDefaultScopes(p.defaultScopes)
+	}
 	err = ar.Validate(func(token *jwt.Token) (interface{}, error) {
 		// Validator for incoming IDToken hints, looks up key.
-		return p.validateJWT(token)
+		return p.validateIDTokenHint(token)
 	})
 	if err != nil {
 		goto done
 	}
 
+	// Ensure that the requested response_type is registered for the client.
+	// Only clients with a valid redirect_uri get the error redirected, all
+	// others are rejected later on.
+	if clientDetails, lookupErr := p.clients.Lookup(req.Context(), ar.ClientID, "", ar.RedirectURI, "", true); lookupErr == nil && clientDetails.Registration != nil {
+		if !clientDetails.Registration.AllowsResponseTypes(ar.ResponseTypes) {
+			err = ar.NewError(konnectoidc.ErrorCodeOAuth2UnauthorizedClient, "response_type not registered for client")
+			goto done
+		}
+		if disallowed := clientDetails.Registration.DisallowedScopes(ar.Scopes); len(disallowed) > 0 {
+			err = ar.NewError(konnectoidc.ErrorCodeOAuth2InvalidScope, "scope not allowed for client: "+strings.Join(disallowed, " "))
+			goto done
+		}
+		p.applyClientStepUp(ar, clientDetails.Registration)
+		if p.nativeClientHardening && clientDetails.Registration.ApplicationType == oidc.ApplicationTypeNative {
+			if err = p.validateNativeClientHardening(ar, clientDetails.Registration); err != nil {
+				goto done
+			}
+		}
+		if (ar.Flow == oidc.FlowCode || ar.Flow == oidc.FlowHybrid) && clientDetails.Registration.RequiresPKCE(p.requirePKCEForPublicClients) {
+			if ar.CodeChallenge == "" {
+				err = ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "code_challenge required for client")
+				goto done
+			}
+			if ar.CodeChallengeMethod != oidc.S256CodeChallengeMethod {
+				err = ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "code_challenge_method S256 required for client")
+				goto done
+			}
+		}
+	}
+
+	// Require fresh authentication for requested acr_values.
+	p.applyACRStepUp(ar)
+
 	// Inject implicit scopes set by client registration.
 	if registration, _ := p.clients.Get(req.Context(), ar.ClientID); registration != nil {
 		err = registration.ApplyImplicitScopes(ar.Scopes)
@@ -223,6 +375,14 @@ func (p *Provider) AuthorizeResponse(rw http.ResponseWriter, req *http.Request,
 
 	authorizedScopes = auth.AuthorizedScopes()
 
+	// Reject replayed nonces, when an ID token is returned directly.
+	if _, ok := ar.ResponseTypes[oidc.ResponseTypeIDToken]; ok && authorizedScopes[oidc.ScopeOpenID] {
+		if !p.nonces.use(ar.ClientID, ar.Nonce, p.idTokenDurationForClient(ctx, ar.ClientID)) {
+			err = ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "nonce has already been used")
+			goto done
+		}
+	}
+
 	// Create code when requested.
 	if _, ok := ar.ResponseTypes[oidc.ResponseTypeCode]; ok {
 		codeString, err = p.codeManager.Create(&code.Record{
@@ -266,9 +426,10 @@ done:
 	if err != nil {
 		switch err.(type) {
 		case *payload.AuthenticationError:
-			p.Found(rw, ar.RedirectURI, err, ar.UseFragment)
+			err.(*payload.AuthenticationError).Iss = p.issuerIdentifier
+			p.Found(rw, ar.RedirectURI, p.withErrorURI(err), ar.UseFragment)
 		case *payload.AuthenticationBadRequest:
-			p.ErrorPage(rw, http.StatusBadRequest, err.Error(), err.(*payload.AuthenticationBadRequest).Description())
+			p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, err.Error(), err.(*payload.AuthenticationBadRequest).Description())
 		case *identity.RedirectError:
 			p.Found(rw, err.(*identity.RedirectError).RedirectURI(), nil, false)
 		case *identity.LoginRequiredError:
@@ -276,8 +437,9 @@ done:
 		case *identity.IsHandledError:
 			// do nothing
 		case *konnectoidc.OAuth2Error:
-			err = ar.NewError(err.Error(), err.(*konnectoidc.OAuth2Error).Description())
-			p.Found(rw, ar.RedirectURI, err, ar.UseFragment)
+			authenticationErr := ar.NewError(err.Error(), err.(*konnectoidc.OAuth2Error).Description())
+			authenticationErr.Iss = p.issuerIdentifier
+			p.Found(rw, ar.RedirectURI, p.withErrorURI(authenticationErr), ar.UseFragment)
 		default:
 			p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("authorize request failed")
 			p.ErrorPage(rw, http.StatusInternalServerError, err.Error(), "well sorry, but there was a problem")
@@ -300,6 +462,8 @@ done:
 		Scope: strings.Join(authorizedScopesList, " "),
 
 		SessionState: sessionState,
+
+		Iss: p.issuerIdentifier,
 	}
 	if codeString != "" {
 		response.Code = codeString
@@ -307,12 +471,23 @@ done:
 	if accessTokenString != "" {
 		response.AccessToken = accessTokenString
 		response.TokenType = oidc.TokenTypeBearer
-		response.ExpiresIn = int64(p.accessTokenDuration.Seconds())
+		response.ExpiresIn = int64(p.accessTokenDurationForClient(req.Context(), ar.ClientID).Seconds())
 	}
 	if idTokenString != "" {
 		response.IDToken = idTokenString
 	}
 
+	if accessTokenString != "" || idTokenString != "" {
+		p.auditLog(req, &audit.Event{
+			Type:      audit.EventTypeTokenIssued,
+			Outcome:   audit.OutcomeSuccess,
+			Subject:   auth.Subject(),
+			ClientID:  ar.ClientID,
+			GrantType: oidc.GrantTypeImplicit,
+			Scopes:    sortedStrings(authorizedScopesList),
+		})
+	}
+
 	p.Found(rw, ar.RedirectURI, response, ar.UseFragment)
 }
 
@@ -325,14 +500,18 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 	var ar *payload.AuthenticationRequest
 	var auth identity.AuthRecord
 	var session *payload.Session
+	var codeRecord *code.Record
 	var accessTokenString string
 	var idTokenString string
 	var refreshTokenString string
 	var approvedScopes map[string]bool
 	var authorizedScopes map[string]bool
 	var clientDetails *clients.Details
+	var withoutSecret bool
 	signinMethod := p.signingMethodDefault
+	errorStatus := http.StatusBadRequest
 
+	utils.LimitRequestBody(rw, req, p.tokenSizeLimit)
 	rw.Header().Set("Cache-Control", "no-store")
 	rw.Header().Set("Pragma", "no-cache")
 
@@ -349,6 +528,9 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 	// http://openid.net/specs/openid-connect-core-1_0.html#TokenRequestValidation
 	err = req.ParseForm()
 	if err != nil {
+		if errors.Is(err, utils.ErrRequestBodyTooLarge) {
+			errorStatus = http.StatusRequestEntityTooLarge
+		}
 		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		goto done
 	}
@@ -360,14 +542,30 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 
 	err = tr.Validate(func(token *jwt.Token) (interface{}, error) {
 		// Validator for incoming refresh tokens, looks up key.
-		return p.validateJWT(token)
+		return p.validateIssuedJWT(token)
 	}, &konnect.RefreshTokenClaims{})
 	if err != nil {
 		goto done
 	}
 
+	// Client authentication with client assertions.
+	if registration, _ := p.clients.Get(req.Context(), tr.ClientID); registration != nil || tr.ClientAssertion != "" {
+		withoutSecret, err = p.authenticateClientAssertion(tr, registration)
+		if err != nil {
+			err = konnectoidc.NewOAuth2Error(konnectoidc.ErrorCodeOAuth2InvalidClient, err.Error())
+			errorStatus = http.StatusUnauthorized
+			goto done
+		}
+		// Dynamic clients registered without a secret authenticate the code
+		// exchange with their PKCE code verifier, which is validated against
+		// the code challenge of the code below.
+		if !withoutSecret && registration != nil && registration.Dynamic && registration.RawTokenEndpointAuthMethod == oidc.AuthMethodNone && tr.GrantType == oidc.GrantTypeAuthorizationCode && tr.CodeVerifier != "" {
+			withoutSecret = true
+		}
+	}
+
 	// Additional validations according to https://tools.ietf.org/html/rfc6749#section-4.1.3
-	clientDetails, err = p.clients.Lookup(req.Context(), tr.ClientID, tr.ClientSecret, tr.RedirectURI, "", false)
+	clientDetails, err = p.clients.Lookup(req.Context(), tr.ClientID, tr.ClientSecret, tr.RedirectURI, "", withoutSecret)
 	if err != nil {
 		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2AccessDenied, err.Error())
 		goto done
@@ -378,9 +576,15 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 
 	switch tr.GrantType {
 	case oidc.GrantTypeAuthorizationCode:
-		codeRecord, codeRecordFound := p.codeManager.Pop(tr.Code)
-		if !codeRecordFound {
-			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidGrant, "code not found")
+		codeRecord, err = p.codeManager.Pop(tr.Code)
+		if err != nil {
+			if err == code.ErrReplayed {
+				// Revoke all tokens issued for a replayed code, see
+				// https://tools.ietf.org/html/rfc6749#section-4.1.2
+				p.logger.WithField("client_id", tr.ClientID).Warnln("token request with replayed code, revoking issued tokens")
+				p.revokedTokens.revoke(codeRecord.IssuedTokens()...)
+			}
+			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidGrant, err.Error())
 			goto done
 		}
 
@@ -402,6 +606,13 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 			goto done
 		}
 
+		// Ensure that codes of clients which require PKCE are bound to a code
+		// challenge, even if the policy changed after the code was issued.
+		if clientDetails.Registration != nil && clientDetails.Registration.RequiresPKCE(p.requirePKCEForPublicClients) && ar.CodeChallenge == "" {
+			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidGrant, "code_verifier required for client")
+			goto done
+		}
+
 		// Validate code challenge according to https://tools.ietf.org/html/rfc7636#section-4.6
 		if tr.CodeVerifier != "" || ar.CodeChallenge != "" {
 			if codeVerifierErr := oidc.ValidateCodeChallenge(ar.CodeChallenge, ar.CodeChallengeMethod, tr.CodeVerifier); codeVerifierErr != nil {
@@ -422,6 +633,10 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 
 		// Get claims from refresh token.
 		claims := tr.RefreshToken.Claims.(*konnect.RefreshTokenClaims)
+		if p.revokedTokens.isRevoked(claims.Id) {
+			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidGrant, "refresh_token revoked")
+			goto done
+		}
 
 		// Ensure that the authorization code was issued to the client id.
 		if claims.Audience != tr.ClientID {
@@ -457,6 +672,23 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 			}
 		}
 
+		if clientDetails.Registration != nil && len(clientDetails.Registration.AllowedScopes) > 0 {
+			// Reject requested and drop approved scopes which are not allowed
+			// for the client.
+			if disallowed := clientDetails.Registration.DisallowedScopes(tr.Scopes); len(disallowed) > 0 {
+				err = konnectoidc.NewOAuth2Error(konnectoidc.ErrorCodeOAuth2InvalidScope, "scope not allowed for client: "+strings.Join(disallowed, " "))
+				goto done
+			}
+			allowedScopes := make(map[string]bool)
+			for scope, approved := range approvedScopes {
+				allowedScopes[scope] = approved
+			}
+			for _, scope := range clientDetails.Registration.DisallowedScopes(approvedScopes) {
+				delete(allowedScopes, scope)
+			}
+			approvedScopes = allowedScopes
+		}
+
 		if len(tr.Scopes) > 0 {
 			// Make sure all requested scopes are granted and limit authorized
 			// scopes to the requested scopes.
@@ -488,9 +720,10 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 		// Add authorized claims from request.
 		auth.AuthorizeClaims(claims.ApprovedClaimsRequest)
 
-		// Create fake request for token generation.
+		// Create fake request for token generation, for the authenticated
+		// client which the refresh token was checked to be issued to.
 		ar = &payload.AuthenticationRequest{
-			ClientID: claims.Audience,
+			ClientID: tr.ClientID,
 		}
 
 	default:
@@ -514,20 +747,40 @@ func (p *Provider) TokenHandler(rw http.ResponseWriter, req *http.Request) {
 			}
 		}
 
-		// Create refresh token when granted.
-		if authorizedScopes[oidc.ScopeOfflineAccess] {
+		// Create refresh token when granted and the client is registered for
+		// the refresh_token grant.
+		if authorizedScopes[oidc.ScopeOfflineAccess] && (clientDetails.Registration == nil || clientDetails.Registration.AllowsGrantType(oidc.GrantTypeRefreshToken)) {
 			refreshTokenString, err = p.makeRefreshToken(req.Context(), ar.ClientID, auth, nil)
 			if err != nil {
 				goto done
 			}
 		}
+
+		// Remember the issued tokens for the code, so they can be revoked
+		// when the code is replayed.
+		if !codeRecord.Issued(accessTokenString, refreshTokenString) {
+			p.revokedTokens.revoke(accessTokenString, refreshTokenString)
+			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidGrant, code.ErrReplayed.Error())
+			goto done
+		}
 	}
 
 done:
 	if err != nil {
+		event := &audit.Event{
+			Type:    audit.EventTypeTokenIssued,
+			Outcome: audit.OutcomeFailure,
+			Reason:  err.Error(),
+		}
+		if tr != nil {
+			event.GrantType = tr.GrantType
+			event.ClientID = tr.ClientID
+		}
+		p.auditLog(req, event)
+
 		switch err.(type) {
 		case *konnectoidc.OAuth2Error:
-			err = utils.WriteJSON(rw, http.StatusBadRequest, err, "")
+			err = utils.WriteJSON(rw, errorStatus, p.withErrorURI(err), "")
 			if err != nil {
 				p.logger.WithError(err).Errorln("token request failed writing response")
 				return
@@ -546,7 +799,7 @@ done:
 	if accessTokenString != "" {
 		response.AccessToken = accessTokenString
 		response.TokenType = oidc.TokenTypeBearer
-		response.ExpiresIn = int64(p.accessTokenDuration.Seconds())
+		response.ExpiresIn = int64(p.accessTokenDurationForClient(req.Context(), ar.ClientID).Seconds())
 	}
 	if idTokenString != "" {
 		response.IDToken = idTokenString
@@ -555,6 +808,15 @@ done:
 		response.RefreshToken = refreshTokenString
 	}
 
+	p.auditLog(req, &audit.Event{
+		Type:      audit.EventTypeTokenIssued,
+		Outcome:   audit.OutcomeSuccess,
+		Subject:   auth.Subject(),
+		ClientID:  ar.ClientID,
+		GrantType: tr.GrantType,
+		Scopes:    sortedStrings(makeArrayFromBoolMap(authorizedScopes)),
+	})
+
 	err = utils.WriteJSON(rw, http.StatusOK, response, "")
 	if err != nil {
 		p.logger.WithError(err).Errorln("token request failed writing response")
@@ -566,6 +828,8 @@ done:
 func (p *Provider) UserInfoHandler(rw http.ResponseWriter, req *http.Request) {
 	var err error
 	addResponseHeaders(rw.Header())
+	// Userinfo responses contain personal data and must never be stored.
+	rw.Header().Set("Cache-Control", "no-store")
 
 	switch req.Method {
 	case http.MethodHead:
@@ -575,6 +839,8 @@ func (p *Provider) UserInfoHandler(rw http.ResponseWriter, req *http.Request) {
 	case http.MethodGet:
 		// pass
 	default:
+		rw.Header().Set("Allow", "GET, POST, HEAD")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -591,6 +857,7 @@ func (p *Provider) UserInfoHandler(rw http.ResponseWriter, req *http.Request) {
 	var auth identity.AuthRecord
 	var found bool
 	var requestedClaimsMap []*payload.ClaimsRequestMap
+	var userInfoClaimsRequestMap *payload.ClaimsRequestMap
 	var authorizedScopes map[string]bool
 
 	userID, sessionRef := p.getUserIDAndSessionRefFromClaims(&claims.StandardClaims, claims.SessionClaims, claims.IdentityClaims)
@@ -608,7 +875,8 @@ func (p *Provider) UserInfoHandler(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	if claims.AuthorizedClaimsRequest != nil && claims.AuthorizedClaimsRequest.UserInfo != nil {
-		requestedClaimsMap = []*payload.ClaimsRequestMap{claims.AuthorizedClaimsRequest.UserInfo}
+		userInfoClaimsRequestMap = claims.AuthorizedClaimsRequest.UserInfo
+		requestedClaimsMap = []*payload.ClaimsRequestMap{userInfoClaimsRequestMap}
 	}
 
 	authorizedScopes = claims.AuthorizedScopes()
@@ -645,6 +913,8 @@ done:
 			},
 			ProfileClaims: konnectoidc.NewProfileClaims(auth.Claims(oidc.ScopeProfile)[0]),
 			EmailClaims:   konnectoidc.NewEmailClaims(auth.Claims(oidc.ScopeEmail)[0]),
+			PhoneClaims:   konnectoidc.NewPhoneClaims(auth.Claims(konnectoidc.ScopePhone)[0]),
+			AddressClaims: konnectoidc.NewAddressClaims(auth.Claims(konnectoidc.ScopeAddress)[0]),
 		},
 	}
 
@@ -709,10 +979,20 @@ done:
 		}
 	}
 
+	// Never return claims for scopes which were not authorized.
+	payload.FilterClaimsByScopes(responseAsMap, authorizedScopes, userInfoClaimsRequestMap)
+	p.claimTransforms.Apply(responseAsMap)
+	err = p.limitClaims(responseAsMap)
+	if err != nil {
+		p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("userinfo request failed to limit claims")
+		p.ErrorPage(rw, http.StatusInternalServerError, err.Error(), "well sorry, but there was a problem")
+		return
+	}
+
 	// Support returning signed user info if the registered client requested it
 	// as specified in https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse and
 	// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
-	registration, _ := p.clients.Get(req.Context(), claims.Audience)
+	registration, _ := p.clients.Get(req.Context(), clientIDFromAccessTokenClaims(claims))
 	if registration != nil {
 		if registration.RawUserInfoSignedResponseAlg != "" {
 			// Get alg.
@@ -753,19 +1033,19 @@ func (p *Provider) EndSessionHandler(rw http.ResponseWriter, req *http.Request)
 	err = req.ParseForm()
 	if err != nil {
 		p.logger.WithError(err).Errorln("endsession request invalid form data")
-		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		return
 	}
 
 	esr, err := payload.DecodeEndSessionRequest(req, p.metadata)
 	if err != nil {
 		p.logger.WithError(err).Errorln("endsession request invalid request data")
-		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		return
 	}
 	err = esr.Validate(func(token *jwt.Token) (interface{}, error) {
 		// Validator for incoming IDToken hints, looks up key.
-		return p.validateJWT(token)
+		return p.validateIDTokenHint(token)
 	})
 	if err != nil {
 		goto done
@@ -783,7 +1063,7 @@ func (p *Provider) EndSessionHandler(rw http.ResponseWriter, req *http.Request)
 	}
 
 	// Authorization unauthenticates end user.
-	err = currentIdentityManager.EndSession(req.Context(), rw, req, esr)
+	err = currentIdentityManager.EndSession(identity.NewSessionDestroyReasonContext(req.Context(), identity.SessionDestroyReasonEndSession), rw, req, esr)
 	if err != nil {
 		goto done
 	}
@@ -792,7 +1072,7 @@ done:
 	if err != nil {
 		switch err.(type) {
 		case *payload.AuthenticationBadRequest:
-			p.ErrorPage(rw, http.StatusBadRequest, err.Error(), err.(*payload.AuthenticationBadRequest).Description())
+			p.OAuth2ErrorPage(rw, req, http.StatusBadRequest, err.Error(), err.(*payload.AuthenticationBadRequest).Description())
 		case *identity.RedirectError:
 			p.Found(rw, err.(*identity.RedirectError).RedirectURI(), nil, false)
 		case *identity.IsHandledError:
@@ -801,9 +1081,9 @@ done:
 			err = esr.NewError(err.Error(), err.(*konnectoidc.OAuth2Error).Description())
 			uri := esr.MakeRedirectEndSessionRequestURL()
 			if uri == nil {
-				p.ErrorPage(rw, http.StatusForbidden, err.Error(), "oauth2 error")
+				p.OAuth2ErrorPage(rw, req, http.StatusForbidden, err.Error(), "oauth2 error")
 			} else {
-				p.Found(rw, uri, err, false)
+				p.Found(rw, uri, p.withErrorURI(err), false)
 			}
 		default:
 			p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("endsession request failed")
@@ -813,6 +1093,19 @@ done:
 		return
 	}
 
+	event := &audit.Event{
+		Type:    audit.EventTypeSessionDestroyed,
+		Outcome: audit.OutcomeSuccess,
+		Reason:  string(identity.SessionDestroyReasonEndSession),
+	}
+	if esr.IDTokenHint != nil {
+		if claims, ok := esr.IDTokenHint.Claims.(*konnectoidc.IDTokenClaims); ok {
+			event.Subject = claims.Subject
+			event.ClientID = claims.Audience
+		}
+	}
+	p.auditLog(req, event)
+
 	// EndSession Response.
 	response := &payload.AuthenticationSuccess{
 		State: esr.State,
@@ -840,6 +1133,8 @@ func (p *Provider) CheckSessionIframeHandler(rw http.ResponseWriter, req *http.R
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
 	rw.Header().Set("X-XSS-Protection", "1; mode=block")
 	rw.Header().Set("Content-Security-Policy", fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'", nonce))
+	// The check session iframe is embedded by relying parties by design.
+	rw.Header().Del("X-Frame-Options")
 
 	data := struct {
 		CookieName string
@@ -855,13 +1150,18 @@ func (p *Provider) CheckSessionIframeHandler(rw http.ResponseWriter, req *http.R
 // with OpenID Connect Registration 1.0 as specified at
 // https://openid.net/specs/openid-connect-registration-1_0.html#ClientRegistration
 func (p *Provider) RegistrationHandler(rw http.ResponseWriter, req *http.Request) {
-	req.Body = http.MaxBytesReader(rw, req.Body, registrationSizeLimit)
+	utils.LimitRequestBody(rw, req, p.registrationSizeLimit)
 	addResponseHeaders(rw.Header())
 
 	crr, err := payload.DecodeClientRegistrationRequest(req)
 	if err != nil {
 		p.logger.WithError(err).Errorln("client registration request failed to decode request data")
+		registrationRejectionsTotal.WithLabelValues(oidc.ErrorCodeOAuth2InvalidRequest).Inc()
 
+		if errors.Is(err, utils.ErrRequestBodyTooLarge) {
+			p.ErrorPage(rw, http.StatusRequestEntityTooLarge, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+			return
+		}
 		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
 		return
 	}
@@ -878,7 +1178,7 @@ func (p *Provider) RegistrationHandler(rw http.ResponseWriter, req *http.Request
 	}
 
 	// Validate request.
-	err = crr.Validate()
+	err = crr.Validate(p.registrationPolicy)
 	if err != nil {
 		goto done
 	}
@@ -897,9 +1197,10 @@ func (p *Provider) RegistrationHandler(rw http.ResponseWriter, req *http.Request
 
 done:
 	if err != nil {
-		switch err.(type) {
+		switch typedErr := err.(type) {
 		case *konnectoidc.OAuth2Error:
-			err = utils.WriteJSON(rw, http.StatusBadRequest, err, "")
+			registrationRejectionsTotal.WithLabelValues(typedErr.ErrorID).Inc()
+			err = utils.WriteJSON(rw, http.StatusBadRequest, p.withErrorURI(err), "")
 			if err != nil {
 				p.logger.WithError(err).Errorln("client registration request failed writing response")
 				return
@@ -918,6 +1219,7 @@ done:
 		"application_type": cr.ApplicationType,
 		"redirect_uris":    cr.RedirectURIs,
 	}).Debugln("registered dynamic client")
+	registrationsTotal.WithLabelValues(registrationOperationCreated).Inc()
 
 	response := &payload.ClientRegistrationResponse{
 		ClientID:     cr.ID,
@@ -928,9 +1230,72 @@ done:
 
 		ClientRegistrationRequest: *crr,
 	}
+	if p.nativeClientHardening && !cr.HasClientSecret() {
+		// Do not hand out a secret which the client must not use.
+		response.ClientSecret = ""
+	}
 
 	err = utils.WriteJSON(rw, http.StatusCreated, response, "")
 	if err != nil {
 		p.logger.WithError(err).Errorln("client registration request failed writing response")
 	}
 }
+
+// RegistrationValidateHandler implements the HTTP endpoint to validate client
+// registration requests without registering a client. It returns the
+// normalized client metadata as it would be registered.
+func (p *Provider) RegistrationValidateHandler(rw http.ResponseWriter, req *http.Request) {
+	utils.LimitRequestBody(rw, req, p.registrationSizeLimit)
+	addResponseHeaders(rw.Header())
+
+	crr, err := payload.DecodeClientRegistrationRequest(req)
+	if err != nil {
+		p.logger.WithError(err).Debugln("client registration validate request failed to decode request data")
+		if errors.Is(err, utils.ErrRequestBodyTooLarge) {
+			p.ErrorPage(rw, http.StatusRequestEntityTooLarge, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+			return
+		}
+		p.ErrorPage(rw, http.StatusBadRequest, oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		return
+	}
+
+	// Validate request method
+	switch req.Method {
+	case http.MethodPost:
+		// breaks
+	default:
+		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidRequest, "request must be sent with POST")
+		goto done
+	}
+
+	// Validate request and ensure it converts to a registration record.
+	err = crr.Validate(p.registrationPolicy)
+	if err != nil {
+		goto done
+	}
+	_, err = crr.ClientRegistration()
+	if err != nil {
+		goto done
+	}
+
+done:
+	if err != nil {
+		switch err.(type) {
+		case *konnectoidc.OAuth2Error:
+			err = utils.WriteJSON(rw, http.StatusBadRequest, p.withErrorURI(err), "")
+			if err != nil {
+				p.logger.WithError(err).Errorln("client registration validate request failed writing response")
+			}
+		default:
+			p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("client registration validate request failed")
+			p.ErrorPage(rw, http.StatusInternalServerError, err.Error(), "well sorry, but there was a problem")
+		}
+
+		return
+	}
+
+	err = utils.WriteJSON(rw, http.StatusOK, crr, "")
+	if err != nil {
+		p.logger.WithError(err).Errorln("client registration validate request failed writing response")
+	}
+}
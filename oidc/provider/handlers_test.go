@@ -18,14 +18,37 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/libregraph/oidc-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/libregraph/lico/audit"
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/code"
+	codeManagers "github.com/libregraph/lico/oidc/code/managers"
+	"github.com/libregraph/lico/oidc/payload"
 )
 
 func TestWellKnownHandler(t *testing.T) {
@@ -105,4 +128,2233 @@ func TestWellKnownHandler(t *testing.T) {
 	if len(wellKnown.IDTokenSigningAlgValuesSupported) == 0 {
 		t.Errorf("IDTokenSigningAlgValuesSupported must not be empty")
 	}
+
+	response := &wellKnownResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		t.Fatal(err)
+	}
+	if !response.AuthorizationResponseIssParameterSupported {
+		t.Errorf("AuthorizationResponseIssParameterSupported must be true")
+	}
+	if expected := []string{oidc.PromptNone, oidc.PromptLogin, oidc.PromptConsent, oidc.PromptSelectAccount}; !reflect.DeepEqual(response.PromptValuesSupported, expected) {
+		t.Errorf("PromptValuesSupported was incorrect, got %v, want %v", response.PromptValuesSupported, expected)
+	}
+
+	provider.identityManager = &identityManagerWithPrompts{provider.identityManager, []string{konnectoidc.PromptCreate}}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	response = &wellKnownResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{oidc.PromptNone, oidc.PromptLogin, oidc.PromptConsent, oidc.PromptSelectAccount, konnectoidc.PromptCreate}; !reflect.DeepEqual(response.PromptValuesSupported, expected) {
+		t.Errorf("PromptValuesSupported was incorrect with additional prompts, got %v, want %v", response.PromptValuesSupported, expected)
+	}
+}
+
+type identityManagerWithPrompts struct {
+	identity.Manager
+	prompts []string
+}
+
+func (im *identityManagerWithPrompts) PromptsSupported() []string {
+	return im.prompts
+}
+
+func TestWellKnownHandlerResourceServers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	fetch := func() map[string]json.RawMessage {
+		req := httptest.NewRequest(http.MethodGet, config.WellKnownPath, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(rr.Body.Bytes(), &fields); err != nil {
+			t.Fatal(err)
+		}
+		return fields
+	}
+
+	if _, ok := fetch()["resource_servers"]; ok {
+		t.Errorf("resource_servers must be omitted when no resource servers are configured")
+	}
+
+	for _, resourceServer := range []*clients.ResourceServer{
+		{ID: "https://files.example.com", Scopes: []string{"files"}},
+		{ID: "https://api.example.com", Scopes: []string{"api.read", "api.write"}},
+	} {
+		if err := provider.clients.RegisterResourceServer(resourceServer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var resourceServers []*clients.ResourceServer
+	if err := json.Unmarshal(fetch()["resource_servers"], &resourceServers); err != nil {
+		t.Fatal(err)
+	}
+	if len(resourceServers) != 2 {
+		t.Fatalf("expected 2 resource servers, got %d", len(resourceServers))
+	}
+	if resourceServers[0].ID != "https://api.example.com" || strings.Join(resourceServers[0].Scopes, " ") != "api.read api.write" {
+		t.Errorf("unexpected first resource server: %+v", resourceServers[0])
+	}
+	if resourceServers[1].ID != "https://files.example.com" || strings.Join(resourceServers[1].Scopes, " ") != "files" {
+		t.Errorf("unexpected second resource server: %+v", resourceServers[1])
+	}
+}
+
+func TestOAuthMetadataHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	req, err := http.NewRequest("GET", config.OAuthMetadataPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	metadata := &konnectoidc.AuthorizationServerMetadata{}
+	if err := json.Unmarshal(rr.Body.Bytes(), metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Issuer != config.IssuerIdentifier {
+		t.Errorf("Issuer identifier was incorrect, got %s, want %s", metadata.Issuer, config.IssuerIdentifier)
+	}
+	if metadata.AuthorizationEndpoint != provider.makeIssURL(config.AuthorizationPath) {
+		t.Errorf("AuthorizationEndpoint was incorrect, got %s, want %s", metadata.AuthorizationEndpoint, provider.makeIssURL(config.AuthorizationPath))
+	}
+	if metadata.TokenEndpoint != provider.makeIssURL(config.TokenPath) {
+		t.Errorf("TokenEndpoint was incorrect, got %s, want %s", metadata.TokenEndpoint, provider.makeIssURL(config.TokenPath))
+	}
+	if metadata.JwksURI != provider.makeIssURL(config.JwksPath) {
+		t.Errorf("JwksURI was incorrect, got %s, want %s", metadata.JwksURI, provider.makeIssURL(config.JwksPath))
+	}
+	if !metadata.AuthorizationResponseIssParameterSupported {
+		t.Errorf("AuthorizationResponseIssParameterSupported must be true")
+	}
+
+	for name, values := range map[string][2][]string{
+		"scopes_supported":                      {metadata.ScopesSupported, provider.metadata.ScopesSupported},
+		"response_types_supported":              {metadata.ResponseTypesSupported, provider.metadata.ResponseTypesSupported},
+		"token_endpoint_auth_methods_supported": {metadata.TokenEndpointAuthMethodsSupported, provider.metadata.TokenEndpointAuthMethodsSupported},
+	} {
+		if strings.Join(values[0], " ") != strings.Join(values[1], " ") {
+			t.Errorf("%s was incorrect, got %v, want %v", name, values[0], values[1])
+		}
+	}
+
+	grantTypes := strings.Join(metadata.GrantTypesSupported, " ")
+	for _, grantType := range []string{oidc.GrantTypeAuthorizationCode, oidc.GrantTypeRefreshToken} {
+		if !strings.Contains(grantTypes, grantType) {
+			t.Errorf("grant_types_supported is missing %s, got %v", grantType, metadata.GrantTypesSupported)
+		}
+	}
+}
+
+func TestWellKnownHandlerClientAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, tc := range []struct {
+		name             string
+		signingAlgs      []string
+		registrationPath string
+		methods          []string
+		clientSecretJWT  bool
+	}{
+		{"defaults", nil, "", []string{oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost, oidc.AuthMethodClientSecretJWT, oidc.AuthMethodNone}, true},
+		{"without hmac", []string{jwt.SigningMethodRS256.Alg()}, "", []string{oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost, oidc.AuthMethodNone}, false},
+		{"with registration", nil, "/konnect/v1/register", []string{oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost, oidc.AuthMethodNone}, true},
+		{"with registration without hmac", []string{jwt.SigningMethodRS256.Alg()}, "/konnect/v1/register", []string{oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost, oidc.AuthMethodNone}, false},
+	} {
+		httpServer, provider, router, cfg := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:                   logger,
+			AllowedClientSigningAlgs: tc.signingAlgs,
+		})
+		defer httpServer.Close()
+		provider.registrationPath = tc.registrationPath
+		if err := provider.InitializeMetadata(); err != nil {
+			t.Fatal(err)
+		}
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+			t.Fatal(err)
+		}
+
+		registry, err := clients.NewRegistry(ctx, nil, "", tc.registrationPath != "", 0, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		registry.StatelessCreator = provider.makeJWT
+		registry.StatelessValidator = provider.validateJWT
+		provider.clients = registry
+		registration := &clients.ClientRegistration{
+			ID:           "client",
+			Secret:       "client-secret-value",
+			RedirectURIs: []string{"https://client.example.com/cb"},
+		}
+		if err = registry.Register(registration); err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, cfg.WellKnownPath, nil))
+		wellKnown := &oidc.WellKnown{}
+		if err = json.Unmarshal(rr.Body.Bytes(), wellKnown); err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := provider.makeIssURL(tc.registrationPath); wellKnown.RegistrationEndpoint != expected {
+			t.Errorf("%s: registration_endpoint was incorrect, got %q, want %q", tc.name, wellKnown.RegistrationEndpoint, expected)
+		}
+		if strings.Join(wellKnown.TokenEndpointAuthMethodsSupported, " ") != strings.Join(tc.methods, " ") {
+			t.Errorf("%s: token_endpoint_auth_methods_supported was incorrect, got %v, want %v", tc.name, wellKnown.TokenEndpointAuthMethodsSupported, tc.methods)
+		}
+
+		// Every advertised secret based method must authenticate the client.
+		advertised := make(map[string]bool)
+		for _, method := range wellKnown.TokenEndpointAuthMethodsSupported {
+			advertised[method] = true
+		}
+		redirectURI, _ := url.Parse("https://client.example.com/cb")
+		for _, method := range []string{oidc.AuthMethodClientSecretBasic, oidc.AuthMethodClientSecretPost} {
+			form := url.Values{}
+			form.Set("grant_type", oidc.GrantTypeAuthorizationCode)
+			if method == oidc.AuthMethodClientSecretPost {
+				form.Set("client_id", registration.ID)
+				form.Set("client_secret", registration.Secret)
+			}
+			req := httptest.NewRequest(http.MethodPost, cfg.TokenPath, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if method == oidc.AuthMethodClientSecretBasic {
+				req.SetBasicAuth(registration.ID, registration.Secret)
+			}
+			if err = req.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			tr, err := payload.DecodeTokenRequest(req, provider.metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = registry.Lookup(ctx, tr.ClientID, tr.ClientSecret, redirectURI, "", false)
+			if advertised[method] && err != nil {
+				t.Errorf("%s: advertised method %s failed: %v", tc.name, method, err)
+			}
+		}
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Issuer:    registration.ID,
+			Subject:   registration.ID,
+			Audience:  jwt.ClaimStrings{provider.metadata.TokenEndpoint},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        "jti-" + tc.name,
+		}).SignedString([]byte(registration.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = provider.validateClientSecretJWT(&payload.TokenRequest{ClientAssertion: s}, registration)
+		if tc.clientSecretJWT && err != nil {
+			t.Errorf("%s: method %s failed: %v", tc.name, oidc.AuthMethodClientSecretJWT, err)
+		} else if !tc.clientSecretJWT && err == nil {
+			t.Errorf("%s: method %s was accepted without allowed hmac alg", tc.name, oidc.AuthMethodClientSecretJWT)
+		}
+
+		if tc.registrationPath == "" {
+			continue
+		}
+		// Every advertised method must be accepted for dynamic clients, and
+		// their secret must authenticate them.
+		for _, method := range wellKnown.TokenEndpointAuthMethodsSupported {
+			req := httptest.NewRequest(http.MethodPost, "http://localhost:8777"+tc.registrationPath, strings.NewReader(`{"redirect_uris": ["https://client.example.com/cb"], "token_endpoint_auth_method": "`+method+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			provider.RegistrationHandler(rr, req)
+			if rr.Code != http.StatusCreated {
+				t.Errorf("%s: registration with advertised method %s failed: %v %v", tc.name, method, rr.Code, rr.Body.String())
+				continue
+			}
+			response := &payload.ClientRegistrationResponse{}
+			if err = json.Unmarshal(rr.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+			if method == oidc.AuthMethodNone {
+				continue
+			}
+			if _, err = registry.Lookup(ctx, response.ClientID, response.ClientSecret, redirectURI, "", false); err != nil {
+				t.Errorf("%s: dynamic client with method %s failed to authenticate: %v", tc.name, method, err)
+			}
+		}
+	}
+}
+
+func TestWellKnownHandlerForwardedPrefix(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	trustedIP := net.ParseIP("192.0.2.1")
+	httpServer, provider, router, config := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:          logger,
+		TrustedProxyIPs: []*net.IP{&trustedIP},
+	})
+	defer httpServer.Close()
+
+	for _, tc := range []struct {
+		remoteAddr string
+		prefix     string
+		expected   string
+	}{
+		{"192.0.2.1:1234", "/auth", "/auth"},
+		{"192.0.2.1:1234", "/auth/", "/auth"},
+		{"192.0.2.1:1234", "", ""},
+		{"198.51.100.1:1234", "/auth", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, config.WellKnownPath, nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.prefix != "" {
+			req.Header.Set("X-Forwarded-Prefix", tc.prefix)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		wellKnown := &oidc.WellKnown{}
+		if err := json.Unmarshal(rr.Body.Bytes(), wellKnown); err != nil {
+			t.Fatal(err)
+		}
+
+		if wellKnown.Issuer != config.IssuerIdentifier {
+			t.Errorf("Issuer identifier must not change with prefix, got %s, want %s", wellKnown.Issuer, config.IssuerIdentifier)
+		}
+		for name, values := range map[string][2]string{
+			"authorization_endpoint": {wellKnown.AuthorizationEndpoint, config.AuthorizationPath},
+			"token_endpoint":         {wellKnown.TokenEndpoint, config.TokenPath},
+			"userinfo_endpoint":      {wellKnown.UserInfoEndpoint, config.UserInfoPath},
+			"jwks_uri":               {wellKnown.JwksURI, config.JwksPath},
+		} {
+			if want := provider.makeIssURL(tc.expected + values[1]); values[0] != want {
+				t.Errorf("%s was incorrect for %v with prefix %q, got %s, want %s", name, tc.remoteAddr, tc.prefix, values[0], want)
+			}
+		}
+	}
+}
+
+func TestJwksHandlerCaching(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	provider.jwksMaxAge = 5 * time.Minute
+
+	fetch := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/jwks.json", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		provider.JwksHandler(rr, req)
+		return rr
+	}
+
+	rr := fetch("")
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("jwks handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "public, max-age=300" {
+		t.Errorf("jwks handler returned unexpected Cache-Control: %v", cacheControl)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("jwks handler returned no strong ETag: %v", etag)
+	}
+
+	rr = fetch(etag)
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("jwks handler returned wrong status code for matching ETag: got %v want %v", status, http.StatusNotModified)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("jwks handler returned body with not modified response")
+	}
+
+	rr = fetch(`"other", ` + etag)
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("jwks handler returned wrong status code for ETag list: got %v want %v", status, http.StatusNotModified)
+	}
+
+	// Simulate key rotation by adding another validation key.
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = provider.SetValidationKey("rotated", rotatedKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = fetch(etag)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("jwks handler returned wrong status code after rotation: got %v want %v", status, http.StatusOK)
+	}
+	if rotatedETag := rr.Header().Get("ETag"); rotatedETag == etag {
+		t.Errorf("jwks handler returned unchanged ETag after rotation")
+	}
+}
+
+func TestUserInfoHandlerFiltersClaimsByScope(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create our server.
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	for _, tc := range []struct {
+		scopes    map[string]bool
+		withEmail bool
+	}{
+		{map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeProfile: true}, false},
+		{map[string]bool{oidc.ScopeOpenID: true, oidc.ScopeEmail: true}, true},
+	} {
+		ar := &payload.AuthenticationRequest{
+			Scopes: tc.scopes,
+		}
+		auth, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth.AuthorizeScopes(tc.scopes)
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		accessToken, err := provider.makeAccessToken(ctx, "unittest", auth, jwt.SigningMethodRS256)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("GET", config.UserInfoPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		userInfo := make(map[string]interface{})
+		if err := json.Unmarshal(rr.Body.Bytes(), &userInfo); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := userInfo[oidc.SubjectIdentifierClaim]; !ok {
+			t.Errorf("sub claim missing in userinfo response: %v", userInfo)
+		}
+		if _, ok := userInfo[oidc.EmailClaim]; ok != tc.withEmail {
+			t.Errorf("email claim presence was incorrect for scopes %v, got %v", tc.scopes, userInfo)
+		}
+	}
+}
+
+func TestUserInfoHandlerSignedResponseForResourceServerToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, cfg := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger: logger,
+	})
+	defer httpServer.Close()
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	if err = registry.RegisterResourceServer(&clients.ResourceServer{ID: "https://api1.example.com", Scopes: []string{"api1.read"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:                           "client",
+		RedirectURIs:                 []string{"https://client.example.com/cb"},
+		ResourceServers:              []string{"https://api1.example.com"},
+		RawUserInfoSignedResponseAlg: jwt.SigningMethodRS256.Alg(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scopes := map[string]bool{oidc.ScopeOpenID: true, "api1.read": true}
+	authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, &payload.AuthenticationRequest{Scopes: scopes}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), scopes, nil, nil)
+	auth.SetUser(authenticated.User())
+
+	// The access token audience is the resource server, not the client.
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	accessToken, err := provider.makeAccessToken(ctx, "client", auth, jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, cfg.UserInfoPath, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("userinfo returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if value := rr.Header().Get("Content-Type"); value != "application/jwt" {
+		t.Fatalf("userinfo returned wrong Content-Type for client with signed response: %v", value)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(rr.Body.String(), claims); err != nil {
+		t.Fatal(err)
+	}
+	if aud := claims[oidc.AudienceClaim]; aud != "client" {
+		t.Errorf("signed userinfo has wrong audience: %v", aud)
+	}
+}
+
+func TestUserInfoHandlerHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	scopes := map[string]bool{oidc.ScopeOpenID: true}
+	auth, err := provider.identityManager.Authenticate(ctx, nil, nil, &payload.AuthenticationRequest{Scopes: scopes}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.AuthorizeScopes(scopes)
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	accessToken, err := provider.makeAccessToken(ctx, "unittest", auth, jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req := httptest.NewRequest(method, config.UserInfoPath, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Origin", "https://spa.example.com")
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("%s userinfo returned wrong status code: got %v want %v", method, status, http.StatusOK)
+		}
+		if value := rr.Header().Get("Cache-Control"); value != "no-store" {
+			t.Errorf("%s userinfo returned wrong Cache-Control header: %v", method, value)
+		}
+		if value := rr.Header().Get("Pragma"); value != "no-cache" {
+			t.Errorf("%s userinfo returned wrong Pragma header: %v", method, value)
+		}
+		if value := rr.Header().Get("Access-Control-Allow-Origin"); value != "https://spa.example.com" {
+			t.Errorf("%s userinfo returned wrong Access-Control-Allow-Origin header: %v", method, value)
+		}
+	}
+
+	// Preflight must allow the Authorization header.
+	req := httptest.NewRequest(http.MethodOptions, config.UserInfoPath, nil)
+	req.Header.Set("Origin", "https://spa.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	if value := rr.Header().Get("Access-Control-Allow-Origin"); value != "https://spa.example.com" {
+		t.Errorf("userinfo preflight returned wrong Access-Control-Allow-Origin header: %v", value)
+	}
+	if value := rr.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(value, "Authorization") {
+		t.Errorf("userinfo preflight does not allow Authorization header: %v", value)
+	}
+}
+
+func TestRegistrationHandlerRequestBodySizeLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	body := `{"redirect_uris": ["https://example.com/cb"], "client_name": "` + strings.Repeat("a", registrationSizeLimit) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	provider.RegistrationHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+		t.Errorf("registration handler returned wrong status code: got %v want %v", status, http.StatusRequestEntityTooLarge)
+	}
+	if !strings.Contains(rr.Body.String(), oidc.ErrorCodeOAuth2InvalidRequest) {
+		t.Errorf("registration handler returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestRegistrationHandlerRejectionMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	before := testutil.ToFloat64(registrationRejectionsTotal.WithLabelValues(oidc.ErrorCodeOIDCInvalidRedirectURI))
+	otherBefore := testutil.ToFloat64(registrationRejectionsTotal.WithLabelValues(oidc.ErrorCodeOIDCInvalidClientMetadata))
+
+	body := `{"redirect_uris": ["http://client.example.com/cb"], "application_type": "native"}`
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	provider.RegistrationHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("registration handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if after := testutil.ToFloat64(registrationRejectionsTotal.WithLabelValues(oidc.ErrorCodeOIDCInvalidRedirectURI)); after != before+1 {
+		t.Errorf("expected %s rejection counter to be incremented, got %v want %v", oidc.ErrorCodeOIDCInvalidRedirectURI, after, before+1)
+	}
+	if after := testutil.ToFloat64(registrationRejectionsTotal.WithLabelValues(oidc.ErrorCodeOIDCInvalidClientMetadata)); after != otherBefore {
+		t.Errorf("expected %s rejection counter to be unchanged, got %v", oidc.ErrorCodeOIDCInvalidClientMetadata, after)
+	}
+}
+
+func TestRegistrationValidateHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	validate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/register/validate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		provider.RegistrationValidateHandler(rr, req)
+		return rr
+	}
+
+	rr := validate(`{"redirect_uris": ["https://client.example.com/cb"]}`)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("registration validate handler returned wrong status code: got %v want %v (%v)", status, http.StatusOK, rr.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	for field, expected := range map[string]interface{}{
+		"response_types":             []interface{}{oidc.ResponseTypeCode},
+		"grant_types":                []interface{}{oidc.GrantTypeAuthorizationCode},
+		"application_type":           oidc.ApplicationTypeWeb,
+		"token_endpoint_auth_method": oidc.AuthMethodClientSecretBasic,
+	} {
+		if fmt.Sprint(response[field]) != fmt.Sprint(expected) {
+			t.Errorf("registration validate handler returned wrong %s: got %v want %v", field, response[field], expected)
+		}
+	}
+	for _, field := range []string{"client_id", "client_secret"} {
+		if _, ok := response[field]; ok {
+			t.Errorf("registration validate handler must not return %s", field)
+		}
+	}
+
+	rr = validate(`{"redirect_uris": ["http://client.example.com/cb"], "application_type": "native"}`)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("registration validate handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	var errResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &errResponse); err != nil {
+		t.Fatal(err)
+	}
+	if errResponse.Error != oidc.ErrorCodeOIDCInvalidRedirectURI || errResponse.ErrorDescription == "" {
+		t.Errorf("registration validate handler returned unexpected error: %v", rr.Body.String())
+	}
+}
+
+func TestTokenHandlerRequestBodySizeLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	body := "grant_type=authorization_code&code=" + strings.Repeat("a", tokenSizeLimit)
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	provider.TokenHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+		t.Errorf("token handler returned wrong status code: got %v want %v", status, http.StatusRequestEntityTooLarge)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["error"] != oidc.ErrorCodeOAuth2InvalidRequest {
+		t.Errorf("token handler returned unexpected error: %v", response["error"])
+	}
+}
+
+func TestTokenHandlerErrorURI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	for _, documentationURI := range []string{"", "https://docs.example.com/lico/errors"} {
+		provider.errorDocumentationURI = nil
+		if documentationURI != "" {
+			provider.errorDocumentationURI, _ = url.Parse(documentationURI)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/token", nil)
+		rr := httptest.NewRecorder()
+
+		provider.TokenHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("token handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response["error"] != oidc.ErrorCodeOAuth2InvalidRequest {
+			t.Errorf("token handler returned unexpected error: %v", response["error"])
+		}
+		if documentationURI == "" {
+			if _, ok := response["error_uri"]; ok {
+				t.Errorf("token handler returned error_uri without documentation URI: %v", response["error_uri"])
+			}
+		} else if response["error_uri"] != documentationURI+"/"+oidc.ErrorCodeOAuth2InvalidRequest {
+			t.Errorf("token handler returned unexpected error_uri: %v", response["error_uri"])
+		}
+	}
+}
+
+func TestAuthorizeResponseNonceReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	authorize := func(clientID string, nonce string) url.Values {
+		values := url.Values{}
+		values.Set("client_id", clientID)
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("nonce", nonce)
+		ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		auth, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth.AuthorizeScopes(ar.Scopes)
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+		provider.AuthorizeResponse(rr, req, ar, auth, nil)
+
+		if status := rr.Code; status != http.StatusFound {
+			t.Fatalf("authorize response returned wrong status code: got %v want %v", status, http.StatusFound)
+		}
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fragment, err := url.ParseQuery(location.Fragment)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return fragment
+	}
+
+	// First use is accepted.
+	result := authorize("client1", "nonce1")
+	if result.Get("id_token") == "" || result.Get("error") != "" {
+		t.Errorf("expected id_token for first use of nonce, got %v", result)
+	}
+
+	// Replay of the same nonce by the same client is rejected.
+	result = authorize("client1", "nonce1")
+	if result.Get("id_token") != "" || result.Get("error") != oidc.ErrorCodeOAuth2InvalidRequest {
+		t.Errorf("expected replayed nonce to be rejected, got %v", result)
+	}
+
+	// Nonces are tracked per client.
+	result = authorize("client2", "nonce1")
+	if result.Get("id_token") == "" {
+		t.Errorf("expected id_token for nonce of other client, got %v", result)
+	}
+}
+
+func TestTokenHandlerOfflineAccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	for _, client := range []*clients.ClientRegistration{
+		{ID: "client-any", RedirectURIs: []string{"https://client.example.com/cb"}},
+		{ID: "client-refresh", RedirectURIs: []string{"https://client.example.com/cb"}, GrantTypes: []string{oidc.GrantTypeAuthorizationCode, oidc.GrantTypeRefreshToken}},
+		{ID: "client-code", RedirectURIs: []string{"https://client.example.com/cb"}, GrantTypes: []string{oidc.GrantTypeAuthorizationCode}},
+	} {
+		if err = registry.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exchange := func(clientID string, scope string) map[string]interface{} {
+		values := url.Values{}
+		values.Set("client_id", clientID)
+		values.Set("scope", scope)
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("prompt", oidc.PromptConsent)
+		ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The dummy identity manager does not support offline_access, so
+		// authorize the requested scopes directly.
+		auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), ar.Scopes, nil, nil)
+		auth.SetUser(authenticated.User())
+
+		code, err := provider.codeManager.Create(&code.Record{
+			AuthenticationRequest: ar,
+			Auth:                  auth,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		form := url.Values{}
+		form.Set("grant_type", oidc.GrantTypeAuthorizationCode)
+		form.Set("code", code)
+		form.Set("client_id", clientID)
+		form.Set("redirect_uri", "https://client.example.com/cb")
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		provider.TokenHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("token handler returned wrong status code: got %v want %v: %s", status, http.StatusOK, rr.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+
+		return response
+	}
+
+	tests := []struct {
+		clientID string
+		scope    string
+		refresh  bool
+	}{
+		{"client-any", "openid offline_access", true},
+		{"client-any", "openid", false},
+		{"client-refresh", "openid offline_access", true},
+		{"client-code", "openid offline_access", false},
+	}
+
+	for _, test := range tests {
+		response := exchange(test.clientID, test.scope)
+		if response["access_token"] == nil {
+			t.Errorf("token response without access_token for %s with %q", test.clientID, test.scope)
+		}
+		if _, ok := response["refresh_token"]; ok != test.refresh {
+			t.Errorf("unexpected refresh_token presence for %s with %q: got %v want %v", test.clientID, test.scope, ok, test.refresh)
+		}
+	}
+}
+
+func newTestTokenProviderWithCode(ctx context.Context, t *testing.T, codeDuration time.Duration) (*Provider, http.Handler, *Config, func(scope string) string) {
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	t.Cleanup(httpServer.Close)
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+	provider.codeManager = codeManagers.NewMemoryMapManager(ctx, codeDuration)
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	if err = registry.Register(&clients.ClientRegistration{ID: "client-code", RedirectURIs: []string{"https://client.example.com/cb"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	createCode := func(scope string) string {
+		values := url.Values{}
+		values.Set("client_id", "client-code")
+		values.Set("scope", scope)
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), ar.Scopes, nil, nil)
+		auth.SetUser(authenticated.User())
+
+		code, err := provider.codeManager.Create(&code.Record{
+			AuthenticationRequest: ar,
+			Auth:                  auth,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return code
+	}
+
+	return provider, router, config, createCode
+}
+
+func redeemTestCode(t *testing.T, provider *Provider, code string) (int, map[string]interface{}) {
+	form := url.Values{}
+	form.Set("grant_type", oidc.GrantTypeAuthorizationCode)
+	form.Set("code", code)
+	form.Set("client_id", "client-code")
+	form.Set("redirect_uri", "https://client.example.com/cb")
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	provider.TokenHandler(rr, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return rr.Code, response
+}
+
+func TestTokenHandlerAuditEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, createCode := newTestTokenProviderWithCode(ctx, t, 0)
+	var buf bytes.Buffer
+	provider.auditLogger = audit.NewJSONLogger(&buf)
+
+	status, response := redeemTestCode(t, provider, createCode("openid offline_access"))
+	if status != http.StatusOK {
+		t.Fatalf("token handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", oidc.GrantTypeRefreshToken)
+	form.Set("refresh_token", response["refresh_token"].(string))
+	form.Set("client_id", "client-code")
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	provider.TokenHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("refresh returned wrong status code: got %v want %v: %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	decoder := json.NewDecoder(&buf)
+	for _, grantType := range []string{oidc.GrantTypeAuthorizationCode, oidc.GrantTypeRefreshToken} {
+		var event audit.Event
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("failed to decode audit event: %v", err)
+		}
+		if event.Type != audit.EventTypeTokenIssued || event.Outcome != audit.OutcomeSuccess || event.GrantType != grantType {
+			t.Errorf("unexpected audit event for %s grant: %+v", grantType, event)
+		}
+		if event.ClientID != "client-code" {
+			t.Errorf("audit event for %s grant has wrong client_id, got %q", grantType, event.ClientID)
+		}
+	}
+}
+
+func TestTokenHandlerAuthorizationCodeReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, router, config, createCode := newTestTokenProviderWithCode(ctx, t, 0)
+
+	code := createCode("openid profile offline_access")
+	status, response := redeemTestCode(t, provider, code)
+	if status != http.StatusOK {
+		t.Fatalf("token handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	accessToken, _ := response["access_token"].(string)
+	refreshToken, _ := response["refresh_token"].(string)
+	if accessToken == "" || refreshToken == "" {
+		t.Fatalf("token response without access_token or refresh_token: %v", response)
+	}
+
+	userInfo := func() int {
+		req := httptest.NewRequest(http.MethodGet, config.UserInfoPath, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if status := userInfo(); status != http.StatusOK {
+		t.Fatalf("userinfo handler returned wrong status code before replay: got %v want %v", status, http.StatusOK)
+	}
+
+	status, response = redeemTestCode(t, provider, code)
+	if status != http.StatusBadRequest {
+		t.Fatalf("token handler returned wrong status code for replayed code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if response["error"] != oidc.ErrorCodeOAuth2InvalidGrant {
+		t.Errorf("unexpected error for replayed code: %v", response)
+	}
+
+	// Tokens issued for the replayed code must be revoked.
+	if status := userInfo(); status != http.StatusUnauthorized {
+		t.Errorf("userinfo handler returned wrong status code after replay: got %v want %v", status, http.StatusUnauthorized)
+	}
+	refreshTokenID, _, _ := tokenIDAndExpiration(refreshToken)
+	if !provider.revokedTokens.isRevoked(refreshTokenID) {
+		t.Errorf("refresh token issued for replayed code was not revoked")
+	}
+}
+
+func TestTokenHandlerAuthorizationCodeExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, createCode := newTestTokenProviderWithCode(ctx, t, 10*time.Millisecond)
+
+	code := createCode("openid")
+	time.Sleep(20 * time.Millisecond)
+
+	status, response := redeemTestCode(t, provider, code)
+	if status != http.StatusBadRequest {
+		t.Fatalf("token handler returned wrong status code for expired code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if response["error"] != oidc.ErrorCodeOAuth2InvalidGrant {
+		t.Errorf("unexpected error for expired code: %v", response)
+	}
+}
+
+func TestJwksHandlerKeyMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = provider.SetValidationKey("validation-ec", &ecKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = provider.SetValidationKey("validation-ed", edKey.Public()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/jwks.json", nil)
+	rr := httptest.NewRecorder()
+	provider.JwksHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("jwks handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	jwks := struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}{}
+	if err = json.Unmarshal(rr.Body.Bytes(), &jwks); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"default":       jwt.SigningMethodRS256.Alg(),
+		"validation-ec": jwt.SigningMethodES384.Alg(),
+		"validation-ed": "EdDSA",
+	}
+	if len(jwks.Keys) != len(expected) {
+		t.Fatalf("jwks contains unexpected number of keys: got %v want %v", len(jwks.Keys), len(expected))
+	}
+	for _, key := range jwks.Keys {
+		kid, _ := key["kid"].(string)
+		alg, ok := expected[kid]
+		if !ok {
+			t.Errorf("jwks contains unexpected kid: %v", kid)
+			continue
+		}
+		if key["alg"] != alg {
+			t.Errorf("jwks key %v has wrong alg: got %v want %v", kid, key["alg"], alg)
+		}
+		if key["use"] != "sig" {
+			t.Errorf("jwks key %v has wrong use: got %v want sig", kid, key["use"])
+		}
+	}
+}
+
+func TestAuthorizeHandlerRegisteredResponseTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:            "client-code",
+		RedirectURIs:  []string{"https://client.example.com/cb"},
+		ResponseTypes: []string{oidc.ResponseTypeCode},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		responseType string
+		rejected     bool
+	}{
+		{"id_token token", true},
+		{"code id_token", true},
+		{"code", false},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", "client-code")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", test.responseType)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("nonce", "nonce")
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := location.Query()
+		if location.Fragment != "" {
+			params, _ = url.ParseQuery(location.Fragment)
+		}
+		if rejected := params.Get("error") == konnectoidc.ErrorCodeOAuth2UnauthorizedClient; rejected != test.rejected {
+			t.Errorf("unexpected result for response_type %q: got %v (%v)", test.responseType, rr.Header().Get("Location"), rr.Code)
+		}
+	}
+}
+
+func TestAuthorizeHandlerRequirePKCE(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                      logger,
+		RequirePKCEForPublicClients: true,
+	})
+	defer httpServer.Close()
+
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	required := true
+	notRequired := false
+	for _, client := range []*clients.ClientRegistration{
+		{ID: "client-public", RedirectURIs: []string{"https://client.example.com/cb"}},
+		{ID: "client-public-optout", RedirectURIs: []string{"https://client.example.com/cb"}, RequirePKCE: &notRequired},
+		{ID: "client-confidential", Secret: "secret", RedirectURIs: []string{"https://client.example.com/cb"}},
+		{ID: "client-confidential-pkce", Secret: "secret", RedirectURIs: []string{"https://client.example.com/cb"}, RequirePKCE: &required},
+	} {
+		if err = registry.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	codeChallenge, err := oidc.MakeCodeChallenge(oidc.S256CodeChallengeMethod, "verifier-0123456789-0123456789-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		clientID            string
+		codeChallenge       string
+		codeChallengeMethod string
+		rejected            bool
+	}{
+		{"client-public", "", "", true},
+		{"client-public", codeChallenge, oidc.S256CodeChallengeMethod, false},
+		{"client-public", codeChallenge, "", true},
+		{"client-public", codeChallenge, oidc.PlainCodeChallengeMethod, true},
+		{"client-public-optout", "", "", false},
+		{"client-confidential", "", "", false},
+		{"client-confidential-pkce", "", "", true},
+		{"client-confidential-pkce", codeChallenge, oidc.S256CodeChallengeMethod, false},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", test.clientID)
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		if test.codeChallenge != "" {
+			values.Set("code_challenge", test.codeChallenge)
+		}
+		if test.codeChallengeMethod != "" {
+			values.Set("code_challenge_method", test.codeChallengeMethod)
+		}
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rejected := rr.Code == http.StatusBadRequest || location.Query().Get("error") == oidc.ErrorCodeOAuth2InvalidRequest
+		if rejected != test.rejected {
+			t.Errorf("unexpected result for %s with code_challenge_method %q: got %v (%v)", test.clientID, test.codeChallengeMethod, rr.Header().Get("Location"), rr.Code)
+		}
+	}
+}
+
+func TestAuthorizeHandlerNativeClientHardening(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                logger,
+		NativeClientHardening: true,
+	})
+	defer httpServer.Close()
+
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	for _, client := range []*clients.ClientRegistration{
+		{ID: "native-public", ApplicationType: oidc.ApplicationTypeNative, RedirectURIs: []string{"http://127.0.0.1/cb", "com.example.app://oauth/cb"}},
+		{ID: "native-secret", ApplicationType: oidc.ApplicationTypeNative, Secret: "secret", RedirectURIs: []string{"http://127.0.0.1/cb"}},
+	} {
+		if err = registry.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	codeChallenge, err := oidc.MakeCodeChallenge(oidc.S256CodeChallengeMethod, "verifier-0123456789-0123456789-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		clientID            string
+		responseType        string
+		redirectURI         string
+		codeChallenge       string
+		codeChallengeMethod string
+		rejected            bool
+	}{
+		{"native-public", oidc.ResponseTypeCode, "http://127.0.0.1:12345/cb", codeChallenge, oidc.S256CodeChallengeMethod, false},
+		{"native-public", oidc.ResponseTypeCode, "com.example.app://oauth/cb", codeChallenge, oidc.S256CodeChallengeMethod, false},
+		{"native-public", oidc.ResponseTypeCode, "http://127.0.0.1/cb", "", "", true},
+		{"native-public", oidc.ResponseTypeCode, "http://127.0.0.1/cb", codeChallenge, oidc.PlainCodeChallengeMethod, true},
+		{"native-public", oidc.ResponseTypeIDToken, "http://127.0.0.1/cb", codeChallenge, oidc.S256CodeChallengeMethod, true},
+		{"native-secret", oidc.ResponseTypeCode, "http://127.0.0.1/cb", codeChallenge, oidc.S256CodeChallengeMethod, true},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", test.clientID)
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", test.responseType)
+		values.Set("redirect_uri", test.redirectURI)
+		values.Set("nonce", "nonce")
+		if test.codeChallenge != "" {
+			values.Set("code_challenge", test.codeChallenge)
+		}
+		if test.codeChallengeMethod != "" {
+			values.Set("code_challenge_method", test.codeChallengeMethod)
+		}
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errorID := location.Query().Get("error")
+		if errorID == "" && location.Fragment != "" {
+			fragment, _ := url.ParseQuery(location.Fragment)
+			errorID = fragment.Get("error")
+		}
+		rejected := rr.Code == http.StatusBadRequest || errorID == oidc.ErrorCodeOAuth2InvalidRequest || errorID == konnectoidc.ErrorCodeOAuth2UnauthorizedClient
+		if rejected != test.rejected {
+			t.Errorf("unexpected result for %s with %s %s %q: got %v (%v)", test.clientID, test.responseType, test.redirectURI, test.codeChallengeMethod, rr.Header().Get("Location"), rr.Code)
+		}
+	}
+}
+
+func TestTokenHandlerNativeClientHardeningCodeExchange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                logger,
+		NativeClientHardening: true,
+	})
+	defer httpServer.Close()
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", true, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.StatelessCreator = provider.makeJWT
+	registry.StatelessValidator = provider.validateJWT
+	provider.clients = registry
+
+	// Register a native client dynamically.
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/register", strings.NewReader(`{"application_type": "native", "redirect_uris": ["http://127.0.0.1/cb"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	provider.RegistrationHandler(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("registration failed: %v %v", rr.Code, rr.Body.String())
+	}
+	registration := &payload.ClientRegistrationResponse{}
+	if err = json.Unmarshal(rr.Body.Bytes(), registration); err != nil {
+		t.Fatal(err)
+	}
+	if registration.ClientSecret != "" {
+		t.Fatalf("hardened native client must not get a client secret")
+	}
+
+	codeVerifier := "verifier-0123456789-0123456789-0123456789"
+	codeChallenge, err := oidc.MakeCodeChallenge(oidc.S256CodeChallengeMethod, codeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, tc := range []struct {
+		codeVerifier string
+		status       int
+	}{
+		{codeVerifier, http.StatusOK},
+		{"", http.StatusBadRequest},
+		{"other-verifier-0123456789-0123456789-0123456789", http.StatusBadRequest},
+	} {
+		values := url.Values{}
+		values.Set("client_id", registration.ClientID)
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "http://127.0.0.1/cb")
+		values.Set("nonce", fmt.Sprintf("nonce-%d", idx))
+		values.Set("code_challenge", codeChallenge)
+		values.Set("code_challenge_method", oidc.S256CodeChallengeMethod)
+		rr = httptest.NewRecorder()
+		provider.AuthorizeHandler(rr, httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil))
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := location.Query().Get("code")
+		if code == "" {
+			t.Fatalf("authorize did not return a code: %v %v", rr.Code, rr.Header().Get("Location"))
+		}
+
+		form := url.Values{}
+		form.Set("grant_type", oidc.GrantTypeAuthorizationCode)
+		form.Set("client_id", registration.ClientID)
+		form.Set("code", code)
+		form.Set("redirect_uri", "http://127.0.0.1/cb")
+		if tc.codeVerifier != "" {
+			form.Set("code_verifier", tc.codeVerifier)
+		}
+		req = httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr = httptest.NewRecorder()
+		provider.TokenHandler(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("code exchange with verifier %q returned wrong status code: got %v want %v (%v)", tc.codeVerifier, rr.Code, tc.status, rr.Body.String())
+		}
+		if tc.status == http.StatusOK && !strings.Contains(rr.Body.String(), `"access_token"`) {
+			t.Errorf("code exchange did not return an access token: %v", rr.Body.String())
+		}
+	}
+}
+
+func TestAuthorizeHandlerDefaultScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, _ := newTestTokenProviderWithCode(ctx, t, 0)
+	provider.defaultScopes = []string{oidc.ScopeProfile, oidc.ScopeEmail}
+
+	tests := []struct {
+		scope    string
+		expected []string
+	}{
+		{"", []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail}},
+		{"openid email", []string{oidc.ScopeOpenID, oidc.ScopeEmail}},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", "client-code")
+		if test.scope != "" {
+			values.Set("scope", test.scope)
+		}
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := location.Query().Get("code")
+		if c == "" {
+			t.Fatalf("expected code for scope %q: got %v (%v)", test.scope, rr.Header().Get("Location"), rr.Code)
+		}
+		record, err := provider.codeManager.Pop(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scopes := record.AuthenticationRequest.Scopes
+		if len(scopes) != len(test.expected) {
+			t.Errorf("unexpected scopes for scope %q: got %v want %v", test.scope, scopes, test.expected)
+		}
+		for _, scope := range test.expected {
+			if !scopes[scope] {
+				t.Errorf("missing scope %s for scope %q: got %v", scope, test.scope, scopes)
+			}
+		}
+	}
+}
+
+func TestAuthorizeHandlerIssParameter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, _ := newTestTokenProviderWithCode(ctx, t, 0)
+
+	tests := []struct {
+		responseType string
+		param        string
+	}{
+		{oidc.ResponseTypeCode, "code"},
+		{"unsupported", "error"},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", "client-code")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", test.responseType)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("state", "xyz")
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := location.Query()
+		if params.Get(test.param) == "" {
+			t.Fatalf("expected %s for response_type %q: got %v (%v)", test.param, test.responseType, rr.Header().Get("Location"), rr.Code)
+		}
+		if iss := params.Get("iss"); iss != provider.issuerIdentifier {
+			t.Errorf("wrong iss for response_type %q: got %q want %q", test.responseType, iss, provider.issuerIdentifier)
+		}
+	}
+}
+
+func TestTokenHandlerRequirePKCE(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, createCode := newTestTokenProviderWithCode(ctx, t, 0)
+
+	// Codes issued without code challenge are rejected once PKCE is required.
+	code := createCode("openid")
+	provider.requirePKCEForPublicClients = true
+
+	status, response := redeemTestCode(t, provider, code)
+	if status != http.StatusBadRequest {
+		t.Fatalf("token handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	if response["error"] != oidc.ErrorCodeOAuth2InvalidGrant {
+		t.Errorf("unexpected error for code without code challenge: %v", response)
+	}
+}
+
+func TestAuthorizeHandlerAllowedScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:            "client-restricted",
+		RedirectURIs:  []string{"https://client.example.com/cb"},
+		AllowedScopes: []string{oidc.ScopeOpenID, oidc.ScopeEmail},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scope    string
+		rejected bool
+	}{
+		{"openid email", false},
+		{"openid", false},
+		{"openid profile", true},
+		{"openid email profile", true},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", "client-restricted")
+		values.Set("scope", test.scope)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("nonce", "nonce")
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, _ := url.ParseQuery(location.Fragment)
+		if rejected := params.Get("error") == konnectoidc.ErrorCodeOAuth2InvalidScope; rejected != test.rejected {
+			t.Errorf("unexpected result for scope %q: got %v (%v)", test.scope, rr.Header().Get("Location"), rr.Code)
+		}
+	}
+}
+
+func TestTokenHandlerClientSecretJWT(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:                         "client-jwt",
+		Secret:                     "client-jwt-secret-value",
+		RedirectURIs:               []string{"https://client.example.com/cb"},
+		RawTokenEndpointAuthMethod: oidc.AuthMethodClientSecretJWT,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	exchange := func(assertion string) *httptest.ResponseRecorder {
+		values := url.Values{}
+		values.Set("client_id", "client-jwt")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeCode)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), ar.Scopes, nil, nil)
+		auth.SetUser(authenticated.User())
+
+		code, err := provider.codeManager.Create(&code.Record{
+			AuthenticationRequest: ar,
+			Auth:                  auth,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		form := url.Values{}
+		form.Set("grant_type", oidc.GrantTypeAuthorizationCode)
+		form.Set("code", code)
+		form.Set("redirect_uri", "https://client.example.com/cb")
+		if assertion != "" {
+			form.Set("client_assertion_type", payload.ClientAssertionTypeJWTBearer)
+			form.Set("client_assertion", assertion)
+		} else {
+			form.Set("client_id", "client-jwt")
+		}
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		provider.TokenHandler(rr, req)
+
+		return rr
+	}
+
+	assertion := func(method jwt.SigningMethod, secret string, jti string) string {
+		token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+			Issuer:    "client-jwt",
+			Subject:   "client-jwt",
+			Audience:  jwt.ClaimStrings{provider.metadata.TokenEndpoint},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        jti,
+		})
+		s, err := token.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		assertion string
+		status    int
+	}{
+		{"valid HS256", assertion(jwt.SigningMethodHS256, "client-jwt-secret-value", "jti-1"), http.StatusOK},
+		{"replayed jti", assertion(jwt.SigningMethodHS256, "client-jwt-secret-value", "jti-1"), http.StatusUnauthorized},
+		{"wrong secret", assertion(jwt.SigningMethodHS256, "wrong-secret", "jti-2"), http.StatusUnauthorized},
+		{"missing assertion", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := exchange(test.assertion)
+			if status := rr.Code; status != test.status {
+				t.Fatalf("token handler returned wrong status code: got %v want %v: %s", status, test.status, rr.Body.String())
+			}
+			if test.status != http.StatusOK {
+				var response map[string]interface{}
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+					t.Fatal(err)
+				}
+				if response["error"] != "invalid_client" {
+					t.Errorf("unexpected error response: %v", response)
+				}
+			}
+		})
+	}
+}
+
+type scopesTestIdentityManager struct {
+	identity.Manager
+
+	scopes []string
+}
+
+func (im *scopesTestIdentityManager) ScopesSupported(scopes map[string]bool) []string {
+	return im.scopes
+}
+
+func TestWellKnownHandlerScopesSupportedMatchesEnforcement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, config := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	im := &scopesTestIdentityManager{
+		Manager: provider.identityManager,
+		scopes:  []string{oidc.ScopeProfile},
+	}
+	provider.identityManager = im
+
+	authenticated, err := im.Authenticate(ctx, nil, nil, &payload.AuthenticationRequest{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := authenticated.User()
+
+	check := func(enabled bool) {
+		req, err := http.NewRequest("GET", config.WellKnownPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		wellKnown := &oidc.WellKnown{}
+		if err := json.Unmarshal(rr.Body.Bytes(), wellKnown); err != nil {
+			t.Fatal(err)
+		}
+		advertised := false
+		for _, scope := range wellKnown.ScopesSupported {
+			if scope == konnectoidc.ScopePhone {
+				advertised = true
+			}
+		}
+		if advertised != enabled {
+			t.Errorf("phone scope advertised was incorrect, got %v, want %v: %v", advertised, enabled, wellKnown.ScopesSupported)
+		}
+
+		authorizedScopes, _ := identity.AuthorizeScopes(im, user, map[string]bool{konnectoidc.ScopePhone: true})
+		if authorizedScopes[konnectoidc.ScopePhone] != enabled {
+			t.Errorf("phone scope authorized was incorrect, got %v, want %v", authorizedScopes[konnectoidc.ScopePhone], enabled)
+		}
+	}
+
+	check(false)
+
+	im.scopes = append(im.scopes, konnectoidc.ScopePhone)
+	check(true)
+}
+
+func TestAuthorizeHandlerRequestObjectSigningAlg(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := jwt.NewWithClaims(jwt.SigningMethodNone, &payload.RequestObjectClaims{
+		ClientID:        "client-unsigned",
+		RawScope:        oidc.ScopeOpenID,
+		RawResponseType: oidc.ResponseTypeIDToken,
+		RawRedirectURI:  "https://client.example.com/cb",
+		Nonce:           "nonce",
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		algs     []string
+		rejected bool
+	}{
+		{"default", nil, true},
+		{"none not allowed", []string{jwt.SigningMethodRS256.Alg()}, true},
+		{"none allowed", []string{jwt.SigningMethodNone.Alg()}, false},
+	}
+
+	for _, test := range tests {
+		httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:                   logger,
+			AllowedClientSigningAlgs: test.algs,
+		})
+		defer httpServer.Close()
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+			t.Fatal(err)
+		}
+		if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+			t.Fatal(err)
+		}
+
+		registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.clients = registry
+
+		values := url.Values{}
+		values.Set("client_id", "client-unsigned")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("request", request)
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		if rejected := rr.Code == http.StatusBadRequest && strings.Contains(rr.Body.String(), oidc.ErrorCodeOIDCInvalidRequestObject); rejected != test.rejected {
+			t.Errorf("%s: unexpected result: got %d %s", test.name, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestAuthorizeHandlerRequestURI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := jwt.NewWithClaims(jwt.SigningMethodNone, &payload.RequestObjectClaims{
+		ClientID:        "client-jar",
+		RawScope:        oidc.ScopeOpenID,
+		RawResponseType: oidc.ResponseTypeIDToken,
+		RawRedirectURI:  "https://client.example.com/cb",
+		Nonce:           "nonce",
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := make(map[string]int)
+	requestServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fetched[req.URL.Path]++
+		rw.Header().Set("Content-Type", "application/oauth-authz-req+jwt")
+		rw.Write([]byte(request))
+	}))
+	defer requestServer.Close()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                   logger,
+		AllowedClientSigningAlgs: []string{jwt.SigningMethodNone.Alg()},
+		HTTPTransport:            requestServer.Client().Transport,
+	})
+	defer httpServer.Close()
+
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.Register(&clients.ClientRegistration{
+		ID:           "client-jar",
+		RedirectURIs: []string{"https://client.example.com/cb"},
+		RequestURIs:  []string{requestServer.URL + "/allowed.jwt#hash"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/allowed.jwt", true},
+		{"/disallowed.jwt", false},
+	}
+
+	for _, test := range tests {
+		values := url.Values{}
+		values.Set("client_id", "client-jar")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("request_uri", requestServer.URL+test.path)
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		rejected := rr.Code == http.StatusBadRequest && strings.Contains(rr.Body.String(), konnectoidc.ErrorCodeOIDCInvalidRequestURI)
+		if test.allowed {
+			if rejected || rr.Code == http.StatusBadRequest {
+				t.Errorf("%s: expected request_uri to be accepted, got %d %s", test.path, rr.Code, rr.Body.String())
+			}
+			if fetched[test.path] != 1 {
+				t.Errorf("%s: expected request_uri to be fetched once, got %d", test.path, fetched[test.path])
+			}
+		} else {
+			if !rejected {
+				t.Errorf("%s: expected request_uri to be rejected, got %d %s", test.path, rr.Code, rr.Body.String())
+			}
+			if fetched[test.path] != 0 {
+				t.Errorf("%s: disallowed request_uri was fetched", test.path)
+			}
+		}
+	}
+}
+
+func TestValidateClientSecretJWTSigningAlg(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                   logger,
+		AllowedClientSigningAlgs: []string{jwt.SigningMethodHS512.Alg()},
+	})
+	defer httpServer.Close()
+
+	registration := &clients.ClientRegistration{
+		ID:     "client-jwt",
+		Secret: "client-jwt-secret-value",
+	}
+	assertion := func(method jwt.SigningMethod, jti string) *payload.TokenRequest {
+		s, err := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+			Issuer:    "client-jwt",
+			Subject:   "client-jwt",
+			Audience:  jwt.ClaimStrings{provider.metadata.TokenEndpoint},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			ID:        jti,
+		}).SignedString([]byte(registration.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &payload.TokenRequest{ClientAssertion: s}
+	}
+
+	if err := provider.validateClientSecretJWT(assertion(jwt.SigningMethodHS256, "jti-1"), registration); err == nil {
+		t.Errorf("expected error for disallowed alg")
+	}
+	if err := provider.validateClientSecretJWT(assertion(jwt.SigningMethodHS512, "jti-2"), registration); err != nil {
+		t.Errorf("unexpected error for allowed alg: %v", err)
+	}
+}
+
+func TestValidateClientSecretJWTLeeway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	registration := &clients.ClientRegistration{
+		ID:     "client-jwt",
+		Secret: "client-jwt-secret-value",
+	}
+	assertion := func(issuedAt time.Time, jti string) *payload.TokenRequest {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Issuer:    "client-jwt",
+			Subject:   "client-jwt",
+			Audience:  jwt.ClaimStrings{provider.metadata.TokenEndpoint},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(5 * time.Minute)),
+			ID:        jti,
+		}).SignedString([]byte(registration.Secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &payload.TokenRequest{ClientAssertion: s}
+	}
+
+	if err := provider.validateClientSecretJWT(assertion(time.Now().Add(30*time.Second), "jti-1"), registration); err != nil {
+		t.Errorf("unexpected error for iat within leeway: %v", err)
+	}
+	if err := provider.validateClientSecretJWT(assertion(time.Now().Add(2*time.Minute), "jti-2"), registration); err == nil {
+		t.Errorf("expected error for iat beyond leeway")
+	}
+}
+
+func TestValidateClientSecretJWTReplayWithinLeeway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	registration := &clients.ClientRegistration{
+		ID:     "client-jwt",
+		Secret: "client-jwt-secret-value",
+	}
+	// Expired, but still accepted within the leeway.
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "client-jwt",
+		Subject:   "client-jwt",
+		Audience:  jwt.ClaimStrings{provider.metadata.TokenEndpoint},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-30 * time.Second)),
+		ID:        "jti-expired",
+	}).SignedString([]byte(registration.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &payload.TokenRequest{ClientAssertion: s}
+
+	if err := provider.validateClientSecretJWT(tr, registration); err != nil {
+		t.Fatalf("unexpected error for exp within leeway: %v", err)
+	}
+	if err := provider.validateClientSecretJWT(tr, registration); err == nil {
+		t.Errorf("expected error for replayed assertion within leeway")
+	}
+}
+
+func TestAuthorizeResponseSessionID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+	provider.sessionCookieName = "__Secure-KKCS"
+	provider.browserStateCookieName = "__Secure-KKBS"
+
+	nonce := 0
+	authorize := func(cookies []*http.Cookie) (string, []*http.Cookie) {
+		nonce++
+		values := url.Values{}
+		values.Set("client_id", "client1")
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("nonce", fmt.Sprintf("nonce-%d", nonce))
+		ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		if ar.Session, err = provider.getSession(req); err != nil {
+			t.Fatal(err)
+		}
+
+		auth, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth.AuthorizeScopes(ar.Scopes)
+
+		rr := httptest.NewRecorder()
+		provider.AuthorizeResponse(rr, req, ar, auth, nil)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fragment, _ := url.ParseQuery(location.Fragment)
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(fragment.Get("id_token"), claims); err != nil {
+			t.Fatalf("failed to parse id token: %v (%v)", err, fragment)
+		}
+		sid, _ := claims[oidc.SessionIDClaim].(string)
+		if sid == "" {
+			t.Fatalf("sid claim missing in id token: %v", claims)
+		}
+
+		for _, cookie := range rr.Result().Cookies() {
+			if cookie.Name == provider.sessionCookieName {
+				cookies = []*http.Cookie{cookie}
+			}
+		}
+		return sid, cookies
+	}
+
+	sid1, cookies := authorize(nil)
+	sid2, _ := authorize(cookies)
+	if sid1 != sid2 {
+		t.Errorf("expected id tokens of the same session to share sid, got %v and %v", sid1, sid2)
+	}
+
+	sid3, _ := authorize(nil)
+	if sid3 == sid1 {
+		t.Errorf("expected new session to have a different sid, got %v", sid3)
+	}
+}
+
+func TestIntrospectionHandlerClientAfterRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, createCode := newTestTokenProviderWithCode(ctx, t, 0)
+	if err := provider.clients.Register(&clients.ClientRegistration{ID: "resource-server", Secret: "resource-secret", RedirectURIs: []string{"https://rs.example.com/cb"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	status, response := redeemTestCode(t, provider, createCode("openid profile offline_access"))
+	if status != http.StatusOK {
+		t.Fatalf("token handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	refreshToken, _ := response["refresh_token"].(string)
+
+	form := url.Values{}
+	form.Set("grant_type", oidc.GrantTypeRefreshToken)
+	form.Set("refresh_token", refreshToken)
+	form.Set("client_id", "client-code")
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	provider.TokenHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("refresh returned wrong status code: got %v want %v: %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var refreshed map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &refreshed); err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _ := refreshed["access_token"].(string)
+
+	introspect := func(clientID string, clientSecret string, token string) (int, map[string]interface{}) {
+		form := url.Values{}
+		form.Set("token", token)
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, clientSecret)
+		rr := httptest.NewRecorder()
+		provider.IntrospectionHandler(rr, req)
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, response
+	}
+
+	for _, token := range []string{accessToken, refreshToken} {
+		status, response = introspect("resource-server", "resource-secret", token)
+		if status != http.StatusOK {
+			t.Fatalf("introspection returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+		}
+		if active, _ := response["active"].(bool); !active {
+			t.Errorf("introspection returned inactive token: %v", response)
+		}
+		if clientID := response["client_id"]; clientID != "client-code" {
+			t.Errorf("introspection returned wrong client_id: got %v want client-code", clientID)
+		}
+		if sub, _ := response["sub"].(string); sub == "" {
+			t.Errorf("introspection returned no sub: %v", response)
+		}
+	}
+
+	status, response = introspect("resource-server", "resource-secret", "invalid")
+	if status != http.StatusOK || response["active"] != false || len(response) != 1 {
+		t.Errorf("introspection of invalid token must be inactive, got %v: %v", status, response)
+	}
+
+	provider.revokedTokens.revoke(accessToken)
+	status, response = introspect("resource-server", "resource-secret", accessToken)
+	if status != http.StatusOK || response["active"] != false {
+		t.Errorf("introspection of revoked token must be inactive, got %v: %v", status, response)
+	}
+
+	for _, credentials := range [][2]string{{"resource-server", "wrong"}, {"client-code", ""}, {"unknown", "secret"}} {
+		if status, _ = introspect(credentials[0], credentials[1], accessToken); status != http.StatusUnauthorized {
+			t.Errorf("introspection by %v returned wrong status code: got %v want %v", credentials[0], status, http.StatusUnauthorized)
+		}
+	}
 }
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+
+	konnect "github.com/libregraph/lico"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+	"github.com/libregraph/lico/utils"
+)
+
+// IntrospectionHandler implements the HTTP token introspection endpoint for
+// OAuth 2.0 as specified at https://tools.ietf.org/html/rfc7662. Only
+// confidential clients are allowed to introspect tokens.
+func (p *Provider) IntrospectionHandler(rw http.ResponseWriter, req *http.Request) {
+	var err error
+	var ir *payload.IntrospectionRequest
+	var response *payload.IntrospectionResponse
+	errorStatus := http.StatusBadRequest
+
+	utils.LimitRequestBody(rw, req, p.tokenSizeLimit)
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	switch req.Method {
+	case http.MethodPost:
+		// breaks
+	default:
+		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidRequest, "request must be sent with POST")
+		goto done
+	}
+
+	err = req.ParseForm()
+	if err != nil {
+		if errors.Is(err, utils.ErrRequestBodyTooLarge) {
+			errorStatus = http.StatusRequestEntityTooLarge
+		}
+		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		goto done
+	}
+	ir, err = payload.DecodeIntrospectionRequest(req)
+	if err != nil {
+		err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidRequest, err.Error())
+		goto done
+	}
+
+	if registration, _ := p.clients.Get(req.Context(), ir.ClientID); registration == nil || registration.IsPublic() {
+		err = konnectoidc.NewOAuth2Error(konnectoidc.ErrorCodeOAuth2InvalidClient, "client not allowed to introspect")
+		errorStatus = http.StatusUnauthorized
+		goto done
+	}
+	if _, err = p.clients.Lookup(req.Context(), ir.ClientID, ir.ClientSecret, &url.URL{}, "", false); err != nil {
+		err = konnectoidc.NewOAuth2Error(konnectoidc.ErrorCodeOAuth2InvalidClient, err.Error())
+		errorStatus = http.StatusUnauthorized
+		goto done
+	}
+
+	response = p.introspect(ir.Token)
+
+done:
+	if err != nil {
+		switch err.(type) {
+		case *konnectoidc.OAuth2Error:
+			if errorStatus == http.StatusUnauthorized {
+				rw.Header().Set("WWW-Authenticate", "Basic")
+			}
+			err = utils.WriteJSON(rw, errorStatus, p.withErrorURI(err), "")
+			if err != nil {
+				p.logger.WithError(err).Errorln("introspection request failed writing response")
+			}
+		default:
+			p.logger.WithFields(utils.ErrorAsFields(err)).Errorln("introspection request failed")
+			p.ErrorPage(rw, http.StatusInternalServerError, err.Error(), "well sorry, but there was a problem")
+		}
+
+		return
+	}
+
+	err = utils.WriteJSON(rw, http.StatusOK, response, "")
+	if err != nil {
+		p.logger.WithError(err).Errorln("introspection request failed writing response")
+	}
+}
+
+// introspect validates the provided access or refresh token and returns its
+// introspection response. Invalid, expired and revoked tokens are inactive.
+func (p *Provider) introspect(tokenString string) *payload.IntrospectionResponse {
+	accessTokenClaims := &konnect.AccessTokenClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, accessTokenClaims, func(token *jwt.Token) (interface{}, error) {
+		return p.validateIssuedJWT(token)
+	}); err == nil {
+		if p.revokedTokens.isRevoked(accessTokenClaims.Id) {
+			return &payload.IntrospectionResponse{}
+		}
+
+		response := &payload.IntrospectionResponse{
+			Active:    true,
+			Scope:     strings.Join(accessTokenClaims.AuthorizedScopesList, " "),
+			ClientID:  clientIDFromAccessTokenClaims(accessTokenClaims),
+			TokenType: oidc.TokenTypeBearer,
+			ExpiresAt: accessTokenClaims.ExpiresAt,
+			IssuedAt:  accessTokenClaims.IssuedAt,
+			NotBefore: accessTokenClaims.NotBefore,
+			Subject:   accessTokenClaims.Subject,
+			Audience:  accessTokenClaims.Audience,
+			Issuer:    accessTokenClaims.Issuer,
+			ID:        accessTokenClaims.Id,
+		}
+		if len(accessTokenClaims.Audiences) > 1 {
+			response.Audience = accessTokenClaims.Audiences
+		}
+		if username, ok := accessTokenClaims.IdentityClaims[konnect.IdentifiedUsernameClaim].(string); ok {
+			response.Username = username
+		}
+
+		return response
+	}
+
+	refreshTokenClaims := &konnect.RefreshTokenClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, refreshTokenClaims, func(token *jwt.Token) (interface{}, error) {
+		return p.validateIssuedJWT(token)
+	}); err == nil {
+		if p.revokedTokens.isRevoked(refreshTokenClaims.Id) {
+			return &payload.IntrospectionResponse{}
+		}
+
+		response := &payload.IntrospectionResponse{
+			Active:    true,
+			Scope:     strings.Join(refreshTokenClaims.ApprovedScopesList, " "),
+			ClientID:  refreshTokenClaims.Audience,
+			ExpiresAt: refreshTokenClaims.ExpiresAt,
+			IssuedAt:  refreshTokenClaims.IssuedAt,
+			Subject:   refreshTokenClaims.Subject,
+			Audience:  refreshTokenClaims.Audience,
+			Issuer:    refreshTokenClaims.Issuer,
+			ID:        refreshTokenClaims.Id,
+		}
+		if username, ok := refreshTokenClaims.IdentityClaims[konnect.IdentifiedUsernameClaim].(string); ok {
+			response.Username = username
+		}
+
+		return response
+	}
+
+	return &payload.IntrospectionResponse{}
+}
+
+// clientIDFromAccessTokenClaims returns the ID of the client for which the
+// access token was issued. Access tokens issued before the client_id claim was
+// added carry the client as authorized party or audience.
+func clientIDFromAccessTokenClaims(claims *konnect.AccessTokenClaims) string {
+	switch {
+	case claims.ClientID != "":
+		return claims.ClientID
+	case claims.AuthorizedParty != "":
+		return claims.AuthorizedParty
+	default:
+		return claims.Audience
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// accessTokenDurationForClient returns the lifetime of access tokens issued
+// for the client with the provided id, clamped to the configured maximum.
+func (p *Provider) accessTokenDurationForClient(ctx context.Context, clientID string) time.Duration {
+	duration := p.accessTokenDuration
+	if registration, _ := p.clients.Get(ctx, clientID); registration != nil && registration.AccessTokenLifetime > 0 {
+		duration = time.Duration(registration.AccessTokenLifetime) * time.Second
+	}
+
+	return p.clampTokenDuration("access token", clientID, duration, p.maxAccessTokenDuration)
+}
+
+// idTokenDurationForClient returns the lifetime of ID tokens issued for the
+// client with the provided id, clamped to the configured maximum.
+func (p *Provider) idTokenDurationForClient(ctx context.Context, clientID string) time.Duration {
+	duration := p.idTokenDuration
+	if registration, _ := p.clients.Get(ctx, clientID); registration != nil && registration.IDTokenLifetime > 0 {
+		duration = time.Duration(registration.IDTokenLifetime) * time.Second
+	}
+
+	return p.clampTokenDuration("id token", clientID, duration, p.maxIDTokenDuration)
+}
+
+// refreshTokenDurationForClient returns the lifetime of refresh tokens issued
+// for the client with the provided id, clamped to the configured maximum.
+func (p *Provider) refreshTokenDurationForClient(ctx context.Context, clientID string) time.Duration {
+	duration := p.refreshTokenDuration
+	if registration, _ := p.clients.Get(ctx, clientID); registration != nil && registration.RefreshTokenLifetime > 0 {
+		duration = time.Duration(registration.RefreshTokenLifetime) * time.Second
+	}
+
+	return p.clampTokenDuration("refresh token", clientID, duration, p.maxRefreshTokenDuration)
+}
+
+func (p *Provider) clampTokenDuration(tokenType string, clientID string, duration time.Duration, max time.Duration) time.Duration {
+	if max <= 0 || duration <= max {
+		return duration
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"client_id": clientID,
+		"lifetime":  duration,
+		"max":       max,
+	}).Debugf("clamped %s lifetime to maximum", tokenType)
+
+	return max
+}
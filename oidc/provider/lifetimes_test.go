@@ -0,0 +1,207 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+func TestTokenLifetimeCeiling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:                  logger,
+		MaxAccessTokenDuration:  30 * time.Minute,
+		MaxIDTokenDuration:      30 * time.Minute,
+		MaxRefreshTokenDuration: 12 * time.Hour,
+	})
+	defer httpServer.Close()
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	for _, registration := range []*clients.ClientRegistration{
+		{
+			ID:                   "client-long",
+			RedirectURIs:         []string{"https://client.example.com/cb"},
+			AccessTokenLifetime:  2 * 60 * 60,
+			IDTokenLifetime:      2 * 60 * 60,
+			RefreshTokenLifetime: 48 * 60 * 60,
+		},
+		{
+			ID:                  "client-short",
+			RedirectURIs:        []string{"https://client.example.com/cb"},
+			AccessTokenLifetime: 5 * 60,
+		},
+	} {
+		if err = registry.Register(registration); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		clientID string
+		access   time.Duration
+		id       time.Duration
+		refresh  time.Duration
+	}{
+		{"client-long", 30 * time.Minute, 30 * time.Minute, 12 * time.Hour},
+		{"client-short", 5 * time.Minute, 30 * time.Minute, 12 * time.Hour},
+		{"unregistered", 10 * time.Minute, 30 * time.Minute, 12 * time.Hour},
+	} {
+		if d := provider.accessTokenDurationForClient(ctx, tc.clientID); d != tc.access {
+			t.Errorf("%s: access token lifetime was incorrect, got %v, want %v", tc.clientID, d, tc.access)
+		}
+		if d := provider.idTokenDurationForClient(ctx, tc.clientID); d != tc.id {
+			t.Errorf("%s: id token lifetime was incorrect, got %v, want %v", tc.clientID, d, tc.id)
+		}
+		if d := provider.refreshTokenDurationForClient(ctx, tc.clientID); d != tc.refresh {
+			t.Errorf("%s: refresh token lifetime was incorrect, got %v, want %v", tc.clientID, d, tc.refresh)
+		}
+	}
+
+	auth := identity.NewAuthRecord(provider.identityManager, "sub", map[string]bool{}, nil, nil)
+	accessTokenString, err := provider.makeAccessToken(ctx, "client-long", auth, jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &konnect.AccessTokenClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(accessTokenString, claims); err != nil {
+		t.Fatal(err)
+	}
+	if lifetime := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; lifetime < 30*time.Minute-time.Second || lifetime > 30*time.Minute {
+		t.Errorf("access token exp was not clamped, got lifetime %v, want %v", lifetime, 30*time.Minute)
+	}
+}
+
+func TestTokenLifetimeCeilingIssuedTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider, _, _, createCode := newTestTokenProviderWithCode(ctx, t, 0)
+	provider.maxAccessTokenDuration = 5 * time.Minute
+	provider.maxIDTokenDuration = 5 * time.Minute
+	provider.maxRefreshTokenDuration = time.Hour
+	provider.sessionCookieName = "__Secure-KKCS"
+	provider.browserStateCookieName = "__Secure-KKBS"
+
+	lifetime := func(tokenString string) time.Duration {
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+		exp, _ := claims[oidc.ExpirationClaim].(float64)
+		iat, _ := claims[oidc.IssuedAtClaim].(float64)
+		return time.Duration(exp-iat) * time.Second
+	}
+	clamped := func(d time.Duration, max time.Duration) bool {
+		return d >= max-time.Second && d <= max
+	}
+
+	status, response := redeemTestCode(t, provider, createCode("openid offline_access"))
+	if status != http.StatusOK {
+		t.Fatalf("token handler returned wrong status code: got %v want %v: %v", status, http.StatusOK, response)
+	}
+	if expiresIn, _ := response["expires_in"].(float64); expiresIn != 300 {
+		t.Errorf("token response expires_in was not clamped, got %v, want %v", response["expires_in"], 300)
+	}
+	idToken, _ := response["id_token"].(string)
+	if d := lifetime(idToken); !clamped(d, 5*time.Minute) {
+		t.Errorf("id token exp was not clamped, got lifetime %v, want %v", d, 5*time.Minute)
+	}
+	refreshToken, _ := response["refresh_token"].(string)
+	if d := lifetime(refreshToken); !clamped(d, time.Hour) {
+		t.Errorf("refresh token exp was not clamped, got lifetime %v, want %v", d, time.Hour)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", oidc.GrantTypeRefreshToken)
+	form.Set("refresh_token", refreshToken)
+	form.Set("client_id", "client-code")
+	req := httptest.NewRequest(http.MethodPost, "http://localhost:8777/konnect/v1/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	provider.TokenHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("refresh returned wrong status code: got %v want %v: %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var refreshed map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if expiresIn, _ := refreshed["expires_in"].(float64); expiresIn != 300 {
+		t.Errorf("refresh response expires_in was not clamped, got %v, want %v", refreshed["expires_in"], 300)
+	}
+
+	// Nonces of directly returned ID tokens are kept as long as the ID token.
+	values := url.Values{}
+	values.Set("client_id", "client-code")
+	values.Set("scope", oidc.ScopeOpenID)
+	values.Set("response_type", oidc.ResponseTypeIDToken)
+	values.Set("redirect_uri", "https://client.example.com/cb")
+	values.Set("nonce", "nonce-lifetime")
+	ar, err := payload.NewAuthenticationRequest(values, provider.metadata, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+	if ar.Session, err = provider.getSession(req); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.AuthorizeScopes(ar.Scopes)
+	rr = httptest.NewRecorder()
+	provider.AuthorizeResponse(rr, req, ar, auth, nil)
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, _ := url.ParseQuery(location.Fragment)
+	if d := lifetime(fragment.Get("id_token")); !clamped(d, 5*time.Minute) {
+		t.Errorf("implicit id token exp was not clamped, got lifetime %v, want %v", d, 5*time.Minute)
+	}
+	expiry, ok := provider.nonces.table.Get(nonceStoreKey("client-code", "nonce-lifetime"))
+	if !ok {
+		t.Fatalf("nonce was not recorded")
+	}
+	if retention := time.Until(expiry.(time.Time)); retention > 5*time.Minute {
+		t.Errorf("nonce retention was not clamped, got %v, want at most %v", retention, 5*time.Minute)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Client registration operations as used for metrics.
+const (
+	registrationOperationCreated = "created"
+	registrationOperationUpdated = "updated"
+	registrationOperationDeleted = "deleted"
+)
+
+var (
+	registrationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "lico",
+			Subsystem: "registration",
+			Name:      "clients_total",
+			Help:      "Total number of dynamic client registration operations",
+		},
+		[]string{"operation"},
+	)
+	registrationRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "lico",
+			Subsystem: "registration",
+			Name:      "rejections_total",
+			Help:      "Total number of rejected dynamic client registration requests by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(registrationsTotal, registrationRejectionsTotal)
+
+	for _, operation := range []string{registrationOperationCreated, registrationOperationUpdated, registrationOperationDeleted} {
+		registrationsTotal.WithLabelValues(operation)
+	}
+}
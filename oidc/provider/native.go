@@ -0,0 +1,51 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"github.com/libregraph/oidc-go"
+
+	"github.com/libregraph/lico/identity/clients"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+// validateNativeClientHardening checks the provided authentication request of
+// a native client against the native client hardening rules. Native clients
+// must use the code flow with PKCE S256, a custom scheme or loopback
+// redirect_uri and must not have a client secret, see
+// https://tools.ietf.org/html/rfc8252#section-8.
+func (p *Provider) validateNativeClientHardening(ar *payload.AuthenticationRequest, registration *clients.ClientRegistration) error {
+	if registration.HasClientSecret() {
+		return ar.NewError(konnectoidc.ErrorCodeOAuth2UnauthorizedClient, "native clients must not have a client secret")
+	}
+	if ar.Flow != oidc.FlowCode {
+		return ar.NewError(konnectoidc.ErrorCodeOAuth2UnauthorizedClient, "native clients must use code flow")
+	}
+	if ar.RedirectURI == nil || !clients.IsNativeRedirectURI(ar.RedirectURI) {
+		return ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "native clients must use custom scheme or loopback redirect_uri")
+	}
+	if ar.CodeChallenge == "" {
+		return ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "code_challenge required for client")
+	}
+	if ar.CodeChallengeMethod != oidc.S256CodeChallengeMethod {
+		return ar.NewError(oidc.ErrorCodeOAuth2InvalidRequest, "code_challenge_method S256 required for client")
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/orcaman/concurrent-map"
+)
+
+const nonceStorePurgeInterval = 30 * time.Second
+
+// nonceStore records nonces which have been used in ID tokens returned
+// directly from the authorization endpoint, to detect replays.
+type nonceStore struct {
+	sync.Mutex
+
+	table     cmap.ConcurrentMap
+	lastPurge time.Time
+}
+
+func newNonceStore() *nonceStore {
+	return &nonceStore{
+		table:     cmap.New(),
+		lastPurge: time.Now(),
+	}
+}
+
+func nonceStoreKey(clientID string, nonce string) string {
+	return clientID + "\x00" + nonce
+}
+
+// use records the provided nonce for the provided client ID as used for the
+// provided duration. It returns false if the nonce has already been used by
+// the client within that duration.
+func (ns *nonceStore) use(clientID string, nonce string, duration time.Duration) bool {
+	now := time.Now()
+	ns.purgeExpired(now)
+
+	ok := false
+	ns.table.Upsert(nonceStoreKey(clientID, nonce), now.Add(duration), func(exist bool, valueInMap interface{}, newValue interface{}) interface{} {
+		if exist && valueInMap.(time.Time).After(now) {
+			return valueInMap
+		}
+		ok = true
+		return newValue
+	})
+
+	return ok
+}
+
+func (ns *nonceStore) purgeExpired(now time.Time) {
+	ns.Lock()
+	if now.Sub(ns.lastPurge) < nonceStorePurgeInterval {
+		ns.Unlock()
+		return
+	}
+	ns.lastPurge = now
+	ns.Unlock()
+
+	var expired []string
+	for entry := range ns.table.IterBuffered() {
+		if entry.Val.(time.Time).Before(now) {
+			expired = append(expired, entry.Key)
+		}
+	}
+	for _, key := range expired {
+		ns.table.RemoveCb(key, func(key string, v interface{}, exists bool) bool {
+			return exists && v.(time.Time).Before(now)
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
 	"strings"
@@ -36,12 +37,14 @@ import (
 	"golang.org/x/crypto/ed25519"
 
 	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/audit"
 	"github.com/libregraph/lico/identity"
 	"github.com/libregraph/lico/identity/clients"
 	identityManagers "github.com/libregraph/lico/identity/managers"
 	"github.com/libregraph/lico/managers"
 	konnectoidc "github.com/libregraph/lico/oidc"
 	"github.com/libregraph/lico/oidc/code"
+	"github.com/libregraph/lico/oidc/payload"
 	"github.com/libregraph/lico/signing"
 	"github.com/libregraph/lico/utils"
 )
@@ -52,8 +55,10 @@ type Provider struct {
 
 	issuerIdentifier string
 	metadata         *oidc.WellKnown
+	oauthMetadata    *konnectoidc.AuthorizationServerMetadata
 
 	wellKnownPath          string
+	oauthMetadataPath      string
 	jwksPath               string
 	authorizationPath      string
 	tokenPath              string
@@ -61,6 +66,7 @@ type Provider struct {
 	endSessionPath         string
 	checkSessionIframePath string
 	registrationPath       string
+	introspectionPath      string
 
 	identityManager   identity.Manager
 	guestManager      identity.Manager
@@ -79,11 +85,73 @@ type Provider struct {
 	sessionCookiePath string
 	sessionCookieName string
 
+	cookieSameSite http.SameSite
+	cookieDomain   string
+
+	registrationSizeLimit int64
+	tokenSizeLimit        int64
+
+	corsDefault  *cors.Cors
+	corsToken    *cors.Cors
+	corsUserInfo *cors.Cors
+
+	registrationPolicy *payload.ClientRegistrationPolicy
+
+	staticClaims    map[string]interface{}
+	claimTransforms ClaimTransforms
+
+	maxClaimValues   int
+	claimLimitPolicy string
+
+	errorPageTemplate     *template.Template
+	errorDocumentationURI *url.URL
+
+	nonces             *nonceStore
+	clientAssertionIDs *nonceStore
+	revokedTokens      *tokenRevocations
+
 	accessTokenDuration  time.Duration
 	idTokenDuration      time.Duration
 	refreshTokenDuration time.Duration
 
-	logger logrus.FieldLogger
+	maxAccessTokenDuration  time.Duration
+	maxIDTokenDuration      time.Duration
+	maxRefreshTokenDuration time.Duration
+
+	jwksMaxAge time.Duration
+
+	minimalIDTokenClaims        bool
+	allowMultipleAudiences      bool
+	additionalAudiences         []string
+	requirePKCEForPublicClients bool
+	nativeClientHardening       bool
+	acrMaxAges                  map[string]time.Duration
+
+	defaultScopes []string
+
+	clientSigningAlgs map[string]bool
+
+	logger      logrus.FieldLogger
+	auditLogger audit.Logger
+}
+
+// defaultClientSigningAlgs defines the algs allowed for request objects and
+// client assertions if not configured otherwise. Unsigned request objects are
+// only accepted when none is explicitly allowed.
+var defaultClientSigningAlgs = []string{
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodES384.Alg(),
+	jwt.SigningMethodES512.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodRS384.Alg(),
+	jwt.SigningMethodRS512.Alg(),
+	jwt.SigningMethodPS256.Alg(),
+	jwt.SigningMethodPS384.Alg(),
+	jwt.SigningMethodPS512.Alg(),
+	signing.SigningMethodEdDSA.Alg(),
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodHS384.Alg(),
+	jwt.SigningMethodHS512.Alg(),
 }
 
 // NewProvider returns a new Provider.
@@ -93,6 +161,7 @@ func NewProvider(c *Config) (*Provider, error) {
 
 		issuerIdentifier:       c.IssuerIdentifier,
 		wellKnownPath:          c.WellKnownPath,
+		oauthMetadataPath:      c.OAuthMetadataPath,
 		jwksPath:               c.JwksPath,
 		authorizationPath:      c.AuthorizationPath,
 		tokenPath:              c.TokenPath,
@@ -100,6 +169,7 @@ func NewProvider(c *Config) (*Provider, error) {
 		endSessionPath:         c.EndSessionPath,
 		checkSessionIframePath: c.CheckSessionIframePath,
 		registrationPath:       c.RegistrationPath,
+		introspectionPath:      c.IntrospectionPath,
 
 		signingKeys:    make(map[jwt.SigningMethod]*SigningKey),
 		validationKeys: make(map[string]crypto.PublicKey),
@@ -111,11 +181,107 @@ func NewProvider(c *Config) (*Provider, error) {
 		sessionCookiePath: c.SessionCookiePath,
 		sessionCookieName: c.SessionCookieName,
 
+		cookieSameSite: c.Config.CookieSameSite,
+		cookieDomain:   c.Config.CookieDomain,
+
+		registrationSizeLimit: registrationSizeLimit,
+		tokenSizeLimit:        tokenSizeLimit,
+
+		corsDefault: cors.Default(),
+		// Userinfo is called by browser applications with a bearer token, so
+		// the request origin is echoed back and the Authorization header is
+		// allowed. Credentials are not needed.
+		corsUserInfo: cors.New(cors.Options{
+			AllowOriginFunc: func(origin string) bool {
+				return true
+			},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodHead},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+		}),
+
+		registrationPolicy: &payload.ClientRegistrationPolicy{
+			MaxPostLogoutRedirectURIs: c.Config.MaxPostLogoutRedirectURIs,
+			AllowNativeImplicit:       c.Config.AllowNativeImplicit,
+			NativeHardening:           c.Config.NativeClientHardening,
+		},
+
 		accessTokenDuration:  c.AccessTokenDuration,
 		idTokenDuration:      c.IDTokenDuration,
 		refreshTokenDuration: c.RefreshTokenDuration,
 
-		logger: c.Config.Logger,
+		maxAccessTokenDuration:  c.Config.MaxAccessTokenDuration,
+		maxIDTokenDuration:      c.Config.MaxIDTokenDuration,
+		maxRefreshTokenDuration: c.Config.MaxRefreshTokenDuration,
+
+		jwksMaxAge: c.JwksMaxAge,
+
+		minimalIDTokenClaims:   c.Config.MinimalIDTokenClaims,
+		allowMultipleAudiences: c.Config.AllowMultipleAudiences,
+		additionalAudiences:    c.Config.AdditionalAudiences,
+
+		requirePKCEForPublicClients: c.Config.RequirePKCEForPublicClients,
+		nativeClientHardening:       c.Config.NativeClientHardening,
+		acrMaxAges:                  c.Config.ACRMaxAges,
+
+		defaultScopes: c.Config.DefaultScopes,
+
+		staticClaims:    c.StaticClaims,
+		claimTransforms: c.ClaimTransforms,
+
+		maxClaimValues:   c.Config.MaxClaimValues,
+		claimLimitPolicy: c.Config.ClaimLimitPolicy,
+
+		errorPageTemplate:     c.ErrorPageTemplate,
+		errorDocumentationURI: c.ErrorDocumentationURI,
+
+		nonces:             newNonceStore(),
+		clientAssertionIDs: newNonceStore(),
+		revokedTokens:      newTokenRevocations(),
+
+		logger:      c.Config.Logger,
+		auditLogger: c.Config.AuditLogger,
+	}
+	if p.auditLogger == nil {
+		p.auditLogger = audit.NopLogger
+	}
+	if p.cookieSameSite == 0 {
+		p.cookieSameSite = http.SameSiteNoneMode
+	}
+	if len(c.Config.AllowedOrigins) > 0 {
+		// Restrict browser-facing endpoints to the configured origins. Since
+		// credentials are allowed, the matching origin is echoed back.
+		p.corsDefault = cors.New(cors.Options{
+			AllowedOrigins:   c.Config.AllowedOrigins,
+			AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders:   []string{"Authorization", "Content-Type"},
+			AllowCredentials: true,
+		})
+		p.corsUserInfo = p.corsDefault
+	}
+	p.corsToken = p.corsDefault
+	if c.Config.AllowClientOrigins {
+		// Restrict token and userinfo endpoints to the origins of registered
+		// clients and the configured origins.
+		p.corsToken = p.newClientOriginsCors(c.Config.AllowedOrigins)
+		p.corsUserInfo = p.corsToken
+	}
+	allowedClientSigningAlgs := c.Config.AllowedClientSigningAlgs
+	if len(allowedClientSigningAlgs) == 0 {
+		allowedClientSigningAlgs = defaultClientSigningAlgs
+	}
+	p.clientSigningAlgs = make(map[string]bool)
+	for _, alg := range allowedClientSigningAlgs {
+		if jwt.GetSigningMethod(alg) == nil {
+			return nil, fmt.Errorf("unknown client signing alg: %s", alg)
+		}
+		p.clientSigningAlgs[alg] = true
+	}
+	if err := p.claimTransforms.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid claim transforms: %w", err)
+	}
+	if c.Config.RequestBodySizeLimit > 0 {
+		p.registrationSizeLimit = c.Config.RequestBodySizeLimit
+		p.tokenSizeLimit = c.Config.RequestBodySizeLimit
 	}
 
 	return p, nil
@@ -385,9 +551,7 @@ func (p *Provider) InitializeMetadata() error {
 		CheckSessionIframe:    p.makeIssURL(p.checkSessionIframePath),
 		JwksURI:               p.makeIssURL(p.jwksPath),
 		RegistrationEndpoint:  p.makeIssURL(p.registrationPath),
-		ScopesSupported: uniqueStrings(append([]string{
-			oidc.ScopeOpenID,
-		}, p.identityManager.ScopesSupported(nil)...)),
+		ScopesSupported:       p.scopesSupported(),
 		ResponseTypesSupported: []string{
 			oidc.ResponseTypeIDTokenToken,
 			oidc.ResponseTypeIDToken,
@@ -404,9 +568,10 @@ func (p *Provider) InitializeMetadata() error {
 			oidc.AudienceClaim,
 			oidc.ExpirationClaim,
 			oidc.IssuedAtClaim,
+			oidc.SessionIDClaim,
 		}, p.identityManager.ClaimsSupported(nil)...)),
 		RequestParameterSupported:    true,
-		RequestURIParameterSupported: false,
+		RequestURIParameterSupported: true,
 	}
 
 	p.metadata.IDTokenSigningAlgValuesSupported = make([]string, 0)
@@ -414,7 +579,8 @@ func (p *Provider) InitializeMetadata() error {
 		p.metadata.IDTokenSigningAlgValuesSupported = append(p.metadata.IDTokenSigningAlgValuesSupported, alg.Alg())
 	}
 	p.metadata.UserInfoSigningAlgValuesSupported = p.metadata.IDTokenSigningAlgValuesSupported
-	p.metadata.RequestObjectSigningAlgValuesSupported = []string{
+	p.metadata.RequestObjectSigningAlgValuesSupported = make([]string, 0)
+	for _, alg := range []string{
 		jwt.SigningMethodES256.Alg(),
 		jwt.SigningMethodES384.Alg(),
 		jwt.SigningMethodES512.Alg(),
@@ -426,36 +592,130 @@ func (p *Provider) InitializeMetadata() error {
 		jwt.SigningMethodPS512.Alg(),
 		jwt.SigningMethodNone.Alg(),
 		signing.SigningMethodEdDSA.Alg(),
+	} {
+		if p.clientSigningAlgs[alg] {
+			p.metadata.RequestObjectSigningAlgValuesSupported = append(p.metadata.RequestObjectSigningAlgValuesSupported, alg)
+		}
 	}
-	p.metadata.TokenEndpointAuthMethodsSupported = []string{
-		oidc.AuthMethodClientSecretBasic,
-		oidc.AuthMethodNone,
+	p.metadata.TokenEndpointAuthMethodsSupported = p.tokenEndpointAuthMethodsSupported()
+	p.metadata.TokenEndpointAuthSigningAlgValuesSupported = make([]string, 0)
+	for _, alg := range []string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodHS384.Alg(),
+		jwt.SigningMethodHS512.Alg(),
+	} {
+		if p.clientSigningAlgs[alg] {
+			p.metadata.TokenEndpointAuthSigningAlgValuesSupported = append(p.metadata.TokenEndpointAuthSigningAlgValuesSupported, alg)
+		}
+	}
+
+	// Create OAuth 2.0 authorization server meta data document from the same
+	// values as the well-known document.
+	p.oauthMetadata = &konnectoidc.AuthorizationServerMetadata{
+		Issuer:                p.metadata.Issuer,
+		AuthorizationEndpoint: p.metadata.AuthorizationEndpoint,
+		TokenEndpoint:         p.metadata.TokenEndpoint,
+		JwksURI:               p.metadata.JwksURI,
+		RegistrationEndpoint:  p.metadata.RegistrationEndpoint,
+
+		IntrospectionEndpoint: p.makeIssURL(p.introspectionPath),
+
+		ScopesSupported:        p.metadata.ScopesSupported,
+		ResponseTypesSupported: p.metadata.ResponseTypesSupported,
+		GrantTypesSupported: []string{
+			oidc.GrantTypeAuthorizationCode,
+			oidc.GrantTypeImplicit,
+			oidc.GrantTypeRefreshToken,
+		},
+
+		TokenEndpointAuthMethodsSupported:          p.metadata.TokenEndpointAuthMethodsSupported,
+		TokenEndpointAuthSigningAlgValuesSupported: p.metadata.TokenEndpointAuthSigningAlgValuesSupported,
+
+		CodeChallengeMethodsSupported: []string{
+			oidc.S256CodeChallengeMethod,
+		},
+
+		AuthorizationResponseIssParameterSupported: true,
 	}
-	p.metadata.TokenEndpointAuthSigningAlgValuesSupported = p.metadata.IDTokenSigningAlgValuesSupported
 
 	return nil
 }
 
+// tokenEndpointAuthMethodsSupported returns the client authentication methods
+// accepted by the token endpoint for all clients. The client secret can be
+// sent with Basic authorization or as form value. Client assertions are only
+// advertised when at least one HMAC alg is allowed for clients and dynamic
+// client registration is disabled, since dynamic clients cannot use them.
+func (p *Provider) tokenEndpointAuthMethodsSupported() []string {
+	methods := []string{
+		oidc.AuthMethodClientSecretBasic,
+		oidc.AuthMethodClientSecretPost,
+	}
+	if p.registrationPath != "" {
+		return append(methods, oidc.AuthMethodNone)
+	}
+	for _, alg := range []string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodHS384.Alg(),
+		jwt.SigningMethodHS512.Alg(),
+	} {
+		if p.clientSigningAlgs[alg] {
+			methods = append(methods, oidc.AuthMethodClientSecretJWT)
+			break
+		}
+	}
+
+	return append(methods, oidc.AuthMethodNone)
+}
+
+// scopesSupported returns the scopes which are currently supported. It uses
+// the same source as scope authorization so advertised and enforced scopes
+// never diverge.
+func (p *Provider) scopesSupported() []string {
+	return uniqueStrings(append([]string{
+		oidc.ScopeOpenID,
+	}, p.identityManager.ScopesSupported(nil)...))
+}
+
+func (p *Provider) promptValuesSupported() []string {
+	promptValues := []string{
+		oidc.PromptNone,
+		oidc.PromptLogin,
+		oidc.PromptConsent,
+		oidc.PromptSelectAccount,
+	}
+	if identityManagerWithPrompts, ok := p.identityManager.(identity.ManagerWithPrompts); ok {
+		promptValues = uniqueStrings(append(promptValues, identityManagerWithPrompts.PromptsSupported()...))
+	}
+
+	return promptValues
+}
+
 // ServerHTTP implements the http.HandlerFunc interface.
 func (p *Provider) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	switch path := req.URL.Path; {
 	case path == p.wellKnownPath:
-		cors.Default().ServeHTTP(rw, req, p.WellKnownHandler)
+		p.corsDefault.ServeHTTP(rw, req, p.WellKnownHandler)
+	case path == p.oauthMetadataPath && path != "":
+		p.corsDefault.ServeHTTP(rw, req, p.OAuthMetadataHandler)
 	case path == p.jwksPath:
-		cors.Default().ServeHTTP(rw, req, p.JwksHandler)
+		p.corsDefault.ServeHTTP(rw, req, p.JwksHandler)
 	case path == p.authorizationPath:
 		p.AuthorizeHandler(rw, req)
 	case path == p.tokenPath:
-		cors.Default().ServeHTTP(rw, req, p.TokenHandler)
+		p.corsToken.ServeHTTP(rw, req, p.TokenHandler)
 	case path == p.userInfoPath:
-		// TODO(longsleep): Use more strict CORS.
-		cors.AllowAll().ServeHTTP(rw, req, p.UserInfoHandler)
+		p.corsUserInfo.ServeHTTP(rw, req, p.UserInfoHandler)
 	case path == p.endSessionPath:
 		p.EndSessionHandler(rw, req)
 	case path == p.checkSessionIframePath:
 		p.CheckSessionIframeHandler(rw, req)
 	case path == p.registrationPath:
 		p.RegistrationHandler(rw, req)
+	case p.registrationPath != "" && path == p.registrationPath+"/validate":
+		p.RegistrationValidateHandler(rw, req)
+	case path == p.introspectionPath && path != "":
+		p.IntrospectionHandler(rw, req)
 	default:
 		http.NotFound(rw, req)
 	}
@@ -477,6 +737,21 @@ func (p *Provider) Found(rw http.ResponseWriter, uri *url.URL, params interface{
 	}
 }
 
+// auditLog records the provided audit event with the client address of the
+// provided request.
+func (p *Provider) auditLog(req *http.Request, event *audit.Event) {
+	event.RemoteAddr = utils.RemoteAddrFromRequest(req, p.Config.Config.TrustedProxyIPs, p.Config.Config.TrustedProxyNets)
+	p.auditLogger.Log(event)
+}
+
+// forwardedPrefix returns the path prefix set by a trusted proxy for the
+// provided request, if any.
+func (p *Provider) forwardedPrefix(req *http.Request) string {
+	trusted, _ := utils.IsRequestFromTrustedSource(req, p.Config.Config.TrustedProxyIPs, p.Config.Config.TrustedProxyNets)
+
+	return getForwardedPrefix(req, trusted)
+}
+
 // LoginRequiredPage writes a HTTP 30 to the provided ResponseWrite with the
 // URL of the provided request (set to the scheme and host of issuer) as
 // continue parameter.
@@ -515,11 +790,13 @@ func (p *Provider) GetAccessTokenClaimsFromRequest(req *http.Request) (*konnect.
 		claims = &konnect.AccessTokenClaims{}
 		_, err = jwt.ParseWithClaims(auth[1], claims, func(token *jwt.Token) (interface{}, error) {
 			// Validator for incoming access tokens, looks up key.
-			return p.validateJWT(token)
+			return p.validateIssuedJWT(token)
 		})
 		if err != nil {
 			// Wrap as OAuth2 error.
 			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidToken, err.Error())
+		} else if p.revokedTokens.isRevoked(claims.Id) {
+			err = konnectoidc.NewOAuth2Error(oidc.ErrorCodeOAuth2InvalidToken, "token revoked")
 		}
 
 	default:
@@ -25,8 +25,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/libregraph/oidc-go"
 	"github.com/sirupsen/logrus"
 
 	"github.com/libregraph/lico/config"
@@ -62,27 +65,36 @@ func init() {
 }
 
 func NewTestProvider(ctx context.Context, t *testing.T) (*httptest.Server, *Provider, http.Handler, *Config) {
+	return newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger: logger,
+	})
+}
+
+func newTestProviderWithConfig(ctx context.Context, t *testing.T, serverConfig *config.Config) (*httptest.Server, *Provider, http.Handler, *Config) {
 	mgrs := managers.New()
 	mgrs.Set("identity", identityManagers.NewDummyIdentityManager(
 		&identity.Config{},
 		"unittestuser",
 	))
-	mgrs.Set("code", codeManagers.NewMemoryMapManager(ctx))
+	mgrs.Set("code", codeManagers.NewMemoryMapManager(ctx, 0))
 	encryptionManager, _ := identityManagers.NewEncryptionManager(nil)
 	mgrs.Set("encryption", encryptionManager)
 	mgrs.Set("clients", &clients.Registry{})
 
 	cfg := &Config{
-		Config: &config.Config{
-			Logger: logger,
-		},
+		Config: serverConfig,
 
 		IssuerIdentifier:  "http://localhost:8777",
 		WellKnownPath:     "/.well-known/openid-configuration",
+		OAuthMetadataPath: "/.well-known/oauth-authorization-server",
 		JwksPath:          "/konnect/v1/jwks.json",
 		AuthorizationPath: "/konnect/v1/authorize",
 		TokenPath:         "/konnect/v1/token",
 		UserInfoPath:      "/konnect/v1/userinfo",
+
+		AccessTokenDuration:  10 * time.Minute,
+		IDTokenDuration:      time.Hour,
+		RefreshTokenDuration: 24 * time.Hour,
 	}
 
 	p, err := NewProvider(cfg)
@@ -111,3 +123,162 @@ func TestNewTestProvider(t *testing.T) {
 	defer cancel()
 	NewTestProvider(ctx, t)
 }
+
+func TestMaxPostLogoutRedirectURIs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, max := range []int{0, 3} {
+		httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:                    logger,
+			MaxPostLogoutRedirectURIs: max,
+		})
+		httpServer.Close()
+
+		// Zero means no limit and is not replaced by a default.
+		if provider.registrationPolicy.MaxPostLogoutRedirectURIs != max {
+			t.Errorf("unexpected post_logout_redirect_uris limit, got %d, want %d", provider.registrationPolicy.MaxPostLogoutRedirectURIs, max)
+		}
+	}
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, _, router, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:         logger,
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+	})
+	defer httpServer.Close()
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://spa.example.org", true},
+		{"https://evil.example.net", false},
+		{"http://app.example.com", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/.well-known/openid-configuration", nil)
+		req.Header.Set("Origin", test.origin)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code for origin %s: got %v want %v", test.origin, status, http.StatusOK)
+		}
+		allowOrigin := rr.Header().Get("Access-Control-Allow-Origin")
+		if test.allowed {
+			if allowOrigin != test.origin {
+				t.Errorf("expected origin %s to be echoed, got %q", test.origin, allowOrigin)
+			}
+			if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("expected credentials to be allowed for origin %s", test.origin)
+			}
+		} else if allowOrigin != "" {
+			t.Errorf("expected origin %s to be rejected, got %q", test.origin, allowOrigin)
+		}
+	}
+}
+
+func TestCORSClientOrigins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, router, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:             logger,
+		AllowClientOrigins: true,
+		AllowedOrigins:     []string{"https://*.example.org"},
+	})
+	defer httpServer.Close()
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	for _, client := range []*clients.ClientRegistration{
+		{ID: "spa", RedirectURIs: []string{"https://spa.example.com/callback", "https://other.example.com:8443/cb"}},
+		{ID: "native", ApplicationType: oidc.ApplicationTypeNative, RedirectURIs: []string{"http://127.0.0.1/cb"}},
+	} {
+		if err = registry.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://spa.example.com", true},
+		{"https://SPA.example.com:443", true},
+		{"https://other.example.com:8443", true},
+		{"https://app.example.org", true},
+		{"https://other.example.com", false},
+		{"http://spa.example.com", false},
+		{"https://evil.example.net", false},
+		{"http://127.0.0.1", false},
+		{"null", false},
+	}
+
+	for _, path := range []string{"/konnect/v1/token", "/konnect/v1/userinfo"} {
+		for _, test := range tests {
+			req := httptest.NewRequest(http.MethodOptions, "http://localhost:8777"+path, nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			allowOrigin := rr.Header().Get("Access-Control-Allow-Origin")
+			if test.allowed && allowOrigin != test.origin {
+				t.Errorf("%s: expected origin %s to be echoed, got %q", path, test.origin, allowOrigin)
+			} else if !test.allowed && allowOrigin != "" {
+				t.Errorf("%s: expected origin %s to be rejected, got %q", path, test.origin, allowOrigin)
+			}
+		}
+	}
+
+	// Other endpoints only use the configured origins.
+	req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/.well-known/openid-configuration", nil)
+	req.Header.Set("Origin", "https://spa.example.com")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if v := rr.Header().Get("Access-Control-Allow-Origin"); v != "" {
+		t.Errorf("discovery returned wrong Access-Control-Allow-Origin: %q", v)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, _, router, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger:         logger,
+		AllowedOrigins: []string{"https://app.example.com"},
+	})
+	defer httpServer.Close()
+
+	req := httptest.NewRequest(http.MethodOptions, "http://localhost:8777/konnect/v1/token", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNoContent && status != http.StatusOK {
+		t.Errorf("preflight returned wrong status code: got %v", status)
+	}
+	if v := rr.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example.com" {
+		t.Errorf("preflight returned wrong Access-Control-Allow-Origin: %q", v)
+	}
+	if v := rr.Header().Get("Access-Control-Allow-Methods"); v != http.MethodPost {
+		t.Errorf("preflight returned wrong Access-Control-Allow-Methods: %q", v)
+	}
+	if v := rr.Header().Get("Access-Control-Allow-Headers"); !strings.EqualFold(v, "Content-Type") {
+		t.Errorf("preflight returned wrong Access-Control-Allow-Headers: %q", v)
+	}
+}
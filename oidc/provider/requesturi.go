@@ -0,0 +1,90 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/libregraph/lico/utils"
+)
+
+const (
+	requestURISizeLimit = 1024 * 64
+	requestURITimeout   = 10 * time.Second
+
+	// pushedRequestURIPrefix is the prefix of request_uri values issued by
+	// pushed authorization request endpoints as specified at
+	// https://www.rfc-editor.org/rfc/rfc9126.html#section-2.2.
+	pushedRequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+)
+
+// resolveRequestURI fetches the request object referenced by the request_uri
+// parameter of the provided request and replaces the request_uri form value
+// with an equivalent request form value. Only request URIs which are
+// registered for the requesting client are fetched, as specified at
+// https://openid.net/specs/openid-connect-core-1_0.html#RequestUriParameter.
+func (p *Provider) resolveRequestURI(req *http.Request) error {
+	requestURI := req.Form.Get("request_uri")
+	if req.Form.Get("request") != "" {
+		return errors.New("request and request_uri must not be used together")
+	}
+	if strings.HasPrefix(requestURI, pushedRequestURIPrefix) {
+		return errors.New("pushed authorization requests are not supported")
+	}
+
+	registration, _ := p.clients.Get(req.Context(), req.Form.Get("client_id"))
+	if registration == nil {
+		return errors.New("request_uri requires a registered client")
+	}
+	if !registration.AllowsRequestURI(requestURI) {
+		return errors.New("request_uri not registered for client")
+	}
+
+	client := utils.HTTPClientWithLimits(&http.Client{
+		Transport: p.Config.Config.HTTPTransport,
+	}, requestURITimeout, requestURISizeLimit)
+	fetchReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, requestURI, nil)
+	if err != nil {
+		return fmt.Errorf("invalid request_uri: %w", err)
+	}
+	fetchReq.Header.Set("Accept", "application/oauth-authz-req+jwt, application/jwt")
+	fetchReq.Header.Set("User-Agent", utils.DefaultHTTPUserAgent)
+
+	response, err := client.Do(fetchReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch request_uri: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch request_uri: unexpected response status: %d", response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to fetch request_uri: %w", err)
+	}
+
+	req.Form.Del("request_uri")
+	req.Form.Set("request", strings.TrimSpace(string(body)))
+
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// tokenRevocations keeps the ids of revoked tokens until the revoked tokens
+// expire.
+type tokenRevocations struct {
+	sync.RWMutex
+
+	table map[string]time.Time
+}
+
+func newTokenRevocations() *tokenRevocations {
+	return &tokenRevocations{
+		table: make(map[string]time.Time),
+	}
+}
+
+// revoke revokes the tokens with the provided token strings, keeping their id
+// until they expire.
+func (tr *tokenRevocations) revoke(tokenStrings ...string) {
+	now := time.Now()
+
+	tr.Lock()
+	defer tr.Unlock()
+
+	for id, expiresAt := range tr.table {
+		if expiresAt.Before(now) {
+			delete(tr.table, id)
+		}
+	}
+	for _, tokenString := range tokenStrings {
+		id, expiresAt, ok := tokenIDAndExpiration(tokenString)
+		if !ok {
+			continue
+		}
+		tr.table[id] = expiresAt
+	}
+}
+
+// isRevoked returns true if the token with the provided id has been revoked.
+func (tr *tokenRevocations) isRevoked(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	tr.RLock()
+	defer tr.RUnlock()
+
+	_, revoked := tr.table[id]
+	return revoked
+}
+
+// tokenIDAndExpiration returns the jti and exp claim values of the provided
+// token string, created by this provider. The token is not validated.
+func tokenIDAndExpiration(tokenString string) (string, time.Time, bool) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return "", time.Time{}, false
+	}
+	id, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if id == "" {
+		return "", time.Time{}, false
+	}
+
+	return id, time.Unix(int64(exp), 0), true
+}
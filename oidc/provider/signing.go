@@ -19,8 +19,13 @@ package provider
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 
 	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/libregraph/lico/signing"
 )
 
 // A SigningKey bundles a signer with meta data and a signign method.
@@ -29,3 +34,35 @@ type SigningKey struct {
 	PrivateKey    crypto.Signer
 	SigningMethod jwt.SigningMethod
 }
+
+// jwkAlgorithm returns the alg value to publish for the provided validation
+// key with the provided kid. The key used for signing by default gets the
+// default signing method. Other keys get the alg their key type implies, RSA
+// keys can be used with multiple algs and thus follow the default signing
+// method if it is RSA based. An empty string is returned if unknown.
+func (p *Provider) jwkAlgorithm(kid string, key crypto.PublicKey) string {
+	if sk, ok := p.signingKeys[p.signingMethodDefault]; ok && sk.ID == kid {
+		return p.signingMethodDefault.Alg()
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256.Alg()
+		case 384:
+			return jwt.SigningMethodES384.Alg()
+		case 521:
+			return jwt.SigningMethodES512.Alg()
+		}
+	case ed25519.PublicKey:
+		return signing.SigningMethodEdDSA.Alg()
+	case *rsa.PublicKey:
+		switch p.signingMethodDefault.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return p.signingMethodDefault.Alg()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	konnect "github.com/libregraph/lico"
+)
+
+// ValidateStaticClaims returns an error if any of the provided static claims
+// would set a protocol reserved claim.
+func ValidateStaticClaims(claims map[string]interface{}) error {
+	for claim := range claims {
+		if claim == "" {
+			return fmt.Errorf("static claims contain an empty claim name")
+		}
+		if konnect.IsReservedClaim(claim) {
+			return fmt.Errorf("static claim %s is reserved", claim)
+		}
+	}
+
+	return nil
+}
+
+// LoadStaticClaimsFromFile loads static claims from the provided YAML or
+// JSON file and validates them.
+func LoadStaticClaimsFromFile(fn string) (map[string]interface{}, error) {
+	claimsFile, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static claims file: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err = yaml.Unmarshal(claimsFile, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse static claims file: %w", err)
+	}
+	if err = ValidateStaticClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// injectStaticClaims adds the static claims of the accociated provider to
+// the provided claims map, never overriding claims which are already set.
+func (p *Provider) injectStaticClaims(claims map[string]interface{}) {
+	for claim, value := range p.staticClaims {
+		if _, ok := claims[claim]; ok {
+			continue
+		}
+		claims[claim] = value
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStaticClaimsFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		data  string
+		valid bool
+	}{
+		{"tenant: tenant-1\nrealm: example\n", true},
+		{`{"tenant": "tenant-1", "groups_source": {"name": "ldap"}}`, true},
+		{"tenant: tenant-1\nsub: admin\n", false},
+		{"iss: https://evil.example.com\n", false},
+		{"aud: other\n", false},
+		{"exp: 0\n", false},
+		{"lg.i: {}\n", false},
+	} {
+		fn := filepath.Join(dir, "claims.yaml")
+		if err := os.WriteFile(fn, []byte(tc.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := LoadStaticClaimsFromFile(fn)
+		if tc.valid {
+			if err != nil {
+				t.Errorf("expected static claims %q to load: %v", tc.data, err)
+			} else if claims["tenant"] != "tenant-1" {
+				t.Errorf("unexpected static claims for %q: %v", tc.data, claims)
+			}
+		} else if err == nil {
+			t.Errorf("expected static claims %q to be rejected", tc.data)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"sort"
+	"time"
+
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+// applyClientStepUp tightens the max age of the provided authentication
+// request to the maximum authentication age required by the provided client
+// registration, if any.
+func (p *Provider) applyClientStepUp(ar *payload.AuthenticationRequest, registration *clients.ClientRegistration) {
+	if registration.MaxAuthAge > 0 {
+		tightenMaxAge(ar, time.Duration(registration.MaxAuthAge)*time.Second)
+	}
+}
+
+// applyACRStepUp tightens the max age of the provided authentication request
+// to the maximum authentication age configured for the first of its
+// acr_values which is known, and remembers that value as satisfied acr.
+func (p *Provider) applyACRStepUp(ar *payload.AuthenticationRequest) {
+	for _, acr := range ar.ACRValues {
+		if maxAge, ok := p.acrMaxAges[acr]; ok {
+			tightenMaxAge(ar, maxAge)
+			ar.ACR = acr
+			return
+		}
+	}
+}
+
+// acrValuesSupported returns the sorted configured acr values.
+func (p *Provider) acrValuesSupported() []string {
+	if len(p.acrMaxAges) == 0 {
+		return nil
+	}
+	acrValues := make([]string, 0, len(p.acrMaxAges))
+	for acr := range p.acrMaxAges {
+		acrValues = append(acrValues, acr)
+	}
+	sort.Strings(acrValues)
+
+	return acrValues
+}
+
+func tightenMaxAge(ar *payload.AuthenticationRequest, maxAge time.Duration) {
+	if ar.MaxAge == 0 || maxAge < ar.MaxAge {
+		ar.MaxAge = maxAge
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+// sessionAgeIdentityManager simulates an existing session which was
+// authenticated at logonAt. Like the identifier, it requires a new sign-in
+// when the session is older than the max age of the request.
+type sessionAgeIdentityManager struct {
+	identity.Manager
+
+	logonAt time.Time
+}
+
+func (im *sessionAgeIdentityManager) Authenticate(ctx context.Context, rw http.ResponseWriter, req *http.Request, ar *payload.AuthenticationRequest, next identity.Manager) (identity.AuthRecord, error) {
+	if ar.MaxAge > 0 && im.logonAt.Add(ar.MaxAge).Before(time.Now()) {
+		return nil, ar.NewError(oidc.ErrorCodeOIDCLoginRequired, "session too old")
+	}
+	auth, err := im.Manager.Authenticate(ctx, rw, req, ar, next)
+	if err == nil {
+		auth.SetAuthTime(im.logonAt)
+	}
+	return auth, err
+}
+
+func TestAuthorizeHandlerStepUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+		Logger: logger,
+		ACRMaxAges: map[string]time.Duration{
+			"urn:example:acr:high": 5 * time.Minute,
+		},
+	})
+	defer httpServer.Close()
+
+	if err := provider.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.encryptionManager.SetKey(make([]byte, provider.encryptionManager.GetKeySize())); err != nil {
+		t.Fatal(err)
+	}
+	im := &sessionAgeIdentityManager{
+		Manager: provider.identityManager,
+		logonAt: time.Now().Add(-10 * time.Minute),
+	}
+	provider.identityManager = im
+
+	registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.clients = registry
+	for _, client := range []*clients.ClientRegistration{
+		{ID: "client", RedirectURIs: []string{"https://client.example.com/cb"}},
+		{ID: "client-sensitive", RedirectURIs: []string{"https://client.example.com/cb"}, MaxAuthAge: 300},
+	} {
+		if err = registry.Register(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nonce := 0
+	authorize := func(clientID string, acrValues string) url.Values {
+		nonce++
+		values := url.Values{}
+		values.Set("client_id", clientID)
+		values.Set("scope", oidc.ScopeOpenID)
+		values.Set("response_type", oidc.ResponseTypeIDToken)
+		values.Set("redirect_uri", "https://client.example.com/cb")
+		values.Set("nonce", fmt.Sprintf("nonce-%d", nonce))
+		values.Set("prompt", oidc.PromptNone)
+		if acrValues != "" {
+			values.Set("acr_values", acrValues)
+		}
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:8777/konnect/v1/authorize?"+values.Encode(), nil)
+		rr := httptest.NewRecorder()
+
+		provider.AuthorizeHandler(rr, req)
+
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fragment, _ := url.ParseQuery(location.Fragment)
+		return fragment
+	}
+
+	// Normal requests reuse the session.
+	if fragment := authorize("client", ""); fragment.Get("id_token") == "" {
+		t.Errorf("expected normal request to reuse session, got %v", fragment)
+	}
+	if fragment := authorize("client", "urn:example:acr:unknown"); fragment.Get("id_token") == "" {
+		t.Errorf("expected request with unknown acr_values to reuse session, got %v", fragment)
+	}
+
+	// Step-up requests require fresh authentication.
+	if fragment := authorize("client", "urn:example:acr:high"); fragment.Get("error") != oidc.ErrorCodeOIDCLoginRequired {
+		t.Errorf("expected acr step-up request to require sign-in, got %v", fragment)
+	}
+	if fragment := authorize("client-sensitive", ""); fragment.Get("error") != oidc.ErrorCodeOIDCLoginRequired {
+		t.Errorf("expected request of sensitive client to require sign-in, got %v", fragment)
+	}
+
+	// After fresh authentication, acr and auth_time are reflected.
+	im.logonAt = time.Now()
+	fragment := authorize("client", "urn:example:acr:unknown urn:example:acr:high")
+	claims := jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(fragment.Get("id_token"), claims); err != nil {
+		t.Fatalf("failed to parse id token: %v (%v)", err, fragment)
+	}
+	if acr, _ := claims["acr"].(string); acr != "urn:example:acr:high" {
+		t.Errorf("expected acr claim in id token, got %v", claims["acr"])
+	}
+	if authTime, _ := claims[oidc.AuthTimeClaim].(float64); int64(authTime) != im.logonAt.Unix() {
+		t.Errorf("expected auth_time claim %d in id token, got %v", im.logonAt.Unix(), claims[oidc.AuthTimeClaim])
+	}
+}
+
+func TestApplyStepUp(t *testing.T) {
+	provider := &Provider{
+		acrMaxAges: map[string]time.Duration{
+			"high":   5 * time.Minute,
+			"medium": time.Hour,
+		},
+	}
+
+	tests := []struct {
+		maxAge      time.Duration
+		acrValues   []string
+		clientAge   int64
+		expected    time.Duration
+		expectedACR string
+	}{
+		{0, nil, 0, 0, ""},
+		{0, nil, 600, 10 * time.Minute, ""},
+		{time.Minute, nil, 600, time.Minute, ""},
+		{0, []string{"unknown", "medium", "high"}, 0, time.Hour, "medium"},
+		{0, []string{"medium"}, 600, 10 * time.Minute, "medium"},
+		{2 * time.Hour, []string{"high"}, 0, 5 * time.Minute, "high"},
+	}
+
+	for _, test := range tests {
+		ar := &payload.AuthenticationRequest{
+			MaxAge:    test.maxAge,
+			ACRValues: test.acrValues,
+		}
+		provider.applyClientStepUp(ar, &clients.ClientRegistration{MaxAuthAge: test.clientAge})
+		provider.applyACRStepUp(ar)
+		if ar.MaxAge != test.expected || ar.ACR != test.expectedACR {
+			t.Errorf("unexpected step-up for %v %v %d: got %v %q want %v %q", test.maxAge, test.acrValues, test.clientAge, ar.MaxAge, ar.ACR, test.expected, test.expectedACR)
+		}
+	}
+}
@@ -47,19 +47,30 @@ func (p *Provider) makeAccessToken(ctx context.Context, audience string, auth id
 	authorizedScopes := auth.AuthorizedScopes()
 	authorizedScopesList := payload.ScopesValue(makeArrayFromBoolMap(authorizedScopes))
 
+	audiences, err := p.accessTokenAudiences(ctx, audience, authorizedScopes)
+	if err != nil {
+		return "", err
+	}
+
 	accessTokenClaims := konnect.AccessTokenClaims{
 		TokenType:               konnect.TokenTypeAccessToken,
 		AuthorizedScopesList:    authorizedScopesList,
 		AuthorizedClaimsRequest: auth.AuthorizedClaims(),
+		ClientID:                audience,
 		StandardClaims: jwt.StandardClaims{
 			Issuer:    p.issuerIdentifier,
 			Subject:   auth.Subject(),
-			Audience:  audience,
-			ExpiresAt: time.Now().Add(p.accessTokenDuration).Unix(),
+			Audience:  audiences[0],
+			ExpiresAt: time.Now().Add(p.accessTokenDurationForClient(ctx, audience)).Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Id:        rndm.GenerateRandomString(24),
 		},
 	}
+	if len(audiences) > 1 || audiences[0] != audience {
+		// Token is not issued for the client itself, so set the client as
+		// authorized party.
+		accessTokenClaims.AuthorizedParty = audience
+	}
 
 	user := auth.User()
 	if user != nil {
@@ -69,41 +80,57 @@ func (p *Provider) makeAccessToken(ctx context.Context, audience string, auth id
 		accessTokenClaims.IdentityProvider = auth.Manager().Name()
 	}
 
-	// Support additional custom user specific claims.
+	// Support additional custom user specific claims and multiple audiences.
 	var finalAccessTokenClaims jwt.Claims = accessTokenClaims
-	if accessTokenClaims.IdentityClaims != nil {
+	if accessTokenClaims.IdentityClaims != nil || len(audiences) > 1 || len(p.staticClaims) > 0 || len(p.claimTransforms) > 0 || p.maxClaimValues > 0 {
 		accessTokenClaimsMap, err := payload.ToMap(accessTokenClaims)
 		if err != nil {
 			return "", err
 		}
 
-		delete(accessTokenClaimsMap[konnect.IdentityClaim].(map[string]interface{}), konnect.InternalExtraIDTokenClaimsClaim)
-		delete(accessTokenClaimsMap[konnect.IdentityClaim].(map[string]interface{}), konnect.InternalExtraAccessTokenClaimsClaim)
+		if len(audiences) > 1 {
+			accessTokenClaimsMap[oidc.AudienceClaim] = audiences
+		}
 
-		// Look for special internal key, if its a map all claims in there are
-		// elevated to top level.
-		extraClaimsMap, _ := accessTokenClaims.IdentityClaims[konnect.InternalExtraAccessTokenClaimsClaim].(map[string]interface{})
-		if extraClaimsMap != nil {
-			// Inject extra claims.
-			for claim, value := range extraClaimsMap {
-				switch claim {
-				case konnect.ScopesClaim:
-					// Support to extend the scopes.
-					extraScopesList, _ := value.(string)
-					if extraScopesList != "" {
-						authorizedScopesList = append(authorizedScopesList, extraScopesList)
-						value = authorizedScopesList
-					}
-				default:
-					if _, ok := accessTokenClaimsMap[claim]; ok {
-						// Prevent override of existing claims, only allow new claims.
-						continue
+		if accessTokenClaims.IdentityClaims != nil {
+			delete(accessTokenClaimsMap[konnect.IdentityClaim].(map[string]interface{}), konnect.InternalExtraIDTokenClaimsClaim)
+			delete(accessTokenClaimsMap[konnect.IdentityClaim].(map[string]interface{}), konnect.InternalExtraAccessTokenClaimsClaim)
+
+			// Look for special internal key, if its a map all claims in there are
+			// elevated to top level.
+			extraClaimsMap, _ := accessTokenClaims.IdentityClaims[konnect.InternalExtraAccessTokenClaimsClaim].(map[string]interface{})
+			if extraClaimsMap != nil {
+				// Inject extra claims.
+				for claim, value := range extraClaimsMap {
+					switch claim {
+					case konnect.ScopesClaim:
+						// Support to extend the scopes.
+						extraScopesList, _ := value.(string)
+						if extraScopesList != "" {
+							authorizedScopesList = append(authorizedScopesList, extraScopesList)
+							value = authorizedScopesList
+						}
+					default:
+						if _, ok := accessTokenClaimsMap[claim]; ok {
+							// Prevent override of existing claims, only allow new claims.
+							continue
+						}
+						if scope, ok := p.scopeForClaim(claim); ok && !authorizedScopes[scope] {
+							// Never include claims for scopes which were not authorized.
+							continue
+						}
 					}
+					accessTokenClaimsMap[claim] = value
 				}
-				accessTokenClaimsMap[claim] = value
 			}
 		}
 
+		p.claimTransforms.Apply(accessTokenClaimsMap)
+		if err = p.limitClaims(accessTokenClaimsMap); err != nil {
+			return "", err
+		}
+		p.injectStaticClaims(accessTokenClaimsMap)
+
 		finalAccessTokenClaims = jwt.MapClaims(accessTokenClaimsMap)
 	}
 
@@ -113,6 +140,19 @@ func (p *Provider) makeAccessToken(ctx context.Context, audience string, auth id
 	return accessToken.SignedString(sk.PrivateKey)
 }
 
+// scopeForClaim returns the scope the provided claim belongs to, if any. In
+// addition to the known scoped claims, the claims with configurable names of
+// the identity manager are looked up.
+func (p *Provider) scopeForClaim(claim string) (string, bool) {
+	if identityManagerWithClaimScopes, ok := p.identityManager.(identity.ManagerWithClaimScopes); ok {
+		if scope, ok := identityManagerWithClaimScopes.ClaimScopes()[claim]; ok {
+			return scope, true
+		}
+	}
+
+	return payload.GetScopeForClaim(claim)
+}
+
 func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRequest, auth identity.AuthRecord, session *payload.Session, accessTokenString string, codeString string, signingMethod jwt.SigningMethod) (string, error) {
 	sk, ok := p.getSigningKey(signingMethod)
 	if !ok {
@@ -130,7 +170,7 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 			Issuer:    p.issuerIdentifier,
 			Subject:   publicSubject,
 			Audience:  ar.ClientID,
-			ExpiresAt: time.Now().Add(p.idTokenDuration).Unix(),
+			ExpiresAt: time.Now().Add(p.idTokenDurationForClient(ctx, ar.ClientID)).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
@@ -152,6 +192,10 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 	withCode := codeString != ""
 	withAuthTime := ar.MaxAge > 0
 	withIDTokenClaimsRequest := authorizedClaimsRequest != nil && authorizedClaimsRequest.IDToken != nil
+	// In minimal mode, scopes never add claims to the ID token. Only claims
+	// which are explicitly requested with the claims parameter are added.
+	withMinimalClaims := p.minimalIDTokenClaims && !withIDTokenClaimsRequest
+	withScopeClaims := !withAccessToken && !p.minimalIDTokenClaims
 
 	user := auth.User()
 	if user == nil {
@@ -169,7 +213,7 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 		}
 	}
 
-	if !withAccessToken || withIDTokenClaimsRequest {
+	if withScopeClaims || withIDTokenClaimsRequest {
 		var userID string
 		if accessTokenClaims.IdentityClaims != nil {
 			if userIDString, ok := accessTokenClaims.IdentityClaims[konnect.IdentifiedUserIDClaim]; ok {
@@ -203,12 +247,18 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 			return "", fmt.Errorf("user not found")
 		}
 
-		if (!withAccessToken && ar.Scopes[oidc.ScopeProfile]) || requestedScopesMap[oidc.ScopeProfile] {
+		if (withScopeClaims && ar.Scopes[oidc.ScopeProfile]) || requestedScopesMap[oidc.ScopeProfile] {
 			idTokenClaims.ProfileClaims = konnectoidc.NewProfileClaims(freshAuth.Claims(oidc.ScopeProfile)[0])
 		}
-		if (!withAccessToken && ar.Scopes[oidc.ScopeEmail]) || requestedScopesMap[oidc.ScopeEmail] {
+		if (withScopeClaims && ar.Scopes[oidc.ScopeEmail]) || requestedScopesMap[oidc.ScopeEmail] {
 			idTokenClaims.EmailClaims = konnectoidc.NewEmailClaims(freshAuth.Claims(oidc.ScopeEmail)[0])
 		}
+		if (withScopeClaims && ar.Scopes[konnectoidc.ScopePhone]) || requestedScopesMap[konnectoidc.ScopePhone] {
+			idTokenClaims.PhoneClaims = konnectoidc.NewPhoneClaims(freshAuth.Claims(konnectoidc.ScopePhone)[0])
+		}
+		if (withScopeClaims && ar.Scopes[konnectoidc.ScopeAddress]) || requestedScopesMap[konnectoidc.ScopeAddress] {
+			idTokenClaims.AddressClaims = konnectoidc.NewAddressClaims(freshAuth.Claims(konnectoidc.ScopeAddress)[0])
+		}
 
 		auth = freshAuth
 	}
@@ -241,6 +291,10 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 			idTokenClaims.AuthTime = time.Now().Unix()
 		}
 	}
+	if ar.ACR != "" {
+		// Reflect the authentication context class satisfied by step-up.
+		idTokenClaims.ACR = ar.ACR
+	}
 
 	// To support extra non-standard claims in ID token, convert claim set to
 	// map.
@@ -249,7 +303,7 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 		return "", err
 	}
 
-	if accessTokenClaims.IdentityClaims != nil {
+	if accessTokenClaims.IdentityClaims != nil && !withMinimalClaims {
 		// Inject available extra ID token claims.
 		extraClaimsMap, _ := accessTokenClaims.IdentityClaims[konnect.InternalExtraIDTokenClaimsClaim].(map[string]interface{})
 		if extraClaimsMap != nil {
@@ -259,7 +313,7 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 		}
 	}
 
-	if !withAccessToken && accessTokenClaims.IdentityClaims != nil {
+	if withScopeClaims && accessTokenClaims.IdentityClaims != nil {
 		// Include requested scope data in ID token when no access token is
 		// generated - additional custom user specific claims.
 
@@ -272,6 +326,19 @@ func (p *Provider) makeIDToken(ctx context.Context, ar *payload.AuthenticationRe
 		}
 	}
 
+	// Never include claims for scopes which were not authorized.
+	var idTokenClaimsRequestMap *payload.ClaimsRequestMap
+	if withIDTokenClaimsRequest {
+		idTokenClaimsRequestMap = authorizedClaimsRequest.IDToken
+	}
+	payload.FilterClaimsByScopes(idTokenClaimsMap, auth.AuthorizedScopes(), idTokenClaimsRequestMap)
+
+	p.claimTransforms.Apply(idTokenClaimsMap)
+	if err := p.limitClaims(idTokenClaimsMap); err != nil {
+		return "", err
+	}
+	p.injectStaticClaims(idTokenClaimsMap)
+
 	// Create signed token.
 	idToken := jwt.NewWithClaims(sk.SigningMethod, jwt.MapClaims(idTokenClaimsMap))
 	idToken.Header[oidc.JWTHeaderKeyID] = sk.ID
@@ -308,7 +375,7 @@ func (p *Provider) makeRefreshToken(ctx context.Context, audience string, auth i
 			Issuer:    p.issuerIdentifier,
 			Subject:   auth.Subject(),
 			Audience:  audience,
-			ExpiresAt: time.Now().Add(p.refreshTokenDuration).Unix(),
+			ExpiresAt: time.Now().Add(p.refreshTokenDurationForClient(ctx, audience)).Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Id:        rndm.GenerateRandomString(24),
 		},
@@ -340,6 +407,27 @@ func (p *Provider) makeJWT(ctx context.Context, signingMethod jwt.SigningMethod,
 	return token.SignedString(sk.PrivateKey)
 }
 
+// validateIDTokenHint returns the validation key for the provided id_token_hint
+// token. Only hints issued by the accociated provider are accepted.
+func (p *Provider) validateIDTokenHint(token *jwt.Token) (interface{}, error) {
+	if claims, ok := token.Claims.(*konnectoidc.IDTokenClaims); !ok || claims.Issuer != p.issuerIdentifier {
+		return nil, fmt.Errorf("id_token_hint was not issued by this issuer")
+	}
+
+	return p.validateJWT(token)
+}
+
+// validateIssuedJWT returns the validation key for the provided access or
+// refresh token. Only tokens issued by the associated provider are accepted,
+// so tenants sharing the same keys do not accept each other's tokens.
+func (p *Provider) validateIssuedJWT(token *jwt.Token) (interface{}, error) {
+	if claims, ok := token.Claims.(interface{ VerifyIssuer(string, bool) bool }); !ok || !claims.VerifyIssuer(p.issuerIdentifier, true) {
+		return nil, fmt.Errorf("token was not issued by this issuer")
+	}
+
+	return p.validateJWT(token)
+}
+
 func (p *Provider) validateJWT(token *jwt.Token) (interface{}, error) {
 	rawAlg, ok := token.Header[oidc.JWTHeaderAlg]
 	if !ok {
@@ -0,0 +1,395 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/libregraph/oidc-go"
+
+	konnect "github.com/libregraph/lico"
+	"github.com/libregraph/lico/config"
+	"github.com/libregraph/lico/identity"
+	"github.com/libregraph/lico/identity/clients"
+	konnectoidc "github.com/libregraph/lico/oidc"
+	"github.com/libregraph/lico/oidc/payload"
+)
+
+func TestMakeIDTokenMinimalClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scopes := map[string]bool{
+		oidc.ScopeOpenID:  true,
+		oidc.ScopeProfile: true,
+		oidc.ScopeEmail:   true,
+	}
+	emailClaimsRequest := &payload.ClaimsRequest{
+		IDToken: &payload.ClaimsRequestMap{
+			oidc.EmailClaim: &payload.ClaimsRequestValue{},
+		},
+	}
+
+	for _, tc := range []struct {
+		minimal       bool
+		claimsRequest *payload.ClaimsRequest
+		withEmail     bool
+	}{
+		{false, nil, true},
+		{true, nil, false},
+		{true, emailClaimsRequest, true},
+	} {
+		httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:               logger,
+			MinimalIDTokenClaims: tc.minimal,
+		})
+		defer httpServer.Close()
+
+		ar := &payload.AuthenticationRequest{
+			ClientID: "unittest",
+			Scopes:   scopes,
+			Nonce:    "nonce-value",
+		}
+		authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), scopes, tc.claimsRequest, nil)
+		auth.SetUser(authenticated.User())
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		idTokenString, err := provider.makeIDToken(ctx, ar, auth, nil, "", "", jwt.SigningMethodRS256)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(idTokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, claim := range []string{oidc.SubjectIdentifierClaim, oidc.IssuerIdentifierClaim, oidc.AudienceClaim, oidc.ExpirationClaim, oidc.IssuedAtClaim, "nonce"} {
+			if _, ok := claims[claim]; !ok {
+				t.Errorf("required claim %v missing in id token (minimal: %v): %v", claim, tc.minimal, claims)
+			}
+		}
+		if _, ok := claims[oidc.EmailClaim]; ok != tc.withEmail {
+			t.Errorf("email claim presence was incorrect (minimal: %v), got %v", tc.minimal, claims)
+		}
+	}
+}
+
+func TestMakeAccessTokenResourceServerAudiences(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, tc := range []struct {
+		allowMultiple bool
+		scopes        []string
+		audiences     []string
+		rejected      bool
+	}{
+		{false, []string{oidc.ScopeOpenID}, []string{"client"}, false},
+		{false, []string{oidc.ScopeOpenID, "api1.read"}, []string{"https://api1.example.com"}, false},
+		{false, []string{oidc.ScopeOpenID, "api1.read", "api2.read"}, nil, true},
+		{true, []string{oidc.ScopeOpenID, "api1.read", "api2.read"}, []string{"https://api1.example.com", "https://api2.example.com"}, false},
+	} {
+		httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:                 logger,
+			AllowMultipleAudiences: tc.allowMultiple,
+		})
+		defer httpServer.Close()
+
+		registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.clients = registry
+		for _, resourceServer := range []*clients.ResourceServer{
+			{ID: "https://api1.example.com", Scopes: []string{"api1.read"}},
+			{ID: "https://api2.example.com", Scopes: []string{"api2.read"}},
+		} {
+			if err = registry.RegisterResourceServer(resourceServer); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = registry.Register(&clients.ClientRegistration{
+			ID:              "client",
+			RedirectURIs:    []string{"https://client.example.com/cb"},
+			ResourceServers: []string{"https://api1.example.com", "https://api2.example.com"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		scopes := make(map[string]bool)
+		for _, scope := range tc.scopes {
+			scopes[scope] = true
+		}
+		auth := identity.NewAuthRecord(provider.identityManager, "sub", scopes, nil, nil)
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		accessTokenString, err := provider.makeAccessToken(ctx, "client", auth, jwt.SigningMethodRS256)
+		if tc.rejected {
+			if oauth2Err, ok := err.(*konnectoidc.OAuth2Error); !ok || oauth2Err.ErrorID != konnectoidc.ErrorCodeOAuth2InvalidTarget {
+				t.Errorf("expected invalid_target error for scopes %v, got %v", tc.scopes, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for scopes %v: %v", tc.scopes, err)
+		}
+
+		claims := &konnect.AccessTokenClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(accessTokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(claims.Audiences, tc.audiences) {
+			t.Errorf("unexpected audiences for scopes %v: got %v want %v", tc.scopes, claims.Audiences, tc.audiences)
+		}
+		if claims.Audience != tc.audiences[0] {
+			t.Errorf("unexpected audience for scopes %v: got %v", tc.scopes, claims.Audience)
+		}
+		wantAuthorizedParty := ""
+		if tc.audiences[0] != "client" {
+			wantAuthorizedParty = "client"
+		}
+		if claims.AuthorizedParty != wantAuthorizedParty {
+			t.Errorf("unexpected azp for scopes %v: got %q want %q", tc.scopes, claims.AuthorizedParty, wantAuthorizedParty)
+		}
+	}
+}
+
+func TestMakeAccessTokenAdditionalAudiences(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, tc := range []struct {
+		additional      []string
+		scopes          []string
+		audiences       []string
+		authorizedParty string
+	}{
+		{[]string{"https://gateway.example.com"}, []string{oidc.ScopeOpenID}, []string{"client", "https://gateway.example.com"}, "client"},
+		{[]string{"https://gateway.example.com", "client"}, []string{oidc.ScopeOpenID}, []string{"client", "https://gateway.example.com"}, "client"},
+		{[]string{"https://api1.example.com"}, []string{oidc.ScopeOpenID, "api1.read"}, []string{"https://api1.example.com"}, "client"},
+		{[]string{"https://gateway.example.com"}, []string{oidc.ScopeOpenID, "api1.read"}, []string{"https://api1.example.com", "https://gateway.example.com"}, "client"},
+		{[]string{"client"}, []string{oidc.ScopeOpenID}, []string{"client"}, ""},
+	} {
+		httpServer, provider, _, _ := newTestProviderWithConfig(ctx, t, &config.Config{
+			Logger:              logger,
+			AdditionalAudiences: tc.additional,
+		})
+		defer httpServer.Close()
+
+		registry, err := clients.NewRegistry(ctx, nil, "", false, 0, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider.clients = registry
+		if err = registry.RegisterResourceServer(&clients.ResourceServer{ID: "https://api1.example.com", Scopes: []string{"api1.read"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err = registry.Register(&clients.ClientRegistration{
+			ID:              "client",
+			RedirectURIs:    []string{"https://client.example.com/cb"},
+			ResourceServers: []string{"https://api1.example.com"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		scopes := make(map[string]bool)
+		for _, scope := range tc.scopes {
+			scopes[scope] = true
+		}
+		auth := identity.NewAuthRecord(provider.identityManager, "sub", scopes, nil, nil)
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		accessTokenString, err := provider.makeAccessToken(ctx, "client", auth, jwt.SigningMethodRS256)
+		if err != nil {
+			t.Fatalf("unexpected error for additional audiences %v: %v", tc.additional, err)
+		}
+
+		claims := &konnect.AccessTokenClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(accessTokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(claims.Audiences, tc.audiences) {
+			t.Errorf("unexpected audiences for additional audiences %v: got %v want %v", tc.additional, claims.Audiences, tc.audiences)
+		}
+		if claims.AuthorizedParty != tc.authorizedParty {
+			t.Errorf("unexpected azp for additional audiences %v: got %q want %q", tc.additional, claims.AuthorizedParty, tc.authorizedParty)
+		}
+	}
+}
+
+func TestMakeTokensStaticClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+	provider.staticClaims = map[string]interface{}{
+		"tenant": "tenant-1",
+		"realm":  "example",
+	}
+
+	scopes := map[string]bool{oidc.ScopeOpenID: true}
+	ar := &payload.AuthenticationRequest{
+		ClientID: "unittest",
+		Scopes:   scopes,
+	}
+	authenticated, err := provider.identityManager.Authenticate(ctx, nil, nil, ar, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := identity.NewAuthRecord(provider.identityManager, authenticated.Subject(), scopes, nil, nil)
+	auth.SetUser(authenticated.User())
+
+	// NOTE: The test key is too small for PSS with salt length of hash size.
+	accessTokenString, err := provider.makeAccessToken(ctx, "unittest", auth, jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idTokenString, err := provider.makeIDToken(ctx, ar, auth, nil, accessTokenString, "", jwt.SigningMethodRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tokenString := range map[string]string{"access token": accessTokenString, "id token": idTokenString} {
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+		if claims["tenant"] != "tenant-1" || claims["realm"] != "example" {
+			t.Errorf("static claims missing in %s: %v", name, claims)
+		}
+		if claims[oidc.AudienceClaim] != "unittest" {
+			t.Errorf("unexpected aud in %s: %v", name, claims[oidc.AudienceClaim])
+		}
+	}
+}
+
+type identityManagerWithClaimScopes struct {
+	identity.Manager
+	claimScopes map[string]string
+}
+
+func (im *identityManagerWithClaimScopes) ClaimScopes() map[string]string {
+	return im.claimScopes
+}
+
+type userWithExtraClaims struct {
+	sub    string
+	claims jwt.MapClaims
+}
+
+func (u *userWithExtraClaims) Subject() string {
+	return u.sub
+}
+
+func (u *userWithExtraClaims) Raw() string {
+	return u.sub
+}
+
+func (u *userWithExtraClaims) Claims() jwt.MapClaims {
+	return u.claims
+}
+
+func TestMakeAccessTokenClaimScopes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+	provider.identityManager = &identityManagerWithClaimScopes{provider.identityManager, map[string]string{
+		"app_roles": konnectoidc.ScopeRoles,
+	}}
+
+	user := &userWithExtraClaims{
+		sub: "user1",
+		claims: jwt.MapClaims{
+			konnect.InternalExtraAccessTokenClaimsClaim: map[string]interface{}{
+				"app_roles": []string{"admin"},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		scopes   map[string]bool
+		included bool
+	}{
+		{map[string]bool{oidc.ScopeOpenID: true}, false},
+		{map[string]bool{oidc.ScopeOpenID: true, konnectoidc.ScopeRoles: true}, true},
+	} {
+		auth := identity.NewAuthRecord(provider.identityManager, user.Subject(), tc.scopes, nil, nil)
+		auth.SetUser(user)
+
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		accessTokenString, err := provider.makeAccessToken(ctx, "unittest", auth, jwt.SigningMethodRS256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(accessTokenString, claims); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := claims["app_roles"]; ok != tc.included {
+			t.Errorf("app_roles claim included %v with scopes %v, want %v", ok, tc.scopes, tc.included)
+		}
+	}
+}
+
+func TestValidateIDTokenHintIssuer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpServer, provider, _, _ := NewTestProvider(ctx, t)
+	defer httpServer.Close()
+
+	for _, tc := range []struct {
+		iss   string
+		valid bool
+	}{
+		{provider.issuerIdentifier, true},
+		{"https://other-tenant.example.com", false},
+	} {
+		// NOTE: The test key is too small for PSS with salt length of hash size.
+		hint, err := provider.makeJWT(ctx, jwt.SigningMethodRS256, &konnectoidc.IDTokenClaims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:  tc.iss,
+				Subject: "user1",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		esr := &payload.EndSessionRequest{
+			RawIDTokenHint: hint,
+		}
+		err = esr.Validate(provider.validateIDTokenHint)
+		if tc.valid && err != nil {
+			t.Errorf("expected id_token_hint of %s to be valid, got %v", tc.iss, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected id_token_hint of %s to be rejected", tc.iss)
+		}
+	}
+}
@@ -18,10 +18,18 @@
 package provider
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
+	"sort"
+	"strings"
 	"time"
+
+	"gopkg.in/square/go-jose.v2"
 )
 
 var (
@@ -66,6 +74,66 @@ func getRequestURL(req *http.Request, isTrustedSource bool) *url.URL {
 	return u
 }
 
+// getForwardedPrefix returns the path prefix as set by a trusted proxy with
+// the X-Forwarded-Prefix header or an empty string if there is none.
+func getForwardedPrefix(req *http.Request, isTrustedSource bool) string {
+	if !isTrustedSource {
+		return ""
+	}
+	prefix := req.Header.Get("X-Forwarded-Prefix")
+	if prefix == "" || !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, "?#") {
+		return ""
+	}
+	prefix = path.Clean(prefix)
+	if prefix == "/" {
+		return ""
+	}
+
+	return prefix
+}
+
+// withPathPrefix returns the provided URL string with the provided path
+// prefix added to its path.
+func withPathPrefix(uriString string, prefix string) string {
+	if uriString == "" || prefix == "" {
+		return uriString
+	}
+	u, err := url.Parse(uriString)
+	if err != nil {
+		return uriString
+	}
+	u.Path = prefix + u.Path
+
+	return u.String()
+}
+
+// makeJWKSETag returns a strong ETag value derived from the provided key set.
+func makeJWKSETag(jwks *jose.JSONWebKeySet) (string, error) {
+	b, err := json.Marshal(jwks)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`, nil
+}
+
+// matchETag returns true if the provided If-None-Match header value matches
+// the provided ETag, using the weak comparison of RFC 7232 Section 3.2.
+func matchETag(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
 func addResponseHeaders(header http.Header) {
 	header.Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	header.Set("Pragma", "no-cache")
@@ -83,3 +151,8 @@ func makeArrayFromBoolMap(m map[string]bool) []string {
 
 	return result
 }
+
+func sortedStrings(s []string) []string {
+	sort.Strings(s)
+	return s
+}
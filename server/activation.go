@@ -0,0 +1,67 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation, see sd_listen_fds(3).
+const listenFdsStart = 3
+
+// activationListener returns the listener passed by the service manager with
+// socket activation, or nil when not socket activated. It is a variable so it
+// can be replaced in tests.
+var activationListener = systemdActivationListener
+
+// systemdActivationListener returns the listener for the socket passed by
+// systemd socket activation via the LISTEN_PID and LISTEN_FDS environment
+// variables. It returns nil if the process was not socket activated.
+func systemdActivationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds == 0 {
+		return nil, nil
+	}
+
+	// Unset environment, so child processes do not pick up the sockets.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if fds != 1 {
+		return nil, fmt.Errorf("socket activation with %d sockets is not supported, expected 1", fds)
+	}
+
+	f := os.NewFile(uintptr(listenFdsStart), "LISTEN_FD_3")
+	defer f.Close()
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use socket activation listener: %w", err)
+	}
+
+	return listener, nil
+}
@@ -32,6 +32,24 @@ type Config struct {
 
 	Handler http.Handler
 	Routes  []WithRoutes
+
+	// Backend is the name of the active backend, reported by the health
+	// check.
+	Backend string
+
+	// Tenants, when set, are selected by request host instead of the
+	// Handler and Routes above.
+	Tenants []*Tenant
+}
+
+// Tenant defines a handler with routes which serves requests for a host.
+type Tenant struct {
+	Host    string
+	Iss     string
+	Backend string
+
+	Handler http.Handler
+	Routes  []WithRoutes
 }
 
 // WithRoutes provide http routing within a context.
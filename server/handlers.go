@@ -19,9 +19,35 @@ package server
 
 import (
 	"net/http"
+
+	"github.com/libregraph/lico/utils"
 )
 
+// healthCheckResponse is the payload returned by the health check handler.
+type healthCheckResponse struct {
+	Status string `json:"status"`
+
+	Backend string            `json:"backend,omitempty"`
+	Tenants map[string]string `json:"tenants,omitempty"`
+}
+
 // HealthCheckHandler a http handler return 200 OK when server health is fine.
+// The response includes the name of the active backend, or the active backend
+// of each tenant, so operators can confirm which backend is live.
 func (s *Server) HealthCheckHandler(rw http.ResponseWriter, req *http.Request) {
-	rw.WriteHeader(http.StatusOK)
+	response := &healthCheckResponse{
+		Status:  "ok",
+		Backend: s.Config.Backend,
+	}
+	if len(s.Config.Tenants) > 0 {
+		response.Tenants = make(map[string]string)
+		for _, tenant := range s.Config.Tenants {
+			response.Tenants[tenant.Host] = tenant.Backend
+		}
+	}
+
+	err := utils.WriteJSON(rw, http.StatusOK, response, "")
+	if err != nil {
+		s.logger.WithError(err).Errorln("health check failed writing response")
+	}
 }
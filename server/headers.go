@@ -0,0 +1,61 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/libregraph/lico/config"
+)
+
+// makeSecurityHeaders returns the hardening headers for the provided config.
+// Headers with an empty configured value are not included.
+func makeSecurityHeaders(c *config.Config) http.Header {
+	header := make(http.Header)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if c.HSTSMaxAge > 0 {
+		header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", int64(c.HSTSMaxAge.Seconds())))
+	}
+	if c.FrameOptions != "" {
+		header.Set("X-Frame-Options", c.FrameOptions)
+	}
+	if c.ContentSecurityPolicy != "" {
+		header.Set("Content-Security-Policy", c.ContentSecurityPolicy)
+	}
+	if c.ReferrerPolicy != "" {
+		header.Set("Referrer-Policy", c.ReferrerPolicy)
+	}
+
+	return header
+}
+
+// AddSecurityHeaders adds the accociated servers security headers to all
+// responses of the provided http.Handler. The headers are set before the
+// handler runs, so handlers can replace or remove them, for example to allow
+// embedding of pages which are meant to be loaded in an iframe.
+func (s *Server) AddSecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		header := rw.Header()
+		for key, values := range s.securityHeaders {
+			header[key] = values
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
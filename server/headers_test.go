@@ -0,0 +1,123 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{
+		Logger: logger,
+
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		FrameOptions:          "DENY",
+		ContentSecurityPolicy: "frame-ancestors 'none'",
+		ReferrerPolicy:        "origin",
+	}
+
+	server, err := NewServer(&Config{
+		Config: cfg,
+
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/embeddable" {
+				rw.Header().Del("X-Frame-Options")
+				rw.Header().Set("Content-Security-Policy", "default-src 'none'")
+			}
+			rw.WriteHeader(http.StatusOK)
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.makeHandler(ctx)
+
+	req := httptest.NewRequest(http.MethodGet, "/sample", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	for key, expected := range map[string]string{
+		"Strict-Transport-Security": "max-age=31536000",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Content-Security-Policy":   "frame-ancestors 'none'",
+		"Referrer-Policy":           "origin",
+	} {
+		if value := rr.Header().Get(key); value != expected {
+			t.Errorf("%s header was incorrect, got %q, want %q", key, value, expected)
+		}
+	}
+
+	// Health check is served by the server itself and gets the headers too.
+	req = httptest.NewRequest(http.MethodGet, "/health-check", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("expected security headers on health check response")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/embeddable", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if value := rr.Header().Get("X-Frame-Options"); value != "" {
+		t.Errorf("expected handler to remove X-Frame-Options, got %q", value)
+	}
+	if value := rr.Header().Get("Content-Security-Policy"); value != "default-src 'none'" {
+		t.Errorf("expected handler Content-Security-Policy, got %q", value)
+	}
+}
+
+func TestSecurityHeadersDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(&Config{
+		Config: &config.Config{
+			Logger: logger,
+		},
+
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sample", nil)
+	rr := httptest.NewRecorder()
+	server.makeHandler(ctx).ServeHTTP(rr, req)
+
+	for _, key := range []string{"Strict-Transport-Security", "X-Frame-Options", "Content-Security-Policy", "Referrer-Policy"} {
+		if value := rr.Header().Get(key); value != "" {
+			t.Errorf("expected no %s header, got %q", key, value)
+		}
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("X-Content-Type-Options must always be set")
+	}
+}
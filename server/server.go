@@ -30,6 +30,8 @@ import (
 	"github.com/longsleep/go-metrics/loggedwriter"
 	"github.com/longsleep/go-metrics/timing"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Server is our HTTP server implementation.
@@ -37,8 +39,11 @@ type Server struct {
 	Config *Config
 
 	listenAddr string
+	enableH2C  bool
 	logger     logrus.FieldLogger
 
+	securityHeaders http.Header
+
 	requestLog bool
 }
 
@@ -48,8 +53,11 @@ func NewServer(c *Config) (*Server, error) {
 		Config: c,
 
 		listenAddr: c.Config.ListenAddr,
+		enableH2C:  c.Config.EnableH2C,
 		logger:     c.Config.Logger,
 
+		securityHeaders: makeSecurityHeaders(c.Config),
+
 		requestLog: os.Getenv("KOPANO_DEBUG_SERVER_REQUEST_LOG") == "1",
 	}
 
@@ -99,6 +107,23 @@ func (s *Server) AddRoutes(ctx context.Context, router *mux.Router) {
 	// TODO(longsleep): Add subpath support to all handlers and paths.
 	router.HandleFunc("/health-check", s.HealthCheckHandler)
 
+	if len(s.Config.Tenants) > 0 {
+		for _, tenant := range s.Config.Tenants {
+			tenantRouter := router.Host(tenant.Host).Subrouter()
+			tenantRouter.Use(s.routeByIDTokenHint(tenant))
+			for _, route := range tenant.Routes {
+				route.AddRoutes(ctx, tenantRouter)
+			}
+			if tenant.Handler != nil {
+				// Delegate rest to tenant provider which is also a handler.
+				tenantRouter.PathPrefix("/").Handler(tenant.Handler)
+			}
+		}
+		// Unknown hosts are not found.
+		router.NotFoundHandler = http.NotFoundHandler()
+		return
+	}
+
 	for _, route := range s.Config.Routes {
 		route.AddRoutes(ctx, router)
 	}
@@ -108,6 +133,21 @@ func (s *Server) AddRoutes(ctx context.Context, router *mux.Router) {
 	}
 }
 
+func (s *Server) makeHandler(ctx context.Context) http.Handler {
+	router := mux.NewRouter()
+	s.AddRoutes(ctx, router)
+
+	handler := s.AddContext(ctx, router)
+	handler = s.AddSecurityHeaders(handler)
+	if s.enableH2C {
+		// Allow HTTP/2 without TLS, for example behind TLS terminating proxies.
+		// HTTP/1.1 requests are passed through unchanged.
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	return handler
+}
+
 // Serve starts all the accociated servers resources and listeners and blocks
 // forever until signals or error occurs. Returns error and gracefully stops
 // all HTTP listeners before return.
@@ -119,21 +159,32 @@ func (s *Server) Serve(ctx context.Context) error {
 
 	errCh := make(chan error, 2)
 	exitCh := make(chan bool, 1)
-	signalCh := make(chan os.Signal)
-
-	router := mux.NewRouter()
-	s.AddRoutes(serveCtx, router)
+	signalCh := make(chan os.Signal, 1)
 
 	// HTTP listener.
 	srv := &http.Server{
-		Handler: s.AddContext(serveCtx, router),
+		Handler: s.makeHandler(serveCtx),
 	}
 
-	logger.WithField("listenAddr", s.listenAddr).Infoln("starting http listener")
-	listener, err := net.Listen("tcp", s.listenAddr)
+	listener, err := activationListener()
 	if err != nil {
 		return err
 	}
+	if listener != nil {
+		logger.WithFields(logrus.Fields{
+			"listenAddr": listener.Addr().String(),
+			"h2c":        s.enableH2C,
+		}).Infoln("starting http listener with socket activation")
+	} else {
+		logger.WithFields(logrus.Fields{
+			"listenAddr": s.listenAddr,
+			"h2c":        s.enableH2C,
+		}).Infoln("starting http listener")
+		listener, err = net.Listen("tcp", s.listenAddr)
+		if err != nil {
+			return err
+		}
+	}
 	logger.Infoln("ready to handle requests")
 
 	go func() {
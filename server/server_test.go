@@ -19,13 +19,22 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/mux"
+	"github.com/libregraph/oidc-go"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
 
 	"github.com/libregraph/lico/config"
 	"github.com/libregraph/lico/identity"
@@ -33,6 +42,7 @@ import (
 	identityManagers "github.com/libregraph/lico/identity/managers"
 	"github.com/libregraph/lico/managers"
 	codeManagers "github.com/libregraph/lico/oidc/code/managers"
+	"github.com/libregraph/lico/oidc/payload"
 	"github.com/libregraph/lico/oidc/provider"
 )
 
@@ -42,25 +52,21 @@ var logger = &logrus.Logger{
 	Level:     logrus.DebugLevel,
 }
 
-func newTestServer(ctx context.Context, t *testing.T) (*httptest.Server, *Server, http.Handler, *config.Config) {
+func newTestProvider(ctx context.Context, t *testing.T, cfg *config.Config, iss string) *provider.Provider {
 	mgrs := managers.New()
 	mgrs.Set("identity", identityManagers.NewDummyIdentityManager(
 		&identity.Config{},
 		"unittestuser",
 	))
-	mgrs.Set("code", codeManagers.NewMemoryMapManager(ctx))
+	mgrs.Set("code", codeManagers.NewMemoryMapManager(ctx, 0))
 	encryptionManager, _ := identityManagers.NewEncryptionManager(nil)
 	mgrs.Set("encryption", encryptionManager)
 	mgrs.Set("clients", &clients.Registry{})
 
-	cfg := &config.Config{
-		Logger: logger,
-	}
-
 	p, err := provider.NewProvider(&provider.Config{
 		Config: cfg,
 
-		IssuerIdentifier:  "http://localhost:8777",
+		IssuerIdentifier:  iss,
 		WellKnownPath:     "/.well-known/openid-configuration",
 		JwksPath:          "/konnect/v1/jwks.json",
 		AuthorizationPath: "/konnect/v1/authorize",
@@ -75,6 +81,16 @@ func newTestServer(ctx context.Context, t *testing.T) (*httptest.Server, *Server
 		t.Fatal(err)
 	}
 
+	return p
+}
+
+func newTestServer(ctx context.Context, t *testing.T) (*httptest.Server, *Server, http.Handler, *config.Config) {
+	cfg := &config.Config{
+		Logger: logger,
+	}
+
+	p := newTestProvider(ctx, t, cfg, "http://localhost:8777")
+
 	server, err := NewServer(&Config{
 		Config: cfg,
 
@@ -98,3 +114,286 @@ func TestNewTestServer(t *testing.T) {
 	defer cancel()
 	newTestServer(ctx, t)
 }
+
+func TestTenantsByHost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{
+		Logger: logger,
+	}
+
+	// All tenants share the same signing key, so that only the issuer keeps
+	// their tokens apart.
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants := make([]*Tenant, 0)
+	accessTokens := make(map[string]string)
+	for _, iss := range []string{"https://tenant1.example.net", "https://tenant2.example.net"} {
+		p := newTestProvider(ctx, t, cfg, iss)
+		if err = p.SetSigningMethod(jwt.SigningMethodRS256); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetSigningKey("default", signingKey); err != nil {
+			t.Fatal(err)
+		}
+		err = p.InitializeMetadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		host := strings.TrimPrefix(iss, "https://")
+		tenants = append(tenants, &Tenant{
+			Host:    host,
+			Handler: p,
+		})
+
+		auth, err := identityManagers.NewDummyIdentityManager(&identity.Config{}, "unittestuser").Authenticate(ctx, nil, nil, &payload.AuthenticationRequest{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth.AuthorizeScopes(map[string]bool{oidc.ScopeOpenID: true})
+		accessToken, err := p.MakeAccessToken(ctx, "client", auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+			t.Fatal(err)
+		}
+		if claims["iss"] != iss {
+			t.Errorf("access token issuer was incorrect for host %s, got %v", host, claims["iss"])
+		}
+		accessTokens[host] = accessToken
+	}
+
+	server, err := NewServer(&Config{
+		Config: cfg,
+
+		Tenants: tenants,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	server.AddRoutes(ctx, router)
+
+	// Tokens of one tenant are not accepted by another.
+	for host, other := range map[string]string{"tenant1.example.net": "tenant2.example.net", "tenant2.example.net": "tenant1.example.net"} {
+		req := httptest.NewRequest("GET", "https://"+other+"/konnect/v1/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessTokens[host])
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("userinfo of %s returned wrong status code for token of %s: got %v want %v", other, host, status, http.StatusUnauthorized)
+		}
+
+		req = httptest.NewRequest("GET", "https://"+host+"/konnect/v1/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+accessTokens[host])
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("userinfo of %s returned wrong status code for own token: got %v want %v", host, status, http.StatusOK)
+		}
+	}
+
+	for _, host := range []string{"tenant1.example.net", "tenant2.example.net"} {
+		req := httptest.NewRequest("GET", "https://"+host+"/.well-known/openid-configuration", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code for host %s: got %v want %v", host, status, http.StatusOK)
+		}
+
+		wellKnown := &oidc.WellKnown{}
+		if err := json.Unmarshal(rr.Body.Bytes(), wellKnown); err != nil {
+			t.Fatal(err)
+		}
+		if wellKnown.Issuer != "https://"+host {
+			t.Errorf("Issuer identifier was incorrect for host %s, got %s", host, wellKnown.Issuer)
+		}
+		if wellKnown.TokenEndpoint != "https://"+host+"/konnect/v1/token" {
+			t.Errorf("TokenEndpoint was incorrect for host %s, got %s", host, wellKnown.TokenEndpoint)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "https://unknown.example.net/.well-known/openid-configuration", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code for unknown host: got %v want %v", status, http.StatusNotFound)
+	}
+
+	req = httptest.NewRequest("GET", "https://unknown.example.net/health-check", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("health-check returned wrong status code for unknown host: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestHealthCheckBackend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{
+		Logger: logger,
+	}
+
+	p := newTestProvider(ctx, t, cfg, "http://localhost:8777")
+
+	server, err := NewServer(&Config{
+		Config: cfg,
+
+		Handler: p,
+		Backend: "ldap",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	server.AddRoutes(ctx, router)
+
+	req := httptest.NewRequest("GET", "http://localhost:8777/health-check", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("health-check returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	response := make(map[string]interface{})
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["backend"] != "ldap" {
+		t.Errorf("health-check response has wrong backend: %v", response)
+	}
+	if response["status"] != "ok" {
+		t.Errorf("health-check response has wrong status: %v", response)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := &config.Config{
+		Logger:    logger,
+		EnableH2C: true,
+	}
+
+	p := newTestProvider(ctx, t, cfg, "http://localhost:8777")
+	err := p.InitializeMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewServer(&Config{
+		Config: cfg,
+
+		Handler: p,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(server.makeHandler(ctx))
+	defer s.Close()
+
+	h2cClient := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	for _, test := range []struct {
+		client *http.Client
+		proto  string
+	}{
+		{h2cClient, "HTTP/2.0"},
+		{http.DefaultClient, "HTTP/1.1"},
+	} {
+		resp, err := test.client.Get(s.URL + "/.well-known/openid-configuration")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+		}
+		if resp.Proto != test.proto {
+			t.Errorf("unexpected protocol: got %v want %v", resp.Proto, test.proto)
+		}
+	}
+}
+
+func TestServeWithSocketActivation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	activationListener = func() (net.Listener, error) {
+		return listener, nil
+	}
+	defer func() {
+		activationListener = systemdActivationListener
+	}()
+
+	cfg := &config.Config{
+		Logger: logger,
+		// Invalid, to ensure that the activation listener is used.
+		ListenAddr: "invalid:listen:addr",
+	}
+	server, err := NewServer(&Config{
+		Config: cfg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serveErrCh := make(chan error, 1)
+	go func() {
+		serveErrCh <- server.Serve(ctx)
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/health-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("health-check returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	// Closing the listener stops the server.
+	listener.Close()
+	if err := <-serveErrCh; err == nil {
+		t.Errorf("expected serve to return error after listener was closed")
+	}
+}
+
+func TestSystemdActivationListenerNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listener, err := systemdActivationListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener != nil {
+		t.Errorf("expected no listener for other LISTEN_PID")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+
+	"github.com/libregraph/lico/utils"
+)
+
+// routeByIDTokenHint returns a middleware which sends requests with an
+// id_token_hint to the tenant which issued the hint. Requests with a hint of
+// the provided tenant itself are passed on, the tenant validates the hint
+// with its own keys. Requests with a hint of another tenant are redirected to
+// the same endpoint of that tenant, so its cookies are available. Hints with
+// an issuer which is not a configured tenant are rejected.
+func (s *Server) routeByIDTokenHint(tenant *Tenant) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if tenant.Iss == "" {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			// Only the query is looked at, so request bodies stay untouched
+			// for the tenant. Tenants reject form posted hints of other
+			// issuers themselves.
+			rawIDTokenHint := req.URL.Query().Get("id_token_hint")
+			if rawIDTokenHint == "" {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			// The hint is not verified here, its issuer only selects the
+			// tenant which then validates it.
+			claims := &jwt.RegisteredClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(rawIDTokenHint, claims); err != nil || claims.Issuer == tenant.Iss {
+				next.ServeHTTP(rw, req)
+				return
+			}
+
+			var issuer *Tenant
+			for _, candidate := range s.Config.Tenants {
+				if candidate.Iss != "" && candidate.Iss == claims.Issuer {
+					issuer = candidate
+					break
+				}
+			}
+			if issuer == nil {
+				s.logger.WithField("iss", claims.Issuer).Debugln("rejected id_token_hint of unknown tenant")
+				utils.WriteErrorPage(rw, http.StatusBadRequest, "", "id_token_hint was not issued by a known issuer")
+				return
+			}
+
+			target, err := url.Parse(issuer.Iss)
+			if err != nil {
+				utils.WriteErrorPage(rw, http.StatusInternalServerError, "", err.Error())
+				return
+			}
+			target.Path = req.URL.Path
+			target.RawPath = req.URL.RawPath
+			target.RawQuery = req.URL.RawQuery
+
+			http.Redirect(rw, req, target.String(), http.StatusFound)
+		})
+	}
+}
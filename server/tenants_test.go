@@ -0,0 +1,116 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+
+	"github.com/libregraph/lico/config"
+)
+
+func TestTenantsRouteByIDTokenHint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	served := make(map[string]int)
+	tenants := make([]*Tenant, 0)
+	for _, host := range []string{"tenant1.example.net", "tenant2.example.net"} {
+		host := host
+		tenants = append(tenants, &Tenant{
+			Host: host,
+			Iss:  "https://" + host,
+			Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				served[host]++
+				rw.WriteHeader(http.StatusOK)
+			}),
+		})
+	}
+
+	server, err := NewServer(&Config{
+		Config: &config.Config{
+			Logger: logger,
+		},
+
+		Tenants: tenants,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	server.AddRoutes(ctx, router)
+
+	makeHint := func(iss string) string {
+		hint, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Issuer:  iss,
+			Subject: "user1",
+		}).SignedString([]byte("not-validated-by-the-server"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hint
+	}
+	request := func(hint string) *httptest.ResponseRecorder {
+		query := url.Values{}
+		query.Set("id_token_hint", hint)
+		query.Set("state", "some-state")
+		req := httptest.NewRequest(http.MethodGet, "https://tenant1.example.net/konnect/v1/endsession?"+query.Encode(), nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Hint of the tenant itself.
+	if rr := request(makeHint("https://tenant1.example.net")); rr.Code != http.StatusOK || served["tenant1.example.net"] != 1 {
+		t.Errorf("expected own hint to be served by tenant1, got status %d", rr.Code)
+	}
+
+	// Hint of another tenant.
+	hint := makeHint("https://tenant2.example.net")
+	rr := request(hint)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected redirect for hint of tenant2, got status %d", rr.Code)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Host != "tenant2.example.net" || location.Path != "/konnect/v1/endsession" {
+		t.Errorf("unexpected redirect location: %v", location)
+	}
+	if location.Query().Get("id_token_hint") != hint || location.Query().Get("state") != "some-state" {
+		t.Errorf("redirect did not keep the query: %v", location)
+	}
+	if served["tenant1.example.net"] != 1 || served["tenant2.example.net"] != 0 {
+		t.Errorf("redirected request must not be served: %v", served)
+	}
+
+	// Hint of an unknown issuer.
+	if rr := request(makeHint("https://unknown.example.net")); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected hint of unknown issuer to be rejected, got status %d", rr.Code)
+	}
+	if served["tenant1.example.net"] != 1 {
+		t.Errorf("rejected request must not be served: %v", served)
+	}
+}
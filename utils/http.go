@@ -19,10 +19,14 @@ package utils
 
 import (
 	"crypto/tls"
+	"errors"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
+	"golang.org/x/net/http/httpproxy"
 	"golang.org/x/net/http2"
 
 	"github.com/libregraph/lico/version"
@@ -67,6 +71,25 @@ func HTTPTransportWithTLSClientConfig(tlsClientConfig *tls.Config) *http.Transpo
 	return transport
 }
 
+// HTTPProxyFunc returns a proxy function suitable for http.Transport. If
+// proxyURL is nil, the proxy is selected from the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables. Otherwise proxyURL is used for both http
+// and https requests, while NO_PROXY is still honored.
+func HTTPProxyFunc(proxyURL *url.URL) func(*http.Request) (*url.URL, error) {
+	if proxyURL == nil {
+		return http.ProxyFromEnvironment
+	}
+
+	config := httpproxy.FromEnvironment()
+	config.HTTPProxy = proxyURL.String()
+	config.HTTPSProxy = proxyURL.String()
+	proxyFunc := config.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
 // DefaultTLSConfig returns a new tls.Config.
 func DefaultTLSConfig() *tls.Config {
 	return &tls.Config{
@@ -94,3 +117,109 @@ var InsecureHTTPClient = &http.Client{
 	Timeout:   defaultHTTPTimeout,
 	Transport: HTTPTransportWithTLSClientConfig(InsecureSkipVerifyTLSConfig()),
 }
+
+// HTTPClientWithLimits returns a copy of the provided http.Client which uses
+// the provided timeout and limits response bodies to maxSize bytes. Reading
+// more than maxSize bytes returns ErrResponseBodyTooLarge. Zero values keep
+// the timeout, respectively do not limit the response size.
+func HTTPClientWithLimits(client *http.Client, timeout time.Duration, maxSize int64) *http.Client {
+	limited := *client
+	if timeout > 0 {
+		limited.Timeout = timeout
+	}
+	if maxSize > 0 {
+		transport := limited.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		limited.Transport = &limitedResponseTransport{
+			RoundTripper: transport,
+
+			limit: maxSize,
+		}
+	}
+
+	return &limited
+}
+
+// ErrResponseBodyTooLarge is returned when reading a response body of a
+// http.Client created with HTTPClientWithLimits which exceeds its limit.
+var ErrResponseBodyTooLarge = errors.New("response body too large")
+
+type limitedResponseTransport struct {
+	http.RoundTripper
+
+	limit int64
+}
+
+func (t *limitedResponseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.ContentLength > t.limit {
+		res.Body.Close()
+		return nil, ErrResponseBodyTooLarge
+	}
+	res.Body = &limitedResponseBody{
+		ReadCloser: res.Body,
+		remaining:  t.limit,
+	}
+
+	return res, nil
+}
+
+type limitedResponseBody struct {
+	io.ReadCloser
+
+	remaining int64
+}
+
+func (b *limitedResponseBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Check if there is more data than allowed.
+		var probe [1]byte
+		if n, _ := b.ReadCloser.Read(probe[:]); n > 0 {
+			return 0, ErrResponseBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+// ErrRequestBodyTooLarge is returned when reading a request body which was
+// limited with LimitRequestBody and exceeds its limit.
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+type limitedRequestBody struct {
+	io.ReadCloser
+
+	limit int64
+	read  int64
+}
+
+func (b *limitedRequestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		return n, ErrRequestBodyTooLarge
+	}
+
+	return n, err
+}
+
+// LimitRequestBody replaces the body of the provided request with a reader
+// which returns ErrRequestBodyTooLarge once more than limit bytes are read.
+func LimitRequestBody(rw http.ResponseWriter, req *http.Request, limit int64) {
+	req.Body = &limitedRequestBody{
+		ReadCloser: http.MaxBytesReader(rw, req.Body, limit),
+
+		limit: limit,
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultJWTLeeway is the leeway applied to the exp, nbf and iat checks when
+// validating JWTs, unless set differently with SetJWTLeeway.
+const DefaultJWTLeeway = 60 * time.Second
+
+var (
+	jwtLeeway     = DefaultJWTLeeway
+	jwtLeewayOnce sync.Once
+)
+
+// SetJWTLeeway sets the leeway applied to the exp, nbf and iat checks when
+// validating JWTs, to tolerate small clock skew between systems. It is meant
+// to be called once during start up, further calls have no effect.
+func SetJWTLeeway(leeway time.Duration) {
+	jwtLeewayOnce.Do(func() {
+		jwtLeeway = leeway
+	})
+}
+
+// JWTLeeway returns the leeway applied when validating JWTs.
+func JWTLeeway() time.Duration {
+	return jwtLeeway
+}
+
+// TimeClaims are claims with time based checks, like jwt.StandardClaims and
+// jwt.MapClaims.
+type TimeClaims interface {
+	VerifyExpiresAt(cmp int64, req bool) bool
+	VerifyIssuedAt(cmp int64, req bool) bool
+	VerifyNotBefore(cmp int64, req bool) bool
+}
+
+// ValidateTimeClaims validates the time based claims of the provided claims
+// like their Valid function would, but applying JWTLeeway.
+func ValidateTimeClaims(c TimeClaims) error {
+	return validateTimeClaims(c, jwtLeeway)
+}
+
+func validateTimeClaims(c TimeClaims, leeway time.Duration) error {
+	now := jwt.TimeFunc().Unix()
+	leewaySeconds := int64(leeway / time.Second)
+
+	vErr := new(jwt.ValidationError)
+	if !c.VerifyExpiresAt(now-leewaySeconds, false) {
+		vErr.Inner = fmt.Errorf("token is expired")
+		vErr.Errors |= jwt.ValidationErrorExpired
+	}
+	if !c.VerifyIssuedAt(now+leewaySeconds, false) {
+		vErr.Inner = fmt.Errorf("token used before issued")
+		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+	if !c.VerifyNotBefore(now+leewaySeconds, false) {
+		vErr.Inner = fmt.Errorf("token is not valid yet")
+		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+	if vErr.Errors != 0 {
+		return vErr
+	}
+
+	return nil
+}
+
+// ValidateRegisteredClaims validates the provided jwt.RegisteredClaims like
+// their Valid function would, but applying JWTLeeway.
+func ValidateRegisteredClaims(c *jwt.RegisteredClaims) error {
+	return validateRegisteredClaims(c, jwtLeeway)
+}
+
+func validateRegisteredClaims(c *jwt.RegisteredClaims, leeway time.Duration) error {
+	now := jwt.TimeFunc()
+
+	vErr := new(jwt.ValidationError)
+	if !c.VerifyExpiresAt(now.Add(-leeway), false) {
+		vErr.Inner = fmt.Errorf("token is expired")
+		vErr.Errors |= jwt.ValidationErrorExpired
+	}
+	if !c.VerifyIssuedAt(now.Add(leeway), false) {
+		vErr.Inner = fmt.Errorf("token used before issued")
+		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+	if !c.VerifyNotBefore(now.Add(leeway), false) {
+		vErr.Inner = fmt.Errorf("token is not valid yet")
+		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+	if vErr.Errors != 0 {
+		return vErr
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestValidateTimeClaimsLeeway(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name   string
+		claims jwt.StandardClaims
+		valid  bool
+	}{
+		{"iat within leeway", jwt.StandardClaims{IssuedAt: now.Add(30 * time.Second).Unix()}, true},
+		{"iat beyond leeway", jwt.StandardClaims{IssuedAt: now.Add(2 * time.Minute).Unix()}, false},
+		{"nbf within leeway", jwt.StandardClaims{NotBefore: now.Add(30 * time.Second).Unix()}, true},
+		{"nbf beyond leeway", jwt.StandardClaims{NotBefore: now.Add(2 * time.Minute).Unix()}, false},
+		{"exp within leeway", jwt.StandardClaims{ExpiresAt: now.Add(-30 * time.Second).Unix()}, true},
+		{"exp beyond leeway", jwt.StandardClaims{ExpiresAt: now.Add(-2 * time.Minute).Unix()}, false},
+	} {
+		err := ValidateTimeClaims(&tc.claims)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+
+		mapClaims := jwt.MapClaims{}
+		if tc.claims.IssuedAt != 0 {
+			mapClaims["iat"] = float64(tc.claims.IssuedAt)
+		}
+		if tc.claims.NotBefore != 0 {
+			mapClaims["nbf"] = float64(tc.claims.NotBefore)
+		}
+		if tc.claims.ExpiresAt != 0 {
+			mapClaims["exp"] = float64(tc.claims.ExpiresAt)
+		}
+		if err = ValidateTimeClaims(mapClaims); (err == nil) != tc.valid {
+			t.Errorf("%s: map claims validation mismatch: %v", tc.name, err)
+		}
+
+		registeredClaims := &jwt.RegisteredClaims{}
+		if tc.claims.IssuedAt != 0 {
+			registeredClaims.IssuedAt = jwt.NewNumericDate(time.Unix(tc.claims.IssuedAt, 0))
+		}
+		if tc.claims.NotBefore != 0 {
+			registeredClaims.NotBefore = jwt.NewNumericDate(time.Unix(tc.claims.NotBefore, 0))
+		}
+		if tc.claims.ExpiresAt != 0 {
+			registeredClaims.ExpiresAt = jwt.NewNumericDate(time.Unix(tc.claims.ExpiresAt, 0))
+		}
+		if err = ValidateRegisteredClaims(registeredClaims); (err == nil) != tc.valid {
+			t.Errorf("%s: registered claims validation mismatch: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateTimeClaimsWithoutLeeway(t *testing.T) {
+	issuedAt := time.Now().Add(30 * time.Second)
+
+	if err := validateTimeClaims(&jwt.StandardClaims{IssuedAt: issuedAt.Unix()}, 0); err == nil {
+		t.Errorf("expected error for iat in the future without leeway")
+	}
+	if err := validateRegisteredClaims(&jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(issuedAt)}, 0); err == nil {
+		t.Errorf("expected error for registered iat in the future without leeway")
+	}
+}
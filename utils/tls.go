@@ -0,0 +1,89 @@
+/*
+ * Copyright 2017-2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSVersionFromString returns the TLS version identifier for the provided
+// version string like "1.2". An empty string returns 0, which selects the
+// default of crypto/tls.
+func TLSVersionFromString(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version: %v", version)
+	}
+}
+
+// TLSCipherSuitesFromStrings returns the cipher suite identifiers for the
+// provided cipher suite names as returned by tls.CipherSuites. Insecure
+// cipher suites are not supported.
+func TLSCipherSuitesFromStrings(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite: %v", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// CertPoolWithPEMFile returns a copy of the system certificate pool with the
+// PEM encoded certificates of the provided file added.
+func CertPoolWithPEMFile(fn string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %v", fn)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestTLSVersionFromString(t *testing.T) {
+	for version, expected := range map[string]uint16{
+		"":    0,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	} {
+		v, err := TLSVersionFromString(version)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", version, err)
+		} else if v != expected {
+			t.Errorf("%q: expected %x, got %x", version, expected, v)
+		}
+	}
+	if _, err := TLSVersionFromString("1.4"); err == nil {
+		t.Errorf("expected error for unknown TLS version")
+	}
+}
+
+func TestTLSCipherSuitesFromStrings(t *testing.T) {
+	suites, err := TLSCipherSuitesFromStrings([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}; !reflect.DeepEqual(suites, expected) {
+		t.Errorf("unexpected cipher suites: %v", suites)
+	}
+
+	for _, name := range []string{"TLS_RSA_WITH_RC4_128_SHA", "unknown"} {
+		if _, err := TLSCipherSuitesFromStrings([]string{name}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -20,6 +20,7 @@ package utils
 import (
 	"net"
 	"net/http"
+	"strings"
 )
 
 // IsRequestFromTrustedSource checks if the provided requests remote address is
@@ -32,17 +33,51 @@ func IsRequestFromTrustedSource(req *http.Request, ips []*net.IP, nets []*net.IP
 
 	ip := net.ParseIP(ipString)
 
+	return isTrustedIP(ip, ips, nets), nil
+}
+
+func isTrustedIP(ip net.IP, ips []*net.IP, nets []*net.IPNet) bool {
 	for _, checkIP := range ips {
 		if checkIP.Equal(ip) {
-			return true, nil
+			return true
 		}
 	}
 
 	for _, checkNet := range nets {
 		if checkNet.Contains(ip) {
-			return true, nil
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoteAddrFromRequest returns the IP address of the client which sent the
+// provided request. The X-Forwarded-For header is only considered when the
+// request was received from one of the provided trusted ips or networks. In
+// that case the first address from the right which is not trusted is returned.
+func RemoteAddrFromRequest(req *http.Request, ips []*net.IP, nets []*net.IPNet) string {
+	remoteAddr, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteAddr = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil || !isTrustedIP(ip, ips, nets) {
+		return remoteAddr
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for idx := len(forwarded) - 1; idx >= 0; idx-- {
+		forwardedIP := net.ParseIP(strings.TrimSpace(forwarded[idx]))
+		if forwardedIP == nil {
+			break
+		}
+		remoteAddr = forwardedIP.String()
+		if !isTrustedIP(forwardedIP, ips, nets) {
+			break
 		}
 	}
 
-	return false, nil
+	return remoteAddr
 }
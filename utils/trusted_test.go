@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteAddrFromRequest(t *testing.T) {
+	_, trustedNet, _ := net.ParseCIDR("10.0.0.0/8")
+	nets := []*net.IPNet{trustedNet}
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		expected     string
+	}{
+		{"192.0.2.10:1234", "", "192.0.2.10"},
+		{"192.0.2.10:1234", "198.51.100.1", "192.0.2.10"},
+		{"10.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.1:1234", "203.0.113.5, 198.51.100.1, 10.0.0.2", "198.51.100.1"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "garbage", "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		if v := RemoteAddrFromRequest(req, nil, nets); v != test.expected {
+			t.Errorf("wrong remote addr for %s with %q: got %s want %s", test.remoteAddr, test.forwardedFor, v, test.expected)
+		}
+	}
+}